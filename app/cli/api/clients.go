@@ -1,6 +1,10 @@
 package api
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"os"
@@ -84,3 +88,37 @@ var authenticatedStreamingClient = &http.Client{
 	},
 	// No global timeout set for the streaming client
 }
+
+// retries after a network error for requests sent with doIdempotent
+const maxIdempotentRetries = 2
+
+// doIdempotent sends a request that changes context with an Idempotency-Key, retrying after network errors like timeouts.
+// The server replays its response for a key it has already processed, so a retry can't apply the change twice.
+// newReq is called for each attempt since a request body can only be read once.
+func doIdempotent(client *http.Client, newReq func() (*http.Request, error)) (*http.Response, error) {
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return nil, fmt.Errorf("error generating idempotency key: %v", err)
+	}
+	key := hex.EncodeToString(keyBytes)
+
+	var err error
+	for attempt := 0; attempt <= maxIdempotentRetries; attempt++ {
+		var req *http.Request
+		req, err = newReq()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Idempotency-Key", key)
+
+		var resp *http.Response
+		resp, err = client.Do(req)
+		if err == nil {
+			return resp, nil
+		}
+
+		log.Printf("Request failed, attempt %d of %d: %v\n", attempt+1, maxIdempotentRetries+1, err)
+	}
+
+	return nil, err
+}
@@ -68,8 +68,8 @@ func branches(cmd *cobra.Command, args []string) {
 			name,
 			format.Time(b.UpdatedAt),
 			// format.Time(b.CreatedAt),
-			strconv.Itoa(b.ContextTokens) + " 🪙",
-			strconv.Itoa(b.ConvoTokens) + " 🪙",
+			strconv.FormatInt(b.ContextTokens, 10) + " 🪙",
+			strconv.FormatInt(b.ConvoTokens, 10) + " 🪙",
 		}
 
 		var style []tablewriter.Colors
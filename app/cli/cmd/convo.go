@@ -44,7 +44,7 @@ func convo(cmd *cobra.Command, args []string) {
 	}
 
 	var convo string
-	var totalTokens int
+	var totalTokens int64
 	for i, msg := range conversation {
 		var author string
 		if msg.Role == "assistant" {
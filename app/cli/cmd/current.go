@@ -68,8 +68,8 @@ func current(cmd *cobra.Command, args []string) {
 		format.Time(plan.CreatedAt),
 		// strconv.Itoa(plan.ActiveBranches),
 		lib.CurrentBranch,
-		strconv.Itoa(branch.ContextTokens) + " 🪙",
-		strconv.Itoa(branch.ConvoTokens) + " 🪙",
+		strconv.FormatInt(branch.ContextTokens, 10) + " 🪙",
+		strconv.FormatInt(branch.ConvoTokens, 10) + " 🪙",
 	}
 
 	style := []tablewriter.Colors{
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plandex/auth"
+	"plandex/fs"
+	"plandex/lib"
+	"plandex/term"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Debugging tools",
+}
+
+var debugIgnoreCmd = &cobra.Command{
+	Use:   "ignore [files-or-dirs...]",
+	Short: "Show why files are left out of context",
+	Long:  `Show which paths .plandexignore or the org's default ignore rules exclude, and the rule that matched each one. With paths given, show whether each of them can be loaded and, if not, why.`,
+	Run:   debugIgnore,
+}
+
+func init() {
+	RootCmd.AddCommand(debugCmd)
+	debugCmd.AddCommand(debugIgnoreCmd)
+}
+
+func debugIgnore(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+	lib.MustResolveProject()
+
+	term.StartSpinner("")
+	paths, excluded, err := fs.GetPathsWithExcluded(fs.ProjectRoot, fs.ProjectRoot)
+	term.StopSpinner()
+
+	if err != nil {
+		term.OutputErrorAndExit("Error getting project paths: %v", err)
+	}
+
+	if len(args) == 0 {
+		if len(excluded) == 0 {
+			fmt.Println("🤷‍♂️ No paths are excluded by .plandexignore")
+			return
+		}
+
+		var excludedPaths []string
+		for path := range excluded {
+			excludedPaths = append(excludedPaths, path)
+		}
+		sort.Strings(excludedPaths)
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Path", "Rule"})
+		table.SetAutoWrapText(false)
+		for _, path := range excludedPaths {
+			table.Append([]string{path, excluded[path]})
+		}
+		table.Render()
+		return
+	}
+
+	for _, arg := range args {
+		path := arg
+		if filepath.IsAbs(path) {
+			rel, err := filepath.Rel(fs.ProjectRoot, path)
+			if err != nil {
+				term.OutputErrorAndExit("Error getting relative path for %s: %v", arg, err)
+			}
+			path = rel
+		}
+		path = fs.NormalizePath(path)
+
+		var msg string
+		if paths.IsActive(path) {
+			msg = color.New(term.ColorHiGreen).Sprint("not ignored")
+		} else if rule, ok := excluded[path]; ok {
+			msg = "ignored by " + color.New(color.Bold).Sprint(rule)
+		} else if reason, ok := paths.IgnoredReason(path); ok {
+			switch reason {
+			case "export-ignore":
+				msg = "ignored as export-ignore in .gitattributes"
+			default:
+				msg = "ignored by git"
+			}
+		} else if parentRule := excludedParentRule(path, excluded); parentRule != "" {
+			msg = "ignored since a parent dir is ignored by " + color.New(color.Bold).Sprint(parentRule)
+		} else {
+			msg = "not found in the project"
+		}
+
+		fmt.Printf("%s: %s\n", arg, msg)
+	}
+}
+
+// excludedParentRule returns the rule for the closest excluded dir above a path, for paths the walk never reached
+func excludedParentRule(path string, excluded map[string]string) string {
+	for dir := filepath.ToSlash(filepath.Dir(path)); dir != "." && dir != "/"; dir = filepath.ToSlash(filepath.Dir(dir)) {
+		if rule, ok := excluded[dir]; ok {
+			return rule
+		}
+	}
+	return ""
+}
@@ -6,7 +6,9 @@ import (
 	"plandex/lib"
 	"plandex/term"
 	"plandex/types"
+	"strings"
 
+	"github.com/plandex/plandex/shared"
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +17,18 @@ var (
 	namesOnly       bool
 	note            string
 	forceSkipIgnore bool
+	stripComments   bool
+	keepDocstrings  bool
+	resolveUrls     bool
+	baseUrl         string
+	encoding        string
+	suggestPairs    bool
+	exportIgnore    bool
+	nestedTrees     string
+	minified        string
+	emptyBody       string
+	dedupe          bool
+	skipSymlinkDirs bool
 )
 
 var contextLoadCmd = &cobra.Command{
@@ -30,6 +44,18 @@ func init() {
 	contextLoadCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Search directories recursively")
 	contextLoadCmd.Flags().BoolVar(&namesOnly, "tree", false, "Load directory tree with file names only")
 	contextLoadCmd.Flags().BoolVarP(&forceSkipIgnore, "force", "f", false, "Load files even when ignored by .gitignore or .plandexignore")
+	contextLoadCmd.Flags().BoolVar(&stripComments, "strip-comments", false, "Strip code comments from files to save tokens")
+	contextLoadCmd.Flags().BoolVar(&keepDocstrings, "keep-docstrings", false, "Keep doc comments and docstrings when stripping comments")
+	contextLoadCmd.Flags().BoolVar(&resolveUrls, "resolve-urls", false, "Rewrite relative links in markdown/html to absolute urls")
+	contextLoadCmd.Flags().StringVar(&baseUrl, "base-url", "", "Base url for --resolve-urls (defaults to the source url for urls; required for files)")
+	contextLoadCmd.Flags().StringVar(&encoding, "encoding", "", "Character encoding of the files being loaded, e.g. windows-1252 or utf-16 (default utf-8)")
+	contextLoadCmd.Flags().BoolVar(&suggestPairs, "suggest-pairs", false, "Suggest test files for loaded sources and vice versa (doesn't load them)")
+	contextLoadCmd.Flags().BoolVar(&exportIgnore, "export-ignore", false, "Skip files marked export-ignore in .gitattributes, like git archive (git repos only)")
+	contextLoadCmd.Flags().StringVar(&nestedTrees, "nested-trees", string(shared.NestedTreesWarn), "How to handle a --tree that's already contained in another loaded tree: warn, skip, or allow")
+	contextLoadCmd.Flags().StringVar(&minified, "minified", "", "How to handle files that look minified: reject, or deminify to reformat js/css/json before loading")
+	contextLoadCmd.Flags().StringVar(&emptyBody, "empty", string(shared.EmptyBodySkip), "How to handle empty files and inputs: skip, or allow to load them anyway")
+	contextLoadCmd.Flags().BoolVar(&dedupe, "dedupe", false, "Skip files and urls whose content is already loaded unchanged")
+	contextLoadCmd.Flags().BoolVar(&skipSymlinkDirs, "skip-symlink-dirs", false, "Don't load files in symlinked directories, like a symlinked node_modules")
 	RootCmd.AddCommand(contextLoadCmd)
 }
 
@@ -42,11 +68,47 @@ func contextLoad(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if encoding != "" {
+		if _, ok := shared.NormalizeEncoding(encoding); !ok {
+			term.OutputErrorAndExit("Unsupported encoding '%s'. Supported encodings include %s", encoding, strings.Join(shared.SupportedEncodings, ", "))
+		}
+	}
+
+	switch shared.NestedTreesMode(nestedTrees) {
+	case shared.NestedTreesWarn, shared.NestedTreesSkip, shared.NestedTreesAllow:
+	default:
+		term.OutputErrorAndExit("Invalid value for --nested-trees: '%s'. Must be warn, skip, or allow", nestedTrees)
+	}
+
+	switch shared.MinifiedMode(minified) {
+	case "", shared.MinifiedReject, shared.MinifiedDeminify:
+	default:
+		term.OutputErrorAndExit("Invalid value for --minified: '%s'. Must be reject or deminify", minified)
+	}
+
+	switch shared.EmptyBodyMode(emptyBody) {
+	case shared.EmptyBodySkip, shared.EmptyBodyAllow:
+	default:
+		term.OutputErrorAndExit("Invalid value for --empty: '%s'. Must be skip or allow", emptyBody)
+	}
+
 	lib.MustLoadContext(args, &types.LoadContextParams{
-		Note:            note,
-		Recursive:       recursive,
-		NamesOnly:       namesOnly,
-		ForceSkipIgnore: forceSkipIgnore,
+		Note:                note,
+		Recursive:           recursive,
+		NamesOnly:           namesOnly,
+		ForceSkipIgnore:     forceSkipIgnore,
+		StripComments:       stripComments,
+		PreserveDocstrings:  keepDocstrings,
+		ResolveRelativeUrls: resolveUrls,
+		BaseUrl:             baseUrl,
+		Encoding:            encoding,
+		SuggestPairs:        suggestPairs,
+		ExportIgnore:        exportIgnore,
+		NestedTrees:         shared.NestedTreesMode(nestedTrees),
+		Minified:            shared.MinifiedMode(minified),
+		EmptyBody:           shared.EmptyBodyMode(emptyBody),
+		Dedupe:              dedupe,
+		SkipSymlinkDirs:     skipSymlinkDirs,
 	})
 
 	fmt.Println()
@@ -12,6 +12,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
+	"github.com/plandex/plandex/shared"
 	"github.com/spf13/cobra"
 )
 
@@ -34,9 +35,9 @@ func listContext(cmd *cobra.Command, args []string) {
 		term.OutputErrorAndExit("Error listing context: %v", err)
 	}
 
-	totalTokens := 0
+	var totalTokens int64
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"#", "Name", "Type", "🪙", "Added", "Updated"})
+	table.SetHeader([]string{"#", "Alias", "Name", "Type", "🪙", "Added", "Updated"})
 	table.SetAutoWrapText(false)
 
 	if len(contexts) == 0 {
@@ -46,22 +47,40 @@ func listContext(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	numOversized := 0
 	for i, context := range contexts {
 		totalTokens += context.NumTokens
 
 		t, icon := lib.GetContextTypeAndIcon(context)
 
+		tokens := strconv.FormatInt(context.NumTokens, 10) //+ " 🪙"
+		tokensColor := tablewriter.Colors{}
+		if context.Oversized {
+			numOversized++
+			tokens += " ⚠️"
+			tokensColor = tablewriter.Colors{tablewriter.FgHiYellowColor, tablewriter.Bold}
+		}
+
+		alias := ""
+		if context.Alias != 0 {
+			alias = shared.ContextAliasRef(context.Alias)
+		}
+
 		row := []string{
 			strconv.Itoa(i + 1),
+			alias,
 			" " + icon + " " + context.Name,
 			t,
-			strconv.Itoa(context.NumTokens), //+ " 🪙",
+			tokens,
 			format.Time(context.CreatedAt),
 			format.Time(context.UpdatedAt),
 		}
 		table.Rich(row, []tablewriter.Colors{
 			{tablewriter.Bold},
+			{},
 			{tablewriter.FgHiGreenColor, tablewriter.Bold},
+			{},
+			tokensColor,
 		})
 	}
 
@@ -73,6 +92,11 @@ func listContext(cmd *cobra.Command, args []string) {
 
 	tokensTbl.Render()
 
+	if numOversized > 0 {
+		fmt.Println()
+		fmt.Printf("⚠️  %d oversized piece(s) of context. Consider removing them or loading something narrower.\n", numOversized)
+	}
+
 	fmt.Println()
 	term.PrintCmds("", "load", "rm", "clear")
 
@@ -186,8 +186,8 @@ func plans(cmd *cobra.Command, args []string) {
 				// format.Time(p.CreatedAt),
 				// strconv.Itoa(p.ActiveBranches),
 				currentBranch.Name,
-				strconv.Itoa(currentBranch.ContextTokens) + " 🪙",
-				strconv.Itoa(currentBranch.ConvoTokens) + " 🪙",
+				strconv.FormatInt(currentBranch.ContextTokens, 10) + " 🪙",
+				strconv.FormatInt(currentBranch.ConvoTokens, 10) + " 🪙",
 			}
 
 			var style []tablewriter.Colors
@@ -12,11 +12,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var rmDryRun bool
+
 var contextRmCmd = &cobra.Command{
 	Use:     "rm",
 	Aliases: []string{"remove", "unload"},
 	Short:   "Remove context",
-	Long:    `Remove context by index, name, or glob.`,
+	Long:    `Remove context by index, alias, name, or glob. Quote aliases like '#3' so the shell doesn't treat them as comments.`,
 	Args:    cobra.MinimumNArgs(1),
 	Run:     contextRm,
 }
@@ -41,7 +43,7 @@ func contextRm(cmd *cobra.Command, args []string) {
 
 	for i, context := range contexts {
 		for _, id := range args {
-			if fmt.Sprintf("%d", i+1) == id || context.Name == id || context.FilePath == id || context.Url == id {
+			if fmt.Sprintf("%d", i+1) == id || (context.Alias != 0 && shared.ContextAliasRef(context.Alias) == id) || context.Name == id || context.FilePath == id || context.Url == id {
 				deleteIds[context.Id] = true
 				break
 			} else if context.FilePath != "" {
@@ -71,7 +73,8 @@ func contextRm(cmd *cobra.Command, args []string) {
 
 	if len(deleteIds) > 0 {
 		res, err := api.Client.DeleteContext(lib.CurrentPlanId, lib.CurrentBranch, shared.DeleteContextRequest{
-			Ids: deleteIds,
+			Ids:    deleteIds,
+			DryRun: rmDryRun,
 		})
 		term.StopSpinner()
 
@@ -79,6 +82,13 @@ func contextRm(cmd *cobra.Command, args []string) {
 			term.OutputErrorAndExit("Error deleting context: %v", err)
 		}
 
+		if rmDryRun {
+			fmt.Println("🔎 Dry run, nothing was removed. This would be the result:")
+			fmt.Println()
+			fmt.Println(res.Msg)
+			return
+		}
+
 		fmt.Println("✅ " + res.Msg)
 	} else {
 		term.StopSpinner()
@@ -87,5 +97,6 @@ func contextRm(cmd *cobra.Command, args []string) {
 }
 
 func init() {
+	contextRmCmd.Flags().BoolVar(&rmDryRun, "dry-run", false, "Show what would be removed without removing it")
 	RootCmd.AddCommand(contextRmCmd)
 }
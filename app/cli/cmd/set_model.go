@@ -154,7 +154,7 @@ func modelsSet(cmd *cobra.Command, args []string) {
 			if value == "" {
 				settings.ModelOverrides.MaxConvoTokens = nil
 			} else {
-				n, err := strconv.Atoi(value)
+				n, err := strconv.ParseInt(value, 10, 64)
 				if err != nil {
 					fmt.Println("Invalid value for max-convo-tokens:", value)
 					return
@@ -165,7 +165,7 @@ func modelsSet(cmd *cobra.Command, args []string) {
 			if value == "" {
 				settings.ModelOverrides.MaxTokens = nil
 			} else {
-				n, err := strconv.Atoi(value)
+				n, err := strconv.ParseInt(value, 10, 64)
 				if err != nil {
 					fmt.Println("Invalid value for max-tokens:", value)
 					return
@@ -176,7 +176,7 @@ func modelsSet(cmd *cobra.Command, args []string) {
 			if value == "" {
 				settings.ModelOverrides.ReservedOutputTokens = nil
 			} else {
-				n, err := strconv.Atoi(value)
+				n, err := strconv.ParseInt(value, 10, 64)
 				if err != nil {
 					fmt.Println("Invalid value for reserved-output-tokens:", value)
 					return
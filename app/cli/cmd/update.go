@@ -9,6 +9,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var updateDryRun bool
+
 var updateCmd = &cobra.Command{
 	Use:     "update ",
 	Aliases: []string{"u"},
@@ -20,6 +22,7 @@ var updateCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(updateCmd)
 
+	updateCmd.Flags().BoolVar(&updateDryRun, "dry-run", false, "Show the commit the update would create without updating anything")
 }
 
 func update(cmd *cobra.Command, args []string) {
@@ -40,5 +43,19 @@ func update(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if updateDryRun {
+		res, err := lib.PreviewUpdateContext(nil)
+		term.StopSpinner()
+
+		if err != nil {
+			term.OutputErrorAndExit("failed to preview context update: %s", err)
+		}
+
+		fmt.Println("🔎 Dry run, nothing was updated. This would be the commit:")
+		fmt.Println()
+		fmt.Println(res.Msg)
+		return
+	}
+
 	lib.MustUpdateContext(nil)
 }
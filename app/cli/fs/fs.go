@@ -3,14 +3,18 @@ package fs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"plandex/term"
 	"plandex/types"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/plandex/plandex/shared"
 	ignore "github.com/sabhiram/go-gitignore"
@@ -26,16 +30,29 @@ var HomeDir string
 var HomeAuthPath string
 var HomeAccountsPath string
 
+var initErr error
+
 func init() {
+	initErr = InitFS()
+}
+
+// InitErr returns the error from setting up the fs package on startup, if there was one, so callers can report it
+func InitErr() error {
+	return initErr
+}
+
+// InitFS finds the current and home dirs, creates the home plandex dir and cache dirs if needed, and finds the current project.
+// It runs on startup, and can be run again, e.g. after changing dirs.
+func InitFS() error {
 	var err error
 	Cwd, err = os.Getwd()
 	if err != nil {
-		term.OutputErrorAndExit("Error getting current working directory: %v", err)
+		return fmt.Errorf("error getting current working directory: %v", err)
 	}
 
 	home, err := os.UserHomeDir()
 	if err != nil {
-		term.OutputErrorAndExit("Couldn't find home dir: %v", err.Error())
+		return fmt.Errorf("couldn't find home dir: %v", err)
 	}
 	HomeDir = home
 
@@ -48,7 +65,7 @@ func init() {
 	// Create the home plandex directory if it doesn't exist
 	err = os.MkdirAll(HomePlandexDir, os.ModePerm)
 	if err != nil {
-		term.OutputErrorAndExit(err.Error())
+		return fmt.Errorf("error creating %s: %v", HomePlandexDir, err)
 	}
 
 	CacheDir = filepath.Join(HomePlandexDir, "cache")
@@ -57,33 +74,25 @@ func init() {
 
 	err = os.MkdirAll(filepath.Join(CacheDir, "tiktoken"), os.ModePerm)
 	if err != nil {
-		term.OutputErrorAndExit(err.Error())
+		return fmt.Errorf("error creating the cache dir: %v", err)
 	}
 	err = os.Setenv("TIKTOKEN_CACHE_DIR", CacheDir)
 	if err != nil {
-		term.OutputErrorAndExit(err.Error())
+		return fmt.Errorf("error setting TIKTOKEN_CACHE_DIR: %v", err)
 	}
 
-	PlandexDir = findPlandex(Cwd)
-	if PlandexDir != "" {
-		ProjectRoot = Cwd
-	}
+	PlandexDir, ProjectRoot = findPlandex(Cwd)
+
+	return nil
 }
 
 func FindOrCreatePlandex() (string, bool, error) {
-	PlandexDir = findPlandex(Cwd)
+	PlandexDir, ProjectRoot = findPlandex(Cwd)
 	if PlandexDir != "" {
-		ProjectRoot = Cwd
 		return PlandexDir, false, nil
 	}
 
-	// Determine the directory path
-	var dir string
-	if os.Getenv("PLANDEX_ENV") == "development" {
-		dir = filepath.Join(Cwd, ".plandex-dev")
-	} else {
-		dir = filepath.Join(Cwd, ".plandex")
-	}
+	dir := filepath.Join(Cwd, plandexDirName())
 
 	err := os.Mkdir(dir, os.ModePerm)
 	if err != nil {
@@ -122,11 +131,72 @@ func IsGitRepo(dir string) bool {
 	return isGitRepo
 }
 
+type GitPathStatus string
+
+const (
+	GitPathTracked   GitPathStatus = "tracked"
+	GitPathUntracked GitPathStatus = "untracked"
+	// ignored by git, so only in context if loaded with --force
+	GitPathIgnored GitPathStatus = "ignored"
+)
+
 type ProjectPaths struct {
 	ActivePaths    map[string]bool
 	AllPaths       map[string]bool
 	PlandexIgnored *ignore.GitIgnore
 	IgnoredPaths   map[string]string
+
+	// every directory found, whether or not the path maps include directories
+	Dirs map[string]bool
+
+	// only set with GetPathsOpts.WithGitStatus, and only for files in a git repo
+	GitStatuses map[string]GitPathStatus
+}
+
+type GetPathsOpts struct {
+	// record whether each file is tracked, untracked, or ignored by git, including files that .plandexignore excludes
+	WithGitStatus bool
+	// exclude paths marked export-ignore in .gitattributes, like `git archive` does. Only applies in git repos.
+	ExportIgnore bool
+	// leave out symlinked directories instead of listing the files in them. Links that would revisit a directory are always skipped.
+	SkipSymlinkDirs bool
+	// list only files in ActivePaths, AllPaths, and IgnoredPaths. Directories are still recorded in Dirs.
+	ExcludeDirs bool
+
+	// list paths as outside a git repo, set when `git ls-files` keeps failing
+	skipGit bool
+}
+
+// IsDir reports whether a path, relative to the dir the paths were listed from, is a directory
+func (p *ProjectPaths) IsDir(path string) bool {
+	return p.Dirs[NormalizePath(path)]
+}
+
+// GitTrackedCounts counts how many of the given files are tracked by git and how many aren't (untracked, or ignored but force loaded).
+// Files with no known git status aren't counted, so it requires paths from GetPathsOpts.WithGitStatus.
+func (p *ProjectPaths) GitTrackedCounts(files []string) (tracked, untracked int) {
+	for _, file := range files {
+		status, ok := p.GitStatuses[NormalizePath(file)]
+		if !ok {
+			continue
+		}
+		if status == GitPathTracked {
+			tracked++
+		} else {
+			untracked++
+		}
+	}
+	return tracked, untracked
+}
+
+// SortedActivePaths returns the active paths sorted lexicographically. Keys are normalized, so the order is the same on every OS.
+func (p *ProjectPaths) SortedActivePaths() []string {
+	paths := make([]string, 0, len(p.ActivePaths))
+	for path := range p.ActivePaths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
 }
 
 func GetProjectPaths(baseDir string) (*ProjectPaths, error) {
@@ -137,24 +207,75 @@ func GetProjectPaths(baseDir string) (*ProjectPaths, error) {
 	return GetPaths(baseDir, ProjectRoot)
 }
 
+func GetProjectPathsWithOpts(baseDir string, opts GetPathsOpts) (*ProjectPaths, error) {
+	if ProjectRoot == "" {
+		return nil, fmt.Errorf("no project root found")
+	}
+
+	return GetPathsWithOpts(baseDir, ProjectRoot, opts)
+}
+
 func GetPaths(baseDir, currentDir string) (*ProjectPaths, error) {
-	ignored, err := GetPlandexIgnore(currentDir)
+	return GetPathsCtx(context.Background(), baseDir, currentDir)
+}
+
+// GetPathsCtx is like GetPaths, but stops the git commands and the directory walk early and returns ctx.Err() once ctx is done
+func GetPathsCtx(ctx context.Context, baseDir, currentDir string) (*ProjectPaths, error) {
+	return GetPathsWithOptsCtx(ctx, baseDir, currentDir, GetPathsOpts{})
+}
+
+// GetPathsSorted is like GetPaths, but returns the active paths as a sorted list, so output built from them, like a directory tree,
+// is the same between runs
+func GetPathsSorted(baseDir, currentDir string) ([]string, error) {
+	paths, err := GetPaths(baseDir, currentDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return paths.SortedActivePaths(), nil
+}
+
+// GetPathsWithGitStatus is like GetPaths, but also records whether each file is tracked, untracked, or ignored by git, including files that .plandexignore excludes
+func GetPathsWithGitStatus(baseDir, currentDir string) (*ProjectPaths, error) {
+	return GetPathsWithOpts(baseDir, currentDir, GetPathsOpts{WithGitStatus: true})
+}
+
+func GetPathsWithOpts(baseDir, currentDir string, opts GetPathsOpts) (*ProjectPaths, error) {
+	return GetPathsWithOptsCtx(context.Background(), baseDir, currentDir, opts)
+}
+
+func GetPathsWithOptsCtx(ctx context.Context, baseDir, currentDir string, opts GetPathsOpts) (*ProjectPaths, error) {
+	ignoreLines, _, err := getPlandexIgnoreLines(currentDir)
 
 	if err != nil {
 		return nil, err
 	}
 
+	var ignored *ignore.GitIgnore
+	if len(ignoreLines) > 0 {
+		ignored = ignore.CompileIgnoreLines(ignoreLines...)
+	}
+	ignoredHasNegation := hasNegatedRule(ignoreLines)
+
 	allPaths := map[string]bool{}
 	activePaths := map[string]bool{}
 
 	allDirs := map[string]bool{}
 	activeDirs := map[string]bool{}
 
-	isGitRepo := IsGitRepo(baseDir)
+	isGitRepo := !opts.skipGit && IsGitRepo(baseDir)
+
+	var gitStatuses map[string]GitPathStatus
+	if opts.WithGitStatus && isGitRepo {
+		gitStatuses = map[string]GitPathStatus{}
+	}
 
-	errCh := make(chan error)
+	// buffered for every routine, so none are left blocked if we return early on an error
+	errCh := make(chan error, 3)
 	var mu sync.Mutex
 	numRoutines := 0
+	// set if either git command still fails after retrying, in which case the paths are listed again without git
+	var gitErr error
 
 	if isGitRepo {
 		// combine `git ls-files` and `git ls-files --others --exclude-standard`
@@ -162,17 +283,19 @@ func GetPaths(baseDir, currentDir string) (*ProjectPaths, error) {
 
 		numRoutines++
 		go func() {
-			// get all tracked files in the repo
-			cmd := exec.Command("git", "ls-files")
-			cmd.Dir = baseDir
-			out, err := cmd.Output()
+			// get all tracked files in the repo, including those in submodules, which are otherwise listed only as the submodule's path.
+			// Linked worktrees need nothing special, since ls-files reads the worktree's own index.
+			out, err := gitOutputWithRetry(ctx, baseDir, "ls-files", "--recurse-submodules")
 
 			if err != nil {
-				errCh <- fmt.Errorf("error getting files in git repo: %s", err)
+				mu.Lock()
+				gitErr = fmt.Errorf("error getting files in git repo: %s", err)
+				mu.Unlock()
+				errCh <- nil
 				return
 			}
 
-			files := strings.Split(string(out), "\n")
+			files := splitGitOutput(out)
 
 			mu.Lock()
 			defer mu.Unlock()
@@ -184,18 +307,18 @@ func GetPaths(baseDir, currentDir string) (*ProjectPaths, error) {
 					errCh <- fmt.Errorf("error getting relative path: %s", err)
 					return
 				}
+				relFile = NormalizePath(relFile)
+
+				if gitStatuses != nil {
+					gitStatuses[relFile] = GitPathTracked
+				}
 
 				if ignored != nil && ignored.MatchesPath(relFile) {
 					continue
 				}
 
 				activePaths[relFile] = true
-
-				parentDir := relFile
-				for parentDir != "." && parentDir != "/" && parentDir != "" {
-					parentDir = filepath.Dir(parentDir)
-					activeDirs[parentDir] = true
-				}
+				addParentDirs(activeDirs, relFile)
 			}
 
 			errCh <- nil
@@ -204,16 +327,17 @@ func GetPaths(baseDir, currentDir string) (*ProjectPaths, error) {
 		// get all untracked non-ignored files in the repo
 		numRoutines++
 		go func() {
-			cmd := exec.Command("git", "ls-files", "--others", "--exclude-standard")
-			cmd.Dir = baseDir
-			out, err := cmd.Output()
+			out, err := gitOutputWithRetry(ctx, baseDir, "ls-files", "--others", "--exclude-standard")
 
 			if err != nil {
-				errCh <- fmt.Errorf("error getting untracked files in git repo: %s", err)
+				mu.Lock()
+				gitErr = fmt.Errorf("error getting untracked files in git repo: %s", err)
+				mu.Unlock()
+				errCh <- nil
 				return
 			}
 
-			files := strings.Split(string(out), "\n")
+			files := splitGitOutput(out)
 
 			mu.Lock()
 			defer mu.Unlock()
@@ -225,37 +349,47 @@ func GetPaths(baseDir, currentDir string) (*ProjectPaths, error) {
 					errCh <- fmt.Errorf("error getting relative path: %s", err)
 					return
 				}
+				relFile = NormalizePath(relFile)
+
+				if gitStatuses != nil {
+					gitStatuses[relFile] = GitPathUntracked
+				}
 
 				if ignored != nil && ignored.MatchesPath(relFile) {
 					continue
 				}
 
 				activePaths[relFile] = true
-
-				parentDir := relFile
-				for parentDir != "." && parentDir != "/" && parentDir != "" {
-					parentDir = filepath.Dir(parentDir)
-					activeDirs[parentDir] = true
-				}
+				addParentDirs(activeDirs, relFile)
 			}
 
 			errCh <- nil
 		}()
 	}
 
+	// outside a git repo, .gitignore files are applied while walking since there's no `git ls-files` to do it
+	var gitIgnores *nestedGitIgnore
+	if !isGitRepo {
+		gitIgnores = newNestedGitIgnore(baseDir)
+	}
+
 	// get all paths in the directory
 	numRoutines++
 	go func() {
-		err = filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		err = WalkPaths(baseDir, WalkOpts{SkipSymlinkDirs: opts.SkipSymlinkDirs}, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
 
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
 			if info.IsDir() {
 				if info.Name() == ".git" {
 					return filepath.SkipDir
 				}
-				if info.Name() == ".plandex" || info.Name() == ".plandex-dev" {
+				if IsPlandexDirName(info.Name()) {
 					return filepath.SkipDir
 				}
 
@@ -263,17 +397,40 @@ func GetPaths(baseDir, currentDir string) (*ProjectPaths, error) {
 				if err != nil {
 					return err
 				}
+				relPath = NormalizePath(relPath)
 
 				allDirs[relPath] = true
 
-				if ignored != nil && ignored.MatchesPath(relPath) {
+				// a '!' rule could re-include a file under an ignored dir, so when there is one, walk in and check each file instead
+				if ignored != nil && ignored.MatchesPath(relPath) && !ignoredHasNegation {
 					return filepath.SkipDir
 				}
+
+				if gitIgnores != nil {
+					baseRelPath, err := filepath.Rel(baseDir, path)
+					if err != nil {
+						return err
+					}
+
+					// keep walking an ignored directory so its files are listed as ignored, as they are in a git repo
+					if !gitIgnores.matches(baseRelPath, true) {
+						err = gitIgnores.enterDir(baseRelPath)
+						if err != nil {
+							return err
+						}
+					}
+				}
 			} else {
+				// a submodule or linked worktree has a .git file pointing at its git dir in place of the dir itself
+				if info.Name() == ".git" {
+					return nil
+				}
+
 				relPath, err := filepath.Rel(currentDir, path)
 				if err != nil {
 					return err
 				}
+				relPath = NormalizePath(relPath)
 
 				allPaths[relPath] = true
 
@@ -282,15 +439,19 @@ func GetPaths(baseDir, currentDir string) (*ProjectPaths, error) {
 				}
 
 				if !isGitRepo {
+					baseRelPath, err := filepath.Rel(baseDir, path)
+					if err != nil {
+						return err
+					}
+
+					if gitIgnores.matches(baseRelPath, false) {
+						return nil
+					}
+
 					mu.Lock()
 					defer mu.Unlock()
 					activePaths[relPath] = true
-
-					parentDir := relPath
-					for parentDir != "." && parentDir != "/" && parentDir != "" {
-						parentDir = filepath.Dir(parentDir)
-						activeDirs[parentDir] = true
-					}
+					addParentDirs(activeDirs, relPath)
 				}
 			}
 
@@ -308,21 +469,60 @@ func GetPaths(baseDir, currentDir string) (*ProjectPaths, error) {
 	for i := 0; i < numRoutines; i++ {
 		err := <-errCh
 		if err != nil {
+			// a cancelled git command just reports being killed, so return the cancellation itself
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			return nil, err
 		}
 	}
 
+	if gitErr != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("Warning: %v. Listing paths without git.\n", gitErr)
+		opts.skipGit = true
+		return GetPathsWithOptsCtx(ctx, baseDir, currentDir, opts)
+	}
+
+	// dirs are in the path maps until the end either way, since export-ignore and ignore reasons apply to them too
+	dirs := map[string]bool{}
 	for dir := range allDirs {
 		allPaths[dir] = true
+		dirs[dir] = true
 	}
 
 	for dir := range activeDirs {
 		activePaths[dir] = true
+		dirs[dir] = true
 	}
 
 	ignoredPaths := map[string]string{}
+
+	if opts.ExportIgnore && isGitRepo {
+		exportIgnored, err := getExportIgnored(ctx, baseDir, currentDir, activePaths)
+		if err != nil {
+			return nil, err
+		}
+
+		for path := range activePaths {
+			// like `git archive`, an export-ignored directory excludes everything under it
+			for parent := path; parent != "." && parent != "/" && parent != ""; parent = slashDir(parent) {
+				if exportIgnored[parent] {
+					delete(activePaths, path)
+					ignoredPaths[path] = "export-ignore"
+					break
+				}
+			}
+		}
+	}
+
 	for path := range allPaths {
 		if _, ok := activePaths[path]; !ok {
+			if _, ok := ignoredPaths[path]; ok {
+				continue
+			}
 			if ignored != nil && ignored.MatchesPath(path) {
 				ignoredPaths[path] = "plandex"
 			} else {
@@ -331,38 +531,382 @@ func GetPaths(baseDir, currentDir string) (*ProjectPaths, error) {
 		}
 	}
 
+	if gitStatuses != nil {
+		for path := range allPaths {
+			if _, ok := gitStatuses[path]; !ok && !allDirs[path] {
+				gitStatuses[path] = GitPathIgnored
+			}
+		}
+	}
+
+	if opts.ExcludeDirs {
+		for dir := range dirs {
+			delete(activePaths, dir)
+			delete(allPaths, dir)
+			delete(ignoredPaths, dir)
+		}
+	}
+
 	return &ProjectPaths{
 		ActivePaths:    activePaths,
 		AllPaths:       allPaths,
 		PlandexIgnored: ignored,
 		IgnoredPaths:   ignoredPaths,
+		Dirs:           dirs,
+		GitStatuses:    gitStatuses,
 	}, nil
 }
 
+// GetPathsWithExcluded is like GetPaths, but also returns the paths that .plandexignore or the org's default ignore rules excluded,
+// each mapped to the rule that matched it, e.g. "node_modules (.plandexignore line 3)". A path under an excluded dir that was
+// never walked isn't listed, but the dir is.
+func GetPathsWithExcluded(baseDir, currentDir string) (*ProjectPaths, map[string]string, error) {
+	paths, err := GetPaths(baseDir, currentDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	excluded := map[string]string{}
+	if paths.PlandexIgnored == nil {
+		return paths, excluded, nil
+	}
+
+	_, sources, err := getPlandexIgnoreLines(currentDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for path, reason := range paths.IgnoredPaths {
+		if reason != "plandex" {
+			continue
+		}
+
+		_, pattern := paths.PlandexIgnored.MatchesPathHow(path)
+		if pattern == nil {
+			excluded[path] = ""
+			continue
+		}
+
+		if pattern.LineNo < 1 || pattern.LineNo > len(sources) {
+			excluded[path] = strings.TrimSpace(pattern.Line)
+			continue
+		}
+		excluded[path] = fmt.Sprintf("%s (%s)", strings.TrimSpace(pattern.Line), sources[pattern.LineNo-1])
+	}
+
+	return paths, excluded, nil
+}
+
+// splitGitOutput splits newline-separated `git ls-files` output into paths, dropping the empty entry after the trailing newline
+// and any other blank lines so they can't be taken for a path
+func splitGitOutput(out []byte) []string {
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		files = append(files, line)
+	}
+	return files
+}
+
+// how many times a git command is retried after a transient failure. The wait starts at gitRetryBackoff and doubles.
+const gitRetries = 2
+
+var gitRetryBackoff = 100 * time.Millisecond
+
+// gitOutputFn runs a git command in dir and returns its stdout. Tests replace it to simulate git failing.
+var gitOutputFn = func(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	return cmd.Output()
+}
+
+// gitOutputWithRetry runs a git command, retrying with a short backoff if it fails on a lock held by another git process
+func gitOutputWithRetry(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	backoff := gitRetryBackoff
+	for attempt := 0; ; attempt++ {
+		out, err := gitOutputFn(ctx, dir, args...)
+		if err == nil || attempt == gitRetries || !isTransientGitError(err) {
+			return out, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isTransientGitError reports whether a git command failed because a lock file, like .git/index.lock, was held
+func isTransientGitError(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+
+	stderr := string(exitErr.Stderr)
+	return strings.Contains(stderr, ".lock") || strings.Contains(stderr, "Another git process")
+}
+
+// NormalizePath returns the key a path relative to the project root has in ProjectPaths: cleaned, with forward slashes on every OS.
+// `git ls-files` output and the directory walk are both normalized this way so the same file never appears under two keys.
+func NormalizePath(p string) string {
+	return filepath.ToSlash(filepath.Clean(p))
+}
+
+// IsActive reports whether a path relative to the project root, with either kind of separator, is an active path
+func (p *ProjectPaths) IsActive(path string) bool {
+	return p.ActivePaths[NormalizePath(path)]
+}
+
+// IgnoredReason returns why a path relative to the project root, with either kind of separator, is ignored
+func (p *ProjectPaths) IgnoredReason(path string) (string, bool) {
+	reason, ok := p.IgnoredPaths[NormalizePath(path)]
+	return reason, ok
+}
+
+// slashDir is filepath.Dir for a normalized path, keeping the result normalized
+func slashDir(p string) string {
+	return filepath.ToSlash(filepath.Dir(p))
+}
+
+// addParentDirs marks every directory above a normalized path, up to and including ".", as a directory
+func addParentDirs(dirs map[string]bool, p string) {
+	for p != "." && p != "/" && p != "" {
+		p = slashDir(p)
+		dirs[p] = true
+	}
+}
+
+// getExportIgnored returns the paths (relative to currentDir) that have the export-ignore attribute set in .gitattributes
+func getExportIgnored(ctx context.Context, baseDir, currentDir string, paths map[string]bool) (map[string]bool, error) {
+	var input strings.Builder
+	for path := range paths {
+		input.WriteString(filepath.Join(currentDir, path))
+		input.WriteByte(0)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "check-attr", "--stdin", "-z", "export-ignore")
+	cmd.Dir = baseDir
+	cmd.Stdin = strings.NewReader(input.String())
+	out, err := cmd.Output()
+
+	if err != nil {
+		return nil, fmt.Errorf("error checking .gitattributes export-ignore: %s", err)
+	}
+
+	res := map[string]bool{}
+
+	// output is NUL-separated <path> <attribute> <value> triples
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		if fields[i+2] != "set" {
+			continue
+		}
+
+		path := fields[i]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		relPath, err := filepath.Rel(currentDir, path)
+		if err != nil {
+			return nil, fmt.Errorf("error getting relative path: %s", err)
+		}
+
+		res[NormalizePath(relPath)] = true
+	}
+
+	return res, nil
+}
+
+var orgDefaultIgnoreFn func() []string
+
+// SetOrgDefaultIgnoreFn sets the source of the org's default .plandexignore rules, which are applied before a project's own .plandexignore
+func SetOrgDefaultIgnoreFn(fn func() []string) {
+	orgDefaultIgnoreFn = fn
+}
+
 func GetPlandexIgnore(dir string) (*ignore.GitIgnore, error) {
-	ignorePath := filepath.Join(dir, ".plandexignore")
+	lines, _, err := getPlandexIgnoreLines(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	return ignore.CompileIgnoreLines(lines...), nil
+}
+
+// getPlandexIgnoreLines returns the org's default rules, then the rules from any .plandexignore in a dir above the project,
+// outermost first, then the project's own .plandexignore rules. Later rules take precedence, so the project's file can override
+// the others, e.g. with a '!' pattern that re-includes a path. Ancestor rules are rebased to apply relative to the project.
+// Also returns where each line came from, like "org default" or "../.plandexignore line 3", by index.
+func getPlandexIgnoreLines(dir string) ([]string, []string, error) {
+	var lines []string
+	var sources []string
+	if orgDefaultIgnoreFn != nil {
+		for _, line := range orgDefaultIgnoreFn() {
+			lines = append(lines, line)
+			sources = append(sources, "org default")
+		}
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting absolute path for %s: %v", dir, err)
+	}
 
-	if _, err := os.Stat(ignorePath); err == nil {
-		ignored, err := ignore.CompileIgnoreFile(ignorePath)
+	var ancestors []string
+	for ancestor := filepath.Dir(absDir); ancestor != absDir; ancestor = filepath.Dir(ancestor) {
+		if aboveSearchCeiling(ancestor) {
+			break
+		}
+		ancestors = append(ancestors, ancestor)
+		if isFilesystemRoot(ancestor) {
+			break
+		}
+	}
+
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		ancestor := ancestors[i]
+		ancestorLines, err := readPlandexIgnoreFile(ancestor)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ancestorLines == nil {
+			continue
+		}
 
+		relDir, err := filepath.Rel(ancestor, absDir)
 		if err != nil {
-			return nil, fmt.Errorf("error reading .plandexignore file: %s", err)
+			return nil, nil, fmt.Errorf("error getting relative path for %s: %v", absDir, err)
+		}
+		relFile, err := filepath.Rel(absDir, filepath.Join(ancestor, ".plandexignore"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting relative path for %s: %v", ancestor, err)
+		}
+
+		for lineNo, line := range ancestorLines {
+			rebased, ok := rebaseIgnoreLine(line, filepath.ToSlash(relDir))
+			if !ok {
+				continue
+			}
+			lines = append(lines, rebased)
+			sources = append(sources, fmt.Sprintf("%s line %d", filepath.ToSlash(relFile), lineNo+1))
+		}
+	}
+
+	projectLines, err := readPlandexIgnoreFile(absDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for lineNo, line := range projectLines {
+		lines = append(lines, line)
+		sources = append(sources, fmt.Sprintf(".plandexignore line %d", lineNo+1))
+	}
+
+	return lines, sources, nil
+}
+
+// readPlandexIgnoreFile returns the lines of the .plandexignore in dir, or nil if there isn't one
+func readPlandexIgnoreFile(dir string) ([]string, error) {
+	ignorePath := filepath.Join(dir, ".plandexignore")
+
+	bytes, err := os.ReadFile(ignorePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading .plandexignore file: %s", err)
+	}
+
+	return strings.Split(string(bytes), "\n"), nil
+}
+
+// rebaseIgnoreLine rewrites a rule from a .plandexignore in an ancestor dir to match paths relative to the project, which is
+// relDir (slash-separated) below the ancestor. A rule with no slash but a trailing one already matches at any depth, so it's
+// unchanged. An anchored rule has the segments that match relDir removed. Returns false for blank lines, comments,
+// and rules that can't match anything in the project, like one for a sibling dir.
+func rebaseIgnoreLine(line, relDir string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", false
+	}
+
+	negation := ""
+	pattern := trimmed
+	if strings.HasPrefix(pattern, "!") {
+		negation = "!"
+		pattern = pattern[1:]
+	}
+
+	if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		return trimmed, true
+	}
+
+	patternSegs := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	dirSegs := strings.Split(relDir, "/")
+	for i, dirSeg := range dirSegs {
+		// a rule for the project dir itself or one of its parents would ignore the whole project, which a filter can't express
+		if i >= len(patternSegs) || (i == len(patternSegs)-1 && patternSegs[i] == "") {
+			return "", false
 		}
 
-		return ignored, nil
-	} else if !os.IsNotExist(err) {
-		return nil, fmt.Errorf("error checking for .plandexignore file: %s", err)
+		seg := patternSegs[i]
+		if seg == "**" {
+			return negation + strings.Join(patternSegs[i:], "/"), true
+		}
+
+		if matched, _ := filepath.Match(seg, dirSeg); !matched {
+			return "", false
+		}
+	}
+
+	rest := patternSegs[len(dirSegs):]
+	if len(rest) == 0 || (len(rest) == 1 && rest[0] == "") {
+		return "", false
 	}
 
-	return nil, nil
+	return negation + "/" + strings.Join(rest, "/"), true
+}
+
+func hasNegatedRule(lines []string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "!") {
+			return true
+		}
+	}
+	return false
 }
 
 func GetParentProjectIdsWithPaths() ([][2]string, error) {
 	var parentProjectIds [][2]string
-	currentDir := filepath.Dir(Cwd)
 
-	for currentDir != "/" {
-		plandexDir := findPlandex(currentDir)
+	// the current project may be in an ancestor of the current dir, so parents start above it
+	startDir := Cwd
+	if ProjectRoot != "" {
+		startDir = ProjectRoot
+	}
+
+	for currentDir := filepath.Dir(startDir); ; currentDir = filepath.Dir(currentDir) {
+		if aboveSearchCeiling(currentDir) {
+			break
+		}
+
+		plandexDir := plandexDirIn(currentDir)
+		if plandexDir == "" {
+			if isFilesystemRoot(currentDir) {
+				break
+			}
+			continue
+		}
+
 		projectSettingsPath := filepath.Join(plandexDir, "project.json")
 		if _, err := os.Stat(projectSettingsPath); err == nil {
 			bytes, err := os.ReadFile(projectSettingsPath)
@@ -380,7 +924,10 @@ func GetParentProjectIdsWithPaths() ([][2]string, error) {
 			projectId := string(settings.Id)
 			parentProjectIds = append(parentProjectIds, [2]string{currentDir, projectId})
 		}
-		currentDir = filepath.Dir(currentDir)
+
+		if isFilesystemRoot(currentDir) {
+			break
+		}
 	}
 
 	return parentProjectIds, nil
@@ -389,7 +936,7 @@ func GetParentProjectIdsWithPaths() ([][2]string, error) {
 func GetChildProjectIdsWithPaths(ctx context.Context) ([][2]string, error) {
 	var childProjectIds [][2]string
 
-	err := filepath.Walk(Cwd, func(path string, info os.FileInfo, err error) error {
+	err := WalkPaths(Cwd, WalkOpts{}, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			// if permission denied, skip the path
 			if os.IsPermission(err) {
@@ -417,7 +964,7 @@ func GetChildProjectIdsWithPaths(ctx context.Context) ([][2]string, error) {
 		}
 
 		if info.IsDir() && path != Cwd {
-			plandexDir := findPlandex(path)
+			plandexDir := plandexDirIn(path)
 			projectSettingsPath := filepath.Join(plandexDir, "project.json")
 			if _, err := os.Stat(projectSettingsPath); err == nil {
 				bytes, err := os.ReadFile(projectSettingsPath)
@@ -489,13 +1036,55 @@ func GetBaseDirForFilePaths(paths []string) string {
 	return baseDir
 }
 
-func findPlandex(baseDir string) string {
-	var dir string
+// PlandexDirName overrides the name of a project's plandex dir when set, e.g. to keep two isolated project configs side by side.
+// Set it before any project lookups, or set PLANDEX_DIR_NAME.
+var PlandexDirName string
+
+// plandexDirName is the name of a project's plandex dir: PlandexDirName, then PLANDEX_DIR_NAME, then .plandex, or .plandex-dev in development
+func plandexDirName() string {
+	if PlandexDirName != "" {
+		return PlandexDirName
+	}
+	if name := strings.TrimSpace(os.Getenv("PLANDEX_DIR_NAME")); name != "" {
+		return name
+	}
 	if os.Getenv("PLANDEX_ENV") == "development" {
-		dir = filepath.Join(baseDir, ".plandex-dev")
-	} else {
-		dir = filepath.Join(baseDir, ".plandex")
+		return ".plandex-dev"
+	}
+	return ".plandex"
+}
+
+// IsPlandexDirName reports whether a dir with this name holds plandex project data, and so is never part of a project's files.
+// The default names count even when overridden, since a project may still have one from before.
+func IsPlandexDirName(name string) bool {
+	return name == plandexDirName() || name == ".plandex" || name == ".plandex-dev"
+}
+
+// findPlandex searches baseDir, then each of its ancestors, for a plandex dir, the way git finds a repo's root, stopping at
+// the filesystem root or a search ceiling. It returns the nearest plandex dir and the project root it's in, or "" for both.
+func findPlandex(baseDir string) (string, string) {
+	for dir := baseDir; ; dir = filepath.Dir(dir) {
+		if aboveSearchCeiling(dir) {
+			return "", ""
+		}
+
+		if plandexDir := plandexDirIn(dir); plandexDir != "" {
+			return plandexDir, dir
+		}
+
+		if isFilesystemRoot(dir) {
+			return "", ""
+		}
+	}
+}
+
+// plandexDirIn returns the plandex dir directly in baseDir, or "" if there isn't one or baseDir is above the search ceiling
+func plandexDirIn(baseDir string) string {
+	if aboveSearchCeiling(baseDir) {
+		return ""
 	}
+
+	dir := filepath.Join(baseDir, plandexDirName())
 	if _, err := os.Stat(dir); !os.IsNotExist(err) {
 		return dir
 	}
@@ -503,10 +1092,76 @@ func findPlandex(baseDir string) string {
 	return ""
 }
 
+// isFilesystemRoot works on every OS, e.g. for C:\ as well as /
+func isFilesystemRoot(dir string) bool {
+	return filepath.Dir(dir) == dir
+}
+
+// searchCeilings returns the dirs that searching up from the current dir stops at, from PLANDEX_ROOT_CEILING.
+// It's a list like PATH, and a "~" entry, or one starting with "~/", is relative to the home dir.
+// With no ceiling set, searches go up to the filesystem root.
+func searchCeilings() []string {
+	var ceilings []string
+	for _, entry := range filepath.SplitList(os.Getenv("PLANDEX_ROOT_CEILING")) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if entry == "~" {
+			entry = HomeDir
+		} else if strings.HasPrefix(entry, "~/") || strings.HasPrefix(entry, "~"+string(filepath.Separator)) {
+			entry = filepath.Join(HomeDir, entry[2:])
+		}
+
+		abs, err := filepath.Abs(entry)
+		if err != nil {
+			continue
+		}
+		ceilings = append(ceilings, abs)
+	}
+	return ceilings
+}
+
+// aboveSearchCeiling reports whether dir is above a ceiling that the current dir is in, so searching up from the current dir
+// shouldn't reach it. A ceiling dir itself is still searched, and the current dir and anything under it never count as above a ceiling.
+func aboveSearchCeiling(dir string) bool {
+	for _, ceiling := range searchCeilings() {
+		if !pathWithin(Cwd, ceiling) {
+			continue
+		}
+		if dir != ceiling && pathWithin(ceiling, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	commandAvailableMu sync.Mutex
+	commandAvailable   = map[string]bool{}
+)
+
+// isCommandAvailable reports whether a command is on the PATH, remembering the answer for the life of the process.
+// It only runs the command when LookPath can't tell, like when the match is relative to the current dir.
 func isCommandAvailable(name string) bool {
-	cmd := exec.Command(name, "--version")
-	if err := cmd.Run(); err != nil {
-		return false
+	commandAvailableMu.Lock()
+	defer commandAvailableMu.Unlock()
+
+	if available, ok := commandAvailable[name]; ok {
+		return available
 	}
-	return true
+
+	var available bool
+	_, err := exec.LookPath(name)
+	if err == nil {
+		available = true
+	} else if errors.Is(err, exec.ErrNotFound) {
+		available = false
+	} else {
+		available = exec.Command(name, "--version").Run() == nil
+	}
+
+	commandAvailable[name] = available
+	return available
 }
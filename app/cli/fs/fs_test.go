@@ -0,0 +1,623 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeTestFiles(t *testing.T, root string, files ...string) {
+	t.Helper()
+
+	for _, file := range files {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertNormalizedKeys(t *testing.T, name string, keys map[string]bool) {
+	t.Helper()
+
+	seen := map[string]string{}
+	for key := range keys {
+		if strings.Contains(key, `\`) {
+			t.Errorf("%s: key %q isn't normalized to forward slashes", name, key)
+		}
+
+		slashed := strings.ReplaceAll(key, `\`, "/")
+		if other, ok := seen[slashed]; ok {
+			t.Errorf("%s: %q and %q are the same path", name, key, other)
+		}
+		seen[slashed] = key
+	}
+}
+
+func TestGetPathsNormalizesKeys(t *testing.T) {
+	files := []string{"main.go", "pkg/util/util.go", "pkg/util/util_test.go", "docs/readme.md"}
+
+	t.Run("walk", func(t *testing.T) {
+		root := t.TempDir()
+		writeTestFiles(t, root, files...)
+
+		paths, err := GetPaths(root, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertNormalizedKeys(t, "ActivePaths", paths.ActivePaths)
+		assertNormalizedKeys(t, "AllPaths", paths.AllPaths)
+
+		if !paths.IsActive(filepath.Join("pkg", "util", "util.go")) {
+			t.Errorf("pkg/util/util.go should be active")
+		}
+	})
+
+	t.Run("git", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git not available")
+		}
+
+		root := t.TempDir()
+		writeTestFiles(t, root, files...)
+
+		for _, args := range [][]string{{"init", "-q"}, {"add", "main.go", "pkg"}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = root
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+
+		// pkg is tracked, docs is untracked, and both are walked, so every file comes from more than one source
+		paths, err := GetPathsWithGitStatus(root, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertNormalizedKeys(t, "ActivePaths", paths.ActivePaths)
+		assertNormalizedKeys(t, "AllPaths", paths.AllPaths)
+
+		for _, file := range files {
+			if !paths.ActivePaths[file] {
+				t.Errorf("%s should be active", file)
+			}
+		}
+
+		tracked, untracked := paths.GitTrackedCounts([]string{"main.go", filepath.Join("pkg", "util", "util.go"), "docs/readme.md"})
+		if tracked != 2 || untracked != 1 {
+			t.Errorf("got %d tracked and %d untracked, want 2 and 1", tracked, untracked)
+		}
+	})
+}
+
+func TestGetPathsSorted(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, "zeta.go", "b/b.go", "a/z.go", "a/b/c.go", "A.md")
+
+	first, err := GetPathsSorted(root, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	for _, path := range first {
+		if strings.HasSuffix(path, ".go") || strings.HasSuffix(path, ".md") {
+			files = append(files, path)
+		}
+	}
+	want := []string{"A.md", "a/b/c.go", "a/z.go", "b/b.go", "zeta.go"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got files %q, want %q", files, want)
+	}
+
+	for i := 0; i < 5; i++ {
+		again, err := GetPathsSorted(root, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again, first) {
+			t.Fatalf("got %q on a later run, want %q", again, first)
+		}
+	}
+}
+
+func TestSplitGitOutput(t *testing.T) {
+	out := []byte("main.go\npkg/util/util.go\n\n  \nname with spaces.txt\r\n")
+
+	got := splitGitOutput(out)
+	want := []string{"main.go", "pkg/util/util.go", "name with spaces.txt"}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+
+	if got := splitGitOutput([]byte("")); len(got) != 0 {
+		t.Errorf("got %q for empty output, want no paths", got)
+	}
+}
+
+func TestGetPathsPlandexIgnoreNegation(t *testing.T) {
+	for _, rule := range []string{"node_modules", "node_modules/"} {
+		t.Run(rule, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFiles(t, root, "main.go", "node_modules/keep.js", "node_modules/dep/index.js")
+
+			ignoreRules := rule + "\n!node_modules/keep.js\n"
+			if err := os.WriteFile(filepath.Join(root, ".plandexignore"), []byte(ignoreRules), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			paths, err := GetPaths(root, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !paths.IsActive("node_modules/keep.js") {
+				t.Errorf("node_modules/keep.js should be re-included, got active paths %v", paths.ActivePaths)
+			}
+			if paths.IsActive("node_modules/dep/index.js") {
+				t.Errorf("node_modules/dep/index.js should be ignored")
+			}
+			if reason, _ := paths.IgnoredReason("node_modules/dep/index.js"); reason != "plandex" {
+				t.Errorf("node_modules/dep/index.js ignored as %q, want plandex", reason)
+			}
+			if !paths.IsActive("main.go") {
+				t.Errorf("main.go should be active")
+			}
+		})
+	}
+}
+
+func TestGetPathsWithExcluded(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, "main.go", "debug.log", "dist/app.js")
+
+	if err := os.WriteFile(filepath.Join(root, ".plandexignore"), []byte("# build output\ndist\n*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, excluded, err := GetPathsWithExcluded(root, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"dist":      "dist (.plandexignore line 2)",
+		"debug.log": "*.log (.plandexignore line 3)",
+	}
+	if len(excluded) != len(want) {
+		t.Fatalf("got %v, want %v", excluded, want)
+	}
+	for path, rule := range want {
+		if excluded[path] != rule {
+			t.Errorf("%s: got rule %q, want %q", path, excluded[path], rule)
+		}
+	}
+}
+
+func TestGetPathsExcludeDirs(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, "main.go", "pkg/util/util.go")
+
+	paths, err := GetPathsWithOpts(root, root, GetPathsOpts{ExcludeDirs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"pkg", "pkg/util"} {
+		if paths.ActivePaths[dir] || paths.AllPaths[dir] {
+			t.Errorf("%s should be left out of the path maps", dir)
+		}
+		if !paths.IsDir(dir) {
+			t.Errorf("%s should be recorded as a dir", dir)
+		}
+	}
+
+	for _, file := range []string{"main.go", "pkg/util/util.go"} {
+		if !paths.IsActive(file) {
+			t.Errorf("%s should be active", file)
+		}
+		if paths.IsDir(file) {
+			t.Errorf("%s shouldn't be recorded as a dir", file)
+		}
+	}
+}
+
+func TestGetPathsAncestorPlandexIgnore(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "proj")
+	writeTestFiles(t, root, "main.go", "debug.log", "keep.log", "dist/app.js", "other/x.go")
+
+	parentRules := "*.log\n/proj/dist\n/other\n"
+	if err := os.WriteFile(filepath.Join(parent, ".plandexignore"), []byte(parentRules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".plandexignore"), []byte("!keep.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, excluded, err := GetPathsWithExcluded(root, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"debug.log": "*.log (../.plandexignore line 1)",
+		"dist":      "/dist (../.plandexignore line 2)",
+		// the project's negated rule means ignored dirs are walked, so their files are listed too
+		"dist/app.js": "/dist (../.plandexignore line 2)",
+	}
+	if len(excluded) != len(want) {
+		t.Fatalf("got %v, want %v", excluded, want)
+	}
+	for path, rule := range want {
+		if excluded[path] != rule {
+			t.Errorf("%s: got rule %q, want %q", path, excluded[path], rule)
+		}
+	}
+
+	// the project's own rules override the ancestor's, and anchored ancestor rules for other dirs don't apply
+	for _, file := range []string{"main.go", "keep.log", "other/x.go"} {
+		if !paths.IsActive(file) {
+			t.Errorf("%s should be active", file)
+		}
+	}
+}
+
+func TestProjectPathsFingerprintIgnoreLines(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "proj")
+	writeTestFiles(t, root, "main.go", "debug.log")
+
+	defer SetOrgDefaultIgnoreFn(orgDefaultIgnoreFn)
+	SetOrgDefaultIgnoreFn(nil)
+
+	fingerprint := func() string {
+		t.Helper()
+		res, err := projectPathsFingerprint(root, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	before := fingerprint()
+	if again := fingerprint(); again != before {
+		t.Fatalf("fingerprint changed without any changes: %s, %s", before, again)
+	}
+
+	// nothing under root changes, only the ancestor's rules
+	if err := os.WriteFile(filepath.Join(parent, ".plandexignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	afterAncestor := fingerprint()
+	if afterAncestor == before {
+		t.Error("fingerprint didn't change with an ancestor's .plandexignore")
+	}
+
+	SetOrgDefaultIgnoreFn(func() []string { return []string{"*.tmp"} })
+	if fingerprint() == afterAncestor {
+		t.Error("fingerprint didn't change with the org's default ignore rules")
+	}
+}
+
+func TestRebaseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		ok   bool
+	}{
+		{"node_modules", "node_modules", true},
+		{"build/", "build/", true},
+		{"!*.md", "!*.md", true},
+		{"apps/web/dist", "/dist", true},
+		{"/apps/web/dist/", "/dist/", true},
+		{"apps/*/dist", "/dist", true},
+		{"!apps/web/keep.txt", "!/keep.txt", true},
+		{"apps/**/dist", "**/dist", true},
+		{"apps/api/dist", "", false},
+		{"apps/web", "", false},
+		{"apps/", "apps/", true},
+		{"# comment", "", false},
+		{"  ", "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := rebaseIgnoreLine(test.line, "apps/web")
+		if got != test.want || ok != test.ok {
+			t.Errorf("rebaseIgnoreLine(%q) = %q, %v, want %q, %v", test.line, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestGetPathsSubmodule(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		// local submodule urls are refused by default since git 2.38.1
+		args = append([]string{"-c", "protocol.file.allow=always", "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	lib := t.TempDir()
+	writeTestFiles(t, lib, "lib.go", "internal/helper.go")
+	git(lib, "init", "-q")
+	git(lib, "add", ".")
+	git(lib, "commit", "-q", "-m", "lib")
+
+	root := t.TempDir()
+	writeTestFiles(t, root, "main.go")
+	git(root, "init", "-q")
+	git(root, "add", "main.go")
+	git(root, "submodule", "add", "-q", lib, "vendor/lib")
+
+	paths, err := GetPathsWithGitStatus(root, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"main.go", "vendor/lib/lib.go", "vendor/lib/internal/helper.go"} {
+		if !paths.IsActive(file) {
+			t.Errorf("%s should be active, got active paths %v", file, paths.ActivePaths)
+		}
+	}
+
+	tracked, _ := paths.GitTrackedCounts([]string{"vendor/lib/lib.go"})
+	if tracked != 1 {
+		t.Errorf("vendor/lib/lib.go should be tracked")
+	}
+
+	// the submodule's path is a dir, not a file
+	if paths.ActivePaths["vendor/lib"] && !paths.IsDir("vendor/lib") {
+		t.Errorf("vendor/lib shouldn't be listed as a file")
+	}
+	if paths.AllPaths["vendor/lib/.git"] {
+		t.Errorf("the submodule's .git file shouldn't be listed")
+	}
+}
+
+func TestGetPathsGitRetry(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	root := t.TempDir()
+	writeTestFiles(t, root, "main.go", ".gitignore", "debug.log")
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "-c", "user.name=test", "-c", "user.email=test@example.com", "init", "-q")
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	origFn, origBackoff := gitOutputFn, gitRetryBackoff
+	defer func() {
+		gitOutputFn, gitRetryBackoff = origFn, origBackoff
+	}()
+	gitRetryBackoff = time.Millisecond
+
+	lockErr := &exec.ExitError{Stderr: []byte("fatal: Unable to create '" + root + "/.git/index.lock': File exists.\n")}
+	otherErr := &exec.ExitError{Stderr: []byte("fatal: not a git repository\n")}
+
+	// fails each ls-files command the given number of times, then runs it
+	failing := func(failures int, failErr error) *sync.Map {
+		calls := &sync.Map{}
+		gitOutputFn = func(ctx context.Context, dir string, args ...string) ([]byte, error) {
+			key := strings.Join(args, " ")
+			n, _ := calls.LoadOrStore(key, new(int32))
+			if int(atomic.AddInt32(n.(*int32), 1)) <= failures {
+				return nil, failErr
+			}
+			return origFn(ctx, dir, args...)
+		}
+		return calls
+	}
+
+	callCount := func(calls *sync.Map, args string) int {
+		n, ok := calls.Load(args)
+		if !ok {
+			return 0
+		}
+		return int(atomic.LoadInt32(n.(*int32)))
+	}
+
+	t.Run("succeeds after a lock error", func(t *testing.T) {
+		calls := failing(1, lockErr)
+
+		paths, err := GetPathsWithGitStatus(root, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if callCount(calls, "ls-files --recurse-submodules") != 2 {
+			t.Errorf("expected ls-files to be retried once, got %d calls", callCount(calls, "ls-files --recurse-submodules"))
+		}
+		if paths.GitStatuses["main.go"] != GitPathUntracked {
+			t.Errorf("expected git statuses from ls-files, got %v", paths.GitStatuses)
+		}
+	})
+
+	t.Run("falls back to walking when git keeps failing", func(t *testing.T) {
+		calls := failing(100, lockErr)
+
+		paths, err := GetPathsWithGitStatus(root, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := callCount(calls, "ls-files --others --exclude-standard"); n != gitRetries+1 {
+			t.Errorf("expected %d attempts, got %d", gitRetries+1, n)
+		}
+		if !paths.IsActive("main.go") {
+			t.Errorf("main.go should be active, got %v", paths.ActivePaths)
+		}
+		// .gitignore is still applied by the walk
+		if paths.IsActive("debug.log") {
+			t.Errorf("debug.log should be ignored")
+		}
+		if paths.GitStatuses != nil {
+			t.Errorf("expected no git statuses without git, got %v", paths.GitStatuses)
+		}
+	})
+
+	t.Run("doesn't retry other errors", func(t *testing.T) {
+		calls := failing(100, otherErr)
+
+		paths, err := GetPaths(root, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := callCount(calls, "ls-files --recurse-submodules"); n != 1 {
+			t.Errorf("expected 1 attempt, got %d", n)
+		}
+		if !paths.IsActive("main.go") {
+			t.Errorf("main.go should be active, got %v", paths.ActivePaths)
+		}
+	})
+}
+
+func initGitRepo(t *testing.T, files ...string) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	root := t.TempDir()
+	writeTestFiles(t, root, files...)
+
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	return root
+}
+
+func writeGitAttributes(t *testing.T, dir, attrs string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte(attrs), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetExportIgnored(t *testing.T) {
+	t.Run("nul separated output", func(t *testing.T) {
+		// names with spaces and newlines only parse correctly with -z
+		files := []string{"a b.bin", "line\nbreak.bin", "keep.go"}
+		root := initGitRepo(t, files...)
+		writeGitAttributes(t, root, "*.bin export-ignore\n")
+
+		paths := map[string]bool{}
+		for _, file := range files {
+			paths[file] = true
+		}
+
+		got, err := getExportIgnored(context.Background(), root, root, paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]bool{"a b.bin": true, "line\nbreak.bin": true}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("parent dir", func(t *testing.T) {
+		root := initGitRepo(t, "sub/main.go", "sub/generated/gen.go", "sub/generated/nested/more.go", "sub/generated2/gen.go")
+		// the attributes are in the repo root, above the dir the paths are listed from
+		writeGitAttributes(t, root, "sub/generated export-ignore\n")
+
+		paths, err := GetPathsWithOpts(root, filepath.Join(root, "sub"), GetPathsOpts{ExportIgnore: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, file := range []string{"generated/gen.go", "generated/nested/more.go"} {
+			if paths.IsActive(file) {
+				t.Errorf("%s is under an export-ignored dir and shouldn't be active", file)
+			}
+			if paths.IgnoredPaths[file] != "export-ignore" {
+				t.Errorf("%s should be ignored for export-ignore, got %q", file, paths.IgnoredPaths[file])
+			}
+		}
+
+		for _, file := range []string{"main.go", "generated2/gen.go"} {
+			if !paths.IsActive(file) {
+				t.Errorf("%s should be active", file)
+			}
+		}
+	})
+
+	t.Run("no gitattributes", func(t *testing.T) {
+		root := initGitRepo(t, "main.go", "data.bin")
+
+		got, err := getExportIgnored(context.Background(), root, root, map[string]bool{"main.go": true, "data.bin": true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Errorf("got %v, want nothing export-ignored", got)
+		}
+	})
+}
+
+func TestFindPlandexWalksUp(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(filepath.Join(root, ".plandex-test"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultCwd, defaultName := Cwd, PlandexDirName
+	Cwd, PlandexDirName = nested, ".plandex-test"
+	defer func() { Cwd, PlandexDirName = defaultCwd, defaultName }()
+
+	plandexDir, projectRoot := findPlandex(nested)
+	if plandexDir != filepath.Join(root, ".plandex-test") || projectRoot != root {
+		t.Errorf("got plandex dir %q and project root %q, want the ancestor %q", plandexDir, projectRoot, root)
+	}
+
+	// the nearest project wins
+	if err := os.MkdirAll(filepath.Join(root, "a", ".plandex-test"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, projectRoot := findPlandex(nested); projectRoot != filepath.Join(root, "a") {
+		t.Errorf("got project root %q, want the nearest ancestor", projectRoot)
+	}
+
+	t.Setenv("PLANDEX_ROOT_CEILING", nested)
+	if plandexDir, projectRoot := findPlandex(nested); plandexDir != "" || projectRoot != "" {
+		t.Errorf("expected the search to stop at the ceiling, got %q and %q", plandexDir, projectRoot)
+	}
+}
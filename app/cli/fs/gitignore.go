@@ -0,0 +1,110 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// nestedGitIgnore applies the .gitignore files in a directory tree that isn't a git repo, the way git scopes them:
+// rules in a directory's .gitignore only apply to paths under that directory, relative to it.
+// Directories must be entered top-down, as WalkPaths does. Nothing under an ignored directory is included again, and
+// .gitignore files inside ignored directories aren't read, also as with git.
+// Deeper .gitignore files take precedence, so a '!' rule can re-include a file that a parent directory's .gitignore ignores.
+type nestedGitIgnore struct {
+	baseDir     string
+	byDir       map[string]*gitIgnoreFile
+	ignoredDirs map[string]bool
+}
+
+type gitIgnoreFile struct {
+	rules *ignore.GitIgnore
+	// just the '!' rules, without the '!', to tell a path this file re-includes from one it says nothing about
+	negated *ignore.GitIgnore
+}
+
+func newNestedGitIgnore(baseDir string) *nestedGitIgnore {
+	return &nestedGitIgnore{
+		baseDir:     baseDir,
+		byDir:       map[string]*gitIgnoreFile{},
+		ignoredDirs: map[string]bool{},
+	}
+}
+
+// enterDir reads the .gitignore in a directory, given relative to baseDir, unless the directory is ignored
+func (n *nestedGitIgnore) enterDir(dir string) error {
+	if n.ignoredDirs[dir] {
+		return nil
+	}
+
+	ignorePath := filepath.Join(n.baseDir, dir, ".gitignore")
+	bytes, err := os.ReadFile(ignorePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading %s: %s", ignorePath, err)
+	}
+
+	lines := strings.Split(string(bytes), "\n")
+
+	var negatedLines []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "!") {
+			negatedLines = append(negatedLines, line[1:])
+		}
+	}
+
+	n.byDir[dir] = &gitIgnoreFile{
+		rules:   ignore.CompileIgnoreLines(lines...),
+		negated: ignore.CompileIgnoreLines(negatedLines...),
+	}
+	return nil
+}
+
+// matches reports whether a path relative to baseDir is ignored. An ignored directory is remembered so its contents are ignored too.
+func (n *nestedGitIgnore) matches(path string, isDir bool) bool {
+	if path == "." {
+		return false
+	}
+
+	parent := filepath.Dir(path)
+	if n.ignoredDirs[parent] {
+		if isDir {
+			n.ignoredDirs[path] = true
+		}
+		return true
+	}
+
+	// the closest .gitignore with a rule for the path decides
+	for dir := parent; ; dir = filepath.Dir(dir) {
+		if file := n.byDir[dir]; file != nil {
+			rel, err := filepath.Rel(dir, path)
+			if err == nil {
+				if gitIgnoreMatches(file.rules, rel, isDir) {
+					if isDir {
+						n.ignoredDirs[path] = true
+					}
+					return true
+				}
+				if gitIgnoreMatches(file.negated, rel, isDir) {
+					return false
+				}
+			}
+		}
+
+		if dir == "." || dir == "/" {
+			break
+		}
+	}
+
+	return false
+}
+
+// directory rules like "build/" only match with the trailing slash
+func gitIgnoreMatches(rules *ignore.GitIgnore, rel string, isDir bool) bool {
+	return rules.MatchesPath(rel) || (isDir && rules.MatchesPath(rel+"/"))
+}
@@ -0,0 +1,101 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type cachedProjectPaths struct {
+	fingerprint string
+	paths       *ProjectPaths
+}
+
+var projectPathsCache = map[string]*cachedProjectPaths{}
+var projectPathsCacheMu sync.Mutex
+
+// GetProjectPathsCached is like GetProjectPaths, but reuses the paths from an earlier call in the same process while the project looks unchanged.
+// The cache is invalidated when any directory's mtime changes, which happens whenever a file is added, removed, or renamed,
+// or when the ignore lines that apply to the project (its own .plandexignore, an ancestor's, or the org defaults) or any .gitignore file change. The returned paths are shared, so callers must not modify them.
+// Use GetProjectPaths when the result must reflect changes that don't touch any of these, like a file becoming tracked by git.
+func GetProjectPathsCached(baseDir string) (*ProjectPaths, error) {
+	if ProjectRoot == "" {
+		return nil, fmt.Errorf("no project root found")
+	}
+
+	fingerprint, err := projectPathsFingerprint(baseDir, ProjectRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	key := ProjectRoot + "\x00" + baseDir
+
+	projectPathsCacheMu.Lock()
+	cached := projectPathsCache[key]
+	projectPathsCacheMu.Unlock()
+
+	if cached != nil && cached.fingerprint == fingerprint {
+		return cached.paths, nil
+	}
+
+	paths, err := GetPaths(baseDir, ProjectRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	projectPathsCacheMu.Lock()
+	projectPathsCache[key] = &cachedProjectPaths{fingerprint: fingerprint, paths: paths}
+	projectPathsCacheMu.Unlock()
+
+	return paths, nil
+}
+
+// projectPathsFingerprint hashes the mtime of every directory under baseDir along with the .plandexignore lines that apply
+// to currentDir and the contents of each .gitignore.
+// Only directories are stat'd, so it's much cheaper than listing the paths again.
+func projectPathsFingerprint(baseDir, currentDir string) (string, error) {
+	hash := sha256.New()
+
+	ignoreLines, _, err := getPlandexIgnoreLines(currentDir)
+	if err != nil {
+		return "", fmt.Errorf("error reading .plandexignore files: %s", err)
+	}
+	for _, line := range ignoreLines {
+		fmt.Fprintf(hash, "%s\x00", line)
+	}
+	hash.Write([]byte{0})
+
+	err = WalkPaths(baseDir, WalkOpts{}, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" || IsPlandexDirName(info.Name()) {
+				return filepath.SkipDir
+			}
+
+			fmt.Fprintf(hash, "%s\x00%d\x00", path, info.ModTime().UnixNano())
+			return nil
+		}
+
+		if info.Name() == ".gitignore" {
+			bytes, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "%s\x00%x\x00", path, sha256.Sum256(bytes))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("error checking for project changes: %s", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type WalkOpts struct {
+	// leave symlinked directories out entirely instead of walking into them
+	SkipSymlinkDirs bool
+}
+
+// WalkPaths is like filepath.Walk, but it walks into symlinked directories, reporting their contents under the link's path.
+// A symlinked directory whose target was already walked, or is inside a directory that was, is skipped so a link back up
+// the tree can't loop and the same files aren't listed twice. Symlinks to files, and broken symlinks, are reported as they are by filepath.Walk.
+func WalkPaths(root string, opts WalkOpts, fn filepath.WalkFunc) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	walked := []string{realRoot}
+
+	var walk func(dir, realDir string) error
+	walk = func(dir, realDir string) error {
+		return filepath.Walk(realDir, func(realPath string, info os.FileInfo, err error) error {
+			path := realPath
+			if realDir != dir {
+				rel, relErr := filepath.Rel(realDir, realPath)
+				if relErr != nil {
+					return relErr
+				}
+				path = filepath.Join(dir, rel)
+
+				// report a followed link under its own name rather than its target's
+				if rel == "." && info != nil {
+					info = renamedFileInfo{FileInfo: info, name: filepath.Base(dir)}
+				}
+			}
+
+			if err != nil || info.Mode()&os.ModeSymlink == 0 {
+				return fn(path, info, err)
+			}
+
+			targetInfo, statErr := os.Stat(realPath)
+			if statErr != nil || !targetInfo.IsDir() {
+				return fn(path, info, err)
+			}
+
+			if opts.SkipSymlinkDirs {
+				return nil
+			}
+
+			target, evalErr := filepath.EvalSymlinks(realPath)
+			if evalErr != nil {
+				return fn(path, info, evalErr)
+			}
+
+			for _, walkedDir := range walked {
+				if pathWithin(target, walkedDir) {
+					return nil
+				}
+			}
+			walked = append(walked, target)
+
+			return walk(path, target)
+		})
+	}
+
+	return walk(root, realRoot)
+}
+
+// pathWithin reports whether path is dir or somewhere under it. Both must be clean.
+func pathWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+type renamedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (info renamedFileInfo) Name() string {
+	return info.name
+}
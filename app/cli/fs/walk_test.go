@@ -0,0 +1,96 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func walkedPaths(t *testing.T, root string, opts WalkOpts) []string {
+	t.Helper()
+
+	var paths []string
+	err := WalkPaths(root, opts, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkPaths: %v", err)
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+func setupSymlinkTree(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, dir := range []string{"src", "shared"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"src/main.go", "shared/util.go"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a link back up to the root, and one to a directory outside it
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "lib.go"), []byte("package lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"src/loop": "..",
+		"vendored": outside,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	return root
+}
+
+func TestWalkPathsSymlinkLoop(t *testing.T) {
+	root := setupSymlinkTree(t)
+
+	got := walkedPaths(t, root, WalkOpts{})
+	want := []string{".", "shared", "shared/util.go", "src", "src/main.go", "vendored", "vendored/lib.go"}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWalkPathsSkipSymlinkDirs(t *testing.T) {
+	root := setupSymlinkTree(t)
+
+	got := walkedPaths(t, root, WalkOpts{SkipSymlinkDirs: true})
+	want := []string{".", "shared", "shared/util.go", "src", "src/main.go"}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
@@ -21,6 +21,9 @@ func GetContextTypeAndIcon(context *shared.Context) (string, string) {
 	case shared.ContextPipedDataType:
 		icon = "↔️ "
 		t = "piped"
+	case shared.ContextMapType:
+		icon = "🗺️ "
+		t = "map"
 	}
 
 	return t, icon
@@ -10,6 +10,7 @@ import (
 	"plandex/term"
 	"plandex/types"
 	"plandex/url"
+	"strconv"
 	"strings"
 
 	"github.com/fatih/color"
@@ -70,10 +71,12 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 
 	ignoredPaths := make(map[string]string)
 
+	var paths *fs.ProjectPaths
+
 	if len(inputFilePaths) > 0 {
 		baseDir := fs.GetBaseDirForFilePaths(inputFilePaths)
 
-		paths, err := fs.GetProjectPaths(baseDir)
+		paths, err = fs.GetProjectPathsWithOpts(baseDir, fs.GetPathsOpts{ExportIgnore: params.ExportIgnore, WithGitStatus: true, SkipSymlinkDirs: params.SkipSymlinkDirs})
 		if err != nil {
 			onErr(fmt.Errorf("failed to get project paths: %v", err))
 		}
@@ -92,13 +95,13 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 			for _, inputFilePath := range inputFilePaths {
 				// log.Println("inputFilePath", inputFilePath)
 
-				if _, ok := paths.ActivePaths[inputFilePath]; !ok {
+				if !paths.IsActive(inputFilePath) {
 					// log.Println("not active", inputFilePath)
 
-					if _, ok := paths.IgnoredPaths[inputFilePath]; ok {
+					if reason, ok := paths.IgnoredReason(inputFilePath); ok {
 						// log.Println("ignored", inputFilePath)
 
-						ignoredPaths[inputFilePath] = paths.IgnoredPaths[inputFilePath]
+						ignoredPaths[inputFilePath] = reason
 					}
 				} else {
 					// log.Println("active", inputFilePath)
@@ -122,19 +125,17 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 					if !params.ForceSkipIgnore {
 						var filteredPaths []string
 						for _, path := range flattenedPaths {
-							if _, ok := paths.ActivePaths[path]; ok {
+							if paths.IsActive(path) {
 								filteredPaths = append(filteredPaths, path)
 							} else {
-								if _, ok := paths.IgnoredPaths[path]; ok {
-									ignoredPaths[path] = paths.IgnoredPaths[path]
+								if reason, ok := paths.IgnoredReason(path); ok {
+									ignoredPaths[path] = reason
 								}
 							}
 						}
 						flattenedPaths = filteredPaths
 					}
 
-					body := strings.Join(flattenedPaths, "\n")
-
 					name := inputFilePath
 					if name == "." {
 						name = "cwd"
@@ -143,12 +144,14 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 						name = "parent"
 					}
 
+					// the server builds the body from the paths, so it's the same as any other client would produce
 					contextCh <- &shared.LoadContextParams{
 						ContextType:     shared.ContextDirectoryTreeType,
 						Name:            name,
-						Body:            body,
+						TreePaths:       flattenedPaths,
 						FilePath:        inputFilePath,
 						ForceSkipIgnore: params.ForceSkipIgnore,
+						NestedTrees:     params.NestedTrees,
 					}
 				}(inputFilePath)
 			}
@@ -162,11 +165,11 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 			if !params.ForceSkipIgnore {
 				var filteredPaths []string
 				for _, path := range flattenedPaths {
-					if _, ok := paths.ActivePaths[path]; ok {
+					if paths.IsActive(path) {
 						filteredPaths = append(filteredPaths, path)
 					} else {
-						if _, ok := paths.IgnoredPaths[path]; ok {
-							ignoredPaths[path] = paths.IgnoredPaths[path]
+						if reason, ok := paths.IgnoredReason(path); ok {
+							ignoredPaths[path] = reason
 						}
 					}
 				}
@@ -185,11 +188,34 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 					}
 					body := string(fileContent)
 
+					// with an explicit encoding the server decodes the raw bytes, since they may not survive as a json string
+					var rawBody []byte
+					if params.Encoding != "" {
+						rawBody = fileContent
+						body = ""
+					}
+
+					info, err := os.Stat(path)
+					if err != nil {
+						errCh <- fmt.Errorf("failed to stat the file %s: %v", path, err)
+						return
+					}
+
 					contextCh <- &shared.LoadContextParams{
 						ContextType: shared.ContextFileType,
 						Name:        path,
 						Body:        body,
+						RawBody:     rawBody,
+						Encoding:    params.Encoding,
 						FilePath:    path,
+						FileMode:    uint32(info.Mode().Perm()),
+
+						StripComments:      params.StripComments,
+						PreserveDocstrings: params.PreserveDocstrings,
+						// files have no source url of their own, so only resolve when a base is given
+						ResolveRelativeUrls: params.ResolveRelativeUrls && params.BaseUrl != "",
+						BaseUrl:             params.BaseUrl,
+						Minified:            params.Minified,
 					}
 				}(path)
 			}
@@ -199,24 +225,36 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 	if len(inputUrls) > 0 {
 		for _, u := range inputUrls {
 			go func(u string) {
-				body, err := url.FetchURLContent(u)
-				if err != nil {
-					errCh <- fmt.Errorf("failed to fetch content from URL %s: %v", u, err)
-					return
-				}
-
 				name := url.SanitizeURL(u)
 				// show the first 20 characters, then ellipsis then the last 20 characters of 'name'
 				if len(name) > 40 {
 					name = name[:20] + "⋯" + name[len(name)-20:]
 				}
 
-				contextCh <- &shared.LoadContextParams{
-					ContextType: shared.ContextURLType,
-					Name:        name,
-					Body:        body,
-					Url:         u,
+				context := &shared.LoadContextParams{
+					ContextType:         shared.ContextURLType,
+					Name:                name,
+					Url:                 u,
+					ResolveRelativeUrls: params.ResolveRelativeUrls,
+					BaseUrl:             params.BaseUrl,
 				}
+
+				// issues and pull requests are fetched by the server through the platform's api when there's a token
+				if token := url.IssueToken(u); token != "" {
+					context.IssueToken = token
+					contextCh <- context
+					return
+				}
+
+				body, contentType, err := url.FetchURLContent(u)
+				if err != nil {
+					errCh <- fmt.Errorf("failed to fetch content from URL %s: %v", u, err)
+					return
+				}
+
+				context.Body = body
+				context.ContentType = contentType
+				contextCh <- context
 			}(u)
 		}
 	}
@@ -233,7 +271,12 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 	filesToLoad := map[string]string{}
 	for _, context := range loadContextReq {
 		if context.ContextType == shared.ContextFileType {
-			filesToLoad[context.FilePath] = context.Body
+			body := context.Body
+			if context.RawBody != nil {
+				// a decoding error is reported by the server when loading
+				body, _ = shared.DecodeToUtf8(context.RawBody, context.Encoding)
+			}
+			filesToLoad[context.FilePath] = body
 		}
 	}
 
@@ -247,11 +290,16 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 		term.StopSpinner()
 		fmt.Println("🤷‍♂️ No context loaded")
 		if len(ignoredPaths) > 0 {
-			printIgnoredMsg()
+			printIgnoredMsg(params.ExportIgnore)
 		}
 		os.Exit(0)
 	}
 
+	for _, context := range loadContextReq {
+		context.EmptyBody = params.EmptyBody
+		context.Dedupe = params.Dedupe
+	}
+
 	res, apiErr := api.Client.LoadContext(CurrentPlanId, CurrentBranch, loadContextReq)
 
 	if apiErr != nil {
@@ -260,8 +308,15 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 
 	term.StopSpinner()
 
+	if res.ContextCountExceeded {
+		term.OutputErrorAndExit("Loading would bring this plan to %d contexts, which exceeds the limit of %d per plan. Remove contexts you no longer need, or load a directory tree instead of many small files.\n", res.NumContexts, res.MaxContexts)
+	}
+
 	if res.MaxTokensExceeded {
 		overage := res.TotalTokens - res.MaxTokens
+		if res.PlanMaxTokens > res.MaxTokens {
+			term.OutputErrorAndExit("Update would add %d 🪙 and exceed this branch's token limit (%d, plan limit %d) by %d 🪙\n", res.TokensAdded, res.MaxTokens, res.PlanMaxTokens, overage)
+		}
 		term.OutputErrorAndExit("Update would add %d 🪙 and exceed token limit (%d) by %d 🪙\n", res.TokensAdded, res.MaxTokens, overage)
 	}
 
@@ -278,12 +333,92 @@ func MustLoadContext(resources []string, params *types.LoadContextParams) {
 
 	fmt.Println("✅ " + res.Msg)
 
+	if res.MaxTokens > 0 {
+		if res.PlanMaxTokens > res.MaxTokens {
+			fmt.Printf("📏 Context limit for this branch → %d 🪙 (plan limit %d 🪙)\n", res.MaxTokens, res.PlanMaxTokens)
+		} else {
+			fmt.Printf("📏 Context limit for this plan → %d 🪙\n", res.MaxTokens)
+		}
+	}
+
+	if res.TokensSaved > 0 {
+		fmt.Printf("✂️  Stripping comments saved %d 🪙\n", res.TokensSaved)
+	}
+
+	for _, d := range res.Deminified {
+		fmt.Printf("🧹 Reformatted minified %s | %d 🪙 → %d 🪙\n", d.Name, d.TokensBefore, d.TokensAfter)
+	}
+
+	if len(res.SkippedEmpty) > 0 {
+		fmt.Println()
+		fmt.Println("ℹ️  " + color.New(color.FgWhite).Sprintf("Skipped empty contexts: %s. Use --empty allow to load them.", strings.Join(res.SkippedEmpty, ", ")))
+	}
+
+	if len(res.SkippedDuplicates) > 0 {
+		fmt.Println()
+		fmt.Println("ℹ️  " + color.New(color.FgWhite).Sprintf("Skipped contexts that are already loaded unchanged: %s", strings.Join(res.SkippedDuplicates, ", ")))
+	}
+
+	if len(res.SkippedNestedTrees) > 0 {
+		fmt.Println()
+		fmt.Println("ℹ️  " + color.New(color.FgWhite).Sprintf("Skipped directory trees already contained in loaded trees: %s", strings.Join(res.SkippedNestedTrees, ", ")))
+	}
+
+	for _, warning := range res.Warnings {
+		fmt.Println()
+		fmt.Println("⚠️  " + color.New(color.FgHiYellow).Sprint(warning))
+	}
+
 	if len(ignoredPaths) > 0 {
-		printIgnoredMsg()
+		printIgnoredMsg(params.ExportIgnore)
+	}
+
+	if paths != nil {
+		printUntrackedWarning(loadContextReq, paths)
+	}
+
+	if params.SuggestPairs {
+		printPairedFileSuggestions(res.PairedFiles, paths, params.ForceSkipIgnore)
 	}
 }
 
-func printIgnoredMsg() {
+// warn above this share of loaded files not committed to git. Set PLANDEX_UNTRACKED_WARN_SHARE to a value between 0 and 1 to change it, or 0 to disable the warning.
+const defaultUntrackedWarnShare = 0.5
+
+func printUntrackedWarning(loaded shared.LoadContextRequest, paths *fs.ProjectPaths) {
+	warnShare := defaultUntrackedWarnShare
+	if s := os.Getenv("PLANDEX_UNTRACKED_WARN_SHARE"); s != "" {
+		if v, err := strconv.ParseFloat(s, 64); err == nil && v >= 0 && v <= 1 {
+			warnShare = v
+		}
+	}
+	if warnShare == 0 {
+		return
+	}
+
+	var files []string
+	for _, context := range loaded {
+		if context.ContextType == shared.ContextFileType {
+			files = append(files, context.FilePath)
+		}
+	}
+
+	tracked, untracked := paths.GitTrackedCounts(files)
+	total := tracked + untracked
+	if total == 0 || float64(untracked)/float64(total) <= warnShare {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("⚠️  " + color.New(color.FgHiYellow).Sprintf("%d of %d loaded files aren't committed to git. Consider committing them so the plan's context is reproducible.", untracked, total))
+}
+
+func printIgnoredMsg(exportIgnore bool) {
+	reason := ".gitignore or .plandexignore"
+	if exportIgnore {
+		reason = ".gitignore, .plandexignore, or .gitattributes export-ignore"
+	}
+
 	fmt.Println()
-	fmt.Println("ℹ️  " + color.New(color.FgWhite).Sprint("Due to .gitignore or .plandexignore, some paths weren't loaded.\nUse --force / -f to load ignored paths."))
+	fmt.Println("ℹ️  " + color.New(color.FgWhite).Sprintf("Due to %s, some paths weren't loaded.\nUse --force / -f to load ignored paths.", reason))
 }
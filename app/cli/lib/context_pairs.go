@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"plandex/fs"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/plandex/plandex/shared"
+)
+
+type pairSuggestion struct {
+	path      string
+	forPath   string
+	numTokens int64
+}
+
+// printPairedFileSuggestions shows the test/source counterparts of loaded files the server suggested, that exist locally and aren't ignored.
+// Nothing is loaded automatically.
+func printPairedFileSuggestions(pairedFiles []shared.PairedFileSuggestion, paths *fs.ProjectPaths, forceSkipIgnore bool) {
+	var suggestions []*pairSuggestion
+	for _, paired := range pairedFiles {
+		if !forceSkipIgnore && paths != nil && !paths.IsActive(paired.Path) {
+			continue
+		}
+
+		bytes, err := os.ReadFile(paired.Path)
+		if err != nil {
+			continue
+		}
+
+		numTokens, err := shared.GetNumTokens(string(bytes))
+		if err != nil {
+			continue
+		}
+
+		suggestions = append(suggestions, &pairSuggestion{
+			path:      paired.Path,
+			forPath:   paired.ForPath,
+			numTokens: numTokens,
+		})
+	}
+
+	if len(suggestions) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("🧪 " + color.New(color.Bold).Sprint("Paired files you may also want to load"))
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"File", "Pairs With", "🪙"})
+	table.SetAutoWrapText(false)
+
+	var suggestedPaths []string
+	for _, suggestion := range suggestions {
+		suggestedPaths = append(suggestedPaths, suggestion.path)
+		table.Append([]string{suggestion.path, suggestion.forPath, strconv.FormatInt(suggestion.numTokens, 10)})
+	}
+
+	table.Render()
+
+	fmt.Println()
+	fmt.Println(color.New(color.FgHiCyan).Sprint("plandex load " + strings.Join(suggestedPaths, " ")))
+}
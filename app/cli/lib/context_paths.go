@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"plandex/fs"
 	"plandex/types"
 	"strings"
 	"sync"
@@ -20,7 +21,7 @@ func ParseInputPaths(fileOrDirPaths []string, params *types.LoadContextParams) (
 		go func(p string) {
 			defer wg.Done()
 
-			err := filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			err := fs.WalkPaths(p, fs.WalkOpts{SkipSymlinkDirs: params.SkipSymlinkDirs}, func(path string, info os.FileInfo, err error) error {
 				if err != nil {
 					return err
 				}
@@ -32,7 +33,7 @@ func ParseInputPaths(fileOrDirPaths []string, params *types.LoadContextParams) (
 				}
 
 				if info.IsDir() {
-					if info.Name() == ".git" || strings.Index(info.Name(), ".plandex") == 0 {
+					if info.Name() == ".git" || strings.Index(info.Name(), ".plandex") == 0 || fs.IsPlandexDirName(info.Name()) {
 						return filepath.SkipDir
 					}
 
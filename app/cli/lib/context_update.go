@@ -119,17 +119,35 @@ func MustUpdateContext(maybeContexts []*shared.Context) {
 
 	fmt.Println("✅ " + updateRes.Msg)
 
+	if updateRes.MaxTokens > 0 {
+		fmt.Printf("📏 Context limit for this plan → %d 🪙\n", updateRes.MaxTokens)
+	}
+
 }
 
+type contextUpdateMode int
+
+const (
+	contextUpdateCheck contextUpdateMode = iota
+	contextUpdateApply
+	// sends the update to be previewed, which returns the commit message it would create without storing anything
+	contextUpdatePreview
+)
+
 func UpdateContext(maybeContexts []*shared.Context) (*types.ContextOutdatedResult, error) {
-	return checkOutdatedAndMaybeUpdateContext(true, maybeContexts)
+	return checkOutdatedAndMaybeUpdateContext(contextUpdateApply, maybeContexts)
 }
 
 func CheckOutdatedContext(maybeContexts []*shared.Context) (*types.ContextOutdatedResult, error) {
-	return checkOutdatedAndMaybeUpdateContext(false, maybeContexts)
+	return checkOutdatedAndMaybeUpdateContext(contextUpdateCheck, maybeContexts)
 }
 
-func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.Context) (*types.ContextOutdatedResult, error) {
+// PreviewUpdateContext checks for outdated context and returns the commit the update would create as Msg, without updating anything
+func PreviewUpdateContext(maybeContexts []*shared.Context) (*types.ContextOutdatedResult, error) {
+	return checkOutdatedAndMaybeUpdateContext(contextUpdatePreview, maybeContexts)
+}
+
+func checkOutdatedAndMaybeUpdateContext(mode contextUpdateMode, maybeContexts []*shared.Context) (*types.ContextOutdatedResult, error) {
 	var contexts []*shared.Context
 
 	if maybeContexts == nil {
@@ -146,13 +164,15 @@ func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.C
 
 	req := shared.UpdateContextRequest{}
 	var updatedContexts []*shared.Context
-	var tokenDiffsById = map[string]int{}
+	var tokenDiffsById = map[string]int64{}
 	var numFiles int
 	var numUrls int
 	var numTrees int
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	contextsById := map[string]*shared.Context{}
+	// issue and pull request contexts, which the server fetches through the platform's api
+	var issueContextIds []string
 
 	var paths *fs.ProjectPaths
 	var hasDirectoryTreeWithIgnoredPaths bool
@@ -189,13 +209,26 @@ func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.C
 					return
 				}
 
-				hash := sha256.Sum256(fileContent)
+				body := string(fileContent)
+				var rawBody []byte
+				if context.Encoding != "" {
+					decoded, err := shared.DecodeToUtf8(fileContent, context.Encoding)
+					if err != nil {
+						errs = append(errs, fmt.Errorf("failed to decode the file %s as %s: %v", context.FilePath, context.Encoding, err))
+						return
+					}
+					body = decoded
+					rawBody = fileContent
+				}
+
+				// the server stores the transformed body, so compare against the same transformation
+				storedBody := context.StoredBody(body)
+
+				hash := sha256.Sum256([]byte(storedBody))
 				sha := hex.EncodeToString(hash[:])
 
 				if sha != context.Sha {
-					body := string(fileContent)
-
-					numTokens, err := shared.GetNumTokens(body)
+					numTokens, err := shared.GetNumTokens(storedBody)
 					if err != nil {
 						errs = append(errs, fmt.Errorf("failed to get the number of tokens in the file %s: %v", context.FilePath, err))
 						return
@@ -206,7 +239,8 @@ func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.C
 					updatedContexts = append(updatedContexts, context)
 
 					req[context.Id] = &shared.UpdateContextParams{
-						Body: body,
+						Body:    body,
+						RawBody: rawBody,
 					}
 				}
 			}(context)
@@ -236,14 +270,14 @@ func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.C
 
 					var filteredPaths []string
 					for _, path := range flattenedPaths {
-						if _, ok := paths.ActivePaths[path]; ok {
+						if paths.IsActive(path) {
 							filteredPaths = append(filteredPaths, path)
 						}
 					}
 					flattenedPaths = filteredPaths
 				}
 
-				body := strings.Join(flattenedPaths, "\n")
+				body := shared.BuildDirectoryTree(flattenedPaths)
 				bytes := []byte(body)
 
 				hash := sha256.Sum256(bytes)
@@ -265,11 +299,14 @@ func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.C
 				}
 			}(context)
 
+		} else if context.ContextType == shared.ContextURLType && context.UrlSource != "" {
+			issueContextIds = append(issueContextIds, context.Id)
+
 		} else if context.ContextType == shared.ContextURLType {
 			wg.Add(1)
 			go func(context *shared.Context) {
 				defer wg.Done()
-				body, err := url.FetchURLContent(context.Url)
+				body, _, err := url.FetchURLContent(context.Url)
 
 				mu.Lock()
 				defer mu.Unlock()
@@ -279,11 +316,13 @@ func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.C
 					return
 				}
 
-				hash := sha256.Sum256([]byte(body))
+				storedBody := context.StoredBody(body)
+
+				hash := sha256.Sum256([]byte(storedBody))
 				sha := hex.EncodeToString(hash[:])
 
 				if sha != context.Sha {
-					numTokens, err := shared.GetNumTokens(body)
+					numTokens, err := shared.GetNumTokens(storedBody)
 					if err != nil {
 						errs = append(errs, fmt.Errorf("failed to get the number of tokens in the file %s: %v", context.FilePath, err))
 						return
@@ -308,13 +347,58 @@ func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.C
 	}
 
 	var msg string
+	var maxTokens int64
 	var hasConflicts bool
 
+	// only refreshed when applying, since the server stores what it fetches. Re-fetching picks up new comments.
+	var refreshMsg string
+	if mode == contextUpdateApply && len(issueContextIds) > 0 {
+		refreshRes, apiErr := api.Client.RefreshUrlContext(CurrentPlanId, CurrentBranch, shared.RefreshUrlContextRequest{
+			Ids:         issueContextIds,
+			IssueTokens: url.IssueTokens(),
+		})
+		if apiErr != nil {
+			return nil, fmt.Errorf("failed to refresh issue contexts: %v", apiErr)
+		}
+
+		for _, id := range refreshRes.ChangedIds {
+			numUrls++
+			updatedContexts = append(updatedContexts, contextsById[id])
+			tokenDiffsById[id] = refreshRes.TokenDiffsById[id]
+		}
+		refreshMsg = refreshRes.Msg
+
+		for _, id := range issueContextIds {
+			if errMsg, failed := refreshRes.FetchErrorsById[id]; failed {
+				refreshMsg += fmt.Sprintf("\n⚠️  Couldn't refresh %s: %s", contextsById[id].Name, errMsg)
+			}
+		}
+		refreshMsg = strings.TrimPrefix(refreshMsg, "\n")
+	}
+
 	if len(req) == 0 {
+		if refreshMsg == "" {
+			refreshMsg = "Context is up to date"
+		}
 		return &types.ContextOutdatedResult{
-			Msg: "Context is up to date",
+			Msg:             refreshMsg,
+			UpdatedContexts: updatedContexts,
+			TokenDiffsById:  tokenDiffsById,
+			NumUrls:         numUrls,
 		}, nil
-	} else if doUpdate {
+	} else if mode == contextUpdatePreview {
+		res, apiErr := api.Client.PreviewUpdateContext(CurrentPlanId, CurrentBranch, req)
+		if apiErr != nil {
+			return nil, fmt.Errorf("failed to preview context update: %v", apiErr)
+		}
+		msg = res.Msg
+		maxTokens = res.MaxTokens
+		if res.MaxTokensExceeded {
+			msg = fmt.Sprintf("The update would bring context to %d 🪙, which exceeds the limit of %d 🪙", res.TotalTokens, res.MaxTokens)
+		} else if msg == "" {
+			msg = "Context is up to date"
+		}
+	} else if mode == contextUpdateApply {
 		filesToLoad := map[string]string{}
 		for id := range req {
 			context := contextsById[id]
@@ -334,6 +418,39 @@ func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.C
 			return nil, fmt.Errorf("failed to update context: %v", apiErr)
 		}
 		msg = res.Msg
+		maxTokens = res.MaxTokens
+		if msg == "" {
+			// the server found every body already matched what's stored
+			msg = "Context is up to date"
+		}
+
+		// the server applies what it can and reports the rest
+		if len(res.FailedById) > 0 {
+			var applied []*shared.Context
+			for _, context := range updatedContexts {
+				errMsg, failed := res.FailedById[context.Id]
+				if !failed {
+					applied = append(applied, context)
+					continue
+				}
+
+				msg += fmt.Sprintf("\n⚠️  Couldn't update %s: %s", context.Name, errMsg)
+				delete(tokenDiffsById, context.Id)
+				switch context.ContextType {
+				case shared.ContextFileType:
+					numFiles--
+				case shared.ContextURLType:
+					numUrls--
+				case shared.ContextDirectoryTreeType:
+					numTrees--
+				}
+			}
+			updatedContexts = applied
+		}
+	}
+
+	if refreshMsg != "" {
+		msg = refreshMsg + "\n" + msg
 	}
 
 	if hasConflicts {
@@ -354,6 +471,7 @@ func checkOutdatedAndMaybeUpdateContext(doUpdate bool, maybeContexts []*shared.C
 		NumFiles:        numFiles,
 		NumUrls:         numUrls,
 		NumTrees:        numTrees,
+		MaxTokens:       maxTokens,
 	}, nil
 }
 
@@ -374,11 +492,11 @@ func tableForContextOutdated(updateRes *types.ContextOutdatedResult) string {
 		t, icon := GetContextTypeAndIcon(context)
 		diff := tokenDiffsById[context.Id]
 
-		diffStr := "+" + strconv.Itoa(diff)
+		diffStr := "+" + strconv.FormatInt(diff, 10)
 		tableColor := tablewriter.FgHiGreenColor
 
 		if diff < 0 {
-			diffStr = strconv.Itoa(diff)
+			diffStr = strconv.FormatInt(diff, 10)
 			tableColor = tablewriter.FgHiRedColor
 		}
 
@@ -11,13 +11,20 @@ import (
 	"plandex/lib"
 	"plandex/plan_exec"
 	"plandex/term"
+	"strings"
+	"sync"
 
 	"github.com/plandex/plandex/shared"
 )
 
 func init() {
+	if err := fs.InitErr(); err != nil {
+		term.OutputErrorAndExit("Error initializing: %v", err)
+	}
+
 	// inter-package dependency injections to avoid circular imports
 	auth.SetApiClient(api.Client)
+	fs.SetOrgDefaultIgnoreFn(orgDefaultIgnoreLines)
 	lib.SetBuildPlanInlineFn(func(maybeContexts []*shared.Context) (bool, error) {
 		return plan_exec.Build(plan_exec.ExecParams{
 			CurrentPlanId: lib.CurrentPlanId,
@@ -39,9 +46,41 @@ func init() {
 	// Set the output of the logger to the file
 	log.SetOutput(file)
 
+	// load the encoder in the background, after fs init has pointed its cache at the plandex cache dir, so it's
+	// usually ready by the time a command counts tokens
+	go func() {
+		if err := shared.WarmTokenizer(); err != nil {
+			log.Printf("Error warming tokenizer: %v\n", err)
+		}
+	}()
+
 	// log.Println("Starting Plandex - logging initialized")
 }
 
+var orgDefaultIgnoreOnce sync.Once
+var orgDefaultIgnore []string
+
+// fetched at most once per command, and only if already signed in to an org
+func orgDefaultIgnoreLines() []string {
+	orgDefaultIgnoreOnce.Do(func() {
+		if auth.Current == nil || auth.Current.OrgId == "" {
+			return
+		}
+
+		body, apiErr := api.Client.GetOrgDefaultIgnore()
+		if apiErr != nil {
+			log.Printf("Error getting org default .plandexignore: %v\n", apiErr.Msg)
+			return
+		}
+
+		if strings.TrimSpace(body) != "" {
+			orgDefaultIgnore = strings.Split(body, "\n")
+		}
+	})
+
+	return orgDefaultIgnore
+}
+
 func main() {
 	checkForUpgrade()
 
@@ -30,7 +30,7 @@ func Build(params ExecParams, buildBg bool) (bool, error) {
 		return false, nil
 	}
 
-	paths, err := fs.GetProjectPaths(fs.GetBaseDirForContexts(contexts))
+	paths, err := fs.GetProjectPathsCached(fs.GetBaseDirForContexts(contexts))
 
 	if err != nil {
 		return false, fmt.Errorf("error getting project paths: %v", err)
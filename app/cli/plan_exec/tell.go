@@ -41,7 +41,7 @@ func TellPlan(
 		os.Exit(0)
 	}
 
-	paths, err := fs.GetProjectPaths(fs.GetBaseDirForContexts(contexts))
+	paths, err := fs.GetProjectPathsCached(fs.GetBaseDirForContexts(contexts))
 
 	if err != nil {
 		term.OutputErrorAndExit("Error getting project paths: %v", err)
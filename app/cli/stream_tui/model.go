@@ -41,7 +41,7 @@ type streamUIModel struct {
 	spinner    spinner.Model
 
 	building       bool
-	tokensByPath   map[string]int
+	tokensByPath   map[string]int64
 	finishedByPath map[string]bool
 
 	ready  bool
@@ -55,7 +55,7 @@ type streamUIModel struct {
 	missingFileSelectedIdx int
 	promptedMissingFile    bool
 	missingFileContent     string
-	missingFileTokens      int
+	missingFileTokens      int64
 
 	prompt string
 
@@ -154,7 +154,7 @@ func initialModel(prestartReply, prompt string, buildOnly bool) *streamUIModel {
 			),
 		},
 
-		tokensByPath:   make(map[string]int),
+		tokensByPath:   make(map[string]int64),
 		finishedByPath: make(map[string]bool),
 		spinner:        s,
 		atScrollBottom: true,
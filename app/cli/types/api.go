@@ -29,6 +29,7 @@ type ApiClient interface {
 	DeleteUser(userId string) *shared.ApiError
 
 	ListOrgRoles() ([]*shared.OrgRole, *shared.ApiError)
+	GetOrgDefaultIgnore() (string, *shared.ApiError)
 
 	InviteUser(req shared.InviteRequest) *shared.ApiError
 	ListPendingInvites() ([]*shared.Invite, *shared.ApiError)
@@ -48,6 +49,7 @@ type ApiClient interface {
 	GetCurrentBranchByPlanId(projectId string, req shared.GetCurrentBranchByPlanIdRequest) (map[string]*shared.Branch, *shared.ApiError)
 
 	GetPlan(planId string) (*shared.Plan, *shared.ApiError)
+	GetPlanLockStatus(planId string) (*shared.PlanLockStatusResponse, *shared.ApiError)
 	CreatePlan(projectId string, req shared.CreatePlanRequest) (*shared.CreatePlanResponse, *shared.ApiError)
 
 	TellPlan(planId, branch string, req shared.TellPlanRequest, onStreamPlan OnStreamPlan) *shared.ApiError
@@ -68,8 +70,19 @@ type ApiClient interface {
 
 	LoadContext(planId, branch string, req shared.LoadContextRequest) (*shared.LoadContextResponse, *shared.ApiError)
 	UpdateContext(planId, branch string, req shared.UpdateContextRequest) (*shared.UpdateContextResponse, *shared.ApiError)
+	PreviewUpdateContext(planId, branch string, req shared.UpdateContextRequest) (*shared.UpdateContextResponse, *shared.ApiError)
+	RefreshUrlContext(planId, branch string, req shared.RefreshUrlContextRequest) (*shared.RefreshUrlContextResponse, *shared.ApiError)
 	DeleteContext(planId, branch string, req shared.DeleteContextRequest) (*shared.DeleteContextResponse, *shared.ApiError)
 	ListContext(planId, branch string) ([]*shared.Context, *shared.ApiError)
+	GetContext(planId, branch, ref string) (*shared.Context, *shared.ApiError)
+	TagContexts(planId, branch string, req shared.TagContextsRequest) (*shared.TagContextsResponse, *shared.ApiError)
+	MoveContexts(planId, branch string, req shared.MoveContextRequest) (*shared.MoveContextResponse, *shared.ApiError)
+	ReplaceContexts(planId, branch string, req shared.ReplaceContextRequest) (*shared.ReplaceContextResponse, *shared.ApiError)
+	GetContextAllowance(planId, branch string, req shared.ContextAllowanceRequest) (*shared.ContextAllowanceResponse, *shared.ApiError)
+	CountContextTokens(planId, branch string, req shared.ContextTokensRequest) (*shared.ContextTokensResponse, *shared.ApiError)
+	ContextsExist(planId, branch string, req shared.ContextExistsRequest) (shared.ContextExistsResponse, *shared.ApiError)
+	RecomputeContextTokens(planId, branch string) (*shared.RecomputeContextTokensResponse, *shared.ApiError)
+	PruneContext(planId, branch string, req shared.PruneContextRequest) (*shared.PruneContextResponse, *shared.ApiError)
 
 	ListConvo(planId, branch string) ([]*shared.ConvoMessage, *shared.ApiError)
 	ListLogs(planId, branch string) (*shared.LogResponse, *shared.ApiError)
@@ -19,19 +19,33 @@ type ClientAuth struct {
 }
 
 type LoadContextParams struct {
-	Note            string
-	Recursive       bool
-	NamesOnly       bool
-	ForceSkipIgnore bool
+	Note                string
+	Recursive           bool
+	NamesOnly           bool
+	ForceSkipIgnore     bool
+	StripComments       bool
+	PreserveDocstrings  bool
+	ResolveRelativeUrls bool
+	BaseUrl             string
+	Encoding            string
+	SuggestPairs        bool
+	ExportIgnore        bool
+	NestedTrees         shared.NestedTreesMode
+	Minified            shared.MinifiedMode
+	EmptyBody           shared.EmptyBodyMode
+	Dedupe              bool
+	SkipSymlinkDirs     bool
 }
 
 type ContextOutdatedResult struct {
 	Msg             string
 	UpdatedContexts []*shared.Context
-	TokenDiffsById  map[string]int
+	TokenDiffsById  map[string]int64
 	NumFiles        int
 	NumUrls         int
 	NumTrees        int
+	// the plan's context token limit, if the server reported it
+	MaxTokens int64
 }
 
 const (
@@ -1,70 +1,40 @@
 package url
 
 import (
-	"errors"
-	"io"
-	"net/http"
 	"net/url"
-	"plandex/term"
+	"os"
 	"regexp"
 	"strings"
-	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/plandex/plandex/shared"
 )
 
-const (
-	// Constants for fetchURLContent function
-	maxRedirections    = 10
-	httpTimeout        = 30 * time.Second
-	maxContentSizeInMB = 10
-)
-
-func FetchURLContent(url string) (string, error) {
-	client := &http.Client{
-		Timeout: httpTimeout,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= maxRedirections {
-				return errors.New("stopped after too many redirects")
-			}
-			return nil
-		},
-	}
-
-	resp, err := client.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", errors.New("non-2xx HTTP response status: " + resp.Status)
-	}
-
-	// Limit the response reader to a maximum amount
-	limitedReader := io.LimitReader(resp.Body, maxContentSizeInMB*1024*1024)
-
-	content, err := io.ReadAll(limitedReader)
-	if err != nil {
-		return "", err
-	}
+// FetchURLContent fetches the body for a url context as a page, along with the Content-Type it was served with.
+func FetchURLContent(u string) (string, string, error) {
+	return shared.FetchURLContent(u, nil)
+}
 
-	contentType := resp.Header.Get("Content-Type")
-	if strings.Contains(contentType, "text/html") {
-		return ExtractTextualContent(string(content)), nil
-	} else {
-		return string(content), nil
+// IssueToken returns GITHUB_TOKEN or GITLAB_TOKEN for a github or gitlab issue or pull/merge request url, or an empty string
+// for other urls or if the token isn't set. The server fetches the url through the platform's api with it, which includes
+// the description and comments. The token is sent with the request and isn't stored.
+func IssueToken(u string) string {
+	ref, ok := shared.ParseIssueUrl(u)
+	if !ok {
+		return ""
 	}
+	return IssueTokens()[ref.Platform]
 }
 
-func ExtractTextualContent(htmlContent string) string {
-	r := strings.NewReader(htmlContent)
-	doc, err := goquery.NewDocumentFromReader(r)
-	if err != nil {
-		term.OutputErrorAndExit("Failed to parse HTML: %v", err)
+// IssueTokens returns the set GITHUB_TOKEN and GITLAB_TOKEN by platform, for refreshing contexts fetched through their apis
+func IssueTokens() map[shared.IssuePlatform]string {
+	tokens := map[shared.IssuePlatform]string{}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		tokens[shared.IssuePlatformGithub] = token
 	}
-
-	return doc.Text()
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		tokens[shared.IssuePlatformGitlab] = token
+	}
+	return tokens
 }
 
 func SanitizeURL(url string) string {
@@ -16,8 +16,8 @@ func CreateBranch(plan *Plan, parentBranch *Branch, name string, tx *sql.Tx) (*B
 	RETURNING id, created_at, updated_at`
 
 	var (
-		contextTokens  int
-		convoTokens    int
+		contextTokens  int64
+		convoTokens    int64
 		parentBranchId *string
 	)
 
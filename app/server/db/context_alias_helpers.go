@@ -0,0 +1,141 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/plandex/plandex/shared"
+)
+
+// reserveContextAliases reserves numAliases sequential aliases for contexts being loaded into a plan branch and returns the first.
+// Contexts created before aliases existed are given aliases first, in creation order, so every context has one and an alias never changes once assigned.
+// Aliases come from the plan's context_alias_seq, which only increases, so an alias is never reused after its context is removed, on any branch.
+// It may write context meta, so it must be called under a write lock.
+func reserveContextAliases(orgId, planId string, numAliases int) (int, error) {
+	contexts, err := GetPlanContexts(orgId, planId, false)
+	if err != nil {
+		return 0, fmt.Errorf("error getting contexts: %v", err)
+	}
+
+	highest := 0
+	numUnaliased := 0
+	for _, context := range contexts {
+		if context.Alias > highest {
+			highest = context.Alias
+		}
+		if context.Alias == 0 {
+			numUnaliased++
+		}
+	}
+
+	next, err := reservePlanContextAliases(planId, highest, numUnaliased+numAliases)
+	if err != nil {
+		return 0, err
+	}
+
+	// GetPlanContexts sorts by CreatedAt
+	for _, context := range contexts {
+		if context.Alias != 0 {
+			continue
+		}
+
+		context.Alias = next
+		next++
+
+		err = StoreContextMeta(context)
+		if err != nil {
+			return 0, fmt.Errorf("error storing context meta: %v", err)
+		}
+	}
+
+	return next, nil
+}
+
+// reservePlanContextAliases advances a plan's alias sequence by num and returns the first alias reserved. The sequence starts
+// past highestInUse, which covers plans whose contexts were given aliases before the sequence was stored.
+func reservePlanContextAliases(planId string, highestInUse, num int) (int, error) {
+	var seq int
+	err := Conn.QueryRow(
+		"UPDATE plans SET context_alias_seq = GREATEST(context_alias_seq, $2) + $3 WHERE id = $1 RETURNING context_alias_seq",
+		planId, highestInUse, num,
+	).Scan(&seq)
+
+	if err != nil {
+		return 0, fmt.Errorf("error reserving context aliases: %v", err)
+	}
+
+	return seq - num + 1, nil
+}
+
+// MatchesRef reports whether a context id or alias ref like '#3' refers to this context
+func (context *Context) MatchesRef(ref string) bool {
+	if ref == context.Id {
+		return true
+	}
+
+	alias, ok := shared.ParseContextAlias(ref)
+	return ok && context.Alias != 0 && alias == context.Alias
+}
+
+// ResolveContextRefAtCommit finds the context stored at a commit with the given id or alias ref, or returns nil if there isn't one
+func ResolveContextRefAtCommit(orgId, planId, commit, ref string, includeBody bool) (*Context, error) {
+	if _, ok := shared.ParseContextAlias(ref); !ok {
+		// ids are uuids, which also keeps refs from naming anything outside the context dir
+		if _, err := uuid.Parse(ref); err != nil {
+			return nil, nil
+		}
+
+		return GetContextAtCommit(orgId, planId, commit, ref, includeBody)
+	}
+
+	contexts, err := GetPlanContextsAtCommit(orgId, planId, commit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting contexts: %v", err)
+	}
+
+	for _, context := range contexts {
+		if context.MatchesRef(ref) {
+			if !includeBody {
+				return context, nil
+			}
+			return GetContextAtCommit(orgId, planId, commit, context.Id, true)
+		}
+	}
+
+	return nil, nil
+}
+
+// InRefs reports whether a set of ids and alias refs, like a delete request's, includes this context
+func (context *Context) InRefs(refs map[string]bool) bool {
+	if _, ok := refs[context.Id]; ok {
+		return true
+	}
+
+	if context.Alias == 0 {
+		return false
+	}
+
+	_, ok := refs[shared.ContextAliasRef(context.Alias)]
+	return ok
+}
+
+// UnmatchedRefs returns the ids and alias refs in a set that don't refer to any of the contexts, sorted
+func UnmatchedRefs(contexts []*Context, refs map[string]bool) []string {
+	var unmatched []string
+	for ref := range refs {
+		found := false
+		for _, context := range contexts {
+			if context.MatchesRef(ref) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, ref)
+		}
+	}
+
+	sort.Strings(unmatched)
+	return unmatched
+}
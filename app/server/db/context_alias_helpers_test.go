@@ -0,0 +1,60 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmatchedRefs(t *testing.T) {
+	contexts := []*Context{
+		{Id: "a1b2c3d4-0000-0000-0000-000000000001", Alias: 1},
+		{Id: "a1b2c3d4-0000-0000-0000-000000000002", Alias: 2},
+		// loaded before aliases existed and not yet backfilled
+		{Id: "a1b2c3d4-0000-0000-0000-000000000003"},
+	}
+
+	refs := map[string]bool{
+		"a1b2c3d4-0000-0000-0000-000000000001": true,
+		"#2":                                   true,
+		"a1b2c3d4-0000-0000-0000-000000000003": true,
+		"a1b2c3d4-0000-0000-0000-000000000009": true,
+		"#7":                                   true,
+		"#0":                                   true,
+	}
+
+	var removed []string
+	for _, context := range contexts {
+		if context.InRefs(refs) {
+			removed = append(removed, context.Id)
+		}
+	}
+
+	wantRemoved := []string{
+		"a1b2c3d4-0000-0000-0000-000000000001",
+		"a1b2c3d4-0000-0000-0000-000000000002",
+		"a1b2c3d4-0000-0000-0000-000000000003",
+	}
+	if !reflect.DeepEqual(removed, wantRemoved) {
+		t.Errorf("matched %v, want %v", removed, wantRemoved)
+	}
+
+	got := UnmatchedRefs(contexts, refs)
+	want := []string{"#0", "#7", "a1b2c3d4-0000-0000-0000-000000000009"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unmatched %v, want %v", got, want)
+	}
+}
+
+func TestUnmatchedRefsAllMissing(t *testing.T) {
+	contexts := []*Context{{Id: "a1b2c3d4-0000-0000-0000-000000000001", Alias: 1}}
+
+	got := UnmatchedRefs(contexts, map[string]bool{"#4": true, "stale-id": true})
+	want := []string{"#4", "stale-id"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unmatched %v, want %v", got, want)
+	}
+
+	if got := UnmatchedRefs(contexts, nil); got != nil {
+		t.Errorf("unmatched %v for no refs, want nil", got)
+	}
+}
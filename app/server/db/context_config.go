@@ -0,0 +1,145 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// Limits applied when loading, updating, and listing contexts.
+// Each can be overridden with the corresponding env var; 0 disables a cap.
+var (
+	// Directory trees above this many tokens produce a warning in the load response
+	TreeWarnTokens = int64(envInt("PLANDEX_TREE_WARN_TOKENS", 10000))
+
+	// Directory trees above this many tokens are rejected
+	TreeMaxTokens = int64(envInt("PLANDEX_TREE_MAX_TOKENS", 0))
+
+	// Contexts above this many tokens are flagged as oversized when listed
+	ContextOversizedTokens = int64(envInt("PLANDEX_CONTEXT_OVERSIZED_TOKENS", 20000))
+
+	// Context lists requested with bodies are rejected when the bodies add up to more than this many bytes
+	ListBodiesMaxBytes = envInt("PLANDEX_LIST_BODIES_MAX_BYTES", 10*1024*1024)
+
+	// Load and update requests with more than this many contexts are rejected
+	MaxContextsPerRequest = envInt("PLANDEX_MAX_CONTEXTS_PER_REQUEST", 1000)
+
+	// Loads are rejected when they'd leave a plan with more than this many contexts, however few tokens they have.
+	// Orgs can set their own cap.
+	MaxContextsPerPlan = envInt("PLANDEX_MAX_CONTEXTS_PER_PLAN", 500)
+
+	// Context bodies larger than this many bytes are rejected before they're tokenized
+	MaxContextBodyBytes = envInt("PLANDEX_MAX_CONTEXT_BODY_BYTES", 5*1024*1024)
+
+	// Context bodies with a null byte, or where more than this percent of the first 8000 bytes isn't text, are rejected as binary
+	BinaryMaxNonTextPercent = envInt("PLANDEX_BINARY_MAX_NON_TEXT_PERCENT", 10)
+
+	// At most this many contexts in a batch are read, tokenized, or stored at once
+	ContextConcurrency = envInt("PLANDEX_CONTEXT_CONCURRENCY", 10)
+
+	// Responses to requests sent with an Idempotency-Key are replayed for this many hours
+	IdempotencyKeyTtlHours = envInt("PLANDEX_IDEMPOTENCY_KEY_TTL_HOURS", 24)
+
+	// Context responses smaller than this many bytes aren't gzipped, even when the client accepts it
+	GzipMinBytes = envInt("PLANDEX_GZIP_MIN_BYTES", 1024)
+
+	// Gzipped request bodies to the context routes are rejected once they decompress past this many bytes
+	GzipRequestMaxBytes = envInt("PLANDEX_GZIP_REQUEST_MAX_BYTES", 100*1024*1024)
+
+	// Tarball uploads are rejected past this many bytes as sent, this many bytes once extracted, or this many entries,
+	// so a small archive can't expand into an unbounded amount of work
+	TarballMaxBytes          = envInt("PLANDEX_TARBALL_MAX_BYTES", 50*1024*1024)
+	TarballMaxExtractedBytes = envInt("PLANDEX_TARBALL_MAX_EXTRACTED_BYTES", 200*1024*1024)
+	TarballMaxEntries        = envInt("PLANDEX_TARBALL_MAX_ENTRIES", 5000)
+
+	// At most this many urls are fetched at once when the server refreshes url contexts
+	UrlFetchConcurrency = envInt("PLANDEX_URL_FETCH_CONCURRENCY", 4)
+)
+
+// Hosts the server may fetch urls from on a user's behalf, as comma-separated lists. A listed host also covers its subdomains.
+// With an allowlist, only hosts on it are fetched. The denylist applies either way.
+var (
+	UrlAllowHosts = envList("PLANDEX_URL_ALLOW_HOSTS")
+	UrlDenyHosts  = envList("PLANDEX_URL_DENY_HOSTS")
+
+	// Private ips and cidr ranges, comma-separated, that those fetches may still reach, like an internal docs server
+	UrlAllowPrivateNets = envList("PLANDEX_URL_ALLOW_PRIVATE_NETS")
+)
+
+func checkContextsPerRequest(n int) error {
+	if MaxContextsPerRequest > 0 && n > MaxContextsPerRequest {
+		return &ContextRequestError{
+			Msg: fmt.Sprintf("request has %d contexts, which exceeds the limit of %d per request. Split it into smaller requests.", n, MaxContextsPerRequest),
+		}
+	}
+	return nil
+}
+
+// checkContextBody rejects a body that's too large or looks binary, naming the context it belongs to
+func checkContextBody(name, body string) error {
+	if MaxContextBodyBytes > 0 && len(body) > MaxContextBodyBytes {
+		return &ContextRequestError{
+			Msg: fmt.Sprintf("%s is %d bytes, which exceeds the limit of %d bytes per context", name, len(body), MaxContextBodyBytes),
+		}
+	}
+
+	if BinaryMaxNonTextPercent > 0 && shared.LooksBinary(body, BinaryMaxNonTextPercent) {
+		return &ContextRequestError{
+			Msg: fmt.Sprintf("%s looks like binary content. Only text can be loaded into context.", name),
+		}
+	}
+
+	return nil
+}
+
+// contextLimiter bounds how many per-context goroutines run at once. A nil limiter doesn't limit.
+type contextLimiter chan struct{}
+
+func newContextLimiter() contextLimiter {
+	if ContextConcurrency <= 0 {
+		return nil
+	}
+	return make(contextLimiter, ContextConcurrency)
+}
+
+func (l contextLimiter) acquire() {
+	if l != nil {
+		l <- struct{}{}
+	}
+}
+
+func (l contextLimiter) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+func envInt(name string, defaultVal int) int {
+	s := os.Getenv(name)
+	if s == "" {
+		return defaultVal
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		log.Printf("Invalid value for %s: %s | using default %d\n", name, s, defaultVal)
+		return defaultVal
+	}
+
+	return n
+}
+
+func envList(name string) []string {
+	var res []string
+	for _, s := range strings.Split(os.Getenv(name), ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
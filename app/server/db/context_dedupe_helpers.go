@@ -0,0 +1,48 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/plandex/plandex/shared"
+)
+
+type contextDedupeKey struct {
+	contextType shared.ContextType
+	// file path, or url for url contexts
+	source string
+	sha    string
+}
+
+func dedupeKeyFor(contextType shared.ContextType, filePath, url, sha string) contextDedupeKey {
+	source := filePath
+	if contextType == shared.ContextURLType {
+		source = url
+	}
+	return contextDedupeKey{contextType: contextType, source: source, sha: sha}
+}
+
+// existingDedupeKeys returns the dedupe keys of a plan's loaded contexts, or nil if no context in the request asked to be deduped
+func existingDedupeKeys(orgId, planId string, req shared.LoadContextRequest) (map[contextDedupeKey]bool, error) {
+	dedupe := false
+	for _, context := range req {
+		if context.Dedupe {
+			dedupe = true
+			break
+		}
+	}
+	if !dedupe {
+		return nil, nil
+	}
+
+	existing, err := GetPlanContexts(orgId, planId, false)
+	if err != nil {
+		return nil, fmt.Errorf("error getting contexts: %v", err)
+	}
+
+	keys := make(map[contextDedupeKey]bool, len(existing))
+	for _, context := range existing {
+		keys[dedupeKeyFor(context.ContextType, context.FilePath, context.Url, context.Sha)] = true
+	}
+
+	return keys, nil
+}
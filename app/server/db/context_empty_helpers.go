@@ -0,0 +1,21 @@
+package db
+
+import "github.com/plandex/plandex/shared"
+
+// splitEmptyContexts separates contexts with empty bodies from the rest of a load request, unless they were loaded with EmptyBodyAllow.
+// It returns the contexts to load and the names of the skipped ones.
+// Bodies are checked as sent, after any decoding but before transforms like comment stripping.
+func splitEmptyContexts(req shared.LoadContextRequest) (shared.LoadContextRequest, []string) {
+	var toLoad shared.LoadContextRequest
+	var skipped []string
+
+	for _, context := range req {
+		if context.EmptyBody != shared.EmptyBodyAllow && shared.IsEmptyContextBody(context.Body) {
+			skipped = append(skipped, context.Name)
+			continue
+		}
+		toLoad = append(toLoad, context)
+	}
+
+	return toLoad, skipped
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func TestSplitEmptyContexts(t *testing.T) {
+	req := shared.LoadContextRequest{
+		{ContextType: shared.ContextFileType, Name: "main.go", FilePath: "main.go", Body: "package main\n"},
+		{ContextType: shared.ContextFileType, Name: "empty.go", FilePath: "empty.go", Body: ""},
+		{ContextType: shared.ContextFileType, Name: "blank.txt", FilePath: "blank.txt", Body: " \n\t\n"},
+		{ContextType: shared.ContextFileType, Name: "keep.txt", FilePath: "keep.txt", Body: "", EmptyBody: shared.EmptyBodyAllow},
+		{ContextType: shared.ContextFileType, Name: "skip.txt", FilePath: "skip.txt", Body: "", EmptyBody: shared.EmptyBodySkip},
+		{ContextType: shared.ContextNoteType, Name: "note", Body: "remember this"},
+	}
+
+	toLoad, skipped := splitEmptyContexts(req)
+
+	var loadedNames []string
+	for _, context := range toLoad {
+		loadedNames = append(loadedNames, context.Name)
+	}
+
+	wantLoaded := []string{"main.go", "keep.txt", "note"}
+	if !reflect.DeepEqual(loadedNames, wantLoaded) {
+		t.Errorf("loaded %v, want %v", loadedNames, wantLoaded)
+	}
+
+	wantSkipped := []string{"empty.go", "blank.txt", "skip.txt"}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("skipped %v, want %v", skipped, wantSkipped)
+	}
+}
+
+func TestSplitEmptyContextsAllEmpty(t *testing.T) {
+	req := shared.LoadContextRequest{
+		{ContextType: shared.ContextFileType, Name: "a.txt", FilePath: "a.txt", Body: ""},
+		{ContextType: shared.ContextFileType, Name: "b.txt", FilePath: "b.txt", Body: "\n"},
+	}
+
+	toLoad, skipped := splitEmptyContexts(req)
+
+	if len(toLoad) != 0 {
+		t.Errorf("expected nothing to load, got %d contexts", len(toLoad))
+	}
+	if len(skipped) != 2 {
+		t.Errorf("expected 2 skipped contexts, got %v", skipped)
+	}
+}
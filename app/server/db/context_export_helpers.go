@@ -0,0 +1,226 @@
+package db
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+)
+
+const ContextExportManifestName = "manifest.json"
+
+// bodies are under their own dir so no context's name can collide with the manifest
+const contextExportDir = "contexts"
+
+type ContextExportParams struct {
+	OrgId      string
+	PlanId     string
+	BranchName string
+	// contexts to export, without bodies. Each body is read just before it's written, so the archive is never held in memory.
+	Contexts    []*Context
+	TotalTokens int64
+	// read bodies from this commit rather than the working tree, if set
+	Commit string
+}
+
+// WriteContextExport writes a gzipped tar of params.Contexts to w: a manifest.json with each context's metadata and where
+// its body is in the archive, followed by one file per body. The manifest comes first so a reader knows what's in an
+// archive before extracting it.
+func WriteContextExport(w io.Writer, params ContextExportParams) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	names := contextExportNames(params.Contexts)
+	exportedAt := time.Now().UTC()
+
+	manifest := shared.ContextExportManifest{
+		PlanId:      params.PlanId,
+		Branch:      params.BranchName,
+		ExportedAt:  exportedAt,
+		TotalTokens: params.TotalTokens,
+		Contexts:    make([]*shared.ContextExportEntry, 0, len(params.Contexts)),
+	}
+	for _, context := range params.Contexts {
+		apiContext := context.ToApi()
+		apiContext.Body = ""
+		manifest.Contexts = append(manifest.Contexts, &shared.ContextExportEntry{
+			Path:    names[context.Id],
+			Context: apiContext,
+		})
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling manifest: %v", err)
+	}
+
+	err = writeTarFile(tw, ContextExportManifestName, manifestBytes, 0644, exportedAt)
+	if err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+
+	for _, context := range params.Contexts {
+		var withBody *Context
+		var err error
+		if params.Commit == "" {
+			withBody, err = GetContext(params.OrgId, params.PlanId, context.Id, true)
+		} else {
+			withBody, err = GetContextAtCommit(params.OrgId, params.PlanId, params.Commit, context.Id, true)
+			if err == nil && withBody == nil {
+				err = fmt.Errorf("not found at commit %s", params.Commit)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error getting context %s: %v", context.Id, err)
+		}
+
+		mode := int64(0644)
+		if context.FileMode != 0 {
+			mode = int64(context.FileMode & 0777)
+		}
+
+		err = writeTarFile(tw, names[context.Id], []byte(withBody.Body), mode, context.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("error writing context %s: %v", context.Id, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error closing tar: %v", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("error closing gzip: %v", err)
+	}
+
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, body []byte, mode int64, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     mode,
+		Size:     int64(len(body)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(body)
+	return err
+}
+
+// contextExportNames gives each context a path in the archive from its file path, url, or name, falling back to its id.
+// Names are made safe to extract anywhere. Two contexts never get the same name, even on a case-insensitive filesystem,
+// and no name is also used as another's parent dir. A context whose name is taken gets its id added to it instead.
+func contextExportNames(contexts []*Context) map[string]string {
+	namesById := make(map[string]string, len(contexts))
+	files := map[string]bool{}
+	dirs := map[string]bool{}
+
+	available := func(name string) bool {
+		key := strings.ToLower(name)
+		if files[key] || dirs[key] {
+			return false
+		}
+		for dir := path.Dir(key); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if files[dir] {
+				return false
+			}
+		}
+		return true
+	}
+
+	claim := func(name string) {
+		key := strings.ToLower(name)
+		files[key] = true
+		for dir := path.Dir(key); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	for _, context := range contexts {
+		base := sanitizeExportPath(contextExportSource(context))
+		if base == "" {
+			base = context.Id
+		}
+
+		candidates := []string{base}
+		ext := path.Ext(base)
+		// dotfiles like .gitignore have no extension to keep
+		if ext == path.Base(base) {
+			ext = ""
+		}
+		candidates = append(candidates, strings.TrimSuffix(base, ext)+"~"+context.Id+ext, context.Id)
+
+		name := ""
+		for _, candidate := range candidates {
+			candidate = path.Join(contextExportDir, candidate)
+			if available(candidate) {
+				name = candidate
+				break
+			}
+		}
+		if name == "" {
+			// only possible if another context's name uses this one's id, so the id with a counter is free
+			for i := 2; ; i++ {
+				candidate := path.Join(contextExportDir, fmt.Sprintf("%s~%d", context.Id, i))
+				if available(candidate) {
+					name = candidate
+					break
+				}
+			}
+		}
+
+		claim(name)
+		namesById[context.Id] = name
+	}
+
+	return namesById
+}
+
+func contextExportSource(context *Context) string {
+	if context.FilePath != "" {
+		return context.FilePath
+	}
+	if context.Url != "" {
+		u := context.Url
+		if _, rest, ok := strings.Cut(u, "://"); ok {
+			u = rest
+		}
+		return u
+	}
+	return context.Name
+}
+
+// sanitizeExportPath makes p a relative path with no empty, '.', or '..' segments and no characters that some filesystems
+// or archive tools reject. It's empty if nothing usable is left.
+func sanitizeExportPath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+
+	var segments []string
+	for _, segment := range strings.Split(p, "/") {
+		segment = strings.Map(func(r rune) rune {
+			if r < 0x20 || r == 0x7f || strings.ContainsRune(`:*?"<>|`, r) {
+				return '_'
+			}
+			return r
+		}, segment)
+		segment = strings.TrimRight(strings.TrimSpace(segment), ".")
+
+		if segment == "" {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	return strings.Join(segments, "/")
+}
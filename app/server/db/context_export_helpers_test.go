@@ -0,0 +1,120 @@
+package db
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func TestContextExportNames(t *testing.T) {
+	contexts := []*Context{
+		{Id: "1", FilePath: "src/main.go"},
+		{Id: "2", FilePath: "src/Main.go"},
+		{Id: "3", FilePath: "../../etc/passwd"},
+		{Id: "4", Url: "https://example.com/docs?page=1"},
+		{Id: "5", Name: "notes: todo"},
+		{Id: "6", FilePath: "src/main.go/inner.go"},
+		{Id: "7"},
+		{Id: "8", FilePath: `C:\repo\.gitignore`},
+		{Id: "9", FilePath: "repo/.gitignore"},
+		{Id: "10", Name: "/"},
+	}
+
+	names := contextExportNames(contexts)
+
+	want := map[string]string{
+		"1": "contexts/src/main.go",
+		"2": "contexts/src/Main~2.go",
+		"3": "contexts/etc/passwd",
+		"4": "contexts/example.com/docs_page=1",
+		"5": "contexts/notes_ todo",
+		// its parent dir is a file, so only its id is free
+		"6":  "contexts/6",
+		"7":  "contexts/7",
+		"8":  "contexts/C_/repo/.gitignore",
+		"9":  "contexts/repo/.gitignore",
+		"10": "contexts/10",
+	}
+	for id, name := range want {
+		if names[id] != name {
+			t.Errorf("context %s: got name %q, want %q", id, names[id], name)
+		}
+	}
+}
+
+func TestWriteContextExport(t *testing.T) {
+	defaultBaseDir := BaseDir
+	BaseDir = t.TempDir()
+	defer func() { BaseDir = defaultBaseDir }()
+
+	var contexts []*Context
+	for _, context := range []*Context{
+		{OrgId: "org", PlanId: "plan", ContextType: shared.ContextFileType, FilePath: "a.txt", Body: "first", NumTokens: 1},
+		{OrgId: "org", PlanId: "plan", ContextType: shared.ContextNoteType, Name: "note", Body: "second", NumTokens: 2, Pinned: true},
+	} {
+		if err := StoreContext(context); err != nil {
+			t.Fatal(err)
+		}
+		contexts = append(contexts, context)
+	}
+
+	var buf bytes.Buffer
+	err := WriteContextExport(&buf, ContextExportParams{OrgId: "org", PlanId: "plan", BranchName: "main", Contexts: contexts, TotalTokens: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	files := map[string]string{}
+	var order []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(body)
+		order = append(order, header.Name)
+	}
+
+	if len(order) != 3 || order[0] != ContextExportManifestName {
+		t.Fatalf("expected the manifest first and one file per context, got %v", order)
+	}
+
+	var manifest shared.ContextExportManifest
+	if err := json.Unmarshal([]byte(files[ContextExportManifestName]), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.TotalTokens != 3 || manifest.Branch != "main" || len(manifest.Contexts) != 2 {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+
+	for i, want := range []string{"first", "second"} {
+		entry := manifest.Contexts[i]
+		if files[entry.Path] != want {
+			t.Errorf("got body %q at %s, want %q", files[entry.Path], entry.Path, want)
+		}
+		if entry.Context.Body != "" {
+			t.Errorf("expected no body in the manifest for %s", entry.Path)
+		}
+	}
+	if !manifest.Contexts[1].Context.Pinned {
+		t.Errorf("expected context metadata in the manifest")
+	}
+}
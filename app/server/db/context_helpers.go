@@ -5,17 +5,106 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"github.com/plandex/plandex/shared"
 )
 
+// ContextRequestError is returned when a context request is rejected because of what the client sent rather than a server failure
+type ContextRequestError struct {
+	Msg string
+}
+
+func (e *ContextRequestError) Error() string {
+	return e.Msg
+}
+
+// ContextNotFoundError is returned when a request refers to context ids that don't exist in the plan, e.g. because the client's view is stale
+type ContextNotFoundError struct {
+	Ids []string
+}
+
+func (e *ContextNotFoundError) Error() string {
+	return "contexts not found: " + strings.Join(e.Ids, ", ")
+}
+
+// ContextConflictError rejects an atomic update when a context's sha doesn't match the update's ExpectedSha
+type ContextConflictError struct {
+	// the stored sha of each conflicting context
+	ShasById map[string]string
+}
+
+func (e *ContextConflictError) Ids() []string {
+	var ids []string
+	for id := range e.ShasById {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (e *ContextConflictError) Error() string {
+	return "contexts changed since they were read: " + strings.Join(e.Ids(), ", ")
+}
+
+// ContextsExistAtCommit reports whether each id has a context stored at a commit. Only the commit's context dir listing is read, not any context files.
+func ContextsExistAtCommit(orgId, planId, commit string, ids []string) (map[string]bool, error) {
+	err := checkContextsPerRequest(len(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		res[id] = false
+	}
+
+	if commit == "" {
+		return res, nil
+	}
+
+	storedIds, err := contextIdsAtCommit(getPlanDir(orgId, planId), commit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range storedIds {
+		if _, requested := res[id]; requested {
+			res[id] = true
+		}
+	}
+
+	return res, nil
+}
+
+// countPlanContexts counts a plan's contexts from the context dir listing, without reading them
+func countPlanContexts(orgId, planId string) (int, error) {
+	files, err := os.ReadDir(getPlanContextDir(orgId, planId))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error reading context dir: %v", err)
+	}
+
+	n := 0
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".meta") {
+			n++
+		}
+	}
+	return n, nil
+}
+
 func GetPlanContexts(orgId, planId string, includeBody bool) ([]*Context, error) {
 	var contexts []*Context
 	contextDir := getPlanContextDir(orgId, planId)
@@ -74,7 +163,7 @@ func GetContext(orgId, planId, contextId string, includeBody bool) (*Context, er
 
 	metaBytes, err := os.ReadFile(metaPath)
 	if err != nil {
-		return nil, fmt.Errorf("error reading context meta file: %v", err)
+		return nil, fmt.Errorf("error reading context meta file: %w", err)
 	}
 
 	var context Context
@@ -98,6 +187,34 @@ func GetContext(orgId, planId, contextId string, includeBody bool) (*Context, er
 	return &context, nil
 }
 
+// missingContextIds returns the sorted ids that are neither in contextsById nor stored in the plan
+func missingContextIds(orgId, planId string, ids []string, contextsById map[string]*Context) ([]string, error) {
+	contextDir := getPlanContextDir(orgId, planId)
+
+	var missing []string
+	for _, id := range ids {
+		if contextsById[id] != nil {
+			continue
+		}
+
+		// an id with a separator can't name a context, and mustn't resolve to a file outside the context dir
+		if id == "" || strings.ContainsAny(id, `/\`) {
+			missing = append(missing, id)
+			continue
+		}
+
+		_, err := os.Stat(filepath.Join(contextDir, id+".meta"))
+		if os.IsNotExist(err) {
+			missing = append(missing, id)
+		} else if err != nil {
+			return nil, fmt.Errorf("error checking context meta file: %v", err)
+		}
+	}
+
+	sort.Strings(missing)
+	return missing, nil
+}
+
 func ContextRemove(contexts []*Context) error {
 	// remove files
 	numFiles := len(contexts) * 2
@@ -170,6 +287,140 @@ func StoreContext(context *Context) error {
 	return nil
 }
 
+// StoreContextMeta writes only the meta file for an existing context, leaving its body untouched
+func StoreContextMeta(context *Context) error {
+	if context.Id == "" {
+		return fmt.Errorf("context id is required")
+	}
+
+	contextDir := getPlanContextDir(context.OrgId, context.PlanId)
+	metaPath := filepath.Join(contextDir, context.Id+".meta")
+
+	context.UpdatedAt = time.Now().UTC()
+
+	body := context.Body
+	context.Body = ""
+	data, err := json.MarshalIndent(context, "", "  ")
+	context.Body = body
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal context meta: %v", err)
+	}
+
+	if err = os.WriteFile(metaPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write context meta to file %s: %v", metaPath, err)
+	}
+
+	return nil
+}
+
+type TagContextsParams struct {
+	Req    *shared.TagContextsRequest
+	OrgId  string
+	PlanId string
+}
+
+// TagContexts adds or removes a tag on every context matching the request's path pattern and type filters.
+// Returns only the contexts that were actually changed.
+func TagContexts(params TagContextsParams) ([]*Context, error) {
+	req := params.Req
+
+	var pathPattern *shared.PathPattern
+	if req.PathPattern != "" {
+		var err error
+		pathPattern, err = shared.CompilePathPattern(req.PathPattern)
+		if err != nil {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("invalid path pattern %s: %v", req.PathPattern, err)}
+		}
+	}
+
+	contexts, err := GetPlanContexts(params.OrgId, params.PlanId, false)
+	if err != nil {
+		return nil, fmt.Errorf("error getting contexts: %v", err)
+	}
+
+	var tagged []*Context
+	for _, context := range contexts {
+		if !contextMatchesFilter(context, pathPattern, req.Types) {
+			continue
+		}
+
+		hasTag := slices.Contains(context.Tags, req.Tag)
+
+		if req.Remove {
+			if !hasTag {
+				continue
+			}
+			context.Tags = slices.DeleteFunc(context.Tags, func(tag string) bool {
+				return tag == req.Tag
+			})
+		} else {
+			if hasTag {
+				continue
+			}
+			context.Tags = append(context.Tags, req.Tag)
+			sort.Strings(context.Tags)
+		}
+
+		err = StoreContextMeta(context)
+		if err != nil {
+			return nil, fmt.Errorf("error storing context meta: %v", err)
+		}
+
+		tagged = append(tagged, context)
+	}
+
+	return tagged, nil
+}
+
+// contextMatchesFilter checks a context against an optional compiled path glob and an optional set of types.
+// Contexts without a file path never match a path pattern.
+func contextMatchesFilter(context *Context, pathPattern *shared.PathPattern, types []shared.ContextType) bool {
+	if len(types) > 0 && !slices.Contains(types, context.ContextType) {
+		return false
+	}
+
+	if pathPattern != nil {
+		return context.FilePath != "" && pathPattern.Match(context.FilePath)
+	}
+
+	return true
+}
+
+type ContextListFilter struct {
+	// any of these types, or all types if empty
+	Types []shared.ContextType
+	// a plain prefix of the file path. Contexts without a file path never match a non-empty prefix.
+	PathPrefix string
+	// the exact source the contexts were loaded by, or any source if empty
+	Source string
+}
+
+// FilterContexts returns the contexts matching a list filter, keeping their order. The input isn't modified.
+func FilterContexts(contexts []*Context, filter ContextListFilter) []*Context {
+	if len(filter.Types) == 0 && filter.PathPrefix == "" && filter.Source == "" {
+		return contexts
+	}
+
+	prefix := filepath.ToSlash(filter.PathPrefix)
+
+	var res []*Context
+	for _, context := range contexts {
+		if len(filter.Types) > 0 && !slices.Contains(filter.Types, context.ContextType) {
+			continue
+		}
+		if prefix != "" && (context.FilePath == "" || !strings.HasPrefix(filepath.ToSlash(context.FilePath), prefix)) {
+			continue
+		}
+		if filter.Source != "" && context.Source != filter.Source {
+			continue
+		}
+		res = append(res, context)
+	}
+
+	return res
+}
+
 type LoadContextsParams struct {
 	Req                      *shared.LoadContextRequest
 	OrgId                    string
@@ -179,6 +430,150 @@ type LoadContextsParams struct {
 	SkipConflictInvalidation bool
 }
 
+// decodeContextBodies builds the bodies of trees sent as path lists and decodes each entry sent with an encoding into Body,
+// then checks every body's size and content
+func decodeContextBodies(req shared.LoadContextRequest) error {
+	err := buildTreeBodies(req)
+	if err != nil {
+		return err
+	}
+
+	for _, context := range req {
+		if context.Encoding == "" {
+			continue
+		}
+
+		encoding, ok := shared.NormalizeEncoding(context.Encoding)
+		if !ok {
+			return &ContextRequestError{
+				Msg: fmt.Sprintf("unsupported encoding '%s' for %s. Supported encodings include %s", context.Encoding, context.Name, strings.Join(shared.SupportedEncodings, ", ")),
+			}
+		}
+
+		raw := context.RawBody
+		if raw == nil {
+			raw = []byte(context.Body)
+		}
+
+		body, err := shared.DecodeToUtf8(raw, encoding)
+		if err != nil {
+			return &ContextRequestError{
+				Msg: fmt.Sprintf("failed to decode %s as %s: %v", context.Name, encoding, err),
+			}
+		}
+
+		context.Encoding = encoding
+		context.Body = body
+		context.RawBody = nil
+	}
+
+	for _, context := range req {
+		err := checkContextBody(context.Name, context.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const maxContextSourceLength = 100
+
+// checkContextSources trims each entry's source and rejects any that's too long or would break up a log line or header
+func checkContextSources(req shared.LoadContextRequest) error {
+	for _, context := range req {
+		context.Source = strings.TrimSpace(context.Source)
+
+		if len(context.Source) > maxContextSourceLength {
+			return &ContextRequestError{Msg: fmt.Sprintf("source for %s is longer than %d characters", context.Name, maxContextSourceLength)}
+		}
+
+		if strings.IndexFunc(context.Source, unicode.IsControl) != -1 {
+			return &ContextRequestError{Msg: fmt.Sprintf("source for %s has control characters", context.Name)}
+		}
+	}
+	return nil
+}
+
+// inferContextTypes sets the type of each entry that didn't specify one, along with the path or url it was inferred from,
+// and returns what was inferred
+func inferContextTypes(req shared.LoadContextRequest) ([]shared.InferredContextType, error) {
+	var inferredTypes []shared.InferredContextType
+	for _, context := range req {
+		if context.ContextType != "" {
+			continue
+		}
+
+		contextType := shared.InferContextType(context)
+		if contextType == "" {
+			return nil, &ContextRequestError{
+				Msg: "can't infer the type of a context with no type, path, url, or body",
+			}
+		}
+		context.ContextType = contextType
+
+		switch contextType {
+		case shared.ContextURLType:
+			if context.Url == "" {
+				context.Url = context.FilePath
+			}
+			if context.Url == "" {
+				context.Url = context.Name
+			}
+			context.FilePath = ""
+		case shared.ContextFileType, shared.ContextDirectoryTreeType:
+			if context.FilePath == "" {
+				context.FilePath = context.Name
+			}
+		}
+		if context.Name == "" {
+			context.Name = context.Url + context.FilePath
+		}
+
+		inferredTypes = append(inferredTypes, shared.InferredContextType{
+			Name:        context.Name,
+			ContextType: contextType,
+		})
+	}
+
+	return inferredTypes, nil
+}
+
+// countLoadTokens counts the tokens in each context's body, at most ContextConcurrency at once
+func countLoadTokens(paramsByTempId map[string]*shared.LoadContextParams, numTokensByTempId map[string]int64) error {
+	var mu sync.Mutex
+	// buffered so goroutines still finish and release the limiter if an early error stops the receive loop
+	errCh := make(chan error, len(paramsByTempId))
+	limiter := newContextLimiter()
+
+	for tempId, params := range paramsByTempId {
+		go func(tempId string, body string) {
+			limiter.acquire()
+			defer limiter.release()
+
+			numTokens, err := shared.GetNumTokens(body)
+			if err != nil {
+				errCh <- fmt.Errorf("error getting num tokens: %v", err)
+				return
+			}
+
+			mu.Lock()
+			numTokensByTempId[tempId] = numTokens
+			mu.Unlock()
+			errCh <- nil
+		}(tempId, params.Body)
+	}
+
+	for range paramsByTempId {
+		err := <-errCh
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func LoadContexts(params LoadContextsParams) (*shared.LoadContextResponse, []*Context, error) {
 	req := params.Req
 	orgId := params.OrgId
@@ -187,6 +582,80 @@ func LoadContexts(params LoadContextsParams) (*shared.LoadContextResponse, []*Co
 	branchName := params.BranchName
 	userId := params.UserId
 
+	err := checkContextsPerRequest(len(*req))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inferredTypes, err := inferContextTypes(*req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = checkContextSources(*req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = decodeContextBodies(*req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toLoad, skippedEmpty := splitEmptyContexts(*req)
+	if len(toLoad) == 0 {
+		return nil, nil, &ContextRequestError{
+			Msg: fmt.Sprintf("nothing to load, since every context is empty: %s. Use --empty allow to load empty contexts.", strings.Join(skippedEmpty, ", ")),
+		}
+	}
+	req = &toLoad
+
+	var warnings []string
+	var skippedNestedTrees []string
+
+	hasTrees := false
+	for _, context := range *req {
+		if context.ContextType == shared.ContextDirectoryTreeType && context.NestedTrees != shared.NestedTreesAllow {
+			hasTrees = true
+			break
+		}
+	}
+
+	if hasTrees {
+		existingTrees, err := getPlanTreeContexts(orgId, planId)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting directory tree contexts: %v", err)
+		}
+
+		nested := findNestedTrees(existingTrees, *req)
+
+		if len(nested) > 0 {
+			var toLoad shared.LoadContextRequest
+			for _, context := range *req {
+				coveringPath, ok := nested[context]
+				if !ok {
+					toLoad = append(toLoad, context)
+					continue
+				}
+
+				if context.NestedTrees == shared.NestedTreesSkip {
+					skippedNestedTrees = append(skippedNestedTrees, context.FilePath)
+				} else {
+					toLoad = append(toLoad, context)
+					warnings = append(warnings, fmt.Sprintf("Directory tree %s is already fully contained in the tree for %s, so its tokens are counted twice. Set nestedTrees to skip to leave out nested trees, or to allow to silence this warning.", context.FilePath, coveringPath))
+				}
+			}
+
+			if len(toLoad) == 0 {
+				return nil, nil, &ContextRequestError{
+					Msg: fmt.Sprintf("directory trees %s are already fully contained in loaded trees", strings.Join(skippedNestedTrees, ", ")),
+				}
+			}
+
+			req = &toLoad
+		}
+	}
+
 	filesToLoad := map[string]string{}
 	for _, context := range *req {
 		if context.ContextType == shared.ContextFileType {
@@ -201,10 +670,10 @@ func LoadContexts(params LoadContextsParams) (*shared.LoadContextResponse, []*Co
 		}
 	}
 
-	tokensAdded := 0
+	var tokensAdded int64
 
 	paramsByTempId := make(map[string]*shared.LoadContextParams)
-	numTokensByTempId := make(map[string]int)
+	numTokensByTempId := make(map[string]int64)
 
 	branch, err := GetDbBranch(planId, branchName)
 	if err != nil {
@@ -217,32 +686,227 @@ func LoadContexts(params LoadContextsParams) (*shared.LoadContextResponse, []*Co
 		return nil, nil, fmt.Errorf("error getting settings: %v", err)
 	}
 
-	maxTokens := settings.GetPlannerEffectiveMaxTokens()
+	maxTokens, planMaxTokens, err := GetBranchMaxTokens(plan, branch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	maxContexts, err := GetMaxContextsPerPlan(orgId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting max contexts per plan: %v", err)
+	}
+
+	var tokensSaved int64
+
+	var deminified []shared.DeminifiedContext
+	deminifiedByTempId := make(map[string]bool)
+
+	var tempIds []string
+
+	dedupeKeys, err := existingDedupeKeys(orgId, planId, *req)
+	if err != nil {
+		return nil, nil, err
+	}
+	var skippedDuplicates []string
 
 	for _, context := range *req {
 		tempId := uuid.New().String()
-		numTokens, err := shared.GetNumTokens(context.Body)
+		tempIds = append(tempIds, tempId)
 
-		if err != nil {
-			return nil, nil, fmt.Errorf("error getting num tokens: %v", err)
+		// before anything else so the sha and token count match across platforms and editors
+		if context.ContextType == shared.ContextFileType && settings.StripBom {
+			context.Body = shared.StripBom(context.Body)
+		}
+
+		if settings.NormalizeLineEndings {
+			context.Body = shared.NormalizeLineEndings(context.Body)
+		}
+
+		if context.ContextType == shared.ContextFileType && context.Minified != "" && shared.IsMinified(context.Body) {
+			if context.Minified == shared.MinifiedReject {
+				return nil, nil, &ContextRequestError{
+					Msg: fmt.Sprintf("%s looks minified. Load the unminified source instead, or use --minified deminify to reformat it.", context.FilePath),
+				}
+			}
+
+			if !shared.CanDeminify(context.FilePath) {
+				return nil, nil, &ContextRequestError{
+					Msg: fmt.Sprintf("%s looks minified, but only js, css, and json files can be reformatted. Load the unminified source instead.", context.FilePath),
+				}
+			}
+
+			body, ok := shared.Deminify(context.FilePath, context.Body)
+			if !ok {
+				return nil, nil, &ContextRequestError{
+					Msg: fmt.Sprintf("%s looks minified, but couldn't be reformatted. Load the unminified source instead.", context.FilePath),
+				}
+			}
+
+			tokensBefore, err := shared.GetNumTokens(context.Body)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error getting num tokens: %v", err)
+			}
+
+			tokensAfter, err := shared.GetNumTokens(body)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error getting num tokens: %v", err)
+			}
+
+			context.Body = body
+			deminifiedByTempId[tempId] = true
+			deminified = append(deminified, shared.DeminifiedContext{
+				Name:         context.Name,
+				TokensBefore: tokensBefore,
+				TokensAfter:  tokensAfter,
+			})
+		}
+
+		if context.ContextType == shared.ContextFileType && context.StripComments {
+			originalTokens, err := shared.GetNumTokens(context.Body)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error getting num tokens: %v", err)
+			}
+
+			context.Body = shared.StripComments(context.FilePath, context.Body, context.PreserveDocstrings)
+
+			strippedTokens, err := shared.GetNumTokens(context.Body)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error getting num tokens: %v", err)
+			}
+
+			tokensSaved += originalTokens - strippedTokens
+		}
+
+		if context.ResolveRelativeUrls {
+			if context.BaseUrl == "" && context.ContextType == shared.ContextURLType {
+				context.BaseUrl = context.Url
+			}
+
+			if !shared.IsValidBaseUrl(context.BaseUrl) {
+				return nil, nil, &ContextRequestError{
+					Msg: fmt.Sprintf("an absolute base url is required to resolve relative urls in %s", context.Name),
+				}
+			}
+
+			linkPath := context.FilePath
+			if linkPath == "" {
+				linkPath = context.Url
+			}
+			context.Body = shared.ResolveRelativeUrls(linkPath, context.ContentType, context.Body, context.BaseUrl)
+		}
+
+		// last, since earlier transformations can change the end of the body
+		if context.ContextType == shared.ContextFileType {
+			context.Body = shared.ApplyTrailingNewline(context.Body, settings.TrailingNewline)
+		}
+
+		// compared with the stored sha, so only once the body is final
+		if dedupeKeys != nil {
+			hash := sha256.Sum256([]byte(context.Body))
+			key := dedupeKeyFor(context.ContextType, context.FilePath, context.Url, hex.EncodeToString(hash[:]))
+			if context.Dedupe && dedupeKeys[key] {
+				skippedDuplicates = append(skippedDuplicates, context.Name)
+				continue
+			}
+			dedupeKeys[key] = true
 		}
 
 		paramsByTempId[tempId] = context
-		numTokensByTempId[tempId] = numTokens
+	}
+
+	if len(paramsByTempId) == 0 {
+		return nil, nil, &ContextRequestError{
+			Msg: fmt.Sprintf("nothing to load, since every context is already loaded: %s", strings.Join(skippedDuplicates, ", ")),
+		}
+	}
+
+	// counting tokens is the slowest step for a large batch, so it runs concurrently once every body is final
+	err = countLoadTokens(paramsByTempId, numTokensByTempId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// in request order, so warnings follow the order contexts were given
+	for _, tempId := range tempIds {
+		context, ok := paramsByTempId[tempId]
+		if !ok {
+			continue
+		}
+		numTokens := numTokensByTempId[tempId]
+
+		if context.ContextType == shared.ContextDirectoryTreeType {
+			if TreeMaxTokens > 0 && numTokens > TreeMaxTokens {
+				return nil, nil, &ContextRequestError{
+					Msg: fmt.Sprintf("directory tree %s is %d tokens, which exceeds the limit of %d. Try loading a narrower directory or filtering with .plandexignore.", context.FilePath, numTokens, TreeMaxTokens),
+				}
+			}
+
+			if TreeWarnTokens > 0 && numTokens > TreeWarnTokens {
+				warnings = append(warnings, fmt.Sprintf("Directory tree %s is %d 🪙 (%.0f%% of the %d 🪙 limit). Consider loading a narrower directory or filtering with .plandexignore.", context.FilePath, numTokens, float64(numTokens)/float64(maxTokens)*100, maxTokens))
+			}
+		}
 
 		tokensAdded += numTokens
 		totalTokens += numTokens
 	}
 
+	// many small contexts slow listing and the repo however few tokens they add up to, so the token check doesn't cover this
+	if maxContexts > 0 {
+		numContexts, err := countPlanContexts(orgId, planId)
+		if err != nil {
+			return nil, nil, err
+		}
+		numContexts += len(paramsByTempId)
+
+		if numContexts > maxContexts {
+			return &shared.LoadContextResponse{
+				TokensAdded:          tokensAdded,
+				TotalTokens:          totalTokens,
+				MaxTokens:            maxTokens,
+				PlanMaxTokens:        planMaxTokens,
+				ContextCountExceeded: true,
+				NumContexts:          numContexts,
+				MaxContexts:          maxContexts,
+				Warnings:             warnings,
+				SkippedNestedTrees:   skippedNestedTrees,
+				SkippedEmpty:         skippedEmpty,
+				SkippedDuplicates:    skippedDuplicates,
+				InferredTypes:        inferredTypes,
+			}, nil, nil
+		}
+	}
+
 	if totalTokens > maxTokens {
 		return &shared.LoadContextResponse{
-			TokensAdded:       tokensAdded,
-			TotalTokens:       totalTokens,
-			MaxTokens:         maxTokens,
-			MaxTokensExceeded: true,
+			TokensAdded:        tokensAdded,
+			TotalTokens:        totalTokens,
+			MaxTokens:          maxTokens,
+			PlanMaxTokens:      planMaxTokens,
+			MaxTokensExceeded:  true,
+			Warnings:           warnings,
+			TokensSaved:        tokensSaved,
+			SkippedNestedTrees: skippedNestedTrees,
+			SkippedEmpty:       skippedEmpty,
+			SkippedDuplicates:  skippedDuplicates,
+			Deminified:         deminified,
+			InferredTypes:      inferredTypes,
 		}, nil, nil
 	}
 
+	nextAlias, err := reserveContextAliases(orgId, planId, len(paramsByTempId))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// in request order, so aliases follow the order contexts were given
+	aliasByTempId := make(map[string]int)
+	for _, tempId := range tempIds {
+		if _, ok := paramsByTempId[tempId]; ok {
+			aliasByTempId[tempId] = nextAlias
+			nextAlias++
+		}
+	}
+
 	dbContextsCh := make(chan *Context)
 	errCh := make(chan error)
 	for tempId, params := range paramsByTempId {
@@ -253,17 +917,34 @@ func LoadContexts(params LoadContextsParams) (*shared.LoadContextResponse, []*Co
 
 			context := Context{
 				// Id generated by db layer
-				OrgId:           orgId,
-				OwnerId:         userId,
-				PlanId:          planId,
-				ContextType:     params.ContextType,
-				Name:            params.Name,
-				Url:             params.Url,
-				FilePath:        params.FilePath,
-				NumTokens:       numTokensByTempId[tempId],
-				Sha:             sha,
-				Body:            params.Body,
-				ForceSkipIgnore: params.ForceSkipIgnore,
+				Alias:                 aliasByTempId[tempId],
+				OrgId:                 orgId,
+				OwnerId:               userId,
+				PlanId:                planId,
+				ContextType:           params.ContextType,
+				Name:                  params.Name,
+				Url:                   params.Url,
+				FilePath:              params.FilePath,
+				NumTokens:             numTokensByTempId[tempId],
+				TokenizerVersion:      shared.TokenizerVersion,
+				CreatedBy:             userId,
+				UpdatedBy:             userId,
+				Sha:                   sha,
+				Body:                  params.Body,
+				ForceSkipIgnore:       params.ForceSkipIgnore,
+				FileMode:              params.FileMode,
+				StripComments:         params.StripComments,
+				PreserveDocstrings:    params.PreserveDocstrings,
+				ResolveRelativeUrls:   params.ResolveRelativeUrls,
+				BaseUrl:               params.BaseUrl,
+				ContentType:           params.ContentType,
+				UrlSource:             params.UrlSource,
+				Source:                params.Source,
+				Encoding:              params.Encoding,
+				LineEndingsNormalized: settings.NormalizeLineEndings,
+				Deminified:            deminifiedByTempId[tempId],
+				BomStripped:           params.ContextType == shared.ContextFileType && settings.StripBom,
+				TrailingNewline:       trailingNewlineFor(params.ContextType, settings.TrailingNewline),
 			}
 
 			err := StoreContext(&context)
@@ -281,7 +962,7 @@ func LoadContexts(params LoadContextsParams) (*shared.LoadContextResponse, []*Co
 	var dbContexts []*Context
 	var apiContexts []*shared.Context
 
-	for i := 0; i < len(*req); i++ {
+	for i := 0; i < len(paramsByTempId); i++ {
 		select {
 		case err := <-errCh:
 			return nil, nil, fmt.Errorf("error storing context: %v", err)
@@ -304,20 +985,175 @@ func LoadContexts(params LoadContextsParams) (*shared.LoadContextResponse, []*Co
 		commitMsg += "\n\n" + shared.TableForLoadContext(apiContexts)
 	}
 
+	// read after storing, so it includes the contexts just loaded
+	planContexts, err := GetPlanContexts(orgId, planId, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting contexts: %v", err)
+	}
+
 	return &shared.LoadContextResponse{
-		TokensAdded: tokensAdded,
-		TotalTokens: totalTokens,
-		Msg:         commitMsg,
+		TokensAdded:        tokensAdded,
+		TotalTokens:        totalTokens,
+		MaxTokens:          maxTokens,
+		PlanMaxTokens:      planMaxTokens,
+		Msg:                commitMsg,
+		Warnings:           warnings,
+		TokensSaved:        tokensSaved,
+		SkippedNestedTrees: skippedNestedTrees,
+		SkippedEmpty:       skippedEmpty,
+		SkippedDuplicates:  skippedDuplicates,
+		Deminified:         deminified,
+		InferredTypes:      inferredTypes,
+		PairedFiles:        pairedFileSuggestions(*req, planContexts),
 	}, dbContexts, nil
 }
 
+func trailingNewlineFor(contextType shared.ContextType, policy shared.TrailingNewlinePolicy) shared.TrailingNewlinePolicy {
+	if contextType != shared.ContextFileType {
+		return ""
+	}
+	return policy
+}
+
 type UpdateContextsParams struct {
 	Req                      *shared.UpdateContextRequest
 	OrgId                    string
 	Plan                     *Plan
 	BranchName               string
+	UserId                   string // recorded as UpdatedBy on each stored context
 	ContextsById             map[string]*Context
 	SkipConflictInvalidation bool
+	// record contexts that fail to update in FailedById and apply the rest, instead of failing the whole batch.
+	// Only failures reading, decoding, or counting a context's tokens are per-context; failures storing contexts still fail the batch.
+	// An id with no context in the plan always rejects the batch with a ContextNotFoundError, partial or not.
+	Partial bool
+	// called as each context is stored, from concurrent goroutines
+	OnStored func(event shared.ContextStoredEvent)
+	// compute the response, including the commit message an update would create, without storing anything
+	DryRun bool
+	// per-context failures are logged with the context's id. slog.Default() if nil.
+	Logger *slog.Logger
+}
+
+// bodyForUpdate returns the body an update leaves a context with, before the context's load transformations are reapplied:
+// the request's body for a replace, or the stored body with the request's body added to the end or start
+func bodyForUpdate(storedBody string, params *shared.UpdateContextParams) (string, error) {
+	switch params.Mode {
+	case "", shared.UpdateContextReplace:
+		return params.Body, nil
+	case shared.UpdateContextAppend:
+		return storedBody + params.Body, nil
+	case shared.UpdateContextPrepend:
+		return params.Body + storedBody, nil
+	}
+
+	return "", &ContextRequestError{
+		Msg: fmt.Sprintf("unsupported update mode '%s'. Supported modes: replace, append, prepend", params.Mode),
+	}
+}
+
+// contextBodyUpdate is what an update does to one context's body and token count
+type contextBodyUpdate struct {
+	// the body to store, with the context's load transformations reapplied
+	body string
+	// set when body is the same as what's stored, in which case the counts aren't set
+	unchanged bool
+	// the count for body, and the stored body's count to diff it against
+	numTokens     int64
+	baseNumTokens int64
+}
+
+// updateContextBody works out the body and token counts an update leaves a context with, without storing anything.
+// The stored body is read if an append or prepend needs it and the context was passed without it. Like a decoded RawBody,
+// the combined body takes the request's place in params, so later steps see the final body.
+func updateContextBody(orgId, planId string, context *Context, params *shared.UpdateContextParams) (*contextBodyUpdate, error) {
+	// contexts passed in ContextsById may not have their bodies loaded
+	if params.Mode != "" && params.Mode != shared.UpdateContextReplace && context.Body == "" && context.NumTokens > 0 {
+		stored, err := GetContext(orgId, planId, context.Id, true)
+		if err != nil {
+			return nil, fmt.Errorf("error getting context body: %v", err)
+		}
+		context.Body = stored.Body
+	}
+
+	var err error
+	params.Body, err = bodyForUpdate(context.Body, params)
+	if err != nil {
+		return nil, err
+	}
+	params.Mode = shared.UpdateContextReplace
+
+	err = checkContextBody(context.Name, params.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	body := context.ToApi().StoredBody(params.Body)
+
+	// context.Sha is the hash of the stored body, so compare after the transforms are reapplied
+	hash := sha256.Sum256([]byte(body))
+	if hex.EncodeToString(hash[:]) == context.Sha {
+		return &contextBodyUpdate{body: body, unchanged: true}, nil
+	}
+
+	if params.EmptyBody != shared.EmptyBodyAllow && shared.IsEmptyContextBody(params.Body) {
+		return nil, &ContextRequestError{
+			Msg: fmt.Sprintf("%s would be empty after the update. Remove it from context instead, or allow empty bodies.", context.Name),
+		}
+	}
+
+	numTokens, err := shared.GetNumTokens(body)
+	if err != nil {
+		return nil, fmt.Errorf("error getting num tokens: %v", err)
+	}
+
+	if context.ContextType == shared.ContextDirectoryTreeType && TreeMaxTokens > 0 && numTokens > TreeMaxTokens {
+		return nil, &ContextRequestError{
+			Msg: fmt.Sprintf("directory tree %s would be %d tokens, which exceeds the limit of %d", context.FilePath, numTokens, TreeMaxTokens),
+		}
+	}
+
+	// a count from an older tokenizer isn't comparable, so diff against the stored body re-tokenized now
+	baseNumTokens := context.NumTokens
+	if context.TokenizerVersion != shared.TokenizerVersion {
+		storedBody := context.Body
+		if storedBody == "" && context.NumTokens > 0 {
+			stored, err := GetContext(orgId, planId, context.Id, true)
+			if err != nil {
+				return nil, fmt.Errorf("error getting context body: %v", err)
+			}
+			storedBody = stored.Body
+		}
+
+		baseNumTokens, err = shared.GetNumTokens(storedBody)
+		if err != nil {
+			return nil, fmt.Errorf("error getting num tokens: %v", err)
+		}
+	}
+
+	return &contextBodyUpdate{body: body, numTokens: numTokens, baseNumTokens: baseNumTokens}, nil
+}
+
+// updatedContextsInOrder returns the contexts with a body to store in the order the plan lists them, by creation time,
+// so an update's response and commit message don't depend on which goroutine finished first
+func updatedContextsInOrder(contextsById map[string]*Context, bodiesById map[string]string) []*shared.Context {
+	var contexts []*Context
+	for id := range bodiesById {
+		contexts = append(contexts, contextsById[id])
+	}
+
+	sort.Slice(contexts, func(i, j int) bool {
+		if !contexts[i].CreatedAt.Equal(contexts[j].CreatedAt) {
+			return contexts[i].CreatedAt.Before(contexts[j].CreatedAt)
+		}
+		return contexts[i].Id < contexts[j].Id
+	})
+
+	var res []*shared.Context
+	for _, context := range contexts {
+		res = append(res, context.ToApi())
+	}
+	return res
 }
 
 func UpdateContexts(params UpdateContextsParams) (*shared.UpdateContextResponse, error) {
@@ -327,6 +1163,11 @@ func UpdateContexts(params UpdateContextsParams) (*shared.UpdateContextResponse,
 	planId := plan.Id
 	branchName := params.BranchName
 
+	err := checkContextsPerRequest(len(*req))
+	if err != nil {
+		return nil, err
+	}
+
 	branch, err := GetDbBranch(planId, branchName)
 	if err != nil {
 		return nil, fmt.Errorf("error getting branch: %v", err)
@@ -336,16 +1177,17 @@ func UpdateContexts(params UpdateContextsParams) (*shared.UpdateContextResponse,
 		return nil, fmt.Errorf("branch not found")
 	}
 
-	settings, err := GetPlanSettings(plan, true)
+	maxTokens, planMaxTokens, err := GetBranchMaxTokens(plan, branch)
 	if err != nil {
-		return nil, fmt.Errorf("error getting settings: %v", err)
+		return nil, err
 	}
 
-	maxTokens := settings.GetPlannerEffectiveMaxTokens()
 	totalTokens := branch.ContextTokens
 
-	tokensDiff := 0
-	tokenDiffsById := make(map[string]int)
+	var tokensDiff int64
+	// how much re-tokenizing stale counts changed them, which isn't part of any update's diff
+	var recountDiff int64
+	tokenDiffsById := make(map[string]int64)
 
 	var contextsById map[string]*Context
 	if params.ContextsById == nil {
@@ -354,79 +1196,254 @@ func UpdateContexts(params UpdateContextsParams) (*shared.UpdateContextResponse,
 		contextsById = params.ContextsById
 	}
 
-	var updatedContexts []*shared.Context
+	// final bodies to store, after any transformations recorded on the context are reapplied
+	bodiesById := make(map[string]string)
+	// contexts whose stored body wouldn't change, which are neither re-tokenized nor written
+	var unchangedIds []string
+
+	// every context here is an existing one updated in place
+	var updatedCounts shared.ContextChangeCounts
 
-	numFiles := 0
-	numUrls := 0
-	numTrees := 0
+	// a stale id is the caller's mistake rather than a failed update, so it's rejected before anything is read
+	ids := make([]string, 0, len(*req))
+	for id := range *req {
+		ids = append(ids, id)
+	}
+	missingIds, err := missingContextIds(orgId, planId, ids, contextsById)
+	if err != nil {
+		return nil, err
+	}
+	if len(missingIds) > 0 {
+		return nil, &ContextNotFoundError{Ids: missingIds}
+	}
 
 	var mu sync.Mutex
-	errCh := make(chan error)
+	// buffered so goroutines still finish and release the limiter if an early error stops the receive loop
+	errCh := make(chan error, len(*req))
+	limiter := newContextLimiter()
+	partial := params.Partial
+	failedById := make(map[string]string)
+	// ids whose ExpectedSha didn't match, mapped to the stored sha
+	conflictsById := make(map[string]string)
+	// ids whose pin the update changed
+	var pinChangedIds []string
+
+	logger := params.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
 
 	for id, params := range *req {
 		go func(id string, params *shared.UpdateContextParams) {
+			var err error
+			defer func() {
+				if err != nil {
+					logger.Error("Error updating context", "contextId", id, "err", err)
+				}
+				if err != nil && partial {
+					mu.Lock()
+					failedById[id] = err.Error()
+					mu.Unlock()
+					err = nil
+				}
+				errCh <- err
+			}()
+
+			limiter.acquire()
+			defer limiter.release()
 
 			var context *Context
-			if _, ok := contextsById[id]; ok {
-				context = contextsById[id]
-			} else {
-				var err error
+			mu.Lock()
+			context = contextsById[id]
+			mu.Unlock()
+
+			if context == nil {
 				context, err = GetContext(orgId, planId, id, true)
 
 				if err != nil {
-					errCh <- fmt.Errorf("error getting context: %v", err)
+					err = fmt.Errorf("error getting context: %v", err)
 					return
 				}
 			}
 
-			mu.Lock()
-			defer mu.Unlock()
+			if params.ExpectedSha != "" && params.ExpectedSha != context.Sha {
+				mu.Lock()
+				defer mu.Unlock()
+				conflictsById[id] = context.Sha
+				return
+			}
 
-			contextsById[id] = context
-			updatedContexts = append(updatedContexts, context.ToApi())
-			updateNumTokens, err := shared.GetNumTokens(params.Body)
+			if params.Pinned != nil && context.Pinned != *params.Pinned {
+				mu.Lock()
+				context.Pinned = *params.Pinned
+				contextsById[id] = context
+				pinChangedIds = append(pinChangedIds, id)
+				mu.Unlock()
+			}
+
+			// only the pin changes, which is stored with the context's meta if the body isn't stored too
+			if params.Pinned != nil && params.Body == "" && params.RawBody == nil && params.EmptyBody != shared.EmptyBodyAllow {
+				mu.Lock()
+				defer mu.Unlock()
+				tokenDiffsById[id] = 0
+				unchangedIds = append(unchangedIds, id)
+				return
+			}
 
+			if context.Encoding != "" && params.RawBody != nil {
+				decoded, decodeErr := shared.DecodeToUtf8(params.RawBody, context.Encoding)
+				if decodeErr != nil {
+					err = fmt.Errorf("failed to decode %s as %s: %v", context.Name, context.Encoding, decodeErr)
+					return
+				}
+				params.Body = decoded
+				params.RawBody = nil
+			}
+
+			var update *contextBodyUpdate
+			update, err = updateContextBody(orgId, planId, context, params)
 			if err != nil {
-				errCh <- fmt.Errorf("error getting num tokens: %v", err)
 				return
 			}
 
-			tokenDiff := updateNumTokens - context.NumTokens
+			if update.unchanged {
+				mu.Lock()
+				defer mu.Unlock()
+				tokenDiffsById[id] = 0
+				unchangedIds = append(unchangedIds, id)
+				return
+			}
+
+			body := update.body
+			updateNumTokens := update.numTokens
+			baseNumTokens := update.baseNumTokens
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			contextsById[id] = context
+			bodiesById[id] = body
+
+			tokenDiff := updateNumTokens - baseNumTokens
 			tokenDiffsById[id] = tokenDiff
 			tokensDiff += tokenDiff
 			totalTokens += tokenDiff
+			// the branch total still counts the stale number, so it moves by the recount too
+			recountDiff += baseNumTokens - context.NumTokens
+			totalTokens += baseNumTokens - context.NumTokens
 
 			context.NumTokens = updateNumTokens
+			context.TokenizerVersion = shared.TokenizerVersion
 
-			switch context.ContextType {
-			case shared.ContextFileType:
-				numFiles++
-			case shared.ContextURLType:
-				numUrls++
-			case shared.ContextDirectoryTreeType:
-				numTrees++
-			}
-
-			errCh <- nil
+			updatedCounts.Add(context.ContextType)
 		}(id, params)
 	}
 
 	for i := 0; i < len(*req); i++ {
 		err := <-errCh
 		if err != nil {
-			return nil, fmt.Errorf("error getting context: %v", err)
+			return nil, fmt.Errorf("error getting context: %w", err)
 		}
 	}
 
+	if len(conflictsById) > 0 && !partial {
+		return nil, &ContextConflictError{ShasById: conflictsById}
+	}
+
+	if len(failedById) > 0 && len(bodiesById) == 0 && len(unchangedIds) == 0 && len(conflictsById) == 0 {
+		var msgs []string
+		for id, msg := range failedById {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", id, msg))
+		}
+		sort.Strings(msgs)
+		return nil, &ContextRequestError{
+			Msg: "no contexts could be updated\n" + strings.Join(msgs, "\n"),
+		}
+	}
+
+	sort.Strings(unchangedIds)
+	updatedContexts := updatedContextsInOrder(contextsById, bodiesById)
+
+	// pin changes for updates that failed aren't applied. Contexts whose body is stored have their pin stored with it.
+	var pinMsg string
+	var pinMetaIds []string
+	numPinned, numUnpinned := 0, 0
+	for _, id := range pinChangedIds {
+		if _, failed := failedById[id]; failed {
+			continue
+		}
+		if contextsById[id].Pinned {
+			numPinned++
+		} else {
+			numUnpinned++
+		}
+		if _, ok := bodiesById[id]; !ok {
+			pinMetaIds = append(pinMetaIds, id)
+		}
+	}
+	if numPinned+numUnpinned > 0 {
+		pinMsg = shared.SummaryForPinContexts(numPinned, numUnpinned)
+	}
+
+	storePins := func() error {
+		for _, id := range pinMetaIds {
+			context := contextsById[id]
+			if params.UserId != "" {
+				context.UpdatedBy = params.UserId
+			}
+			err := StoreContextMeta(context)
+			if err != nil {
+				return fmt.Errorf("error storing context meta: %v", err)
+			}
+		}
+		return nil
+	}
+
+	if len(bodiesById) == 0 && len(pinMetaIds) > 0 {
+		res := &shared.LoadContextResponse{
+			TotalTokens:    totalTokens,
+			MaxTokens:      maxTokens,
+			PlanMaxTokens:  planMaxTokens,
+			Msg:            pinMsg,
+			TokenDiffsById: tokenDiffsById,
+			UnchangedIds:   unchangedIds,
+			FailedById:     failedById,
+			ConflictsById:  conflictsById,
+		}
+
+		if params.DryRun {
+			return res, nil
+		}
+
+		err = storePins()
+		if err != nil {
+			return nil, err
+		}
+
+		return res, nil
+	}
+
+	if len(bodiesById) == 0 {
+		// nothing to store or commit
+		return &shared.LoadContextResponse{
+			TotalTokens:    totalTokens,
+			MaxTokens:      maxTokens,
+			PlanMaxTokens:  planMaxTokens,
+			TokenDiffsById: tokenDiffsById,
+			UnchangedIds:   unchangedIds,
+			FailedById:     failedById,
+			ConflictsById:  conflictsById,
+		}, nil
+	}
+
 	updateRes := &shared.ContextUpdateResult{
 		UpdatedContexts: updatedContexts,
 		TokenDiffsById:  tokenDiffsById,
 		TokensDiff:      tokensDiff,
 		TotalTokens:     totalTokens,
-		NumFiles:        numFiles,
-		NumUrls:         numUrls,
-		NumTrees:        numTrees,
+		Updated:         updatedCounts,
 		MaxTokens:       maxTokens,
+		FailedById:      failedById,
 	}
 
 	if totalTokens > maxTokens {
@@ -434,10 +1451,36 @@ func UpdateContexts(params UpdateContextsParams) (*shared.UpdateContextResponse,
 			TokensAdded:       tokensDiff,
 			TotalTokens:       totalTokens,
 			MaxTokens:         maxTokens,
+			PlanMaxTokens:     planMaxTokens,
 			MaxTokensExceeded: true,
+			TokenDiffsById:    tokenDiffsById,
+			UnchangedIds:      unchangedIds,
+			FailedById:        failedById,
+			ConflictsById:     conflictsById,
 		}, nil
 	}
 
+	commitMsg := shared.SummaryForUpdateContext(updateRes) + "\n\n" + shared.TableForContextUpdate(updateRes)
+	if pinMsg != "" {
+		commitMsg += "\n\n" + pinMsg
+	}
+
+	res := &shared.LoadContextResponse{
+		TokensAdded:    tokensDiff,
+		TotalTokens:    totalTokens,
+		MaxTokens:      maxTokens,
+		PlanMaxTokens:  planMaxTokens,
+		Msg:            commitMsg,
+		TokenDiffsById: tokenDiffsById,
+		UnchangedIds:   unchangedIds,
+		FailedById:     failedById,
+		ConflictsById:  conflictsById,
+	}
+
+	if params.DryRun {
+		return res, nil
+	}
+
 	filesToLoad := map[string]string{}
 	for _, context := range updatedContexts {
 		if context.ContextType == shared.ContextFileType {
@@ -452,18 +1495,24 @@ func UpdateContexts(params UpdateContextsParams) (*shared.UpdateContextResponse,
 		}
 	}
 
-	errCh = make(chan error)
+	errCh = make(chan error, len(bodiesById))
 
-	for id, params := range *req {
-		go func(id string, params *shared.UpdateContextParams) {
+	for id := range bodiesById {
+		go func(id string) {
+			limiter.acquire()
+			defer limiter.release()
 
 			context := contextsById[id]
+			body := bodiesById[id]
 
-			hash := sha256.Sum256([]byte(params.Body))
+			hash := sha256.Sum256([]byte(body))
 			sha := hex.EncodeToString(hash[:])
 
-			context.Body = params.Body
+			context.Body = body
 			context.Sha = sha
+			if params.UserId != "" {
+				context.UpdatedBy = params.UserId
+			}
 
 			err := StoreContext(context)
 
@@ -472,29 +1521,37 @@ func UpdateContexts(params UpdateContextsParams) (*shared.UpdateContextResponse,
 				return
 			}
 
+			if params.OnStored != nil {
+				params.OnStored(shared.ContextStoredEvent{
+					Id:        id,
+					Name:      context.Name,
+					NumTokens: context.NumTokens,
+					Sha:       sha,
+				})
+			}
+
 			errCh <- nil
-		}(id, params)
+		}(id)
 	}
 
-	for i := 0; i < len(*req); i++ {
+	for i := 0; i < len(bodiesById); i++ {
 		err := <-errCh
 		if err != nil {
 			return nil, fmt.Errorf("error storing context: %v", err)
 		}
 	}
 
-	err = AddPlanContextTokens(planId, branchName, tokensDiff)
+	err = storePins()
 	if err != nil {
-		return nil, fmt.Errorf("error adding plan context tokens: %v", err)
+		return nil, err
 	}
 
-	commitMsg := shared.SummaryForUpdateContext(updateRes) + "\n\n" + shared.TableForContextUpdate(updateRes)
+	err = AddPlanContextTokens(planId, branchName, tokensDiff+recountDiff)
+	if err != nil {
+		return nil, fmt.Errorf("error adding plan context tokens: %v", err)
+	}
 
-	return &shared.LoadContextResponse{
-		TokensAdded: tokensDiff,
-		TotalTokens: totalTokens,
-		Msg:         commitMsg,
-	}, nil
+	return res, nil
 }
 
 func invalidateConflictedResults(orgId, planId string, filesToLoad map[string]string) error {
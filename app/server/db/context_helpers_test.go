@@ -0,0 +1,51 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMissingContextIds(t *testing.T) {
+	defaultBaseDir := BaseDir
+	BaseDir = t.TempDir()
+	defer func() { BaseDir = defaultBaseDir }()
+
+	contextDir := getPlanContextDir("org", "plan")
+	if err := os.MkdirAll(contextDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, "valid.meta"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// outside the context dir, so an id reaching it through a separator must still be missing
+	if err := os.WriteFile(filepath.Join(filepath.Dir(contextDir), "outside.meta"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		ids          []string
+		contextsById map[string]*Context
+		want         []string
+	}{
+		{"one valid one invalid", []string{"valid", "invalid"}, nil, []string{"invalid"}},
+		{"all valid", []string{"valid"}, nil, nil},
+		{"already loaded", []string{"loaded"}, map[string]*Context{"loaded": {Id: "loaded"}}, nil},
+		{"separator", []string{"../outside"}, nil, []string{"../outside"}},
+		{"sorted", []string{"b", "valid", "a"}, nil, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := missingContextIds("org", "plan", tt.ids, tt.contextsById)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingContextIds(%v) = %v, want %v", tt.ids, got, tt.want)
+			}
+		})
+	}
+}
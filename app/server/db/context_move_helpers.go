@@ -0,0 +1,108 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/plandex/plandex/shared"
+)
+
+type MoveContextsParams struct {
+	Req    *shared.MoveContextRequest
+	OrgId  string
+	PlanId string
+}
+
+// MoveContexts changes the paths of file contexts, keeping their ids, bodies, and token counts.
+// Every id must be a file context, and no two file contexts can end up with the same path, counting those that aren't moved.
+// A context whose name was its path is renamed to match. Returns the moved contexts, sorted by new path, and the path
+// each one was moved from by id. Contexts already at their new path are left out.
+func MoveContexts(params MoveContextsParams) ([]*Context, map[string]string, error) {
+	req := params.Req
+
+	if len(req.Paths) == 0 {
+		return nil, nil, &ContextRequestError{Msg: "No contexts to move"}
+	}
+
+	contexts, err := GetPlanContexts(params.OrgId, params.PlanId, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting contexts: %v", err)
+	}
+
+	contextsById := map[string]*Context{}
+	for _, context := range contexts {
+		contextsById[context.Id] = context
+	}
+
+	var missingIds []string
+	newPathsById := map[string]string{}
+	for id, newPath := range req.Paths {
+		context, ok := contextsById[id]
+		if !ok {
+			missingIds = append(missingIds, id)
+			continue
+		}
+
+		if context.ContextType != shared.ContextFileType {
+			return nil, nil, &ContextRequestError{Msg: fmt.Sprintf("Context %s is a %s, only file contexts can be moved", id, context.ContextType)}
+		}
+
+		if newPath == "" {
+			return nil, nil, &ContextRequestError{Msg: fmt.Sprintf("New path for context %s is empty", id)}
+		}
+
+		newPathsById[id] = filepath.Clean(newPath)
+	}
+
+	if len(missingIds) > 0 {
+		sort.Strings(missingIds)
+		return nil, nil, &ContextNotFoundError{Ids: missingIds}
+	}
+
+	// every file context's path after the move, to catch two moves to one path or a move onto a context that stays put
+	idsByPath := map[string]string{}
+	for _, context := range contexts {
+		if context.ContextType != shared.ContextFileType {
+			continue
+		}
+
+		path := context.FilePath
+		if newPath, ok := newPathsById[context.Id]; ok {
+			path = newPath
+		}
+
+		if otherId, ok := idsByPath[path]; ok {
+			return nil, nil, &ContextRequestError{Msg: fmt.Sprintf("Contexts %s and %s would both have the path %s", otherId, context.Id, path)}
+		}
+		idsByPath[path] = context.Id
+	}
+
+	var moved []*Context
+	fromPathsById := map[string]string{}
+	for id, newPath := range newPathsById {
+		context := contextsById[id]
+		if context.FilePath == newPath {
+			continue
+		}
+
+		fromPathsById[id] = context.FilePath
+		if context.Name == context.FilePath {
+			context.Name = newPath
+		}
+		context.FilePath = newPath
+
+		err = StoreContextMeta(context)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error storing context meta: %v", err)
+		}
+
+		moved = append(moved, context)
+	}
+
+	sort.Slice(moved, func(i, j int) bool {
+		return moved[i].FilePath < moved[j].FilePath
+	})
+
+	return moved, fromPathsById, nil
+}
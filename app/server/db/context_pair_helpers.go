@@ -0,0 +1,48 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// pairedFileSuggestions returns the test or source counterparts of the loaded file contexts that aren't among the plan's contexts,
+// sorted by path. A candidate paired with more than one loaded file is suggested once, for the first of them.
+func pairedFileSuggestions(loaded []*shared.LoadContextParams, contexts []*Context) []shared.PairedFileSuggestion {
+	skip := map[string]bool{}
+	for _, context := range contexts {
+		if context.ContextType == shared.ContextFileType {
+			skip[context.FilePath] = true
+		}
+	}
+	for _, context := range loaded {
+		if context.ContextType == shared.ContextFileType {
+			skip[context.FilePath] = true
+		}
+	}
+
+	var suggestions []shared.PairedFileSuggestion
+	for _, context := range loaded {
+		if context.ContextType != shared.ContextFileType {
+			continue
+		}
+
+		for _, candidate := range shared.PairedFileCandidates(context.FilePath) {
+			if skip[candidate] {
+				continue
+			}
+
+			skip[candidate] = true
+			suggestions = append(suggestions, shared.PairedFileSuggestion{
+				Path:    candidate,
+				ForPath: context.FilePath,
+			})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Path < suggestions[j].Path
+	})
+
+	return suggestions
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func TestPairedFileSuggestions(t *testing.T) {
+	file := func(path string) *shared.LoadContextParams {
+		return &shared.LoadContextParams{ContextType: shared.ContextFileType, FilePath: path}
+	}
+
+	tests := []struct {
+		name     string
+		loaded   []*shared.LoadContextParams
+		existing []string
+		want     string
+	}{
+		{"go source", []*shared.LoadContextParams{file("pkg/foo.go")}, nil, "pkg/foo_test.go<-pkg/foo.go"},
+		{"go test", []*shared.LoadContextParams{file("pkg/foo_test.go")}, nil, "pkg/foo.go<-pkg/foo_test.go"},
+		{"go bare test suffix", []*shared.LoadContextParams{file("_test.go")}, nil, ""},
+		{"ts source", []*shared.LoadContextParams{file("src/a.ts")}, nil, "src/a.spec.ts<-src/a.ts,src/a.test.ts<-src/a.ts"},
+		{"ts spec", []*shared.LoadContextParams{file("src/a.spec.tsx")}, nil, "src/a.tsx<-src/a.spec.tsx"},
+		{"python source", []*shared.LoadContextParams{file("app/foo.py")}, nil, "app/foo_test.py<-app/foo.py,app/test_foo.py<-app/foo.py"},
+		{"python test prefix", []*shared.LoadContextParams{file("app/test_foo.py")}, nil, "app/foo.py<-app/test_foo.py"},
+		{"ruby spec", []*shared.LoadContextParams{file("lib/foo_spec.rb")}, nil, "lib/foo.rb<-lib/foo_spec.rb"},
+		{"java main", []*shared.LoadContextParams{file("src/main/java/a/Foo.java")}, nil, "src/test/java/a/FooTest.java<-src/main/java/a/Foo.java"},
+		{"java test", []*shared.LoadContextParams{file("src/test/java/a/FooTest.java")}, nil, "src/main/java/a/Foo.java<-src/test/java/a/FooTest.java"},
+		{"java test without suffix", []*shared.LoadContextParams{file("src/test/java/a/Helper.java")}, nil, ""},
+		{"unknown extension", []*shared.LoadContextParams{file("README.md")}, nil, ""},
+		{"already in context", []*shared.LoadContextParams{file("foo.go")}, []string{"foo_test.go"}, ""},
+		{"loaded together", []*shared.LoadContextParams{file("foo.go"), file("foo_test.go")}, nil, ""},
+		{"shared candidate suggested once", []*shared.LoadContextParams{file("a.test.js"), file("a.spec.js")}, nil, "a.js<-a.test.js"},
+		{"non-file contexts ignored", []*shared.LoadContextParams{{ContextType: shared.ContextNoteType, FilePath: "foo.go"}}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var existing []*Context
+			for _, path := range tt.existing {
+				existing = append(existing, &Context{ContextType: shared.ContextFileType, FilePath: path})
+			}
+
+			var got []string
+			for _, suggestion := range pairedFileSuggestions(tt.loaded, existing) {
+				got = append(got, suggestion.Path+"<-"+suggestion.ForPath)
+			}
+
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("got %q, want %q", strings.Join(got, ","), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+type ContextPruneSelection struct {
+	Prune []*Context
+	Keep  []*Context
+	// the total once the pruned contexts are removed
+	TotalTokens int64
+}
+
+// SelectContextsToPrune picks the least recently updated unpinned contexts to remove, oldest first, until the total is at or
+// under targetTokens. Nothing is pruned if the total is already under it. Pinned contexts are always kept, so a target they
+// exceed on their own can't be met and is rejected.
+func SelectContextsToPrune(contexts []*Context, totalTokens, targetTokens int64) (*ContextPruneSelection, error) {
+	if targetTokens < 0 {
+		return nil, &ContextRequestError{Msg: "targetTokens can't be negative"}
+	}
+
+	var pinnedTokens int64
+	var unpinned []*Context
+	for _, context := range contexts {
+		if context.Pinned {
+			pinnedTokens += context.NumTokens
+		} else {
+			unpinned = append(unpinned, context)
+		}
+	}
+
+	if totalTokens > targetTokens && pinnedTokens > targetTokens {
+		return nil, &ContextRequestError{
+			Msg: fmt.Sprintf("pinned contexts use %d tokens, more than the target of %d. Unpin some contexts or raise the target.", pinnedTokens, targetTokens),
+		}
+	}
+
+	sort.SliceStable(unpinned, func(i, j int) bool {
+		if !unpinned[i].UpdatedAt.Equal(unpinned[j].UpdatedAt) {
+			return unpinned[i].UpdatedAt.Before(unpinned[j].UpdatedAt)
+		}
+		return unpinned[i].Id < unpinned[j].Id
+	})
+
+	res := &ContextPruneSelection{TotalTokens: totalTokens}
+	pruneIds := map[string]bool{}
+	for _, context := range unpinned {
+		if res.TotalTokens <= targetTokens {
+			break
+		}
+		res.Prune = append(res.Prune, context)
+		res.TotalTokens -= context.NumTokens
+		pruneIds[context.Id] = true
+	}
+
+	for _, context := range contexts {
+		if !pruneIds[context.Id] {
+			res.Keep = append(res.Keep, context)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func pruneIds(contexts []*Context) string {
+	var ids []string
+	for _, context := range contexts {
+		ids = append(ids, context.Id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSelectContextsToPrune(t *testing.T) {
+	now := time.Now()
+	contexts := []*Context{
+		{Id: "a", NumTokens: 100, UpdatedAt: now.Add(-3 * time.Hour), Pinned: true},
+		{Id: "b", NumTokens: 50, UpdatedAt: now.Add(-2 * time.Hour)},
+		{Id: "c", NumTokens: 30, UpdatedAt: now.Add(-1 * time.Hour)},
+		{Id: "d", NumTokens: 20, UpdatedAt: now.Add(-2 * time.Hour)},
+		{Id: "e", NumTokens: 10, UpdatedAt: now},
+	}
+
+	res, err := SelectContextsToPrune(contexts, 210, 140)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b and d were updated at the same time, so they're pruned in id order, and the pinned a is kept though it's oldest
+	if got := pruneIds(res.Prune); got != "b,d" {
+		t.Errorf("got pruned %s, want b,d", got)
+	}
+	if got := pruneIds(res.Keep); got != "a,c,e" {
+		t.Errorf("got kept %s, want a,c,e", got)
+	}
+	if res.TotalTokens != 140 {
+		t.Errorf("got total %d, want 140", res.TotalTokens)
+	}
+
+	res, err = SelectContextsToPrune(contexts, 210, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Prune) != 0 || len(res.Keep) != len(contexts) {
+		t.Errorf("expected nothing pruned under the target, got pruned %s", pruneIds(res.Prune))
+	}
+
+	_, err = SelectContextsToPrune(contexts, 210, 90)
+	if _, ok := err.(*ContextRequestError); !ok {
+		t.Errorf("expected a request error when pinned contexts exceed the target, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/plandex/plandex/shared"
+)
+
+type ReplaceContextsParams struct {
+	Req        *shared.ReplaceContextRequest
+	OrgId      string
+	Plan       *Plan
+	BranchName string
+	UserId     string
+	// slog.Default() if nil
+	Logger *slog.Logger
+}
+
+// replaceKeyFor identifies the context an entry replaces: the same type and path, or url for url contexts.
+// Notes and piped data have neither, so they only match a context with an identical body.
+func replaceKeyFor(contextType shared.ContextType, filePath, url, sha string) contextDedupeKey {
+	key := dedupeKeyFor(contextType, filePath, url, "")
+	if key.source == "" {
+		key.sha = sha
+	}
+	return key
+}
+
+// ReplaceContexts makes the plan's contexts match the request. Entries matching an existing context are applied as updates,
+// keeping the context's id and load settings, entries with no match are loaded, and contexts no entry matches are removed.
+// Removals happen first, so freed tokens count toward the limit check for what's added.
+// The caller is responsible for committing and for rolling back the repo if an error or a LimitExceeded response is returned.
+func ReplaceContexts(params ReplaceContextsParams) (*shared.ReplaceContextResponse, error) {
+	req := *params.Req
+	orgId := params.OrgId
+	plan := params.Plan
+	planId := plan.Id
+	branchName := params.BranchName
+
+	err := checkContextsPerRequest(len(req))
+	if err != nil {
+		return nil, err
+	}
+
+	inferredTypes, err := inferContextTypes(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// entries that match a stored context are applied as updates, which take a body
+	err = buildTreeBodies(req)
+	if err != nil {
+		return nil, err
+	}
+
+	branch, err := GetDbBranch(planId, branchName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting branch: %v", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch not found")
+	}
+
+	dbContexts, err := GetPlanContexts(orgId, planId, false)
+	if err != nil {
+		return nil, fmt.Errorf("error getting contexts: %v", err)
+	}
+
+	existingByKey := make(map[contextDedupeKey]*Context)
+	for _, dbContext := range dbContexts {
+		key := replaceKeyFor(dbContext.ContextType, dbContext.FilePath, dbContext.Url, dbContext.Sha)
+		// a second context with the same source isn't matched, so it's removed
+		if _, ok := existingByKey[key]; !ok {
+			existingByKey[key] = dbContext
+		}
+	}
+
+	matchedIds := make(map[string]bool)
+	contextsById := make(map[string]*Context)
+	updateReq := shared.UpdateContextRequest{}
+	var loadReq shared.LoadContextRequest
+	var skippedEmpty []string
+
+	for _, entry := range req {
+		hash := sha256.Sum256([]byte(entry.Body))
+		key := replaceKeyFor(entry.ContextType, entry.FilePath, entry.Url, hex.EncodeToString(hash[:]))
+
+		if dbContext, ok := existingByKey[key]; ok {
+			if matchedIds[dbContext.Id] {
+				return nil, &ContextRequestError{Msg: fmt.Sprintf("%s is in the request more than once", entry.Name)}
+			}
+			matchedIds[dbContext.Id] = true
+			contextsById[dbContext.Id] = dbContext
+			updateReq[dbContext.Id] = &shared.UpdateContextParams{
+				Body:      entry.Body,
+				RawBody:   entry.RawBody,
+				EmptyBody: entry.EmptyBody,
+			}
+			continue
+		}
+
+		// LoadContexts rejects a request where every entry is empty, which shouldn't fail a replace
+		if entry.RawBody == nil && entry.EmptyBody != shared.EmptyBodyAllow && shared.IsEmptyContextBody(entry.Body) {
+			skippedEmpty = append(skippedEmpty, entry.Name)
+			continue
+		}
+
+		loadReq = append(loadReq, entry)
+	}
+
+	var toRemove []*Context
+	var toRemoveApiContexts []*shared.Context
+	var removeTokens int64
+	for _, dbContext := range dbContexts {
+		if !matchedIds[dbContext.Id] {
+			toRemove = append(toRemove, dbContext)
+			toRemoveApiContexts = append(toRemoveApiContexts, dbContext.ToApi())
+			removeTokens += dbContext.NumTokens
+		}
+	}
+
+	res := &shared.ReplaceContextResponse{}
+	res.InferredTypes = inferredTypes
+	res.SkippedEmpty = skippedEmpty
+
+	var msgs []string
+	var tokensDiff int64
+
+	logger := params.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	// branch token counts live in the db, so they aren't covered by the repo rollback
+	revertTokens := func() {
+		err := restorePlanContextTokens(planId, branchName, branch.ContextTokens)
+		if err != nil {
+			logger.Error("Error reverting plan context tokens", "err", err)
+		}
+	}
+
+	if len(toRemove) > 0 {
+		err = ContextRemove(toRemove)
+		if err != nil {
+			return nil, fmt.Errorf("error removing contexts: %v", err)
+		}
+
+		err = AddPlanContextTokens(planId, branchName, -removeTokens)
+		if err != nil {
+			return nil, fmt.Errorf("error updating plan tokens: %v", err)
+		}
+		tokensDiff -= removeTokens
+		res.NumRemoved = len(toRemove)
+
+		msgs = append(msgs, shared.SummaryForRemoveContext(toRemoveApiContexts, branch.ContextTokens)+"\n\n"+shared.TableForRemoveContext(toRemoveApiContexts))
+	}
+
+	if len(updateReq) > 0 {
+		updateRes, err := UpdateContexts(UpdateContextsParams{
+			Logger:       logger,
+			Req:          &updateReq,
+			OrgId:        orgId,
+			Plan:         plan,
+			BranchName:   branchName,
+			UserId:       params.UserId,
+			ContextsById: contextsById,
+		})
+		if err != nil {
+			revertTokens()
+			return nil, err
+		}
+
+		if updateRes.MaxTokensExceeded {
+			revertTokens()
+			res.LoadContextResponse = *updateRes
+			return res, nil
+		}
+
+		tokensDiff += updateRes.TokensAdded
+		res.NumUpdated = len(updateRes.TokenDiffsById) - len(updateRes.UnchangedIds)
+		res.UnchangedIds = updateRes.UnchangedIds
+		res.TokenDiffsById = updateRes.TokenDiffsById
+		res.MaxTokens = updateRes.MaxTokens
+		res.PlanMaxTokens = updateRes.PlanMaxTokens
+		if updateRes.Msg != "" {
+			msgs = append(msgs, updateRes.Msg)
+		}
+	}
+
+	if len(loadReq) > 0 {
+		loadRes, loaded, err := LoadContexts(LoadContextsParams{
+			Req:        &loadReq,
+			OrgId:      orgId,
+			Plan:       plan,
+			BranchName: branchName,
+			UserId:     params.UserId,
+		})
+		if err != nil {
+			revertTokens()
+			return nil, err
+		}
+
+		if loadRes.LimitExceeded() {
+			revertTokens()
+			res.LoadContextResponse = *loadRes
+			return res, nil
+		}
+
+		tokensDiff += loadRes.TokensAdded
+		res.NumAdded = len(loaded)
+		res.MaxTokens = loadRes.MaxTokens
+		res.PlanMaxTokens = loadRes.PlanMaxTokens
+		res.Warnings = loadRes.Warnings
+		res.TokensSaved = loadRes.TokensSaved
+		res.SkippedNestedTrees = loadRes.SkippedNestedTrees
+		res.SkippedEmpty = append(res.SkippedEmpty, loadRes.SkippedEmpty...)
+		res.SkippedDuplicates = loadRes.SkippedDuplicates
+		res.Deminified = loadRes.Deminified
+		msgs = append(msgs, loadRes.Msg)
+	}
+
+	res.TokensAdded = tokensDiff
+	res.TotalTokens = branch.ContextTokens + tokensDiff
+
+	// nothing was written if every entry matched its stored context
+	if len(msgs) > 0 {
+		res.Msg = shared.SummaryForReplaceContext(res) + "\n\n" + strings.Join(msgs, "\n\n")
+	}
+
+	return res, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// ContextSelector picks out contexts by type and path for requests that act on them in bulk.
+// A context matches when it has one of the types, if any are given, and its path matches one of the globs, if any are given.
+type ContextSelector struct {
+	Types []shared.ContextType
+	// matched with shared.MatchPathPattern against a file context's path and each of its parent directories, so "src/legacy"
+	// selects everything under it and "src/**/*.go" selects go files at any depth. Contexts without a file path never match.
+	PathPatterns []*shared.PathPattern
+	// the exact sources the contexts were loaded by
+	Sources []string
+}
+
+// NewContextSelector validates short type names like file, url, tree and path globs from a request
+func NewContextSelector(typeNames, pathGlobs, sources []string) (ContextSelector, error) {
+	var selector ContextSelector
+
+	for _, name := range typeNames {
+		contextType, ok := shared.ParseContextTypeName(strings.TrimSpace(name))
+		if !ok {
+			return selector, &ContextRequestError{Msg: fmt.Sprintf("invalid type '%s', expected one of: %s", name, shared.ContextTypeNames)}
+		}
+		selector.Types = append(selector.Types, contextType)
+	}
+
+	for _, glob := range pathGlobs {
+		glob = filepath.Clean(strings.TrimSpace(glob))
+		pattern, err := shared.CompilePathPattern(glob)
+		if err != nil {
+			return selector, &ContextRequestError{Msg: fmt.Sprintf("invalid path glob '%s': %v", glob, err)}
+		}
+		selector.PathPatterns = append(selector.PathPatterns, pattern)
+	}
+
+	for _, source := range sources {
+		if source = strings.TrimSpace(source); source != "" {
+			selector.Sources = append(selector.Sources, source)
+		}
+	}
+
+	return selector, nil
+}
+
+func (s ContextSelector) IsEmpty() bool {
+	return len(s.Types) == 0 && len(s.PathPatterns) == 0 && len(s.Sources) == 0
+}
+
+func (s ContextSelector) Matches(context *Context) bool {
+	if s.IsEmpty() {
+		return false
+	}
+
+	if len(s.Types) > 0 && !slices.Contains(s.Types, context.ContextType) {
+		return false
+	}
+
+	if len(s.Sources) > 0 && !slices.Contains(s.Sources, context.Source) {
+		return false
+	}
+
+	if len(s.PathPatterns) == 0 {
+		return true
+	}
+
+	if context.FilePath == "" {
+		return false
+	}
+
+	for dir := filepath.Clean(context.FilePath); dir != "." && dir != "/" && dir != ""; dir = filepath.Dir(dir) {
+		for _, pattern := range s.PathPatterns {
+			if pattern.Match(dir) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func TestContextSelectorMatches(t *testing.T) {
+	file := func(path string) *Context {
+		return &Context{ContextType: shared.ContextFileType, FilePath: path}
+	}
+
+	tests := []struct {
+		name    string
+		globs   []string
+		context *Context
+		want    bool
+	}{
+		{"dir selects everything under it", []string{"src/legacy"}, file("src/legacy/a/b.go"), true},
+		{"dir with trailing slash", []string{"src/legacy/"}, file("src/legacy/b.go"), true},
+		{"sibling dir", []string{"src/legacy"}, file("src/legacy2/b.go"), false},
+		{"single star", []string{"src/*.go"}, file("src/main.go"), true},
+		{"double star at any depth", []string{"src/**/*.go"}, file("src/a/b/main.go"), true},
+		{"double star at top level", []string{"src/**/*.go"}, file("src/main.go"), true},
+		{"double star other extension", []string{"src/**/*.go"}, file("src/a/main.ts"), false},
+		{"dir matched by a glob", []string{"*/legacy"}, file("src/legacy/b.go"), true},
+		{"any of several globs", []string{"docs", "*.md"}, file("README.md"), true},
+		{"no file path", []string{"**"}, &Context{ContextType: shared.ContextNoteType}, false},
+	}
+
+	for _, tt := range tests {
+		selector, err := NewContextSelector(nil, tt.globs, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := selector.Matches(tt.context); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewContextSelectorInvalidGlob(t *testing.T) {
+	if _, err := NewContextSelector(nil, []string{"[z-a]"}, nil); err == nil {
+		t.Error("got no error for an invalid glob")
+	}
+}
@@ -0,0 +1,255 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GetPlanContextsAtCommit reads context metadata (without bodies) from a commit in the plan repo rather than from the working tree.
+// Commits are immutable, so this doesn't need a repo lock: a caller can resolve the commit with GitBranchCommit under a branch read lock, release the lock, and then read a consistent snapshot while writers proceed.
+// The result reflects the branch as of that commit, so writes that land afterward aren't included.
+func GetPlanContextsAtCommit(orgId, planId, commit string) ([]*Context, error) {
+	dir := getPlanDir(orgId, planId)
+
+	contexts := []*Context{}
+	if commit == "" {
+		return contexts, nil
+	}
+
+	ids, err := contextIdsAtCommit(dir, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var input strings.Builder
+	for _, id := range ids {
+		input.WriteString(commit + ":context/" + id + ".meta\n")
+	}
+
+	if input.Len() == 0 {
+		return contexts, nil
+	}
+
+	out, err := gitCatFileBatch(dir, "--batch", input.String())
+	if err != nil {
+		return nil, fmt.Errorf("error reading contexts at commit %s: %v", commit, err)
+	}
+
+	contents, err := parseCatFileBatch(out)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, content := range contents {
+		var context Context
+		if err := json.Unmarshal(content, &context); err != nil {
+			return nil, fmt.Errorf("error unmarshalling context meta file: %v", err)
+		}
+
+		contexts = append(contexts, &context)
+	}
+
+	// match GetPlanContexts
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].CreatedAt.Before(contexts[j].CreatedAt)
+	})
+
+	return contexts, nil
+}
+
+// contextIdsAtCommit lists the ids of the contexts stored at a commit, from their meta files
+func contextIdsAtCommit(dir, commit string) ([]string, error) {
+	res, err := exec.Command("git", "-C", dir, "ls-tree", "--name-only", commit, "context/").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error listing contexts at commit %s for dir: %s, err: %v, output: %s", commit, dir, err, string(res))
+	}
+
+	var ids []string
+	for _, line := range strings.Split(string(res), "\n") {
+		if id, ok := strings.CutSuffix(strings.TrimPrefix(line, "context/"), ".meta"); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// GetContextAtCommit reads a context from a commit like GetContext reads it from the working tree. Returns nil if the commit has no context with the id.
+func GetContextAtCommit(orgId, planId, commit, contextId string, includeBody bool) (*Context, error) {
+	if commit == "" {
+		return nil, nil
+	}
+
+	dir := getPlanDir(orgId, planId)
+
+	metaBytes, ok, err := gitReadFileAtCommit(dir, commit, "context/"+contextId+".meta")
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var context Context
+	err = json.Unmarshal(metaBytes, &context)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling context meta file: %v", err)
+	}
+
+	if includeBody {
+		bodyBytes, ok, err := gitReadFileAtCommit(dir, commit, "context/"+contextId+".body")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("context body not found at commit %s: %s", commit, contextId)
+		}
+		context.Body = string(bodyBytes)
+	}
+
+	return &context, nil
+}
+
+// gitReadFileAtCommit reads a file from a commit, reporting false if the commit doesn't have it
+func gitReadFileAtCommit(dir, commit, path string) ([]byte, bool, error) {
+	out, err := gitCatFileBatch(dir, "--batch", commit+":"+path+"\n")
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading %s at commit %s: %v", path, commit, err)
+	}
+
+	// "<object> missing" instead of a header
+	header, _, _ := bytes.Cut(out, []byte("\n"))
+	if fields := strings.Fields(string(header)); len(fields) == 2 && fields[1] == "missing" {
+		return nil, false, nil
+	}
+
+	contents, err := parseCatFileBatch(out)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(contents) != 1 {
+		return nil, false, fmt.Errorf("expected 1 object reading %s at commit %s, got %d", path, commit, len(contents))
+	}
+
+	return contents[0], true, nil
+}
+
+// ContextBodiesTooLargeError is returned when bodies requested with a context list add up to more than ListBodiesMaxBytes
+type ContextBodiesTooLargeError struct {
+	Bytes    int
+	MaxBytes int
+}
+
+func (e *ContextBodiesTooLargeError) Error() string {
+	return fmt.Sprintf("context bodies total %d bytes, more than the %d byte limit for a list with bodies", e.Bytes, e.MaxBytes)
+}
+
+// GetContextBodiesAtCommit fills in the bodies of contexts read with GetPlanContextsAtCommit from the same commit.
+// Body sizes are checked first, and a *ContextBodiesTooLargeError is returned without reading any bodies if they
+// add up to more than ListBodiesMaxBytes.
+func GetContextBodiesAtCommit(orgId, planId, commit string, contexts []*Context) error {
+	if len(contexts) == 0 || commit == "" {
+		return nil
+	}
+
+	dir := getPlanDir(orgId, planId)
+
+	var input strings.Builder
+	for _, context := range contexts {
+		input.WriteString(commit + ":context/" + context.Id + ".body\n")
+	}
+
+	// "<sha> <type> <size>" per object, or "<object> missing"
+	out, err := gitCatFileBatch(dir, "--batch-check", input.String())
+	if err != nil {
+		return fmt.Errorf("error checking context bodies at commit %s: %v", commit, err)
+	}
+
+	total := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("context body not found at commit %s: %s", commit, line)
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("error parsing context body size: %v", err)
+		}
+		total += size
+	}
+
+	if ListBodiesMaxBytes > 0 && total > ListBodiesMaxBytes {
+		return &ContextBodiesTooLargeError{Bytes: total, MaxBytes: ListBodiesMaxBytes}
+	}
+
+	out, err = gitCatFileBatch(dir, "--batch", input.String())
+	if err != nil {
+		return fmt.Errorf("error reading context bodies at commit %s: %v", commit, err)
+	}
+
+	contents, err := parseCatFileBatch(out)
+	if err != nil {
+		return err
+	}
+
+	if len(contents) != len(contexts) {
+		return fmt.Errorf("expected %d context bodies, got %d", len(contexts), len(contents))
+	}
+
+	for i, content := range contents {
+		contexts[i].Body = string(content)
+	}
+
+	return nil
+}
+
+func gitCatFileBatch(dir, mode, input string) ([]byte, error) {
+	cmd := exec.Command("git", "-C", dir, "cat-file", mode)
+	cmd.Stdin = strings.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running git cat-file %s for dir: %s, err: %v, output: %s", mode, dir, err, stderr.String())
+	}
+	return out, nil
+}
+
+// parseCatFileBatch splits `git cat-file --batch` output into object contents, in input order
+func parseCatFileBatch(out []byte) ([][]byte, error) {
+	var contents [][]byte
+
+	// each object is a "<sha> <type> <size>" header line, then <size> bytes of content and a newline
+	reader := bufio.NewReader(bytes.NewReader(out))
+	for {
+		header, err := reader.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading context batch header: %v", err)
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected context batch header: %s", strings.TrimSpace(header))
+		}
+
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing context batch size: %v", err)
+		}
+
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(reader, content); err != nil {
+			return nil, fmt.Errorf("error reading context batch content: %v", err)
+		}
+
+		contents = append(contents, content[:size])
+	}
+
+	return contents, nil
+}
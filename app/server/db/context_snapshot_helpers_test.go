@@ -0,0 +1,75 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadContextsAtBranchCommit(t *testing.T) {
+	const id = "6f1c1c3e-8f0e-4a55-9bd4-6a6a4f6c2b10"
+
+	dir := initTestPlanRepo(t,
+		[]string{"init", "-q", "-b", "main"},
+		[]string{"commit", "-q", "--allow-empty", "-m", "init"},
+		[]string{"checkout", "-q", "-b", "feature"},
+	)
+
+	contextDir := filepath.Join(dir, "context")
+	if err := os.MkdirAll(contextDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, id+".meta"), []byte(`{"id":"`+id+`","name":"main.go","alias":3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, id+".body"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "add context"}, {"checkout", "-q", "main"}} {
+		gitOutput(t, dir, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	}
+
+	// the context is only on feature, which isn't checked out
+	commit, err := GitBranchCommit("org", "plan", "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := ContextsExistAtCommit("org", "plan", commit, []string{id, "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{id: true, "other": false}; !reflect.DeepEqual(exists, want) {
+		t.Errorf("got %v, want %v", exists, want)
+	}
+
+	for _, ref := range []string{id, "#3"} {
+		context, err := ResolveContextRefAtCommit("org", "plan", commit, ref, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if context == nil || context.Id != id || context.Body != "package main\n" {
+			t.Errorf("%s: got %+v, want the context with its body", ref, context)
+		}
+	}
+
+	for _, ref := range []string{"6f1c1c3e-0000-4a55-9bd4-6a6a4f6c2b10", "#4", "../main"} {
+		context, err := ResolveContextRefAtCommit("org", "plan", commit, ref, true)
+		if err != nil || context != nil {
+			t.Errorf("%s: got %+v, %v, want no context", ref, context, err)
+		}
+	}
+
+	mainCommit, err := GitBranchCommit("org", "plan", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, err = ContextsExistAtCommit("org", "plan", mainCommit, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists[id] {
+		t.Errorf("the context shouldn't exist on main")
+	}
+}
@@ -0,0 +1,320 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+)
+
+var stagingMu sync.Mutex
+
+// the branch name is hashed since it can contain '/', '..', or anything else git allows in a ref
+func getStagedContextPath(orgId, planId, branch, userId string) string {
+	branchHash := sha256.Sum256([]byte(branch))
+	return filepath.Join(getPlanStagingDir(orgId, planId), hex.EncodeToString(branchHash[:]), userId+".json")
+}
+
+func GetStagedContext(orgId, planId, branch, userId string) (*shared.StagedContextChanges, error) {
+	stagingMu.Lock()
+	defer stagingMu.Unlock()
+
+	return getStagedContext(orgId, planId, branch, userId)
+}
+
+func getStagedContext(orgId, planId, branch, userId string) (*shared.StagedContextChanges, error) {
+	staged := &shared.StagedContextChanges{
+		Update: shared.UpdateContextRequest{},
+		Delete: map[string]bool{},
+	}
+
+	bytes, err := os.ReadFile(getStagedContextPath(orgId, planId, branch, userId))
+
+	if os.IsNotExist(err) {
+		return staged, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading staged context: %v", err)
+	}
+
+	err = json.Unmarshal(bytes, staged)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling staged context: %v", err)
+	}
+
+	if staged.Update == nil {
+		staged.Update = shared.UpdateContextRequest{}
+	}
+	if staged.Delete == nil {
+		staged.Delete = map[string]bool{}
+	}
+
+	return staged, nil
+}
+
+type StageContextParams struct {
+	OrgId  string
+	PlanId string
+	Branch string
+	UserId string
+	Load   shared.LoadContextRequest
+	Update shared.UpdateContextRequest
+	Delete map[string]bool
+}
+
+// mergeStagedUpdate combines an update with one already staged for the same context. An append after a replace or append,
+// or a prepend after a replace or prepend, adds to the staged body. A prepend after an append, or the reverse,
+// can't be expressed as one update.
+func mergeStagedUpdate(staged, update *shared.UpdateContextParams) (*shared.UpdateContextParams, error) {
+	if staged == nil || update.Mode == "" || update.Mode == shared.UpdateContextReplace {
+		return update, nil
+	}
+
+	// rejects an unsupported mode
+	_, err := bodyForUpdate("", update)
+	if err != nil {
+		return nil, err
+	}
+
+	if staged.RawBody != nil || update.RawBody != nil {
+		return nil, &ContextRequestError{Msg: "an append or prepend can't be combined with a staged update that has a raw body"}
+	}
+
+	stagedMode := staged.Mode
+	if stagedMode == "" {
+		stagedMode = shared.UpdateContextReplace
+	}
+
+	merged := *staged
+	merged.EmptyBody = update.EmptyBody
+
+	switch {
+	case update.Mode == shared.UpdateContextAppend && stagedMode != shared.UpdateContextPrepend:
+		merged.Body = staged.Body + update.Body
+	case update.Mode == shared.UpdateContextPrepend && stagedMode != shared.UpdateContextAppend:
+		merged.Body = update.Body + staged.Body
+	default:
+		return nil, &ContextRequestError{
+			Msg: fmt.Sprintf("can't %s to a context with a staged %s. Commit or discard the staged changes first.", update.Mode, stagedMode),
+		}
+	}
+
+	return &merged, nil
+}
+
+// StageContextChanges merges changes into the user's staging area for a branch without touching the plan repo.
+// Later updates to the same id win, except that an append or prepend is added to the staged update for the id,
+// and deleting an id drops any staged update for it.
+func StageContextChanges(params StageContextParams) (*shared.StagedContextChanges, error) {
+	branch, err := GetDbBranch(params.PlanId, params.Branch)
+	if err != nil {
+		return nil, err
+	}
+	if branch == nil {
+		return nil, &BranchNotFoundError{Branch: params.Branch}
+	}
+
+	stagingMu.Lock()
+	defer stagingMu.Unlock()
+
+	staged, err := getStagedContext(params.OrgId, params.PlanId, params.Branch, params.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	staged.Load = append(staged.Load, params.Load...)
+
+	for id, update := range params.Update {
+		if staged.Delete[id] {
+			continue
+		}
+		merged, err := mergeStagedUpdate(staged.Update[id], update)
+		if err != nil {
+			return nil, err
+		}
+		staged.Update[id] = merged
+	}
+
+	for id := range params.Delete {
+		staged.Delete[id] = true
+		delete(staged.Update, id)
+	}
+
+	staged.UpdatedAt = time.Now().UTC()
+
+	bytes, err := json.Marshal(staged)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling staged context: %v", err)
+	}
+
+	path := getStagedContextPath(params.OrgId, params.PlanId, params.Branch, params.UserId)
+
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("error creating staging dir: %v", err)
+	}
+
+	err = os.WriteFile(path, bytes, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error writing staged context: %v", err)
+	}
+
+	return staged, nil
+}
+
+func ClearStagedContext(orgId, planId, branch, userId string) error {
+	stagingMu.Lock()
+	defer stagingMu.Unlock()
+
+	err := os.Remove(getStagedContextPath(orgId, planId, branch, userId))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing staged context: %v", err)
+	}
+
+	return nil
+}
+
+type CommitStagedContextParams struct {
+	OrgId      string
+	Plan       *Plan
+	BranchName string
+	UserId     string
+	// slog.Default() if nil
+	Logger *slog.Logger
+}
+
+// CommitStagedContext applies staged deletes, updates, and loads in that order, so freed tokens count toward the limit check for what's added.
+// The caller is responsible for committing and for rolling back the repo if an error or a LimitExceeded response is returned.
+func CommitStagedContext(params CommitStagedContextParams) (*shared.CommitStagedContextResponse, error) {
+	orgId := params.OrgId
+	plan := params.Plan
+	planId := plan.Id
+	branchName := params.BranchName
+
+	staged, err := GetStagedContext(orgId, planId, branchName, params.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(staged.Load) == 0 && len(staged.Update) == 0 && len(staged.Delete) == 0 {
+		return nil, &ContextRequestError{Msg: "No staged context changes"}
+	}
+
+	branch, err := GetDbBranch(planId, branchName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting branch: %v", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch not found")
+	}
+
+	var msgs []string
+	var tokensDiff int64
+
+	logger := params.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	// branch token counts live in the db, so they aren't covered by the repo rollback
+	revertTokens := func() {
+		err := restorePlanContextTokens(planId, branchName, branch.ContextTokens)
+		if err != nil {
+			logger.Error("Error reverting plan context tokens", "err", err)
+		}
+	}
+
+	if len(staged.Delete) > 0 {
+		dbContexts, err := GetPlanContexts(orgId, planId, false)
+		if err != nil {
+			return nil, fmt.Errorf("error getting contexts: %v", err)
+		}
+
+		var toRemove []*Context
+		var toRemoveApiContexts []*shared.Context
+		var removeTokens int64
+		for _, dbContext := range dbContexts {
+			if dbContext.InRefs(staged.Delete) {
+				toRemove = append(toRemove, dbContext)
+				toRemoveApiContexts = append(toRemoveApiContexts, dbContext.ToApi())
+				removeTokens += dbContext.NumTokens
+			}
+		}
+
+		if len(toRemove) > 0 {
+			err = ContextRemove(toRemove)
+			if err != nil {
+				return nil, fmt.Errorf("error removing contexts: %v", err)
+			}
+
+			err = AddPlanContextTokens(planId, branchName, -removeTokens)
+			if err != nil {
+				return nil, fmt.Errorf("error updating plan tokens: %v", err)
+			}
+			tokensDiff -= removeTokens
+
+			msgs = append(msgs, shared.SummaryForRemoveContext(toRemoveApiContexts, branch.ContextTokens)+"\n\n"+shared.TableForRemoveContext(toRemoveApiContexts))
+		}
+	}
+
+	if len(staged.Update) > 0 {
+		res, err := UpdateContexts(UpdateContextsParams{
+			Logger:     logger,
+			Req:        &staged.Update,
+			OrgId:      orgId,
+			Plan:       plan,
+			BranchName: branchName,
+			UserId:     params.UserId,
+		})
+		if err != nil {
+			revertTokens()
+			return nil, fmt.Errorf("error updating contexts: %v", err)
+		}
+
+		if res.MaxTokensExceeded {
+			revertTokens()
+			return res, nil
+		}
+
+		tokensDiff += res.TokensAdded
+		// empty if every staged update was unchanged
+		if res.Msg != "" {
+			msgs = append(msgs, res.Msg)
+		}
+	}
+
+	if len(staged.Load) > 0 {
+		res, _, err := LoadContexts(LoadContextsParams{
+			Req:        &staged.Load,
+			OrgId:      orgId,
+			Plan:       plan,
+			BranchName: branchName,
+			UserId:     params.UserId,
+		})
+		if err != nil {
+			revertTokens()
+			return nil, err
+		}
+
+		if res.LimitExceeded() {
+			revertTokens()
+			return res, nil
+		}
+
+		tokensDiff += res.TokensAdded
+		msgs = append(msgs, res.Msg)
+	}
+
+	return &shared.CommitStagedContextResponse{
+		TokensAdded: tokensDiff,
+		TotalTokens: branch.ContextTokens + tokensDiff,
+		Msg:         strings.Join(msgs, "\n\n"),
+	}, nil
+}
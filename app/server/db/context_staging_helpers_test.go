@@ -0,0 +1,27 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetStagedContextPathStaysInStagingDir(t *testing.T) {
+	stagingDir := getPlanStagingDir("org", "plan")
+
+	seen := map[string]string{}
+	for _, branch := range []string{"main", "feature/x", "../../other-plan", "..", "a/../../b", `a\b`} {
+		path := getStagedContextPath("org", "plan", branch, "user")
+
+		rel, err := filepath.Rel(stagingDir, path)
+		if err != nil || strings.HasPrefix(rel, "..") || strings.Count(rel, string(filepath.Separator)) != 1 {
+			t.Errorf("branch %q: got path %q outside a branch dir of %q", branch, path, stagingDir)
+		}
+
+		dir := filepath.Dir(path)
+		if other, ok := seen[dir]; ok {
+			t.Errorf("branches %q and %q share staging dir %q", other, branch, dir)
+		}
+		seen[dir] = branch
+	}
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/plandex/plandex/shared"
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+type TarballContextsParams struct {
+	// a tar archive, gzipped or not
+	Archive io.Reader
+	// only entries equal to or under one of these paths are loaded. Everything is if empty.
+	AllowPaths []string
+	// .plandexignore rules applied before the archive's own root .plandexignore, like the org's defaults
+	IgnoreLines []string
+}
+
+type TarballContexts struct {
+	Req shared.LoadContextRequest
+	// entries that weren't loaded because they look binary
+	SkippedBinary []string
+	// entries that weren't loaded because .plandexignore rules or the allowed paths exclude them
+	NumIgnored int
+}
+
+// ContextsFromTarball reads an archive's regular files into file contexts, in path order. Each entry's path in the archive
+// is its path in the project. The archive's root .plandexignore, if any, applies after params.IgnoreLines, so it can override them.
+// Limits on the archive's size, its extracted size, and its number of entries are enforced while reading, before anything
+// is buffered past them.
+func ContextsFromTarball(params TarballContextsParams) (*TarballContexts, error) {
+	var limited io.Reader = params.Archive
+	var remaining *io.LimitedReader
+	if TarballMaxBytes > 0 {
+		remaining = &io.LimitedReader{R: params.Archive, N: int64(TarballMaxBytes) + 1}
+		limited = remaining
+	}
+	tooLarge := func() bool {
+		return remaining != nil && remaining.N <= 0
+	}
+
+	reader := bufio.NewReader(limited)
+
+	// gzipped archives are detected from their header, so a client doesn't need to say which it sent
+	var archive io.Reader = reader
+	magic, err := reader.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("invalid gzip data: %v", err)}
+		}
+		defer gz.Close()
+		archive = gz
+	}
+
+	var allowPaths []string
+	for _, p := range params.AllowPaths {
+		p = strings.Trim(path.Clean(strings.ReplaceAll(p, "\\", "/")), "/")
+		if p == "." || p == "" {
+			allowPaths = nil
+			break
+		}
+		allowPaths = append(allowPaths, p)
+	}
+
+	bodiesByPath := map[string]string{}
+	var plandexIgnoreLines []string
+	numEntries := 0
+	extractedBytes := 0
+
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		// checked first, since the limit ends the stream early and can look like the end of the archive
+		if tooLarge() {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("archive is larger than %d bytes", TarballMaxBytes)}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("invalid tar archive: %v", err)}
+		}
+
+		numEntries++
+		if TarballMaxEntries > 0 && numEntries > TarballMaxEntries {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("archive has more than %d entries", TarballMaxEntries)}
+		}
+
+		// directories, links, and devices aren't loaded
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, ok := tarEntryPath(hdr.Name)
+		if !ok {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("archive entry %s has a path outside the archive root", hdr.Name)}
+		}
+
+		if MaxContextBodyBytes > 0 && hdr.Size > int64(MaxContextBodyBytes) {
+			return nil, &ContextRequestError{
+				Msg: fmt.Sprintf("%s is %d bytes, which exceeds the limit of %d bytes per context", name, hdr.Size, MaxContextBodyBytes),
+			}
+		}
+
+		extractedBytes += int(hdr.Size)
+		if TarballMaxExtractedBytes > 0 && extractedBytes > TarballMaxExtractedBytes {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("archive extracts to more than %d bytes", TarballMaxExtractedBytes)}
+		}
+
+		// the header's size bounds what's read, so a corrupt header can't make an entry larger than it claims
+		body, err := io.ReadAll(io.LimitReader(tr, hdr.Size))
+		if tooLarge() {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("archive is larger than %d bytes", TarballMaxBytes)}
+		}
+		if err != nil {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("error reading %s from archive: %v", name, err)}
+		}
+
+		if name == ".plandexignore" {
+			plandexIgnoreLines = strings.Split(string(body), "\n")
+			continue
+		}
+
+		// a later entry for the same path replaces an earlier one, as when extracting
+		bodiesByPath[name] = string(body)
+	}
+
+	ignoreLines := append(append([]string{}, params.IgnoreLines...), plandexIgnoreLines...)
+	var ignored *ignore.GitIgnore
+	if len(ignoreLines) > 0 {
+		ignored = ignore.CompileIgnoreLines(ignoreLines...)
+	}
+
+	var paths []string
+	for p := range bodiesByPath {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	res := &TarballContexts{}
+	for _, p := range paths {
+		if !tarPathAllowed(p, allowPaths) || (ignored != nil && ignored.MatchesPath(p)) {
+			res.NumIgnored++
+			continue
+		}
+
+		body := bodiesByPath[p]
+		if BinaryMaxNonTextPercent > 0 && shared.LooksBinary(body, BinaryMaxNonTextPercent) {
+			res.SkippedBinary = append(res.SkippedBinary, p)
+			continue
+		}
+
+		res.Req = append(res.Req, &shared.LoadContextParams{
+			ContextType: shared.ContextFileType,
+			Name:        p,
+			FilePath:    p,
+			Body:        body,
+		})
+	}
+
+	if len(res.Req) == 0 {
+		return nil, &ContextRequestError{Msg: "archive has no text files to load"}
+	}
+
+	return res, nil
+}
+
+// tarEntryPath cleans an entry's path relative to the archive root, returning false for one that escapes it
+func tarEntryPath(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "./"))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || name == "." {
+		return "", false
+	}
+	return name, true
+}
+
+func tarPathAllowed(p string, allowPaths []string) bool {
+	if len(allowPaths) == 0 {
+		return true
+	}
+	for _, allowed := range allowPaths {
+		if p == allowed || strings.HasPrefix(p, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
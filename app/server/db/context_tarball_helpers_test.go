@@ -0,0 +1,142 @@
+package db
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name string
+	body string
+}
+
+func buildTarball(t *testing.T, entries []tarEntry, gzipped bool) *bytes.Buffer {
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	var tw *tar.Writer
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+
+	for _, entry := range entries {
+		err := tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.body)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte(entry.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buf
+}
+
+func tarballPaths(res *TarballContexts) []string {
+	var paths []string
+	for _, context := range res.Req {
+		paths = append(paths, context.FilePath)
+	}
+	return paths
+}
+
+func TestContextsFromTarball(t *testing.T) {
+	entries := []tarEntry{
+		{"./src/main.go", "package main\n"},
+		{"src/gen/out.go", "package gen\n"},
+		{"docs/readme.md", "# readme\n"},
+		{"build/app.log", "log\n"},
+		{"assets/logo.png", "\x89PNG\x00\x00"},
+		{".plandexignore", "src/gen/\n"},
+	}
+
+	for _, gzipped := range []bool{false, true} {
+		res, err := ContextsFromTarball(TarballContextsParams{
+			Archive:     buildTarball(t, entries, gzipped),
+			IgnoreLines: []string{"build/"},
+		})
+		if err != nil {
+			t.Fatalf("gzipped %v: %v", gzipped, err)
+		}
+
+		got := strings.Join(tarballPaths(res), ",")
+		if got != "docs/readme.md,src/main.go" {
+			t.Errorf("gzipped %v: got paths %s", gzipped, got)
+		}
+		if res.NumIgnored != 2 {
+			t.Errorf("gzipped %v: expected 2 ignored entries, got %d", gzipped, res.NumIgnored)
+		}
+		if len(res.SkippedBinary) != 1 || res.SkippedBinary[0] != "assets/logo.png" {
+			t.Errorf("gzipped %v: expected the png to be skipped as binary, got %v", gzipped, res.SkippedBinary)
+		}
+	}
+
+	res, err := ContextsFromTarball(TarballContextsParams{
+		Archive:    buildTarball(t, entries, true),
+		AllowPaths: []string{"docs/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tarballPaths(res), ","); got != "docs/readme.md" {
+		t.Errorf("expected only paths under docs with an allowlist, got %s", got)
+	}
+}
+
+func TestContextsFromTarballLimits(t *testing.T) {
+	_, err := ContextsFromTarball(TarballContextsParams{
+		Archive: buildTarball(t, []tarEntry{{"../outside.txt", "x"}}, false),
+	})
+	if _, ok := err.(*ContextRequestError); !ok {
+		t.Errorf("expected a request error for a path outside the archive, got %v", err)
+	}
+
+	defaultEntries := TarballMaxEntries
+	TarballMaxEntries = 2
+	defer func() { TarballMaxEntries = defaultEntries }()
+
+	_, err = ContextsFromTarball(TarballContextsParams{
+		Archive: buildTarball(t, []tarEntry{{"a.txt", "a"}, {"b.txt", "b"}, {"c.txt", "c"}}, false),
+	})
+	if err == nil || !strings.Contains(err.Error(), "more than 2 entries") {
+		t.Errorf("expected the entry limit to be enforced, got %v", err)
+	}
+
+	defaultExtracted := TarballMaxExtractedBytes
+	TarballMaxExtractedBytes = 1024
+	defer func() { TarballMaxExtractedBytes = defaultExtracted }()
+
+	// compresses to far less than it extracts to, like a tar bomb
+	bomb := strings.Repeat("a", 2048)
+	_, err = ContextsFromTarball(TarballContextsParams{
+		Archive: buildTarball(t, []tarEntry{{"bomb.txt", bomb}}, true),
+	})
+	if err == nil || !strings.Contains(err.Error(), "extracts to more than 1024 bytes") {
+		t.Errorf("expected the extracted size limit to be enforced, got %v", err)
+	}
+
+	defaultBytes := TarballMaxBytes
+	TarballMaxBytes = 1024
+	defer func() { TarballMaxBytes = defaultBytes }()
+
+	_, err = ContextsFromTarball(TarballContextsParams{
+		Archive: buildTarball(t, []tarEntry{{"a.txt", "a"}}, false),
+	})
+	if err == nil || !strings.Contains(err.Error(), "larger than 1024 bytes") {
+		t.Errorf("expected the archive size limit to be enforced, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/plandex/plandex/shared"
+)
+
+type CountContextTokensParams struct {
+	Req        *shared.ContextTokensRequest
+	Plan       *Plan
+	BranchName string
+}
+
+// CountContextTokens counts the tokens each entry would add if loaded, without storing anything. Entries are decoded, checked,
+// and have their types inferred as they would be for a load, but load-time transforms like stripping comments aren't applied,
+// so the counts are an upper bound for entries that use them.
+func CountContextTokens(params CountContextTokensParams) (*shared.ContextTokensResponse, error) {
+	req := *params.Req
+	plan := params.Plan
+
+	err := checkContextsPerRequest(len(req))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = inferContextTypes(req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = decodeContextBodies(req)
+	if err != nil {
+		return nil, err
+	}
+
+	branch, err := GetDbBranch(plan.Id, params.BranchName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting branch: %v", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch not found")
+	}
+
+	maxTokens, planMaxTokens, err := GetBranchMaxTokens(plan, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]shared.ContextTokensEntry, len(req))
+	errCh := make(chan error, len(req))
+	limiter := newContextLimiter()
+
+	for i, context := range req {
+		go func(i int, context *shared.LoadContextParams) {
+			limiter.acquire()
+			defer limiter.release()
+
+			numTokens, err := shared.GetNumTokens(context.Body)
+			if err != nil {
+				errCh <- fmt.Errorf("error getting num tokens for %s: %v", context.Name, err)
+				return
+			}
+
+			// each goroutine writes only its own index
+			entries[i] = shared.ContextTokensEntry{
+				Name:        context.Name,
+				ContextType: context.ContextType,
+				NumTokens:   numTokens,
+			}
+			errCh <- nil
+		}(i, context)
+	}
+
+	for range req {
+		err := <-errCh
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tokensAdded int64
+	for _, entry := range entries {
+		tokensAdded += entry.NumTokens
+	}
+	totalTokens := branch.ContextTokens + tokensAdded
+
+	return &shared.ContextTokensResponse{
+		Entries:           entries,
+		TokensAdded:       tokensAdded,
+		TotalTokens:       totalTokens,
+		MaxTokens:         maxTokens,
+		PlanMaxTokens:     planMaxTokens,
+		MaxTokensExceeded: totalTokens > maxTokens,
+	}, nil
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/plandex/plandex/shared"
+)
+
+type treeContext struct {
+	path  string
+	paths map[string]bool
+}
+
+func newTreeContext(path, body string) *treeContext {
+	paths := map[string]bool{}
+	for _, line := range strings.Split(body, "\n") {
+		if line != "" {
+			paths[line] = true
+		}
+	}
+	return &treeContext{path: filepath.Clean(path), paths: paths}
+}
+
+// covers reports whether t is at or above other's directory and already lists every path in other
+func (t *treeContext) covers(other *treeContext) bool {
+	if t.path != "." && other.path != t.path && !strings.HasPrefix(other.path, t.path+string(filepath.Separator)) {
+		return false
+	}
+
+	for path := range other.paths {
+		if !t.paths[path] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getPlanTreeContexts returns the plan's directory tree contexts with their bodies. Other contexts' bodies aren't needed
+// to find nested trees, so they're never read.
+func getPlanTreeContexts(orgId, planId string) ([]*Context, error) {
+	contexts, err := GetPlanContexts(orgId, planId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var trees []*Context
+	for _, context := range contexts {
+		if context.ContextType != shared.ContextDirectoryTreeType {
+			continue
+		}
+
+		withBody, err := GetContext(orgId, planId, context.Id, true)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, withBody)
+	}
+
+	return trees, nil
+}
+
+// findNestedTrees returns the directory trees in a load request that are fully contained in a tree already in context or in another tree in the same request, mapped to the path of the covering tree.
+// When two trees in the request are identical, only the later one is treated as nested.
+func findNestedTrees(existing []*Context, req shared.LoadContextRequest) map[*shared.LoadContextParams]string {
+	var existingTrees []*treeContext
+	for _, context := range existing {
+		if context.ContextType == shared.ContextDirectoryTreeType {
+			existingTrees = append(existingTrees, newTreeContext(context.FilePath, context.Body))
+		}
+	}
+
+	reqTrees := make([]*treeContext, len(req))
+	for i, context := range req {
+		if context.ContextType == shared.ContextDirectoryTreeType {
+			reqTrees[i] = newTreeContext(context.FilePath, context.Body)
+		}
+	}
+
+	res := map[*shared.LoadContextParams]string{}
+
+	for i, tree := range reqTrees {
+		if tree == nil || req[i].NestedTrees == shared.NestedTreesAllow {
+			continue
+		}
+
+		for _, other := range existingTrees {
+			if other.covers(tree) {
+				res[req[i]] = other.path
+				break
+			}
+		}
+		if _, ok := res[req[i]]; ok {
+			continue
+		}
+
+		for j, other := range reqTrees {
+			if j == i || other == nil || !other.covers(tree) {
+				continue
+			}
+			// identical trees cover each other, so keep the first
+			if tree.covers(other) && j > i {
+				continue
+			}
+			res[req[i]] = other.path
+			break
+		}
+	}
+
+	return res
+}
+
+// buildTreeBodies sets the body of each directory tree in a load request that was sent as a list of paths
+func buildTreeBodies(req shared.LoadContextRequest) error {
+	for _, context := range req {
+		if context.TreePaths == nil {
+			continue
+		}
+
+		if context.ContextType != shared.ContextDirectoryTreeType {
+			return &ContextRequestError{Msg: fmt.Sprintf("%s has tree paths, but only a directory tree can be built from paths", context.Name)}
+		}
+		if context.Body != "" || context.RawBody != nil {
+			return &ContextRequestError{Msg: fmt.Sprintf("%s has both a body and tree paths", context.Name)}
+		}
+
+		context.Body = shared.BuildDirectoryTree(context.TreePaths)
+		context.TreePaths = nil
+	}
+
+	return nil
+}
+
+type RefreshTreeContextsParams struct {
+	Req        *shared.RefreshTreeContextRequest
+	OrgId      string
+	Plan       *Plan
+	BranchName string
+	UserId     string
+}
+
+// RefreshTreeContexts replaces the bodies of directory tree contexts with regenerated ones, as a single atomic update.
+// Every id must be a tree context in the plan. The plan's token limit applies as with any update, and unchanged trees aren't stored.
+// The caller commits the response's Msg if it isn't empty.
+func RefreshTreeContexts(params RefreshTreeContextsParams) (*shared.RefreshTreeContextResponse, error) {
+	trees := make(map[string]string, len(params.Req.Trees)+len(params.Req.Paths))
+	for id, body := range params.Req.Trees {
+		trees[id] = body
+	}
+	for id, paths := range params.Req.Paths {
+		if _, ok := trees[id]; ok {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("tree %s has both a body and paths", id)}
+		}
+		trees[id] = shared.BuildDirectoryTree(paths)
+	}
+
+	if len(trees) == 0 {
+		return nil, &ContextRequestError{Msg: "no directory trees to refresh"}
+	}
+
+	contextsById := make(map[string]*Context)
+	var missingIds []string
+	updateReq := shared.UpdateContextRequest{}
+
+	for id, body := range trees {
+		// ids are uuids, which also keeps them from reaching outside the context dir
+		if _, err := uuid.Parse(id); err != nil {
+			missingIds = append(missingIds, id)
+			continue
+		}
+
+		context, err := GetContext(params.OrgId, params.Plan.Id, id, false)
+		if errors.Is(err, os.ErrNotExist) {
+			missingIds = append(missingIds, id)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error getting context: %v", err)
+		}
+
+		if context.ContextType != shared.ContextDirectoryTreeType {
+			return nil, &ContextRequestError{
+				Msg: fmt.Sprintf("%s isn't a directory tree", context.Name),
+			}
+		}
+
+		contextsById[id] = context
+		updateReq[id] = &shared.UpdateContextParams{
+			Body: body,
+			// a tree whose files were all removed or ignored is still a valid snapshot
+			EmptyBody: shared.EmptyBodyAllow,
+		}
+	}
+
+	if len(missingIds) > 0 {
+		sort.Strings(missingIds)
+		return nil, &ContextNotFoundError{Ids: missingIds}
+	}
+
+	updateRes, err := UpdateContexts(UpdateContextsParams{
+		Req:          &updateReq,
+		OrgId:        params.OrgId,
+		Plan:         params.Plan,
+		BranchName:   params.BranchName,
+		UserId:       params.UserId,
+		ContextsById: contextsById,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changedIds := []string{}
+	if !updateRes.MaxTokensExceeded {
+		unchanged := make(map[string]bool, len(updateRes.UnchangedIds))
+		for _, id := range updateRes.UnchangedIds {
+			unchanged[id] = true
+		}
+		for id := range trees {
+			if !unchanged[id] {
+				changedIds = append(changedIds, id)
+			}
+		}
+		sort.Strings(changedIds)
+	}
+
+	return &shared.RefreshTreeContextResponse{
+		UpdateContextResponse: *updateRes,
+		ChangedIds:            changedIds,
+	}, nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func TestFindNestedTrees(t *testing.T) {
+	tree := func(path, body string) *shared.LoadContextParams {
+		return &shared.LoadContextParams{ContextType: shared.ContextDirectoryTreeType, FilePath: path, Body: body}
+	}
+	existingTree := func(path, body string) *Context {
+		return &Context{ContextType: shared.ContextDirectoryTreeType, FilePath: path, Body: body}
+	}
+
+	allowed := tree("app", "app/a.go")
+	allowed.NestedTrees = shared.NestedTreesAllow
+
+	tests := []struct {
+		name     string
+		existing []*Context
+		req      shared.LoadContextRequest
+		// index into req of each nested tree, mapped to the covering tree's path
+		want map[int]string
+	}{
+		{
+			name:     "sibling prefix",
+			existing: []*Context{existingTree("app", "app/a.go\napp2/b.go")},
+			req:      shared.LoadContextRequest{tree("app2", "app2/b.go")},
+			want:     map[int]string{},
+		},
+		{
+			name: "sibling prefix in request",
+			req:  shared.LoadContextRequest{tree("app", "app/a.go\napp2/b.go"), tree("app2", "app2/b.go")},
+			want: map[int]string{},
+		},
+		{
+			name:     "nested in existing",
+			existing: []*Context{existingTree("app", "app/a.go\napp/pkg/b.go")},
+			req:      shared.LoadContextRequest{tree("app/pkg", "app/pkg/b.go")},
+			want:     map[int]string{0: "app"},
+		},
+		{
+			name:     "existing root tree",
+			existing: []*Context{existingTree(".", "main.go\napp/a.go\napp2/b.go")},
+			req:      shared.LoadContextRequest{tree("app", "app/a.go"), tree("app2", "app2/b.go")},
+			want:     map[int]string{0: ".", 1: "."},
+		},
+		{
+			name: "root tree in request",
+			req:  shared.LoadContextRequest{tree("app", "app/a.go"), tree(".", "main.go\napp/a.go")},
+			want: map[int]string{0: "."},
+		},
+		{
+			name:     "root tree missing a path",
+			existing: []*Context{existingTree(".", "main.go\napp/a.go")},
+			req:      shared.LoadContextRequest{tree("app", "app/a.go\napp/new.go")},
+			want:     map[int]string{},
+		},
+		{
+			name: "identical trees",
+			req:  shared.LoadContextRequest{tree("app", "app/a.go"), tree("app", "app/a.go")},
+			want: map[int]string{1: "app"},
+		},
+		{
+			name:     "identical to existing",
+			existing: []*Context{existingTree("app", "app/a.go")},
+			req:      shared.LoadContextRequest{tree("app", "app/a.go")},
+			want:     map[int]string{0: "app"},
+		},
+		{
+			name:     "nested trees allowed",
+			existing: []*Context{existingTree(".", "app/a.go")},
+			req:      shared.LoadContextRequest{allowed},
+			want:     map[int]string{},
+		},
+		{
+			name:     "not a tree",
+			existing: []*Context{existingTree(".", "app/a.go")},
+			req:      shared.LoadContextRequest{{ContextType: shared.ContextFileType, FilePath: "app", Body: "app/a.go"}},
+			want:     map[int]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := findNestedTrees(tt.existing, tt.req)
+
+			got := map[int]string{}
+			for i, params := range tt.req {
+				if path, ok := res[params]; ok {
+					got[i] = path
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPlanTreeContexts(t *testing.T) {
+	defaultBaseDir := BaseDir
+	BaseDir = t.TempDir()
+	defer func() { BaseDir = defaultBaseDir }()
+
+	contextDir := getPlanContextDir("org", "plan")
+	if err := os.MkdirAll(contextDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"tree.meta": `{"id":"tree","contextType":"directory tree","filePath":"app"}`,
+		"tree.body": "app/a.go\napp/b.go\n",
+		// a file context's body isn't read, so it's fine for it to be missing
+		"file.meta": `{"id":"file","contextType":"file","filePath":"app/a.go"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(contextDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trees, err := getPlanTreeContexts("org", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trees) != 1 || trees[0].Id != "tree" || trees[0].Body != files["tree.body"] {
+		t.Errorf("got %+v, want only the tree with its body", trees)
+	}
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func TestBodyForUpdate(t *testing.T) {
+	stored := "line 1\n"
+
+	tests := []struct {
+		name string
+		mode shared.UpdateContextMode
+		want string
+	}{
+		{"default replaces", "", "line 2\n"},
+		{"replace", shared.UpdateContextReplace, "line 2\n"},
+		{"append", shared.UpdateContextAppend, "line 1\nline 2\n"},
+		{"prepend", shared.UpdateContextPrepend, "line 2\nline 1\n"},
+	}
+
+	for _, test := range tests {
+		got, err := bodyForUpdate(stored, &shared.UpdateContextParams{Body: "line 2\n", Mode: test.mode})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	_, err := bodyForUpdate(stored, &shared.UpdateContextParams{Body: "x", Mode: "insert"})
+	if _, ok := err.(*ContextRequestError); !ok {
+		t.Errorf("expected a request error for an unsupported mode, got %v", err)
+	}
+}
+
+func TestUpdateContextBody(t *testing.T) {
+	defaultBaseDir := BaseDir
+	BaseDir = t.TempDir()
+	defer func() { BaseDir = defaultBaseDir }()
+
+	stored := "2024-04-01 deployed the api\n"
+	added := "2024-04-02 rolled back the api after errors in checkout\n"
+
+	storedTokens, err := shared.GetNumTokens(stored)
+	if err != nil {
+		// the encoding is downloaded on first use
+		t.Skipf("tokenizer unavailable: %v", err)
+	}
+
+	// only the body is read from disk, for contexts passed without it
+	contextDir := getPlanContextDir("org", "plan")
+	if err := os.MkdirAll(contextDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, "note.meta"), []byte(`{"id":"note"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, "note.body"), []byte(stored), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		mode             shared.UpdateContextMode
+		bodyLoaded       bool
+		tokenizerVersion int
+		want             string
+	}{
+		{"append", shared.UpdateContextAppend, true, shared.TokenizerVersion, stored + added},
+		{"prepend", shared.UpdateContextPrepend, true, shared.TokenizerVersion, added + stored},
+		{"append without body loaded", shared.UpdateContextAppend, false, shared.TokenizerVersion, stored + added},
+		{"prepend without body loaded", shared.UpdateContextPrepend, false, shared.TokenizerVersion, added + stored},
+		{"append with a stale count", shared.UpdateContextAppend, false, 0, stored + added},
+	}
+
+	for _, test := range tests {
+		// a stale count is off, so the diff has to come from a recount rather than from it
+		numTokens := storedTokens
+		if test.tokenizerVersion != shared.TokenizerVersion {
+			numTokens = storedTokens * 2
+		}
+
+		context := &Context{Id: "note", ContextType: shared.ContextNoteType, NumTokens: numTokens, TokenizerVersion: test.tokenizerVersion}
+		if test.bodyLoaded {
+			context.Body = stored
+		}
+		params := &shared.UpdateContextParams{Body: added, Mode: test.mode}
+
+		update, err := updateContextBody("org", "plan", context, params)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if update.unchanged {
+			t.Errorf("%s: got unchanged", test.name)
+		}
+		if update.body != test.want {
+			t.Errorf("%s: got body %q, want %q", test.name, update.body, test.want)
+		}
+		if params.Body != test.want || params.Mode != shared.UpdateContextReplace {
+			t.Errorf("%s: got params %q, %q, want the combined body as a replace", test.name, params.Body, params.Mode)
+		}
+
+		// the count is for the combined body, not just the added text
+		wantTokens, err := shared.GetNumTokens(test.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if update.numTokens != wantTokens {
+			t.Errorf("%s: got %d tokens, want %d", test.name, update.numTokens, wantTokens)
+		}
+		if update.baseNumTokens != storedTokens {
+			t.Errorf("%s: got base of %d tokens, want %d", test.name, update.baseNumTokens, storedTokens)
+		}
+		if diff := update.numTokens - update.baseNumTokens; diff <= 0 {
+			t.Errorf("%s: expected the update to add tokens, got a diff of %d", test.name, diff)
+		}
+	}
+}
+
+func TestMergeStagedUpdate(t *testing.T) {
+	replace := &shared.UpdateContextParams{Body: "a"}
+	appendB := &shared.UpdateContextParams{Body: "b", Mode: shared.UpdateContextAppend}
+	prependC := &shared.UpdateContextParams{Body: "c", Mode: shared.UpdateContextPrepend}
+
+	merged, err := mergeStagedUpdate(nil, appendB)
+	if err != nil || merged != appendB {
+		t.Errorf("expected an update with nothing staged to be staged as-is, got %v, %v", merged, err)
+	}
+
+	merged, err = mergeStagedUpdate(replace, appendB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Body != "ab" || merged.Mode != "" {
+		t.Errorf("append after replace: got body %q with mode %q", merged.Body, merged.Mode)
+	}
+
+	merged, err = mergeStagedUpdate(appendB, appendB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Body != "bb" || merged.Mode != shared.UpdateContextAppend {
+		t.Errorf("append after append: got body %q with mode %q", merged.Body, merged.Mode)
+	}
+
+	merged, err = mergeStagedUpdate(replace, prependC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Body != "ca" {
+		t.Errorf("prepend after replace: got body %q", merged.Body)
+	}
+
+	merged, err = mergeStagedUpdate(appendB, replace)
+	if err != nil || merged != replace {
+		t.Errorf("expected a replace to win, got %v, %v", merged, err)
+	}
+
+	_, err = mergeStagedUpdate(appendB, prependC)
+	if _, ok := err.(*ContextRequestError); !ok {
+		t.Errorf("expected a request error for a prepend after an append, got %v", err)
+	}
+}
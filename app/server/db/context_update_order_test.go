@@ -0,0 +1,36 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdatedContextsInOrder(t *testing.T) {
+	created := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	contextsById := map[string]*Context{
+		"c": {Id: "c", CreatedAt: created.Add(2 * time.Minute)},
+		"a": {Id: "a", CreatedAt: created.Add(time.Minute)},
+		// loaded in the same batch as "a"
+		"b": {Id: "b", CreatedAt: created.Add(time.Minute)},
+		"d": {Id: "d", CreatedAt: created},
+		// unchanged, so it has no body to store
+		"e": {Id: "e", CreatedAt: created},
+	}
+	bodiesById := map[string]string{"a": "a", "b": "b", "c": "c", "d": "d"}
+
+	want := []string{"d", "a", "b", "c"}
+
+	// map iteration order varies between runs, so check it's stable across several
+	for i := 0; i < 20; i++ {
+		var got []string
+		for _, context := range updatedContextsInOrder(contextsById, bodiesById) {
+			got = append(got, context.Id)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got order %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/plandex/plandex/shared"
+)
+
+// GetUrlContexts reads the url contexts with the given ids, without bodies. Every id must be a url context in the plan.
+func GetUrlContexts(orgId, planId string, ids []string) ([]*Context, error) {
+	if len(ids) == 0 {
+		return nil, &ContextRequestError{Msg: "no urls to refresh"}
+	}
+
+	err := checkContextsPerRequest(len(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	var contexts []*Context
+	var missingIds []string
+	seen := make(map[string]bool)
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		// ids are uuids, which also keeps them from reaching outside the context dir
+		if _, err := uuid.Parse(id); err != nil {
+			missingIds = append(missingIds, id)
+			continue
+		}
+
+		context, err := GetContext(orgId, planId, id, false)
+		if errors.Is(err, os.ErrNotExist) {
+			missingIds = append(missingIds, id)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting context: %v", err)
+		}
+
+		if context.ContextType != shared.ContextURLType {
+			return nil, &ContextRequestError{Msg: fmt.Sprintf("context %s is a %s, not a url", id, context.ContextType)}
+		}
+
+		contexts = append(contexts, context)
+	}
+
+	if len(missingIds) > 0 {
+		sort.Strings(missingIds)
+		return nil, &ContextNotFoundError{Ids: missingIds}
+	}
+
+	return contexts, nil
+}
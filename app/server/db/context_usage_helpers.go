@@ -0,0 +1,83 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// max plans read at once by ListOrgContextSizes
+const orgContextSizesConcurrency = 8
+
+// ListOrgContextSizes returns every context in an org's plans, largest first, without reading bodies.
+// Each plan is read from a snapshot of its current head commit (see GetPlanContextsAtCommit), so no repo locks are taken.
+func ListOrgContextSizes(orgId string) ([]*shared.OrgContextSize, error) {
+	plans, err := ListOrgPlans(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var res []*shared.OrgContextSize
+	var firstErr error
+
+	sem := make(chan struct{}, orgContextSizesConcurrency)
+	var wg sync.WaitGroup
+
+	for _, plan := range plans {
+		wg.Add(1)
+		go func(plan *Plan) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			contexts, err := getPlanContextsAtHead(orgId, plan.Id)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("error getting contexts for plan %s: %v", plan.Id, err)
+				}
+				return
+			}
+
+			for _, context := range contexts {
+				res = append(res, &shared.OrgContextSize{
+					PlanId:      plan.Id,
+					PlanName:    plan.Name,
+					ContextId:   context.Id,
+					Name:        context.Name,
+					ContextType: context.ContextType,
+					FilePath:    context.FilePath,
+					Url:         context.Url,
+					NumTokens:   context.NumTokens,
+				})
+			}
+		}(plan)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].NumTokens > res[j].NumTokens
+	})
+
+	return res, nil
+}
+
+func getPlanContextsAtHead(orgId, planId string) ([]*Context, error) {
+	commit, err := GitHeadCommit(orgId, planId)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetPlanContextsAtCommit(orgId, planId, commit)
+}
@@ -28,6 +28,12 @@ type Org struct {
 	OwnerId            string  `db:"owner_id"`
 	IsTrial            bool    `db:"is_trial"`
 
+	// .plandexignore rules applied before a project's own .plandexignore
+	DefaultPlandexIgnore *string `db:"default_plandex_ignore"`
+
+	// overrides MaxContextsPerPlan for the org's plans when set
+	MaxContextsPerPlan *int `db:"max_contexts_per_plan"`
+
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
 }
@@ -129,11 +135,20 @@ type Plan struct {
 	TotalReplies    int        `db:"total_replies"`
 	ActiveBranches  int        `db:"active_branches"`
 	ArchivedAt      *time.Time `db:"archived_at,omitempty"`
-	CreatedAt       time.Time  `db:"created_at"`
-	UpdatedAt       time.Time  `db:"updated_at"`
+	// used by the context routes that don't name a branch. nil if the plan hasn't set one.
+	DefaultBranch *string `db:"default_branch,omitempty"`
+	// the highest context alias ever given out in the plan, so a removed context's alias isn't reused
+	ContextAliasSeq int       `db:"context_alias_seq"`
+	CreatedAt       time.Time `db:"created_at"`
+	UpdatedAt       time.Time `db:"updated_at"`
 }
 
 func (plan *Plan) ToApi() *shared.Plan {
+	var defaultBranch string
+	if plan.DefaultBranch != nil {
+		defaultBranch = *plan.DefaultBranch
+	}
+
 	return &shared.Plan{
 		Id:              plan.Id,
 		OwnerId:         plan.OwnerId,
@@ -143,6 +158,7 @@ func (plan *Plan) ToApi() *shared.Plan {
 		TotalReplies:    plan.TotalReplies,
 		ActiveBranches:  plan.ActiveBranches,
 		ArchivedAt:      plan.ArchivedAt,
+		DefaultBranch:   defaultBranch,
 		CreatedAt:       plan.CreatedAt,
 		UpdatedAt:       plan.UpdatedAt,
 	}
@@ -157,8 +173,9 @@ type Branch struct {
 	Name            string            `db:"name"`
 	Status          shared.PlanStatus `db:"status"`
 	Error           *string           `db:"error"`
-	ContextTokens   int               `db:"context_tokens"`
-	ConvoTokens     int               `db:"convo_tokens"`
+	ContextTokens   int64             `db:"context_tokens"`
+	ConvoTokens     int64             `db:"convo_tokens"`
+	MaxTokens       *int64            `db:"max_tokens"`
 	SharedWithOrgAt *time.Time        `db:"shared_with_org_at,omitempty"`
 	ArchivedAt      *time.Time        `db:"archived_at,omitempty"`
 	CreatedAt       time.Time         `db:"created_at"`
@@ -176,6 +193,7 @@ func (branch *Branch) ToApi() *shared.Branch {
 		Status:          branch.Status,
 		ContextTokens:   branch.ContextTokens,
 		ConvoTokens:     branch.ConvoTokens,
+		MaxTokens:       branch.MaxTokens,
 		SharedWithOrgAt: branch.SharedWithOrgAt,
 		ArchivedAt:      branch.ArchivedAt,
 		CreatedAt:       branch.CreatedAt,
@@ -190,7 +208,7 @@ type ConvoSummary struct {
 	LatestConvoMessageId        string    `db:"latest_convo_message_id"`
 	LatestConvoMessageCreatedAt time.Time `db:"latest_convo_message_created_at"`
 	Summary                     string    `db:"summary"`
-	Tokens                      int       `db:"tokens"`
+	Tokens                      int64     `db:"tokens"`
 	NumMessages                 int       `db:"num_messages"`
 	CreatedAt                   time.Time `db:"created_at"`
 }
@@ -275,6 +293,8 @@ type LockScope string
 const (
 	LockScopeRead  LockScope = "r"
 	LockScopeWrite LockScope = "w"
+	// a read of one branch's committed state, which doesn't check the branch out. See LockRepo.
+	LockScopeBranchRead LockScope = "b"
 )
 
 type repoLock struct {
@@ -289,40 +309,91 @@ type repoLock struct {
 	CreatedAt       time.Time `db:"created_at"`
 }
 
+type IdempotencyKey struct {
+	PlanId         string    `db:"plan_id"`
+	Branch         string    `db:"branch"`
+	IdempotencyKey string    `db:"idempotency_key"`
+	UserId         string    `db:"user_id"`
+	RequestSha     string    `db:"request_sha"`
+	StatusCode     *int      `db:"status_code"`
+	ContentType    *string   `db:"content_type"`
+	Response       []byte    `db:"response"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
 // Models below are stored in files, not in the database.
 // This allows us to store them in a git repo and use git to manage history.
 
 type Context struct {
-	Id              string             `json:"id"`
-	OrgId           string             `json:"orgId"`
-	OwnerId         string             `json:"ownerId"`
-	PlanId          string             `json:"planId"`
-	ContextType     shared.ContextType `json:"contextType"`
-	Name            string             `json:"name"`
-	Url             string             `json:"url"`
-	FilePath        string             `json:"filePath"`
-	Sha             string             `json:"sha"`
-	NumTokens       int                `json:"numTokens"`
-	Body            string             `json:"body,omitempty"`
-	ForceSkipIgnore bool               `json:"forceSkipIgnore"`
-	CreatedAt       time.Time          `json:"createdAt"`
-	UpdatedAt       time.Time          `json:"updatedAt"`
+	Id                    string                       `json:"id"`
+	Alias                 int                          `json:"alias,omitempty"`
+	OrgId                 string                       `json:"orgId"`
+	OwnerId               string                       `json:"ownerId"`
+	PlanId                string                       `json:"planId"`
+	ContextType           shared.ContextType           `json:"contextType"`
+	Name                  string                       `json:"name"`
+	Url                   string                       `json:"url"`
+	FilePath              string                       `json:"filePath"`
+	Sha                   string                       `json:"sha"`
+	NumTokens             int64                        `json:"numTokens"`
+	TokenizerVersion      int                          `json:"tokenizerVersion,omitempty"`
+	Body                  string                       `json:"body,omitempty"`
+	ForceSkipIgnore       bool                         `json:"forceSkipIgnore"`
+	FileMode              uint32                       `json:"fileMode,omitempty"`
+	Tags                  []string                     `json:"tags,omitempty"`
+	Pinned                bool                         `json:"pinned,omitempty"`
+	Source                string                       `json:"source,omitempty"`
+	StripComments         bool                         `json:"stripComments,omitempty"`
+	PreserveDocstrings    bool                         `json:"preserveDocstrings,omitempty"`
+	ResolveRelativeUrls   bool                         `json:"resolveRelativeUrls,omitempty"`
+	BaseUrl               string                       `json:"baseUrl,omitempty"`
+	ContentType           string                       `json:"contentType,omitempty"`
+	UrlSource             shared.UrlSource             `json:"urlSource,omitempty"`
+	Encoding              string                       `json:"encoding,omitempty"`
+	LineEndingsNormalized bool                         `json:"lineEndingsNormalized,omitempty"`
+	Deminified            bool                         `json:"deminified,omitempty"`
+	BomStripped           bool                         `json:"bomStripped,omitempty"`
+	TrailingNewline       shared.TrailingNewlinePolicy `json:"trailingNewline,omitempty"`
+	CreatedBy             string                       `json:"createdBy,omitempty"`
+	UpdatedBy             string                       `json:"updatedBy,omitempty"`
+	CreatedAt             time.Time                    `json:"createdAt"`
+	UpdatedAt             time.Time                    `json:"updatedAt"`
 }
 
 func (context *Context) ToApi() *shared.Context {
 	return &shared.Context{
-		Id:              context.Id,
-		OwnerId:         context.OwnerId,
-		ContextType:     context.ContextType,
-		Name:            context.Name,
-		Url:             context.Url,
-		FilePath:        context.FilePath,
-		Sha:             context.Sha,
-		NumTokens:       context.NumTokens,
-		Body:            context.Body,
-		ForceSkipIgnore: context.ForceSkipIgnore,
-		CreatedAt:       context.CreatedAt,
-		UpdatedAt:       context.UpdatedAt,
+		Id:                    context.Id,
+		Alias:                 context.Alias,
+		OwnerId:               context.OwnerId,
+		ContextType:           context.ContextType,
+		Name:                  context.Name,
+		Url:                   context.Url,
+		FilePath:              context.FilePath,
+		Sha:                   context.Sha,
+		NumTokens:             context.NumTokens,
+		TokenizerVersion:      context.TokenizerVersion,
+		Body:                  context.Body,
+		ForceSkipIgnore:       context.ForceSkipIgnore,
+		FileMode:              context.FileMode,
+		Tags:                  context.Tags,
+		Pinned:                context.Pinned,
+		Source:                context.Source,
+		StripComments:         context.StripComments,
+		PreserveDocstrings:    context.PreserveDocstrings,
+		ResolveRelativeUrls:   context.ResolveRelativeUrls,
+		BaseUrl:               context.BaseUrl,
+		ContentType:           context.ContentType,
+		UrlSource:             context.UrlSource,
+		Encoding:              context.Encoding,
+		LineEndingsNormalized: context.LineEndingsNormalized,
+		Deminified:            context.Deminified,
+		BomStripped:           context.BomStripped,
+		TrailingNewline:       context.TrailingNewline,
+		Oversized:             ContextOversizedTokens > 0 && context.NumTokens > ContextOversizedTokens,
+		CreatedBy:             context.CreatedBy,
+		UpdatedBy:             context.UpdatedBy,
+		CreatedAt:             context.CreatedAt,
+		UpdatedAt:             context.UpdatedAt,
 	}
 }
 
@@ -332,7 +403,7 @@ type ConvoMessage struct {
 	PlanId    string    `json:"planId"`
 	UserId    string    `json:"userId"`
 	Role      string    `json:"role"`
-	Tokens    int       `json:"tokens"`
+	Tokens    int64     `json:"tokens"`
 	Num       int       `json:"num"`
 	Message   string    `json:"message"`
 	Stopped   bool      `json:"stopped"`
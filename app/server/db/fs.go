@@ -61,6 +61,12 @@ func DeletePlanDir(orgId, planId string) error {
 		return fmt.Errorf("error deleting plan dir: %v", err)
 	}
 
+	err = os.RemoveAll(getPlanStagingDir(orgId, planId))
+
+	if err != nil {
+		return fmt.Errorf("error deleting plan staging dir: %v", err)
+	}
+
 	return nil
 }
 
@@ -68,6 +74,11 @@ func getPlanDir(orgId, planId string) string {
 	return filepath.Join(BaseDir, "orgs", orgId, "plans", planId)
 }
 
+// staged context changes live outside the plan repo so they're never picked up by a commit
+func getPlanStagingDir(orgId, planId string) string {
+	return filepath.Join(BaseDir, "orgs", orgId, "staging", planId)
+}
+
 func getPlanContextDir(orgId, planId string) string {
 	return filepath.Join(getPlanDir(orgId, planId), "context")
 }
@@ -2,6 +2,7 @@ package db
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -116,6 +117,72 @@ func GetLatestCommit(orgId, planId, branch string) (sha, body string, err error)
 	return sha, body, nil
 }
 
+// GitDeletedContextIdsSince returns the ids of contexts whose files were removed in commits up to the given commit after the given time
+func GitDeletedContextIdsSince(orgId, planId, commit string, since time.Time) ([]string, error) {
+	dir := getPlanDir(orgId, planId)
+
+	res, err := exec.Command("git", "-C", dir, "log", commit, "--since="+since.UTC().Format(time.RFC3339), "--diff-filter=D", "--name-only", "--pretty=format:", "--", "context/*.meta").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error getting deleted contexts for dir: %s, err: %v, output: %s", dir, err, string(res))
+	}
+
+	seen := map[string]bool{}
+	var ids []string
+	for _, line := range strings.Split(string(res), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		id := strings.TrimSuffix(filepath.Base(line), ".meta")
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// GitHeadCommit returns the sha of the commit currently checked out in the plan repo, or an empty string if there are no commits yet
+func GitHeadCommit(orgId, planId string) (string, error) {
+	dir := getPlanDir(orgId, planId)
+
+	res, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", "HEAD").CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(bytes.TrimSpace(res)) == 0 {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("error getting head commit for dir: %s, err: %v, output: %s", dir, err, string(res))
+	}
+
+	return strings.TrimSpace(string(res)), nil
+}
+
+// GitBranchCommit returns the sha of the commit at the tip of branch, without checking it out, or an empty string if the branch has no commits yet.
+// Returns a BranchNotFoundError if the branch isn't in the repo.
+func GitBranchCommit(orgId, planId, branch string) (string, error) {
+	dir := getPlanDir(orgId, planId)
+
+	res, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch+"^{commit}").CombinedOutput()
+	if err == nil {
+		return strings.TrimSpace(string(res)), nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 || len(bytes.TrimSpace(res)) != 0 {
+		return "", fmt.Errorf("error getting branch commit for dir: %s, err: %v, output: %s", dir, err, string(res))
+	}
+
+	// a new repo's branch is listed before it has a commit
+	branches, err := GitListBranches(orgId, planId)
+	if err != nil {
+		return "", err
+	}
+	return "", requireGitBranch(branches, branch)
+}
+
 func GitListBranches(orgId, planId string) ([]string, error) {
 	dir := getPlanDir(orgId, planId)
 
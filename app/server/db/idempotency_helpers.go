@@ -0,0 +1,75 @@
+package db
+
+import (
+	"fmt"
+)
+
+// a claimed key whose request never completed, e.g. because the server restarted, can be claimed again after this long
+const idempotencyKeyPendingTimeoutMinutes = 10
+
+// ClaimIdempotencyKey records that a request with the given key is being processed for a plan branch.
+// It returns true if the key was claimed. Otherwise it returns the existing record, which has no StatusCode while its request is still in progress.
+// Expired keys for the plan are removed first.
+func ClaimIdempotencyKey(planId, branch, key, userId, requestSha string) (bool, *IdempotencyKey, error) {
+	_, err := Conn.Exec(
+		`DELETE FROM context_idempotency_keys WHERE plan_id = $1 AND (
+			created_at < NOW() - make_interval(hours => $2) OR
+			(status_code IS NULL AND created_at < NOW() - make_interval(mins => $3))
+		)`,
+		planId, IdempotencyKeyTtlHours, idempotencyKeyPendingTimeoutMinutes,
+	)
+	if err != nil {
+		return false, nil, fmt.Errorf("error removing expired idempotency keys: %v", err)
+	}
+
+	res, err := Conn.Exec(
+		"INSERT INTO context_idempotency_keys (plan_id, branch, idempotency_key, user_id, request_sha) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING",
+		planId, branch, key, userId, requestSha,
+	)
+	if err != nil {
+		return false, nil, fmt.Errorf("error claiming idempotency key: %v", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, nil, fmt.Errorf("error claiming idempotency key: %v", err)
+	}
+
+	if rows == 1 {
+		return true, nil, nil
+	}
+
+	var existing IdempotencyKey
+	err = Conn.Get(&existing, "SELECT * FROM context_idempotency_keys WHERE plan_id = $1 AND branch = $2 AND idempotency_key = $3", planId, branch, key)
+	if err != nil {
+		return false, nil, fmt.Errorf("error getting idempotency key: %v", err)
+	}
+
+	return false, &existing, nil
+}
+
+// CompleteIdempotencyKey stores the response to a claimed key's request so it can be replayed
+func CompleteIdempotencyKey(planId, branch, key string, statusCode int, contentType string, response []byte) error {
+	_, err := Conn.Exec(
+		"UPDATE context_idempotency_keys SET status_code = $4, content_type = $5, response = $6 WHERE plan_id = $1 AND branch = $2 AND idempotency_key = $3",
+		planId, branch, key, statusCode, contentType, response,
+	)
+	if err != nil {
+		return fmt.Errorf("error completing idempotency key: %v", err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey removes a claimed key whose request failed, so a retry with the same key runs again
+func ReleaseIdempotencyKey(planId, branch, key string) error {
+	_, err := Conn.Exec(
+		"DELETE FROM context_idempotency_keys WHERE plan_id = $1 AND branch = $2 AND idempotency_key = $3",
+		planId, branch, key,
+	)
+	if err != nil {
+		return fmt.Errorf("error releasing idempotency key: %v", err)
+	}
+
+	return nil
+}
@@ -2,19 +2,52 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/plandex/plandex/shared"
 )
 
 const lockHeartbeatInterval = 700 * time.Millisecond
 const lockHeartbeatTimeout = 4 * time.Second
+const lockRetryInterval = 500 * time.Millisecond
+
+// LockTimeout is how long LockRepo waits for a conflicting lock to be released when the request doesn't set its own timeout
+var LockTimeout = time.Duration(envInt("PLANDEX_LOCK_TIMEOUT_SECONDS", 10)) * time.Second
+
+// ErrRepoLocked is returned by LockRepo when another lock on the plan is held past the timeout, or is a write on the same branch,
+// which isn't waited for since it's usually a long-running stream
+var ErrRepoLocked = errors.New("plan is currently being updated by another user")
+
+// BranchNotFoundError is returned by LockRepo when the branch to check out isn't in the plan's repo
+type BranchNotFoundError struct {
+	Branch string
+}
+
+func (e *BranchNotFoundError) Error() string {
+	return fmt.Sprintf("branch not found: %s", e.Branch)
+}
+
+// requireGitBranch returns a BranchNotFoundError unless branch is one of the repo's branches
+func requireGitBranch(branches []string, branch string) error {
+	if slices.Contains(branches, branch) {
+		return nil
+	}
+	return &BranchNotFoundError{Branch: branch}
+}
 
 // distributed locking to ensure only one user can write to a plan repo at a time
 // multiple readers are allowed, but read locks block writes
 // write lock is exclusive (blocks both reads and writes)
+//
+// read and write locks are per plan, since every branch shares the plan's single working tree and acquiring one checks out
+// its branch. A branch read lock is per branch: it's for readers that only read the branch's committed state, resolving the
+// commit with GitBranchCommit and reading it with GetPlanContextsAtCommit and friends. It doesn't touch the working tree,
+// so it only waits on a write to the same branch, or one with no branch, and plan-level locks don't wait on it.
 
 type LockRepoParams struct {
 	OrgId       string
@@ -25,13 +58,44 @@ type LockRepoParams struct {
 	PlanBuildId string
 	Ctx         context.Context
 	CancelFn    context.CancelFunc
+	// how long to wait for a conflicting lock to be released. LockTimeout if 0.
+	Timeout time.Duration
 }
 
 func LockRepo(params LockRepoParams) (string, error) {
-	return lockRepo(params, 0)
+	timeout := params.Timeout
+	if timeout <= 0 {
+		timeout = LockTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for numRetry := 0; ; numRetry++ {
+		id, canRetry, err := lockRepo(params)
+		if err == nil {
+			return id, nil
+		}
+
+		if !errors.Is(err, ErrRepoLocked) || !canRetry || time.Now().Add(lockRetryInterval).After(deadline) {
+			return "", err
+		}
+
+		log.Println("can't acquire lock yet. numRetry:", numRetry)
+
+		var done <-chan struct{}
+		if params.Ctx != nil {
+			done = params.Ctx.Done()
+		}
+		select {
+		case <-done:
+			return "", ErrRepoLocked
+		case <-time.After(lockRetryInterval):
+		}
+	}
 }
 
-func lockRepo(params LockRepoParams, numRetry int) (string, error) {
+// lockRepo makes a single attempt at acquiring the lock. If another lock prevents it, it returns ErrRepoLocked along with
+// whether that lock is worth waiting on. The transaction is always finished before returning, so waiting doesn't hold row locks.
+func lockRepo(params LockRepoParams) (string, bool, error) {
 	log.Println("locking repo")
 	// spew.Dump(params)
 
@@ -46,7 +110,7 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 
 	tx, err := Conn.Begin()
 	if err != nil {
-		return "", fmt.Errorf("error starting transaction: %v", err)
+		return "", false, fmt.Errorf("error starting transaction: %v", err)
 	}
 
 	// Ensure that rollback is attempted in case of failure
@@ -100,58 +164,30 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 
 		return nil
 	}
-	if err := fn(); err != nil {
-		return "", err
+	err = fn()
+	if err != nil {
+		return "", false, err
 	}
 
-	canAcquire := true
-	canRetry := true
-
 	// log.Println("locks:")
 	// spew.Dump(locks)
 
-	for _, lock := range locks {
-		lockBranch := ""
-		if lock.Branch != nil {
-			lockBranch = *lock.Branch
-		}
-
-		if scope == LockScopeRead {
-			canAcquireThisLock := lock.Scope == LockScopeRead && lockBranch == branch
-			if !canAcquireThisLock {
-				canAcquire = false
-			}
-		} else if scope == LockScopeWrite {
-			canAcquire = false
-
-			// if lock is for the same plan plan and branch, allow parallel writes
-			if planId == lock.PlanId && branch == lockBranch {
-				canAcquire = true
-			}
+	if scope == LockScopeBranchRead && branch == "" {
+		err = fmt.Errorf("a branch read lock requires a branch")
+		return "", false, err
+	}
 
-			if lock.Scope == LockScopeWrite && lockBranch == branch {
-				canRetry = false
-			}
-		} else {
-			err = fmt.Errorf("invalid lock scope: %v", scope)
-			return "", err
-		}
+	canAcquire, canRetry, err := canAcquireLock(planId, branch, scope, locks)
+	if err != nil {
+		return "", false, err
 	}
 
 	if !canAcquire {
-		log.Println("can't acquire lock. canRetry:", canRetry, "numRetry:", numRetry)
+		log.Println("can't acquire lock. canRetry:", canRetry)
 
-		if canRetry {
-			// 10 second timeout
-			if numRetry > 20 {
-				err = fmt.Errorf("plan is currently being updated by another user")
-				return "", err
-			}
-			time.Sleep(500 * time.Millisecond)
-			return lockRepo(params, numRetry+1)
-		}
-		err = fmt.Errorf("plan is currently being updated by another user")
-		return "", err
+		// set so the deferred rollback releases the row locks
+		err = ErrRepoLocked
+		return "", canRetry, err
 	}
 
 	// Insert the new lock
@@ -187,34 +223,25 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 		newLock.Branch,
 	).Scan(&newLock.Id)
 	if err != nil {
-		return "", fmt.Errorf("error inserting new lock: %v", err)
-	}
-
-	// check if git lock file exists
-	// remove it if so
-	err = gitRemoveIndexLockFileIfExists(getPlanDir(orgId, planId))
-	if err != nil {
-		return "", fmt.Errorf("error removing lock file: %v", err)
+		return "", false, fmt.Errorf("error inserting new lock: %v", err)
 	}
 
-	branches, err := GitListBranches(orgId, planId)
-	if err != nil {
-		return "", fmt.Errorf("error getting branches: %v", err)
-	}
-
-	log.Println("branches:", branches)
-
-	if branch != "" {
-		// checkout the branch
-		err = gitCheckoutBranch(getPlanDir(orgId, planId), branch)
+	if scope == LockScopeBranchRead {
+		// a plan-level lock may be using the working tree, so only the branch's ref is checked
+		_, err = GitBranchCommit(orgId, planId, branch)
+		if err != nil {
+			return "", false, err
+		}
+	} else {
+		err = prepareWorkingTree(orgId, planId, branch)
 		if err != nil {
-			return "", fmt.Errorf("error checking out branch: %v", err)
+			return "", false, err
 		}
 	}
 
 	// Commit the transaction
 	if err = tx.Commit(); err != nil {
-		return "", fmt.Errorf("error committing transaction: %v", err)
+		return "", false, fmt.Errorf("error committing transaction: %v", err)
 	}
 
 	// Start a goroutine to keep the lock alive
@@ -265,7 +292,128 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 
 	log.Println("repo locked. id:", newLock.Id)
 
-	return newLock.Id, nil
+	return newLock.Id, false, nil
+}
+
+// canAcquireLock checks a lock request against the plan's live locks, returning whether it can be acquired now and, if not,
+// whether it's worth waiting for
+func canAcquireLock(planId, branch string, scope LockScope, locks []*repoLock) (bool, bool, error) {
+	canAcquire := true
+	canRetry := true
+
+	for _, lock := range locks {
+		lockBranch := ""
+		if lock.Branch != nil {
+			lockBranch = *lock.Branch
+		}
+
+		if scope == LockScopeBranchRead {
+			// only a write that could move the branch's ref matters
+			if lock.Scope == LockScopeWrite && (lockBranch == branch || lockBranch == "") {
+				canAcquire = false
+			}
+		} else if lock.Scope == LockScopeBranchRead {
+			// branch reads don't use the working tree, and a write doesn't change commits they've already resolved
+			continue
+		} else if scope == LockScopeRead {
+			canAcquireThisLock := lock.Scope == LockScopeRead && lockBranch == branch
+			if !canAcquireThisLock {
+				canAcquire = false
+			}
+		} else if scope == LockScopeWrite {
+			// if lock is for the same plan plan and branch, allow parallel writes
+			if planId != lock.PlanId || branch != lockBranch {
+				canAcquire = false
+			}
+
+			if lock.Scope == LockScopeWrite && lockBranch == branch {
+				canRetry = false
+			}
+		} else {
+			return false, false, fmt.Errorf("invalid lock scope: %v", scope)
+		}
+	}
+
+	return canAcquire, canRetry, nil
+}
+
+// prepareWorkingTree clears a stale git index lock and checks out branch, for a lock that uses the plan's working tree
+func prepareWorkingTree(orgId, planId, branch string) error {
+	// check if git lock file exists
+	// remove it if so
+	err := gitRemoveIndexLockFileIfExists(getPlanDir(orgId, planId))
+	if err != nil {
+		return fmt.Errorf("error removing lock file: %v", err)
+	}
+
+	branches, err := GitListBranches(orgId, planId)
+	if err != nil {
+		return fmt.Errorf("error getting branches: %v", err)
+	}
+
+	log.Println("branches:", branches)
+
+	if branch != "" {
+		// a missing branch would otherwise only show up as a failed checkout
+		err = requireGitBranch(branches, branch)
+		if err != nil {
+			return err
+		}
+
+		// checkout the branch
+		err = gitCheckoutBranch(getPlanDir(orgId, planId), branch)
+		if err != nil {
+			return fmt.Errorf("error checking out branch: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// GetPlanLocks returns the live locks on a plan, oldest first. It reads them without taking or waiting on a lock.
+// Expiry is checked in the database, against the same heartbeat timeout lockRepo uses.
+func GetPlanLocks(planId string) ([]*shared.PlanLock, error) {
+	query := `SELECT scope, branch, user_id, plan_build_id, EXTRACT(EPOCH FROM (NOW() - created_at))::float8
+		FROM repo_locks
+		WHERE plan_id = $1 AND last_heartbeat_at > NOW() - make_interval(secs => $2)
+		ORDER BY created_at`
+
+	rows, err := Conn.Query(query, planId, lockHeartbeatTimeout.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error getting repo locks: %v", err)
+	}
+	defer rows.Close()
+
+	locks := []*shared.PlanLock{}
+	for rows.Next() {
+		var scope LockScope
+		var branch, planBuildId *string
+		var lock shared.PlanLock
+
+		err := rows.Scan(&scope, &branch, &lock.UserId, &planBuildId, &lock.HeldForSeconds)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning repo lock: %v", err)
+		}
+
+		lock.Scope = "read"
+		if scope == LockScopeWrite {
+			lock.Scope = "write"
+		}
+		if branch != nil {
+			lock.Branch = *branch
+		}
+		if planBuildId != nil {
+			lock.PlanBuildId = *planBuildId
+		}
+
+		locks = append(locks, &lock)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading repo locks: %v", err)
+	}
+
+	return locks, nil
 }
 
 func UnlockRepo(id string) error {
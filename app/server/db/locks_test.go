@@ -0,0 +1,180 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// initTestPlanRepo creates the plan repo for org and plan in a temp BaseDir, running each set of git args in it
+func initTestPlanRepo(t *testing.T, cmds ...[]string) string {
+	t.Helper()
+
+	defaultBaseDir := BaseDir
+	BaseDir = t.TempDir()
+	t.Cleanup(func() { BaseDir = defaultBaseDir })
+
+	dir := getPlanDir("org", "plan")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range cmds {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("git unavailable: %v: %s", err, out)
+		}
+	}
+
+	return dir
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestRequireGitBranch(t *testing.T) {
+	initTestPlanRepo(t,
+		[]string{"init", "-q", "-b", "main"},
+		[]string{"commit", "-q", "--allow-empty", "-m", "init"},
+		[]string{"branch", "feature"},
+	)
+
+	branches, err := GitListBranches("org", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, branch := range []string{"main", "feature"} {
+		if err := requireGitBranch(branches, branch); err != nil {
+			t.Errorf("expected %s to exist, got %v", branch, err)
+		}
+	}
+
+	err = requireGitBranch(branches, "missing")
+	var notFoundErr *BranchNotFoundError
+	if !errors.As(err, &notFoundErr) || notFoundErr.Branch != "missing" {
+		t.Errorf("expected a not found error for a missing branch, got %v", err)
+	}
+}
+
+func TestCanAcquireLock(t *testing.T) {
+	lock := func(scope LockScope, branch string) *repoLock {
+		l := &repoLock{PlanId: "plan", Scope: scope}
+		if branch != "" {
+			l.Branch = &branch
+		}
+		return l
+	}
+
+	tests := []struct {
+		name      string
+		scope     LockScope
+		branch    string
+		held      []*repoLock
+		want      bool
+		wantRetry bool
+	}{
+		{"branch read during a write to another branch", LockScopeBranchRead, "b", []*repoLock{lock(LockScopeWrite, "a")}, true, true},
+		{"branch read during a write to the same branch", LockScopeBranchRead, "b", []*repoLock{lock(LockScopeWrite, "b")}, false, true},
+		{"branch read during a plan-level write", LockScopeBranchRead, "b", []*repoLock{lock(LockScopeWrite, "")}, false, true},
+		{"branch read during a read of another branch", LockScopeBranchRead, "b", []*repoLock{lock(LockScopeRead, "a")}, true, true},
+		{"write during a branch read of another branch", LockScopeWrite, "a", []*repoLock{lock(LockScopeBranchRead, "b")}, true, true},
+		{"write during a branch read of the same branch", LockScopeWrite, "a", []*repoLock{lock(LockScopeBranchRead, "a")}, true, true},
+		{"read during a branch read of another branch", LockScopeRead, "a", []*repoLock{lock(LockScopeBranchRead, "b")}, true, true},
+
+		// plan-level locks are unchanged
+		{"read during a write to another branch", LockScopeRead, "b", []*repoLock{lock(LockScopeWrite, "a")}, false, true},
+		{"read during a read of another branch", LockScopeRead, "b", []*repoLock{lock(LockScopeRead, "a")}, false, true},
+		{"write during a read of another branch", LockScopeWrite, "a", []*repoLock{lock(LockScopeRead, "b")}, false, true},
+		{"write during a write to the same branch", LockScopeWrite, "a", []*repoLock{lock(LockScopeWrite, "a")}, true, false},
+		{"write with one conflicting lock among others", LockScopeWrite, "a", []*repoLock{lock(LockScopeRead, "b"), lock(LockScopeRead, "a")}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotRetry, err := canAcquireLock("plan", tt.branch, tt.scope, tt.held)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got canAcquire %v, want %v", got, tt.want)
+			}
+			if !got && gotRetry != tt.wantRetry {
+				t.Errorf("got canRetry %v, want %v", gotRetry, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestGitBranchCommit(t *testing.T) {
+	dir := initTestPlanRepo(t,
+		[]string{"init", "-q", "-b", "main"},
+		[]string{"commit", "-q", "--allow-empty", "-m", "init"},
+		[]string{"checkout", "-q", "-b", "feature"},
+		[]string{"commit", "-q", "--allow-empty", "-m", "feature"},
+		[]string{"checkout", "-q", "main"},
+	)
+
+	got, err := GitBranchCommit("org", "plan", "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := gitOutput(t, dir, "rev-parse", "feature"); got != want {
+		t.Errorf("got %s, want feature's commit %s", got, want)
+	}
+
+	// resolving a branch doesn't check it out
+	if head := gitOutput(t, dir, "rev-parse", "--abbrev-ref", "HEAD"); head != "main" {
+		t.Errorf("got %s checked out, want main", head)
+	}
+
+	_, err = GitBranchCommit("org", "plan", "missing")
+	var notFoundErr *BranchNotFoundError
+	if !errors.As(err, &notFoundErr) || notFoundErr.Branch != "missing" {
+		t.Errorf("expected a not found error for a missing branch, got %v", err)
+	}
+}
+
+func TestGitBranchCommitNoCommits(t *testing.T) {
+	initTestPlanRepo(t, []string{"init", "-q", "-b", "main"})
+
+	got, err := GitBranchCommit("org", "plan", "main")
+	if err != nil || got != "" {
+		t.Errorf("got %q, %v for a branch with no commits, want an empty commit", got, err)
+	}
+}
+
+func TestPrepareWorkingTreeMissingBranch(t *testing.T) {
+	dir := initTestPlanRepo(t,
+		[]string{"init", "-q", "-b", "main"},
+		[]string{"commit", "-q", "--allow-empty", "-m", "init"},
+		[]string{"branch", "feature"},
+	)
+
+	// what a write lock, like a delete's, does on a branch with no ref: fail with a not found error before checking anything out
+	err := prepareWorkingTree("org", "plan", "missing")
+	var notFoundErr *BranchNotFoundError
+	if !errors.As(err, &notFoundErr) || notFoundErr.Branch != "missing" {
+		t.Errorf("expected a not found error for a missing branch, got %v", err)
+	}
+	if head := gitOutput(t, dir, "rev-parse", "--abbrev-ref", "HEAD"); head != "main" {
+		t.Errorf("got %s checked out, want main", head)
+	}
+
+	if err := prepareWorkingTree("org", "plan", "feature"); err != nil {
+		t.Fatal(err)
+	}
+	if head := gitOutput(t, dir, "rev-parse", "--abbrev-ref", "HEAD"); head != "feature" {
+		t.Errorf("got %s checked out, want feature", head)
+	}
+}
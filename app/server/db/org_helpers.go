@@ -124,6 +124,46 @@ func CreateOrg(req *shared.CreateOrgRequest, userId string, domain *string, tx *
 	return org, nil
 }
 
+func UpdateOrgDefaultIgnore(orgId, body string) error {
+	var value *string
+	if strings.TrimSpace(body) != "" {
+		value = &body
+	}
+
+	_, err := Conn.Exec("UPDATE orgs SET default_plandex_ignore = $1 WHERE id = $2", value, orgId)
+
+	if err != nil {
+		return fmt.Errorf("error updating org default ignore: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateOrgMaxContextsPerPlan sets the org's cap on contexts per plan. A nil max goes back to the server's MaxContextsPerPlan.
+func UpdateOrgMaxContextsPerPlan(orgId string, max *int) error {
+	_, err := Conn.Exec("UPDATE orgs SET max_contexts_per_plan = $1 WHERE id = $2", max, orgId)
+
+	if err != nil {
+		return fmt.Errorf("error updating org max contexts per plan: %v", err)
+	}
+
+	return nil
+}
+
+// GetMaxContextsPerPlan returns the org's cap on contexts per plan, or the server's if the org hasn't set one. 0 means no cap.
+func GetMaxContextsPerPlan(orgId string) (int, error) {
+	org, err := GetOrg(orgId)
+	if err != nil {
+		return 0, err
+	}
+
+	if org.MaxContextsPerPlan != nil {
+		return *org.MaxContextsPerPlan, nil
+	}
+
+	return MaxContextsPerPlan, nil
+}
+
 func GetOrgForDomain(domain string) (*Org, error) {
 	var org Org
 	err := Conn.Get(&org, "SELECT * FROM orgs WHERE domain = $1", domain)
@@ -106,7 +106,18 @@ func ListOwnedPlans(projectIds []string, userId string, archived bool) ([]*Plan,
 	return plans, nil
 }
 
-func AddPlanContextTokens(planId, branch string, addTokens int) error {
+func ListOrgPlans(orgId string) ([]*Plan, error) {
+	var plans []*Plan
+	err := Conn.Select(&plans, "SELECT * FROM plans WHERE org_id = $1", orgId)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing org plans: %v", err)
+	}
+
+	return plans, nil
+}
+
+func AddPlanContextTokens(planId, branch string, addTokens int64) error {
 	_, err := Conn.Exec("UPDATE branches SET context_tokens = context_tokens + $1 WHERE plan_id = $2 AND name = $3", addTokens, planId, branch)
 	if err != nil {
 		return fmt.Errorf("error updating plan tokens: %v", err)
@@ -114,6 +125,51 @@ func AddPlanContextTokens(planId, branch string, addTokens int) error {
 	return nil
 }
 
+// restorePlanContextTokens sets the branch's context token count back to one read before a multi-step change, undoing everything
+// the change applied, including recounts of stale token numbers that aren't part of any response's diff.
+// Must be called with the repo's write lock held, so no other write's tokens are undone.
+func restorePlanContextTokens(planId, branch string, tokens int64) error {
+	_, err := Conn.Exec("UPDATE branches SET context_tokens = $1 WHERE plan_id = $2 AND name = $3", tokens, planId, branch)
+	if err != nil {
+		return fmt.Errorf("error restoring plan tokens: %v", err)
+	}
+	return nil
+}
+
+// RecomputeBranchContextTokens sets the branch's context token count to the sum of its stored contexts, correcting drift left by a
+// write that stopped between storing contexts and updating the count. Returns the count before and after.
+// Must be called with the repo's write lock held, so the contexts read are the branch's and the count can't change underneath it.
+func RecomputeBranchContextTokens(orgId, planId, branchName string) (int64, int64, error) {
+	branch, err := GetDbBranch(planId, branchName)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting branch: %v", err)
+	}
+	if branch == nil {
+		return 0, 0, fmt.Errorf("branch not found")
+	}
+
+	contexts, err := GetPlanContexts(orgId, planId, false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting contexts: %v", err)
+	}
+
+	var contextTokens int64
+	for _, context := range contexts {
+		contextTokens += context.NumTokens
+	}
+
+	if contextTokens == branch.ContextTokens {
+		return branch.ContextTokens, contextTokens, nil
+	}
+
+	_, err = Conn.Exec("UPDATE branches SET context_tokens = $1 WHERE plan_id = $2 AND name = $3", contextTokens, planId, branchName)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error updating plan tokens: %v", err)
+	}
+
+	return branch.ContextTokens, contextTokens, nil
+}
+
 func AddPlanConvoMessage(msg *ConvoMessage, branch string) error {
 	errCh := make(chan error)
 
@@ -175,12 +231,12 @@ func SyncPlanTokens(orgId, planId, branch string) error {
 		}
 	}
 
-	contextTokens := 0
+	var contextTokens int64
 	for _, context := range contexts {
 		contextTokens += context.NumTokens
 	}
 
-	convoTokens := 0
+	var convoTokens int64
 	for _, msg := range convos {
 		convoTokens += msg.Tokens
 	}
@@ -399,6 +455,17 @@ func ValidatePlanAccess(planId, userId, orgId string) (*Plan, error) {
 	return nil, nil
 }
 
+// SetPlanDefaultBranch sets the branch used by context routes that don't name one. nil clears it.
+func SetPlanDefaultBranch(planId string, branch *string) error {
+	_, err := Conn.Exec("UPDATE plans SET default_branch = $1 WHERE id = $2", branch, planId)
+
+	if err != nil {
+		return fmt.Errorf("error updating plan default branch: %v", err)
+	}
+
+	return nil
+}
+
 func BumpPlanUpdatedAt(planId string, t time.Time) error {
 	_, err := Conn.Exec("UPDATE plans SET updated_at = $1 WHERE id = $2", t, planId)
 
@@ -466,6 +466,8 @@ func ApplyPlan(orgId, userId, branchName string, plan *Plan) error {
 					Name:        path,
 					FilePath:    path,
 					Body:        currentPlanState.CurrentPlanFiles.Files[path],
+					// files the plan created should be in context even if they're empty
+					EmptyBody: shared.EmptyBodyAllow,
 				})
 			}
 
@@ -497,6 +499,8 @@ func ApplyPlan(orgId, userId, branchName string, plan *Plan) error {
 				context := contextsByPath[path]
 				updateReq[context.Id] = &shared.UpdateContextParams{
 					Body: currentPlanState.CurrentPlanFiles.Files[path],
+					// the context should match the applied file even if the plan emptied it
+					EmptyBody: shared.EmptyBodyAllow,
 				}
 			}
 
@@ -540,11 +544,11 @@ func ApplyPlan(orgId, userId, branchName string, plan *Plan) error {
 
 	msg := "✅ Marked pending results as applied"
 
-	if loadContextRes != nil && !loadContextRes.MaxTokensExceeded {
+	if loadContextRes != nil && !loadContextRes.LimitExceeded() {
 		msg += "\n\n" + loadContextRes.Msg
 	}
 
-	if updateContextRes != nil && !updateContextRes.MaxTokensExceeded {
+	if updateContextRes != nil && !updateContextRes.MaxTokensExceeded && updateContextRes.Msg != "" {
 		msg += "\n\n" + updateContextRes.Msg
 	}
 
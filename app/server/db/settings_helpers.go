@@ -10,6 +10,32 @@ import (
 	"github.com/plandex/plandex/shared"
 )
 
+// GetPlanMaxTokens returns the context token limit for a plan: its planner model's max tokens (or the max-tokens override) less the tokens reserved for output
+func GetPlanMaxTokens(plan *Plan) (int64, error) {
+	settings, err := GetPlanSettings(plan, true)
+	if err != nil {
+		return 0, fmt.Errorf("error getting settings: %v", err)
+	}
+
+	return settings.GetPlannerEffectiveMaxTokens(), nil
+}
+
+// GetBranchMaxTokens returns the context token limit for a branch, which is its own MaxTokens when set, capped at the plan's limit.
+// The plan's limit is returned too, since a branch without its own limit uses it.
+func GetBranchMaxTokens(plan *Plan, branch *Branch) (maxTokens int64, planMaxTokens int64, err error) {
+	planMaxTokens, err = GetPlanMaxTokens(plan)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	maxTokens = planMaxTokens
+	if branch.MaxTokens != nil && *branch.MaxTokens > 0 && *branch.MaxTokens < planMaxTokens {
+		maxTokens = *branch.MaxTokens
+	}
+
+	return maxTokens, planMaxTokens, nil
+}
+
 func GetPlanSettings(plan *Plan, fillDefaultModelSet bool) (*shared.PlanSettings, error) {
 	planDir := getPlanDir(plan.OrgId, plan.Id)
 	settingsPath := filepath.Join(planDir, "settings.json")
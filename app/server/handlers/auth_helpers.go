@@ -13,20 +13,34 @@ import (
 )
 
 func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *types.ServerAuth {
-	log.Println("authenticating request")
+	auth, apiErr := authenticateToken(r.Header.Get("Authorization"), requireOrg)
 
-	authHeader := r.Header.Get("Authorization")
+	if apiErr != nil {
+		if apiErr.Type == shared.ApiErrorTypeOther {
+			http.Error(w, apiErr.Msg, apiErr.Status)
+		} else {
+			writeApiError(w, *apiErr)
+		}
+		return nil
+	}
+
+	return auth
+}
+
+// authenticateToken validates an Authorization header value, "Bearer " and the base64 encoded shared.AuthHeader, and
+// loads the user, and their org membership and permissions if requireOrg is set. It's shared by the http handlers and
+// the grpc context service. On failure it returns the error to respond with.
+func authenticateToken(authHeader string, requireOrg bool) (*types.ServerAuth, *shared.ApiError) {
+	log.Println("authenticating request")
 
 	if authHeader == "" {
 		log.Println("no auth header")
-		http.Error(w, "no auth header", http.StatusUnauthorized)
-		return nil
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusUnauthorized, Msg: "no auth header"}
 	}
 
 	if !strings.HasPrefix(authHeader, "Bearer ") {
 		log.Println("invalid auth header")
-		http.Error(w, "invalid auth header", http.StatusUnauthorized)
-		return nil
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusUnauthorized, Msg: "invalid auth header"}
 	}
 
 	// strip off the "Bearer " prefix
@@ -37,8 +51,7 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 
 	if err != nil {
 		log.Printf("error decoding auth token: %v\n", err)
-		http.Error(w, "error decoding auth token", http.StatusUnauthorized)
-		return nil
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusUnauthorized, Msg: "error decoding auth token"}
 	}
 
 	// parse the credentials
@@ -47,8 +60,7 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 
 	if err != nil {
 		log.Printf("error parsing auth token: %v\n", err)
-		http.Error(w, "error parsing auth token", http.StatusUnauthorized)
-		return nil
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusUnauthorized, Msg: "error parsing auth token"}
 	}
 
 	// validate the token
@@ -57,33 +69,30 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 	if err != nil {
 		log.Printf("error validating auth token: %v\n", err)
 
-		writeApiError(w, shared.ApiError{
+		return nil, &shared.ApiError{
 			Type:   shared.ApiErrorTypeInvalidToken,
 			Status: http.StatusUnauthorized,
 			Msg:    "Invalid auth token",
-		})
-		return nil
+		}
 	}
 
 	user, err := db.GetUser(authToken.UserId)
 
 	if err != nil {
 		log.Printf("error getting user: %v\n", err)
-		http.Error(w, "error getting user", http.StatusInternalServerError)
-		return nil
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusInternalServerError, Msg: "error getting user"}
 	}
 
 	if !requireOrg {
 		return &types.ServerAuth{
 			AuthToken: authToken,
 			User:      user,
-		}
+		}, nil
 	}
 
 	if parsed.OrgId == "" {
 		log.Println("no org id")
-		http.Error(w, "no org id", http.StatusUnauthorized)
-		return nil
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusUnauthorized, Msg: "no org id"}
 	}
 
 	// validate the org membership
@@ -91,8 +100,7 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 
 	if err != nil {
 		log.Printf("error validating org membership: %v\n", err)
-		http.Error(w, "error validating org membership", http.StatusInternalServerError)
-		return nil
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusInternalServerError, Msg: "error validating org membership"}
 	}
 
 	if !isMember {
@@ -101,8 +109,7 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 
 		if err != nil {
 			log.Printf("error getting invite for org user: %v\n", err)
-			http.Error(w, "error getting invite for org user", http.StatusInternalServerError)
-			return nil
+			return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusInternalServerError, Msg: "error getting invite for org user"}
 		}
 
 		if invite != nil {
@@ -112,14 +119,12 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 
 			if err != nil {
 				log.Printf("error accepting invite: %v\n", err)
-				http.Error(w, "error accepting invite", http.StatusInternalServerError)
-				return nil
+				return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusInternalServerError, Msg: "error accepting invite"}
 			}
 
 		} else {
 			log.Println("user is not a member of the org")
-			http.Error(w, "not a member of org", http.StatusUnauthorized)
-			return nil
+			return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusUnauthorized, Msg: "not a member of org"}
 		}
 	}
 
@@ -128,8 +133,7 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 
 	if err != nil {
 		log.Printf("error getting user permissions: %v\n", err)
-		http.Error(w, "error getting user permissions", http.StatusInternalServerError)
-		return nil
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Status: http.StatusInternalServerError, Msg: "error getting user permissions"}
 	}
 
 	// build the permissions map
@@ -145,7 +149,7 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 		User:        user,
 		OrgId:       parsed.OrgId,
 		Permissions: permissionsMap,
-	}
+	}, nil
 
 }
 
@@ -2,16 +2,66 @@ package handlers
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"plandex-server/db"
 	"plandex-server/types"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/plandex/plandex/shared"
+	"golang.org/x/sync/singleflight"
 )
 
-func loadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, loadReq *shared.LoadContextRequest, plan *db.Plan, branchName string) (*shared.LoadContextResponse, []*db.Context) {
+// writeJsonBytes writes an already marshalled json response, setting its Content-Type and Content-Length
+func writeJsonBytes(w http.ResponseWriter, r *http.Request, bytes []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+
+	_, err := w.Write(bytes)
+	if err != nil {
+		requestLogger(r).Error("Error writing response", "err", err)
+	}
+}
+
+// resolveBranch falls back to the plan's default branch when the request doesn't specify one, as with the context routes
+// under /plans/{planId}/context. Writes a 400 and returns an empty string if no branch can be resolved.
+func resolveBranch(w http.ResponseWriter, r *http.Request, plan *db.Plan) string {
+	logger := requestLogger(r)
+	vars := mux.Vars(r)
+	branchName := strings.TrimSpace(vars["branch"])
+
+	if branchName == "" && plan.DefaultBranch != nil {
+		branchName = strings.TrimSpace(*plan.DefaultBranch)
+	}
+
+	if branchName == "" {
+		logger.Info("Branch not specified and plan has no default branch")
+		http.Error(w, "Branch not specified and plan has no default branch", http.StatusBadRequest)
+		return ""
+	}
+
+	// lockRepo reads the branch from the route vars, so keep them in sync with the resolved branch
+	if vars != nil {
+		vars["branch"] = branchName
+	}
+
+	return branchName
+}
+
+const contextSourceHeader = "X-Plandex-Source"
+
+// lockAndLoadContexts takes the repo's write lock for loadContexts and releases it before returning
+func lockAndLoadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, loadReq *shared.LoadContextRequest, plan *db.Plan, branchName string) (*shared.LoadContextResponse, []*db.Context) {
 	var err error
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -24,6 +74,25 @@ func loadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth
 		}()
 	}
 
+	res, dbContexts, err := loadContexts(w, r, auth, loadReq, plan, branchName)
+	return res, dbContexts
+}
+
+// loadContexts loads contexts and commits them, and must be called with the repo's write lock held so the token limit check,
+// the write, and the commit can't interleave with another change to the branch. If it responds with an error or with
+// a limit exceeded, it returns a nil response, along with the error to pass to the unlock function.
+func loadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, loadReq *shared.LoadContextRequest, plan *db.Plan, branchName string) (*shared.LoadContextResponse, []*db.Context, error) {
+	logger := requestLogger(r)
+
+	// entries that don't name their own source take the request's
+	if source := strings.TrimSpace(r.Header.Get(contextSourceHeader)); source != "" {
+		for _, params := range *loadReq {
+			if params.Source == "" {
+				params.Source = source
+			}
+		}
+	}
+
 	res, dbContexts, err := db.LoadContexts(db.LoadContextsParams{
 		OrgId:      auth.OrgId,
 		Plan:       plan,
@@ -33,32 +102,526 @@ func loadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth
 	})
 
 	if err != nil {
-		log.Printf("Error loading contexts: %v\n", err)
+		logger.Error("Error loading contexts", "err", err)
+
+		var reqErr *db.ContextRequestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.Msg, http.StatusBadRequest)
+			return nil, nil, err
+		}
+
 		http.Error(w, "Error loading contexts: "+err.Error(), http.StatusInternalServerError)
-		return nil, nil
+		return nil, nil, err
 	}
 
-	if res.MaxTokensExceeded {
-		log.Printf("The total number of tokens (%d) exceeds the maximum allowed (%d)", res.TotalTokens, res.MaxTokens)
+	if res.LimitExceeded() {
+		logLimitExceeded(logger, res)
 		bytes, err := json.Marshal(res)
 
 		if err != nil {
-			log.Printf("Error marshalling response: %v\n", err)
+			logger.Error("Error marshalling response", "err", err)
 			http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
-			return nil, nil
+			return nil, nil, err
 		}
 
-		w.Write(bytes)
-		return nil, nil
+		writeJsonBytes(w, r, bytes)
+		return nil, nil, nil
 	}
 
 	err = db.GitAddAndCommit(auth.OrgId, plan.Id, branchName, res.Msg)
 
 	if err != nil {
-		log.Printf("Error committing changes: %v\n", err)
+		logger.Error("Error committing changes", "err", err)
 		http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
-		return nil, nil
+		return nil, nil, err
 	}
 
-	return res, dbContexts
+	return res, dbContexts, nil
+}
+
+// correctDriftedTokens recomputes the branch's context tokens after a write found them inconsistent with its contexts, and returns
+// the corrected total, or total if recomputing fails. The write has already been committed, so a failure here is only logged.
+func correctDriftedTokens(logger *slog.Logger, orgId, planId, branchName string, total int64) int64 {
+	oldTokens, newTokens, err := db.RecomputeBranchContextTokens(orgId, planId, branchName)
+	if err != nil {
+		logger.Error("Error recomputing context tokens", "err", err)
+		return total
+	}
+
+	logger.Warn("Corrected branch context tokens", "branch", branchName, "oldTokens", oldTokens, "newTokens", newTokens)
+	return newTokens
+}
+
+func logLimitExceeded(logger *slog.Logger, res *shared.LoadContextResponse) {
+	if res.ContextCountExceeded {
+		logger.Info("The number of contexts exceeds the maximum allowed per plan", "numContexts", res.NumContexts, "maxContexts", res.MaxContexts)
+		return
+	}
+	logger.Info("The total number of tokens exceeds the maximum allowed", "totalTokens", res.TotalTokens, "maxTokens", res.MaxTokens)
+}
+
+// writeContextUpdateError responds with 404 for unknown context ids, 409 for contexts that changed since the client read them,
+// 400 for a rejected request, and 500 otherwise
+func writeContextUpdateError(w http.ResponseWriter, err error, prefix string) {
+	var conflictErr *db.ContextConflictError
+	if errors.As(err, &conflictErr) {
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeContextConflict,
+			Status: http.StatusConflict,
+			Msg:    "Contexts changed since they were read: " + strings.Join(conflictErr.Ids(), ", "),
+			ContextConflictError: &shared.ContextConflictError{
+				ShasById: conflictErr.ShasById,
+			},
+		})
+		return
+	}
+
+	var notFoundErr *db.ContextNotFoundError
+	if errors.As(err, &notFoundErr) {
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeContextNotFound,
+			Status: http.StatusNotFound,
+			Msg:    "Contexts not found: " + strings.Join(notFoundErr.Ids, ", "),
+			ContextNotFoundError: &shared.ContextNotFoundError{
+				ContextIds: notFoundErr.Ids,
+			},
+		})
+		return
+	}
+
+	var reqErr *db.ContextRequestError
+	if errors.As(err, &reqErr) {
+		http.Error(w, reqErr.Msg, http.StatusBadRequest)
+		return
+	}
+
+	http.Error(w, prefix+": "+err.Error(), http.StatusInternalServerError)
+}
+
+// tarballArchive returns the archive a tarball upload carries: the 'file' part of a multipart form, or else the whole body
+func tarballArchive(r *http.Request) (io.Reader, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("multipart form has no 'file' part")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+func isStageRequest(r *http.Request) bool {
+	return r.URL.Query().Get("stage") == "true"
+}
+
+// stageContextChanges adds changes to the user's staging area instead of applying them, and writes the StageContextResponse
+func stageContextChanges(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, plan *db.Plan, branchName string, params db.StageContextParams) {
+	params.OrgId = auth.OrgId
+	params.PlanId = plan.Id
+	params.Branch = branchName
+	params.UserId = auth.User.Id
+
+	staged, err := db.StageContextChanges(params)
+
+	if err != nil {
+		requestLogger(r).Error("Error staging context changes", "err", err)
+
+		var branchNotFoundErr *db.BranchNotFoundError
+		if errors.As(err, &branchNotFoundErr) {
+			http.Error(w, "Branch not found: "+branchNotFoundErr.Branch, http.StatusNotFound)
+			return
+		}
+
+		var reqErr *db.ContextRequestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.Msg, http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, "Error staging context changes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeStagedContext(w, r, staged)
+}
+
+func writeStagedContext(w http.ResponseWriter, r *http.Request, staged *shared.StagedContextChanges) {
+	res := shared.StageContextResponse{
+		Staged: staged,
+		Msg:    shared.SummaryForStagedContext(staged),
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		requestLogger(r).Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJsonBytes(w, r, bytes)
+}
+
+var contextsCsvHeader = []string{"id", "name", "type", "path", "url", "tokens", "tags", "last_updated_at"}
+
+// writeContextsCsv streams context token accounting as csv, one row per context, flushing as it goes so large plans aren't buffered in memory.
+// Bodies aren't included. Per-context usage isn't tracked, so the last update time is the freshest timestamp available.
+func writeContextsCsv(w http.ResponseWriter, contexts []*db.Context) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"context.csv\"")
+
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(contextsCsvHeader)
+	if err != nil {
+		return err
+	}
+
+	for i, context := range contexts {
+		err = writer.Write([]string{
+			context.Id,
+			context.Name,
+			string(context.ContextType),
+			context.FilePath,
+			context.Url,
+			strconv.FormatInt(context.NumTokens, 10),
+			strings.Join(context.Tags, ";"),
+			context.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+
+		if i%100 == 99 {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func writeContextExport(w http.ResponseWriter, params db.ContextExportParams) error {
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"context.tar.gz\"")
+
+	return db.WriteContextExport(w, params)
+}
+
+// writeContextsJsonStream writes contexts as a json array one element at a time, flushing as it goes, so large plans aren't marshalled into memory before the first byte is sent.
+// With changes set, the array is wrapped in the same object as shared.ListContextChangesResponse, taking DeletedIds and SyncedAt from changes.
+// Bodies are included only if they were read into contexts, as with the buffered response. The output decodes the same as json.Marshal's, except that no contexts is '[]' rather than 'null'.
+func writeContextsJsonStream(w http.ResponseWriter, contexts []*db.Context, changes *shared.ListContextChangesResponse) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	write := func(s string) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+
+	if changes != nil {
+		if err := write(`{"contexts":`); err != nil {
+			return err
+		}
+	}
+
+	if err := write("["); err != nil {
+		return err
+	}
+
+	for i, context := range contexts {
+		if i > 0 {
+			if err := write(","); err != nil {
+				return err
+			}
+		}
+
+		if err := encoder.Encode(context.ToApi()); err != nil {
+			return err
+		}
+
+		if flusher != nil && i%100 == 99 {
+			flusher.Flush()
+		}
+	}
+
+	if err := write("]"); err != nil {
+		return err
+	}
+
+	if changes != nil {
+		if err := write(`,"deletedIds":`); err != nil {
+			return err
+		}
+		if err := encoder.Encode(changes.DeletedIds); err != nil {
+			return err
+		}
+		if err := write(`,"syncedAt":`); err != nil {
+			return err
+		}
+		if err := encoder.Encode(changes.SyncedAt); err != nil {
+			return err
+		}
+		if err := write("}"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseModifiedSince(s string) (time.Time, bool) {
+	if ts, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return ts, true
+	}
+
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), true
+	}
+
+	return time.Time{}, false
+}
+
+// filterModifiedContexts keeps contexts created or updated after since, and drops deleted ids that are present again
+func filterModifiedContexts(contexts []*db.Context, deletedIds []string, since time.Time) ([]*db.Context, []string) {
+	var modified []*db.Context
+	current := map[string]bool{}
+
+	for _, context := range contexts {
+		current[context.Id] = true
+		if context.CreatedAt.After(since) || context.UpdatedAt.After(since) {
+			modified = append(modified, context)
+		}
+	}
+
+	filteredIds := []string{}
+	for _, id := range deletedIds {
+		if !current[id] {
+			filteredIds = append(filteredIds, id)
+		}
+	}
+
+	return modified, filteredIds
+}
+
+type contextsSnapshot struct {
+	commit     string
+	contexts   []*db.Context
+	deletedIds []string
+	syncedAt   time.Time
+}
+
+var listContextsGroup singleflight.Group
+
+// listContextsCoalesced shares a single read of a plan's contexts between concurrent identical requests.
+// Callers must already be authorized for the plan, and must treat the returned contexts as read-only.
+// The key includes the branch's head commit, resolved under a branch read lock, so a request never joins a read from before a write
+// it could have seen. Writes commit before releasing their lock, so this holds across server instances too.
+//
+// The lock is only held long enough to resolve the head commit, and doesn't wait on writes to other branches. Contexts are then read from that commit, so a slow list
+// on a large plan doesn't block writers. The snapshot is consistent as of the head commit when the lock was taken: writes that start
+// after the lock is released aren't reflected, even if they finish before the list does.
+//
+// Contexts are read without bodies. The snapshot's commit can be passed to db.GetContextBodiesAtCommit to read bodies from the same commit.
+func listContextsCoalesced(logger *slog.Logger, auth *types.ServerAuth, plan *db.Plan, branchName string, modifiedSince *time.Time) (*contextsSnapshot, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repoLockId, err := db.LockRepo(
+		db.LockRepoParams{
+			OrgId:    auth.OrgId,
+			UserId:   auth.User.Id,
+			PlanId:   plan.Id,
+			Branch:   branchName,
+			Scope:    db.LockScopeBranchRead,
+			Ctx:      ctx,
+			CancelFn: cancel,
+		},
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("error locking repo: %w", err)
+	}
+
+	syncedAt := time.Now().UTC()
+	commit, err := db.GitBranchCommit(auth.OrgId, plan.Id, branchName)
+
+	unlockErr := db.UnlockRepo(repoLockId)
+	if unlockErr != nil {
+		logger.Error("Error unlocking repo", "err", unlockErr)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting head commit: %v", err)
+	}
+
+	key := fmt.Sprintf("%s|%s|%s|%s", auth.OrgId, plan.Id, branchName, commit)
+	if modifiedSince != nil {
+		key += "|" + modifiedSince.UTC().Format(time.RFC3339Nano)
+	}
+
+	res, err, coalesced := listContextsGroup.Do(key, func() (interface{}, error) {
+		snapshot := &contextsSnapshot{commit: commit, syncedAt: syncedAt}
+
+		var err error
+		snapshot.contexts, err = db.GetPlanContextsAtCommit(auth.OrgId, plan.Id, commit)
+		if err != nil {
+			return nil, fmt.Errorf("error getting contexts: %v", err)
+		}
+
+		if modifiedSince != nil && commit != "" {
+			deletedIds, err := db.GitDeletedContextIdsSince(auth.OrgId, plan.Id, commit, *modifiedSince)
+			if err != nil {
+				return nil, fmt.Errorf("error getting deleted contexts: %v", err)
+			}
+
+			snapshot.contexts, snapshot.deletedIds = filterModifiedContexts(snapshot.contexts, deletedIds, *modifiedSince)
+		}
+
+		return snapshot, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if coalesced {
+		logger.Info("Shared list contexts result", "planId", plan.Id, "branch", branchName)
+	}
+
+	return res.(*contextsSnapshot), nil
+}
+
+// per-context usage isn't tracked, so lastUsedAt sorts by the last update time, matching the csv export
+var contextSortLess = map[string]func(a, b *db.Context) bool{
+	"tokens":     func(a, b *db.Context) bool { return a.NumTokens < b.NumTokens },
+	"name":       func(a, b *db.Context) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"path":       func(a, b *db.Context) bool { return a.FilePath < b.FilePath },
+	"type":       func(a, b *db.Context) bool { return a.ContextType < b.ContextType },
+	"createdAt":  func(a, b *db.Context) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"updatedAt":  func(a, b *db.Context) bool { return a.UpdatedAt.Before(b.UpdatedAt) },
+	"lastUsedAt": func(a, b *db.Context) bool { return a.UpdatedAt.Before(b.UpdatedAt) },
+}
+
+// parseContextListFilter reads the type, pathPrefix, and source query params. type is a comma-separated list of short type names like file,url,tree.
+func parseContextListFilter(r *http.Request) (db.ContextListFilter, error) {
+	filter := db.ContextListFilter{
+		PathPrefix: r.URL.Query().Get("pathPrefix"),
+		Source:     strings.TrimSpace(r.URL.Query().Get("source")),
+	}
+
+	if s := r.URL.Query().Get("type"); s != "" {
+		for _, name := range strings.Split(s, ",") {
+			contextType, ok := shared.ParseContextTypeName(strings.TrimSpace(name))
+			if !ok {
+				return filter, fmt.Errorf("invalid type '%s', expected one of: %s", name, shared.ContextTypeNames)
+			}
+			filter.Types = append(filter.Types, contextType)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseContextPaging reads the limit and offset query params. A limit of 0 means no limit.
+func parseContextPaging(r *http.Request) (limit, offset int, err error) {
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("invalid limit '%s', expected a positive integer", s)
+		}
+	}
+
+	if s := r.URL.Query().Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset '%s', expected a non-negative integer", s)
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// pageContexts returns the page of contexts for limit and offset without copying
+func pageContexts(contexts []*db.Context, limit, offset int) []*db.Context {
+	if offset >= len(contexts) {
+		return nil
+	}
+	contexts = contexts[offset:]
+	if limit > 0 && limit < len(contexts) {
+		contexts = contexts[:limit]
+	}
+	return contexts
+}
+
+// parseContextSort validates the sort and order query params. An empty key keeps the default creation order.
+func parseContextSort(r *http.Request) (key string, desc bool, err error) {
+	key = r.URL.Query().Get("sort")
+	order := strings.ToLower(r.URL.Query().Get("order"))
+
+	if key != "" {
+		if _, ok := contextSortLess[key]; !ok {
+			keys := make([]string, 0, len(contextSortLess))
+			for k := range contextSortLess {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return "", false, fmt.Errorf("invalid sort '%s', expected one of: %s", key, strings.Join(keys, ", "))
+		}
+	}
+
+	switch order {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return "", false, fmt.Errorf("invalid order '%s', expected asc or desc", order)
+	}
+
+	return key, desc, nil
+}
+
+// sortContexts returns a sorted copy, leaving the input untouched since it may be shared between coalesced requests.
+// Ties keep their default order.
+func sortContexts(contexts []*db.Context, key string, desc bool) []*db.Context {
+	if key == "" && !desc {
+		return contexts
+	}
+
+	sorted := slices.Clone(contexts)
+
+	if key == "" {
+		slices.Reverse(sorted)
+		return sorted
+	}
+
+	less := contextSortLess[key]
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if desc {
+			return less(sorted[j], sorted[i])
+		}
+		return less(sorted[i], sorted[j])
+	})
+
+	return sorted
 }
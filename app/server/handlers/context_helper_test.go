@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"plandex-server/db"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/plandex/plandex/shared"
+)
+
+func TestWriteJsonBytes(t *testing.T) {
+	body := []byte(`{"msg":"ok"}`)
+
+	rec := httptest.NewRecorder()
+	writeJsonBytes(rec, httptest.NewRequest(http.MethodGet, "/", nil), body)
+
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(body)) {
+		t.Errorf("got Content-Length %q, want %d", got, len(body))
+	}
+	if rec.Body.String() != string(body) {
+		t.Errorf("got body %q, want %q", rec.Body.String(), string(body))
+	}
+}
+
+func TestWriteJsonBytesGzipped(t *testing.T) {
+	small := []byte(`{"msg":"ok"}`)
+	large := []byte(`{"msg":"` + strings.Repeat("a", db.GzipMinBytes+1) + `"}`)
+
+	var body []byte
+	r := mux.NewRouter()
+	r.Use(GzipContextMiddleware)
+	r.HandleFunc(ContextRoutePrefix, func(w http.ResponseWriter, r *http.Request) {
+		writeJsonBytes(w, r, body)
+	})
+
+	for _, test := range []struct {
+		name     string
+		body     []byte
+		wantGzip bool
+	}{
+		{"small", small, false},
+		{"large", large, true},
+	} {
+		body = test.body
+
+		req := httptest.NewRequest(http.MethodGet, "/plans/p1/main/context", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s: got Content-Type %q, want application/json", test.name, got)
+		}
+
+		gzipped := rec.Header().Get("Content-Encoding") == "gzip"
+		if gzipped != test.wantGzip {
+			t.Errorf("%s: got gzipped %v, want %v", test.name, gzipped, test.wantGzip)
+		}
+
+		// the length set by the handler is for the uncompressed body, so it's dropped when the response is gzipped
+		wantLength := strconv.Itoa(len(test.body))
+		if test.wantGzip {
+			wantLength = ""
+		}
+		if got := rec.Header().Get("Content-Length"); got != wantLength {
+			t.Errorf("%s: got Content-Length %q, want %q", test.name, got, wantLength)
+		}
+	}
+}
+
+func TestGzipRequestBodyLimit(t *testing.T) {
+	defaultMax := db.GzipRequestMaxBytes
+	db.GzipRequestMaxBytes = 1024
+	defer func() { db.GzipRequestMaxBytes = defaultMax }()
+
+	var got []byte
+	r := mux.NewRouter()
+	r.Use(GzipContextMiddleware)
+	r.HandleFunc(ContextRoutePrefix, func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		got, err = io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("error reading body: %v", err)
+		}
+	})
+
+	for _, test := range []struct {
+		name       string
+		size       int
+		wantStatus int
+	}{
+		{"at limit", 1024, http.StatusOK},
+		{"over limit", 1025, http.StatusRequestEntityTooLarge},
+		// compresses to a few kb
+		{"gzip bomb", 10 * 1024 * 1024, http.StatusRequestEntityTooLarge},
+	} {
+		got = nil
+		body := bytes.Repeat([]byte("a"), test.size)
+
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(body)
+		gz.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/plans/p1/main/context", &buf)
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: got status %d, want %d", test.name, rec.Code, test.wantStatus)
+		}
+		if test.wantStatus == http.StatusOK && !bytes.Equal(got, body) {
+			t.Errorf("%s: handler got %d bytes, want %d", test.name, len(got), len(body))
+		}
+		if test.wantStatus != http.StatusOK && got != nil {
+			t.Errorf("%s: handler ran for a rejected body", test.name)
+		}
+	}
+}
+
+func TestResolveBranch(t *testing.T) {
+	defaultBranch := "dev"
+
+	for _, test := range []struct {
+		name          string
+		path          string
+		defaultBranch *string
+		wantBranch    string
+		wantStatus    int
+	}{
+		{"branch in route", "/plans/p1/feature/context", &defaultBranch, "feature", http.StatusOK},
+		{"default branch", "/plans/p1/context", &defaultBranch, "dev", http.StatusOK},
+		{"no default branch", "/plans/p1/context", nil, "", http.StatusBadRequest},
+	} {
+		plan := &db.Plan{Id: "p1", DefaultBranch: test.defaultBranch}
+
+		var got string
+		r := mux.NewRouter()
+		for _, prefix := range []string{ContextRoutePrefix, DefaultBranchContextRoutePrefix} {
+			r.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+				got = resolveBranch(w, r, plan)
+				if got != "" && mux.Vars(r)["branch"] != got {
+					t.Errorf("%s: expected the route vars to be updated for lockRepo", test.name)
+				}
+			})
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if got != test.wantBranch || rec.Code != test.wantStatus {
+			t.Errorf("%s: got branch %q and status %d, want %q and %d", test.name, got, rec.Code, test.wantBranch, test.wantStatus)
+		}
+	}
+}
+
+func TestWriteContextUpdateErrorNotFound(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeContextUpdateError(rec, fmt.Errorf("error updating: %w", &db.ContextNotFoundError{Ids: []string{"invalid"}}), "Error updating contexts")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	var apiErr shared.ApiError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+		t.Fatal(err)
+	}
+	if apiErr.ContextNotFoundError == nil || !reflect.DeepEqual(apiErr.ContextNotFoundError.ContextIds, []string{"invalid"}) {
+		t.Errorf("got %+v, want the unknown id", apiErr.ContextNotFoundError)
+	}
+}
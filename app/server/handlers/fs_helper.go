@@ -2,16 +2,22 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"plandex-server/db"
 	"plandex-server/types"
 	"runtime/debug"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// sent as Retry-After when the repo lock couldn't be acquired in time
+const lockRetryAfterSeconds = 2
+
 func lockRepo(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, scope db.LockScope, ctx context.Context, cancelFn context.CancelFunc, requireBranch bool) *func(err error) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
@@ -23,6 +29,12 @@ func lockRepo(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, sc
 		return nil
 	}
 
+	// don't wait on the lock past the request's own deadline
+	timeout := db.LockTimeout
+	if deadline, ok := r.Context().Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+
 	repoLockId, err := db.LockRepo(
 		db.LockRepoParams{
 			OrgId:    auth.OrgId,
@@ -32,12 +44,13 @@ func lockRepo(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, sc
 			Scope:    scope,
 			Ctx:      ctx,
 			CancelFn: cancelFn,
+			Timeout:  timeout,
 		},
 	)
 
 	if err != nil {
 		log.Printf("Error locking repo: %v\n", err)
-		http.Error(w, "Error locking repo: "+err.Error(), http.StatusInternalServerError)
+		writeLockRepoError(w, err)
 		return nil
 	}
 
@@ -53,10 +66,13 @@ func lockRepo(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, sc
 			http.Error(w, "Error locking repo: "+err.Error(), http.StatusInternalServerError)
 		}
 
-		// log.Println("Rolling back repo if error")
-		err = RollbackRepoIfErr(auth.OrgId, planId, err)
-		if err != nil {
-			log.Printf("Error rolling back repo: %v\n", err)
+		// a branch read doesn't own the working tree, which a write to another branch may be using
+		if scope != db.LockScopeBranchRead {
+			// log.Println("Rolling back repo if error")
+			err = RollbackRepoIfErr(auth.OrgId, planId, err)
+			if err != nil {
+				log.Printf("Error rolling back repo: %v\n", err)
+			}
 		}
 
 		err = db.UnlockRepo(repoLockId)
@@ -68,6 +84,23 @@ func lockRepo(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, sc
 	return &fn
 }
 
+// writeLockRepoError responds to an error from db.LockRepo: a 409 to retry if the repo is locked, or a 404 if the branch doesn't exist
+func writeLockRepoError(w http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrRepoLocked) {
+		w.Header().Set("Retry-After", strconv.Itoa(lockRetryAfterSeconds))
+		http.Error(w, "Error locking repo: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	var branchNotFoundErr *db.BranchNotFoundError
+	if errors.As(err, &branchNotFoundErr) {
+		http.Error(w, "Branch not found: "+branchNotFoundErr.Branch, http.StatusNotFound)
+		return
+	}
+
+	http.Error(w, "Error locking repo: "+err.Error(), http.StatusInternalServerError)
+}
+
 func RollbackRepoIfErr(orgId, planId string, err error) error {
 	// if no error, return nil
 	if err == nil {
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"plandex-server/db"
+	"testing"
+)
+
+func TestWriteLockRepoErrorMissingBranch(t *testing.T) {
+	defaultBaseDir := db.BaseDir
+	db.BaseDir = t.TempDir()
+	defer func() { db.BaseDir = defaultBaseDir }()
+
+	dir := filepath.Join(db.BaseDir, "orgs", "org", "plans", "plan")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("git unavailable: %v: %s", err, out)
+		}
+	}
+
+	// the branch check LockRepo makes before any handler reads the branch
+	_, err := db.GitBranchCommit("org", "plan", "missing")
+	if err == nil {
+		t.Fatal("expected an error for a missing branch")
+	}
+
+	rec := httptest.NewRecorder()
+	writeLockRepoError(rec, err)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d for a missing branch, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestWriteLockRepoError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"locked", db.ErrRepoLocked, http.StatusConflict},
+		{"branch not found", &db.BranchNotFoundError{Branch: "missing"}, http.StatusNotFound},
+		{"other", fmt.Errorf("error getting branches"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeLockRepoError(rec, tt.err)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		if tt.wantStatus == http.StatusConflict && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: expected a Retry-After header", tt.name)
+		}
+	}
+}
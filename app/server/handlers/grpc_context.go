@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/proto/contextpb"
+	"plandex-server/types"
+	"strings"
+
+	"github.com/plandex/plandex/shared"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ContextService serves the context endpoints over grpc. Each rpc authenticates, authorizes the plan, and takes the repo
+// lock the same way as its HTTP handler, then calls the same db functions.
+type ContextService struct {
+	contextpb.UnimplementedContextServiceServer
+}
+
+func NewContextService() *ContextService {
+	return &ContextService{}
+}
+
+var contextTypesByProto = map[contextpb.ContextType]shared.ContextType{
+	contextpb.ContextType_CONTEXT_TYPE_FILE:           shared.ContextFileType,
+	contextpb.ContextType_CONTEXT_TYPE_URL:            shared.ContextURLType,
+	contextpb.ContextType_CONTEXT_TYPE_NOTE:           shared.ContextNoteType,
+	contextpb.ContextType_CONTEXT_TYPE_DIRECTORY_TREE: shared.ContextDirectoryTreeType,
+	contextpb.ContextType_CONTEXT_TYPE_PIPED_DATA:     shared.ContextPipedDataType,
+	contextpb.ContextType_CONTEXT_TYPE_MAP:            shared.ContextMapType,
+}
+
+func (s *ContextService) ListContext(ctx context.Context, req *contextpb.ListContextRequest) (*contextpb.ListContextResponse, error) {
+	logger := slog.Default().With("rpc", "ListContext", "planId", req.GetPlanId())
+
+	auth, plan, branchName, err := authorizeGrpcPlan(ctx, req.GetPlanId(), req.GetBranch())
+	if err != nil {
+		return nil, err
+	}
+
+	filter := db.ContextListFilter{
+		PathPrefix: req.GetPathPrefix(),
+		Source:     strings.TrimSpace(req.GetSource()),
+	}
+	for _, name := range req.GetTypes() {
+		contextType, ok := shared.ParseContextTypeName(strings.TrimSpace(name))
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid type '%s', expected one of: %s", name, shared.ContextTypeNames)
+		}
+		filter.Types = append(filter.Types, contextType)
+	}
+
+	if req.GetSort() != "" {
+		if _, ok := contextSortLess[req.GetSort()]; !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid sort '%s'", req.GetSort())
+		}
+	}
+
+	snapshot, err := listContextsCoalesced(logger, auth, plan, branchName, nil)
+	if err != nil {
+		logger.Error("Error listing contexts", "err", err)
+		return nil, grpcLockError(err, "Error listing contexts")
+	}
+
+	dbContexts := sortContexts(db.FilterContexts(snapshot.contexts, filter), req.GetSort(), req.GetSortDesc())
+
+	if req.GetIncludeBodies() && len(dbContexts) > 0 {
+		// snapshot contexts may be shared with coalesced requests, so fill bodies on copies
+		withBodyContexts := make([]*db.Context, len(dbContexts))
+		for i, dbContext := range dbContexts {
+			c := *dbContext
+			withBodyContexts[i] = &c
+		}
+
+		err = db.GetContextBodiesAtCommit(auth.OrgId, plan.Id, snapshot.commit, withBodyContexts)
+		if err != nil {
+			var tooLargeErr *db.ContextBodiesTooLargeError
+			if errors.As(err, &tooLargeErr) {
+				return nil, status.Errorf(codes.ResourceExhausted, "Context bodies total %d bytes, over the %d byte limit for listing with bodies", tooLargeErr.Bytes, tooLargeErr.MaxBytes)
+			}
+
+			logger.Error("Error getting context bodies", "err", err)
+			return nil, status.Error(codes.Internal, "Error getting context bodies: "+err.Error())
+		}
+
+		dbContexts = withBodyContexts
+	}
+
+	res := &contextpb.ListContextResponse{}
+	for _, dbContext := range dbContexts {
+		res.Contexts = append(res.Contexts, contextToProto(dbContext.ToApi()))
+	}
+
+	return res, nil
+}
+
+func (s *ContextService) LoadContext(ctx context.Context, req *contextpb.LoadContextRequest) (*contextpb.LoadContextResponse, error) {
+	logger := slog.Default().With("rpc", "LoadContext", "planId", req.GetPlanId())
+
+	auth, plan, branchName, err := authorizeGrpcPlan(ctx, req.GetPlanId(), req.GetBranch())
+	if err != nil {
+		return nil, err
+	}
+
+	loadReq := make(shared.LoadContextRequest, 0, len(req.GetContexts()))
+	for _, params := range req.GetContexts() {
+		loadReq = append(loadReq, loadContextParamsFromProto(params))
+	}
+
+	unlockFn, err := lockGrpcRepo(auth, plan.Id, branchName, db.LockScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		unlockFn(err)
+	}()
+
+	res, _, err := db.LoadContexts(db.LoadContextsParams{
+		OrgId:      auth.OrgId,
+		Plan:       plan,
+		BranchName: branchName,
+		Req:        &loadReq,
+		UserId:     auth.User.Id,
+	})
+
+	if err != nil {
+		logger.Error("Error loading contexts", "err", err)
+		return nil, grpcContextUpdateError(err, "Error loading contexts")
+	}
+
+	if res.LimitExceeded() {
+		logLimitExceeded(logger, res)
+		return loadContextResponseToProto(res), nil
+	}
+
+	err = db.GitAddAndCommit(auth.OrgId, plan.Id, branchName, res.Msg)
+	if err != nil {
+		logger.Error("Error committing changes", "err", err)
+		return nil, status.Error(codes.Internal, "Error committing changes: "+err.Error())
+	}
+
+	return loadContextResponseToProto(res), nil
+}
+
+func (s *ContextService) UpdateContext(ctx context.Context, req *contextpb.UpdateContextRequest) (*contextpb.LoadContextResponse, error) {
+	logger := slog.Default().With("rpc", "UpdateContext", "planId", req.GetPlanId())
+
+	auth, plan, branchName, err := authorizeGrpcPlan(ctx, req.GetPlanId(), req.GetBranch())
+	if err != nil {
+		return nil, err
+	}
+
+	updateReq := make(shared.UpdateContextRequest, len(req.GetContexts()))
+	for id, params := range req.GetContexts() {
+		updateReq[id] = &shared.UpdateContextParams{
+			Body:        params.GetBody(),
+			RawBody:     params.GetRawBody(),
+			ExpectedSha: params.GetExpectedSha(),
+			Mode:        shared.UpdateContextMode(params.GetMode()),
+			Pinned:      params.Pinned,
+		}
+	}
+
+	unlockFn, err := lockGrpcRepo(auth, plan.Id, branchName, db.LockScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		unlockFn(err)
+	}()
+
+	updateRes, err := db.UpdateContexts(db.UpdateContextsParams{
+		Logger:     logger,
+		Req:        &updateReq,
+		OrgId:      auth.OrgId,
+		Plan:       plan,
+		BranchName: branchName,
+		UserId:     auth.User.Id,
+		Partial:    !req.GetAtomic(),
+	})
+
+	if err != nil {
+		logger.Error("Error updating contexts", "err", err)
+		return nil, grpcContextUpdateError(err, "Error updating contexts")
+	}
+
+	if updateRes.MaxTokensExceeded {
+		logLimitExceeded(logger, updateRes)
+		return loadContextResponseToProto(updateRes), nil
+	}
+
+	// no message means every context was unchanged, so nothing was written
+	if updateRes.Msg != "" {
+		err = db.GitAddAndCommit(auth.OrgId, plan.Id, branchName, updateRes.Msg)
+		if err != nil {
+			logger.Error("Error committing changes", "err", err)
+			return nil, status.Error(codes.Internal, "Error committing changes: "+err.Error())
+		}
+
+		if updateRes.TotalTokens < 0 {
+			updateRes.TotalTokens = correctDriftedTokens(logger, auth.OrgId, plan.Id, branchName, updateRes.TotalTokens)
+		}
+	}
+
+	return loadContextResponseToProto(updateRes), nil
+}
+
+func (s *ContextService) DeleteContext(ctx context.Context, req *contextpb.DeleteContextRequest) (*contextpb.DeleteContextResponse, error) {
+	logger := slog.Default().With("rpc", "DeleteContext", "planId", req.GetPlanId())
+
+	auth, plan, branchName, err := authorizeGrpcPlan(ctx, req.GetPlanId(), req.GetBranch())
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]bool, len(req.GetIds()))
+	for _, id := range req.GetIds() {
+		refs[id] = true
+	}
+
+	unlockFn, err := lockGrpcRepo(auth, plan.Id, branchName, db.LockScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		unlockFn(err)
+	}()
+
+	branch, err := db.GetDbBranch(plan.Id, branchName)
+	if err != nil {
+		logger.Error("Error getting branch", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting branch: "+err.Error())
+	}
+	if branch == nil {
+		err = fmt.Errorf("branch not found: %s", branchName)
+		return nil, status.Error(codes.NotFound, "Branch not found: "+branchName)
+	}
+
+	dbContexts, err := db.GetPlanContexts(auth.OrgId, plan.Id, false)
+	if err != nil {
+		logger.Error("Error getting contexts", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting contexts: "+err.Error())
+	}
+
+	notFoundIds := db.UnmatchedRefs(dbContexts, refs)
+	if len(notFoundIds) > 0 {
+		err = &db.ContextNotFoundError{Ids: notFoundIds}
+		return nil, status.Error(codes.NotFound, "Contexts not found: "+strings.Join(notFoundIds, ", "))
+	}
+
+	var toRemove []*db.Context
+	var toRemoveApiContexts []*shared.Context
+	var removeTokens, storedTokens int64
+	res := &contextpb.DeleteContextResponse{}
+	for _, dbContext := range dbContexts {
+		storedTokens += dbContext.NumTokens
+		if dbContext.InRefs(refs) {
+			toRemove = append(toRemove, dbContext)
+			toRemoveApiContexts = append(toRemoveApiContexts, dbContext.ToApi())
+			removeTokens += dbContext.NumTokens
+			res.DeletedIds = append(res.DeletedIds, dbContext.Id)
+		}
+	}
+
+	res.TokensRemoved = removeTokens
+	res.TotalTokens = branch.ContextTokens - removeTokens
+
+	if len(toRemove) == 0 {
+		res.Msg = "No contexts matched"
+		return res, nil
+	}
+
+	res.Msg = shared.SummaryForRemoveContext(toRemoveApiContexts, branch.ContextTokens) + "\n\n" + shared.TableForRemoveContext(toRemoveApiContexts)
+
+	err = db.ContextRemove(toRemove)
+	if err != nil {
+		logger.Error("Error deleting contexts", "err", err)
+		return nil, status.Error(codes.Internal, "Error deleting contexts: "+err.Error())
+	}
+
+	err = db.GitAddAndCommit(auth.OrgId, plan.Id, branchName, res.Msg)
+	if err != nil {
+		logger.Error("Error committing changes", "err", err)
+		return nil, status.Error(codes.Internal, "Error committing changes: "+err.Error())
+	}
+
+	err = db.AddPlanContextTokens(plan.Id, branchName, -removeTokens)
+	if err != nil {
+		logger.Error("Error updating plan tokens", "err", err)
+		return nil, status.Error(codes.Internal, "Error updating plan tokens: "+err.Error())
+	}
+
+	if storedTokens != branch.ContextTokens {
+		res.TotalTokens = correctDriftedTokens(logger, auth.OrgId, plan.Id, branchName, res.TotalTokens)
+	}
+
+	return res, nil
+}
+
+// authorizeGrpcPlan authenticates the "authorization" metadata like authenticate, checks plan access like authorizePlan,
+// and resolves the branch like resolveBranch
+func authorizeGrpcPlan(ctx context.Context, planId, branchName string) (*types.ServerAuth, *db.Plan, string, error) {
+	var authHeader string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authHeader = values[0]
+		}
+	}
+
+	auth, apiErr := authenticateToken(authHeader, true)
+	if apiErr != nil {
+		return nil, nil, "", grpcApiError(apiErr)
+	}
+
+	plan, err := db.ValidatePlanAccess(planId, auth.User.Id, auth.OrgId)
+	if err != nil {
+		return nil, nil, "", status.Error(codes.Internal, "error validating plan membership")
+	}
+	if plan == nil {
+		return nil, nil, "", status.Error(codes.PermissionDenied, "no access to plan")
+	}
+
+	branchName = strings.TrimSpace(branchName)
+	if branchName == "" && plan.DefaultBranch != nil {
+		branchName = strings.TrimSpace(*plan.DefaultBranch)
+	}
+	if branchName == "" {
+		return nil, nil, "", status.Error(codes.InvalidArgument, "Branch not specified and plan has no default branch")
+	}
+
+	return auth, plan, branchName, nil
+}
+
+// lockGrpcRepo takes the repo lock like lockRepo. The returned function rolls back uncommitted changes if err is set, then unlocks.
+func lockGrpcRepo(auth *types.ServerAuth, planId, branchName string, scope db.LockScope) (func(err error), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	repoLockId, err := db.LockRepo(
+		db.LockRepoParams{
+			OrgId:    auth.OrgId,
+			UserId:   auth.User.Id,
+			PlanId:   planId,
+			Branch:   branchName,
+			Scope:    scope,
+			Ctx:      ctx,
+			CancelFn: cancel,
+			Timeout:  db.LockTimeout,
+		},
+	)
+
+	if err != nil {
+		cancel()
+		return nil, grpcLockError(err, "Error locking repo")
+	}
+
+	return func(err error) {
+		err = RollbackRepoIfErr(auth.OrgId, planId, err)
+		if err != nil {
+			slog.Default().Error("Error rolling back repo", "err", err)
+		}
+
+		err = db.UnlockRepo(repoLockId)
+		if err != nil {
+			slog.Default().Error("Error unlocking repo", "err", err)
+		}
+	}, nil
+}
+
+func grpcLockError(err error, prefix string) error {
+	if errors.Is(err, db.ErrRepoLocked) {
+		return status.Error(codes.Aborted, prefix+": "+err.Error())
+	}
+
+	var branchNotFoundErr *db.BranchNotFoundError
+	if errors.As(err, &branchNotFoundErr) {
+		return status.Error(codes.NotFound, "Branch not found: "+branchNotFoundErr.Branch)
+	}
+
+	return status.Error(codes.Internal, prefix+": "+err.Error())
+}
+
+// grpcContextUpdateError maps errors the same way as writeContextUpdateError
+func grpcContextUpdateError(err error, prefix string) error {
+	var conflictErr *db.ContextConflictError
+	if errors.As(err, &conflictErr) {
+		return status.Error(codes.Aborted, "Contexts changed since they were read: "+strings.Join(conflictErr.Ids(), ", "))
+	}
+
+	var notFoundErr *db.ContextNotFoundError
+	if errors.As(err, &notFoundErr) {
+		return status.Error(codes.NotFound, "Contexts not found: "+strings.Join(notFoundErr.Ids, ", "))
+	}
+
+	var reqErr *db.ContextRequestError
+	if errors.As(err, &reqErr) {
+		return status.Error(codes.InvalidArgument, reqErr.Msg)
+	}
+
+	return status.Error(codes.Internal, prefix+": "+err.Error())
+}
+
+func grpcApiError(apiErr *shared.ApiError) error {
+	return status.Error(grpcCode(apiErr.Status), apiErr.Msg)
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	default:
+		return codes.Internal
+	}
+}
+
+func contextTypeToProto(contextType shared.ContextType) contextpb.ContextType {
+	for protoType, t := range contextTypesByProto {
+		if t == contextType {
+			return protoType
+		}
+	}
+	return contextpb.ContextType_CONTEXT_TYPE_UNSPECIFIED
+}
+
+func contextToProto(c *shared.Context) *contextpb.Context {
+	return &contextpb.Context{
+		Id:                    c.Id,
+		OwnerId:               c.OwnerId,
+		ContextType:           contextTypeToProto(c.ContextType),
+		Name:                  c.Name,
+		Url:                   c.Url,
+		FilePath:              c.FilePath,
+		Sha:                   c.Sha,
+		NumTokens:             c.NumTokens,
+		Body:                  c.Body,
+		ForceSkipIgnore:       c.ForceSkipIgnore,
+		FileMode:              c.FileMode,
+		Tags:                  c.Tags,
+		StripComments:         c.StripComments,
+		PreserveDocstrings:    c.PreserveDocstrings,
+		ResolveRelativeUrls:   c.ResolveRelativeUrls,
+		BaseUrl:               c.BaseUrl,
+		ContentType:           c.ContentType,
+		Encoding:              c.Encoding,
+		CreatedAt:             timestamppb.New(c.CreatedAt),
+		UpdatedAt:             timestamppb.New(c.UpdatedAt),
+		LineEndingsNormalized: c.LineEndingsNormalized,
+		Deminified:            c.Deminified,
+		BomStripped:           c.BomStripped,
+		TrailingNewline:       string(c.TrailingNewline),
+		UrlSource:             string(c.UrlSource),
+		Alias:                 int32(c.Alias),
+		Pinned:                c.Pinned,
+		Source:                c.Source,
+	}
+}
+
+func loadContextParamsFromProto(params *contextpb.LoadContextParams) *shared.LoadContextParams {
+	return &shared.LoadContextParams{
+		ContextType:         contextTypesByProto[params.GetContextType()],
+		Name:                params.GetName(),
+		Url:                 params.GetUrl(),
+		FilePath:            params.GetFilePath(),
+		Body:                params.GetBody(),
+		ForceSkipIgnore:     params.GetForceSkipIgnore(),
+		FileMode:            params.GetFileMode(),
+		StripComments:       params.GetStripComments(),
+		PreserveDocstrings:  params.GetPreserveDocstrings(),
+		ResolveRelativeUrls: params.GetResolveRelativeUrls(),
+		BaseUrl:             params.GetBaseUrl(),
+		ContentType:         params.GetContentType(),
+		Encoding:            params.GetEncoding(),
+		RawBody:             params.GetRawBody(),
+		TreePaths:           params.GetTreePaths(),
+		Dedupe:              params.GetDedupe(),
+		Source:              params.GetSource(),
+	}
+}
+
+func loadContextResponseToProto(res *shared.LoadContextResponse) *contextpb.LoadContextResponse {
+	var pairedFiles []*contextpb.PairedFile
+	for _, paired := range res.PairedFiles {
+		pairedFiles = append(pairedFiles, &contextpb.PairedFile{Path: paired.Path, ForPath: paired.ForPath})
+	}
+
+	return &contextpb.LoadContextResponse{
+		TokensAdded:          res.TokensAdded,
+		TotalTokens:          res.TotalTokens,
+		MaxTokensExceeded:    res.MaxTokensExceeded,
+		MaxTokens:            res.MaxTokens,
+		Msg:                  res.Msg,
+		Warnings:             res.Warnings,
+		TokensSaved:          res.TokensSaved,
+		ContextCountExceeded: res.ContextCountExceeded,
+		FailedById:           res.FailedById,
+		UnchangedIds:         res.UnchangedIds,
+		PairedFiles:          pairedFiles,
+	}
+}
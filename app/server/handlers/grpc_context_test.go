@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"plandex-server/proto/contextpb"
+	"testing"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newContextServiceClient(t *testing.T) contextpb.ContextServiceClient {
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	contextpb.RegisterContextServiceServer(server, NewContextService())
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return contextpb.NewContextServiceClient(conn)
+}
+
+func TestContextServiceRequiresAuth(t *testing.T) {
+	client := newContextServiceClient(t)
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+	}{
+		{"no metadata", nil},
+		{"not bearer", metadata.Pairs("authorization", "Basic abc")},
+		{"not base64", metadata.Pairs("authorization", "Bearer !!!")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if tt.md != nil {
+				ctx = metadata.NewOutgoingContext(ctx, tt.md)
+			}
+
+			calls := map[string]func() error{
+				"ListContext": func() error {
+					_, err := client.ListContext(ctx, &contextpb.ListContextRequest{PlanId: "plan"})
+					return err
+				},
+				"LoadContext": func() error {
+					_, err := client.LoadContext(ctx, &contextpb.LoadContextRequest{PlanId: "plan"})
+					return err
+				},
+				"UpdateContext": func() error {
+					_, err := client.UpdateContext(ctx, &contextpb.UpdateContextRequest{PlanId: "plan"})
+					return err
+				},
+				"DeleteContext": func() error {
+					_, err := client.DeleteContext(ctx, &contextpb.DeleteContextRequest{PlanId: "plan"})
+					return err
+				},
+			}
+
+			for rpc, call := range calls {
+				if code := status.Code(call()); code != codes.Unauthenticated {
+					t.Errorf("%s: got code %v, want Unauthenticated", rpc, code)
+				}
+			}
+		})
+	}
+}
+
+func TestGrpcCode(t *testing.T) {
+	tests := []struct {
+		status int
+		want   codes.Code
+	}{
+		{http.StatusBadRequest, codes.InvalidArgument},
+		{http.StatusUnauthorized, codes.Unauthenticated},
+		{http.StatusForbidden, codes.PermissionDenied},
+		{http.StatusNotFound, codes.NotFound},
+		{http.StatusConflict, codes.Aborted},
+		{http.StatusInternalServerError, codes.Internal},
+	}
+
+	for _, tt := range tests {
+		if got := grpcCode(tt.status); got != tt.want {
+			t.Errorf("grpcCode(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestContextTypeProtoRoundTrip(t *testing.T) {
+	for protoType, contextType := range contextTypesByProto {
+		if got := contextTypeToProto(contextType); got != protoType {
+			t.Errorf("contextTypeToProto(%q) = %v, want %v", contextType, got, protoType)
+		}
+	}
+
+	if got := contextTypeToProto(shared.ContextType("unknown")); got != contextpb.ContextType_CONTEXT_TYPE_UNSPECIFIED {
+		t.Errorf("got %v for an unknown type, want unspecified", got)
+	}
+
+	// unspecified is left for the server to infer
+	params := loadContextParamsFromProto(&contextpb.LoadContextParams{FilePath: "main.go"})
+	if params.ContextType != "" {
+		t.Errorf("got type %q for an unspecified type, want empty", params.ContextType)
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"plandex-server/db"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const ContextRoutePrefix = "/plans/{planId}/{branch}/context"
+
+// DefaultBranchContextRoutePrefix is for the same context routes on the plan's default branch
+const DefaultBranchContextRoutePrefix = "/plans/{planId}/context"
+
+// GzipContextMiddleware decodes gzipped request bodies and gzips responses for the context routes, so large file contents
+// aren't sent uncompressed. Handlers only ever see the decompressed body, so token counts and request hashes are unaffected.
+// Request bodies that decompress past db.GzipRequestMaxBytes get a 413. Responses under db.GzipMinBytes, and event streams,
+// are sent uncompressed.
+func GzipContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isContextRoute(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if strings.EqualFold(strings.TrimSpace(r.Header.Get("Content-Encoding")), "gzip") {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				requestLogger(r).Error("Error reading gzipped request body", "err", err)
+				http.Error(w, "Error reading gzipped request body", http.StatusBadRequest)
+				return
+			}
+			defer reader.Close()
+
+			r.Body = reader
+
+			// a small gzipped body can expand into an unbounded one, so past the cap it's read up front and
+			// rejected before the handler sees any of it
+			if db.GzipRequestMaxBytes > 0 {
+				body, err := io.ReadAll(io.LimitReader(reader, int64(db.GzipRequestMaxBytes)+1))
+				if err != nil {
+					requestLogger(r).Error("Error reading gzipped request body", "err", err)
+					http.Error(w, "Error reading gzipped request body", http.StatusBadRequest)
+					return
+				}
+				if len(body) > db.GzipRequestMaxBytes {
+					http.Error(w, fmt.Sprintf("Request body is over the %d byte limit once decompressed", db.GzipRequestMaxBytes), http.StatusRequestEntityTooLarge)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+		}
+
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, logger: requestLogger(r)}
+		defer gw.close()
+
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func isContextRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range []string{ContextRoutePrefix, DefaultBranchContextRoutePrefix} {
+		if tpl == prefix || strings.HasPrefix(tpl, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(encoding), "gzip") {
+			continue
+		}
+
+		// "gzip;q=0" means not acceptable
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response until it's known to be at least db.GzipMinBytes, then gzips the rest.
+// A response that ends, or is flushed, before then is sent as-is.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	logger *slog.Logger
+
+	status int
+	buf    bytes.Buffer
+	// set once it's decided whether to compress
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		w.buf.Write(b)
+		if w.buf.Len() < db.GzipMinBytes {
+			return len(b), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// start sends the headers and anything buffered so far, compressed or not
+func (w *gzipResponseWriter) start(compress bool) error {
+	w.decided = true
+
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+
+	// only successful bodies that aren't already encoded, compressed, or streamed are worth compressing
+	if header.Get("Content-Encoding") != "" ||
+		strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") ||
+		header.Get("Content-Type") == "application/gzip" ||
+		w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		compress = false
+	}
+
+	if compress {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	if compress {
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+		_, err := w.gz.Write(w.buf.Bytes())
+		w.buf.Reset()
+		return err
+	}
+
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// Flush sends a response still under the threshold uncompressed, since a flushing handler is streaming and
+// shouldn't wait on the buffer. Otherwise it flushes the compressed data written so far.
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if err := w.start(false); err != nil {
+			w.logger.Error("Error writing response", "err", err)
+			return
+		}
+	}
+
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			w.logger.Error("Error flushing gzipped response", "err", err)
+			return
+		}
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) close() {
+	if !w.decided {
+		// nothing was written at all
+		if w.status == 0 && w.buf.Len() == 0 {
+			return
+		}
+		if err := w.start(false); err != nil {
+			w.logger.Error("Error writing response", "err", err)
+		}
+		return
+	}
+
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			w.logger.Error("Error closing gzipped response", "err", err)
+		}
+	}
+}
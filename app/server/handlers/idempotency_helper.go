@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/types"
+	"strings"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentResponseWriter passes a response through while keeping a copy, so it can be stored for replay once the handler finishes
+type idempotentResponseWriter struct {
+	http.ResponseWriter
+	logger *slog.Logger
+	planId string
+	branch string
+	key    string
+	status int
+	body   bytes.Buffer
+	// set when a failure is reported after a 200 status was sent, as with an error event in an event stream
+	failed bool
+}
+
+func (w *idempotentResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotentResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps event streams working through the wrapper
+func (w *idempotentResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// markResponseFailed keeps a response from being replayed when it failed after its status was already sent
+func markResponseFailed(w http.ResponseWriter) {
+	if rec, ok := w.(*idempotentResponseWriter); ok {
+		rec.failed = true
+	}
+}
+
+// finish stores a successful response for replay, or releases the key so a retry runs again
+func (w *idempotentResponseWriter) finish() {
+	if w.status == http.StatusOK && !w.failed {
+		err := db.CompleteIdempotencyKey(w.planId, w.branch, w.key, w.status, w.Header().Get("Content-Type"), w.body.Bytes())
+		if err != nil {
+			w.logger.Error("Error storing idempotent response", "err", err)
+		}
+		return
+	}
+
+	err := db.ReleaseIdempotencyKey(w.planId, w.branch, w.key)
+	if err != nil {
+		w.logger.Error("Error releasing idempotency key", "err", err)
+	}
+}
+
+// beginIdempotentRequest handles the Idempotency-Key header for a request that changes a plan branch's context.
+// With no key, it returns w unchanged. If the key was already used for the same request, the stored response is replayed and ok is false.
+// Otherwise the key is claimed and the returned writer must be used for the response, with finish deferred so it runs after the repo is unlocked.
+// A key is scoped to the plan branch, and reusing it for a different request or by a different user is rejected.
+func beginIdempotentRequest(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, planId, branch string) (http.ResponseWriter, func(), bool) {
+	logger := requestLogger(r)
+	key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+	if key == "" {
+		return w, func() {}, true
+	}
+
+	if len(key) > 255 {
+		logger.Info("Idempotency key too long")
+		http.Error(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	// the same key on a different endpoint or with different params is a different request
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	requestSha := hex.EncodeToString(hash.Sum(nil))
+
+	claimed, existing, err := db.ClaimIdempotencyKey(planId, branch, key, auth.User.Id, requestSha)
+	if err != nil {
+		logger.Error("Error claiming idempotency key", "err", err)
+		http.Error(w, "Error claiming idempotency key: "+err.Error(), http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	if claimed {
+		rec := &idempotentResponseWriter{ResponseWriter: w, logger: logger, planId: planId, branch: branch, key: key}
+		return rec, rec.finish, true
+	}
+
+	if existing.UserId != auth.User.Id || existing.RequestSha != requestSha {
+		logger.Info("Idempotency key reused for a different request")
+		http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+		return nil, nil, false
+	}
+
+	if existing.StatusCode == nil {
+		logger.Info("Request with idempotency key still in progress")
+		http.Error(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+		return nil, nil, false
+	}
+
+	logger.Info("Replaying stored response for idempotency key")
+
+	if existing.ContentType != nil && *existing.ContentType != "" {
+		w.Header().Set("Content-Type", *existing.ContentType)
+	}
+	w.Header().Set("Idempotency-Replayed", "true")
+	w.WriteHeader(*existing.StatusCode)
+	w.Write(existing.Response)
+
+	return nil, nil, false
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"plandex-server/db"
 	"plandex-server/types"
+	"strconv"
 
 	"github.com/plandex/plandex/shared"
 )
@@ -212,3 +213,258 @@ func ListOrgRolesHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Write(bytes)
 }
+
+func GetOrgDefaultIgnoreHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request for GetOrgDefaultIgnoreHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	org, err := db.GetOrg(auth.OrgId)
+
+	if err != nil {
+		log.Printf("Error getting org: %v\n", err)
+		http.Error(w, "Error getting org: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var res shared.OrgDefaultIgnoreResponse
+	if org.DefaultPlandexIgnore != nil {
+		res.Body = *org.DefaultPlandexIgnore
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		log.Printf("Error marshalling response: %v\n", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully got org default ignore")
+
+	w.Write(bytes)
+}
+
+func UpdateOrgDefaultIgnoreHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request for UpdateOrgDefaultIgnoreHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	if auth.User.IsTrial {
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeTrialActionNotAllowed,
+			Status: http.StatusForbidden,
+			Msg:    "Anonymous trial user can't update org settings",
+		})
+		return
+	}
+
+	if !auth.HasPermission(types.PermissionManageOrgSettings) {
+		log.Println("User cannot manage org settings")
+		http.Error(w, "User cannot manage org settings", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v\n", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var req shared.UpdateOrgDefaultIgnoreRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		log.Printf("Error unmarshalling request: %v\n", err)
+		http.Error(w, "Error unmarshalling request", http.StatusBadRequest)
+		return
+	}
+
+	err = db.UpdateOrgDefaultIgnore(auth.OrgId, req.Body)
+
+	if err != nil {
+		log.Printf("Error updating org default ignore: %v\n", err)
+		http.Error(w, "Error updating org default ignore: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully updated org default ignore")
+}
+
+func GetOrgMaxContextsPerPlanHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request for GetOrgMaxContextsPerPlanHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	org, err := db.GetOrg(auth.OrgId)
+
+	if err != nil {
+		log.Printf("Error getting org: %v\n", err)
+		http.Error(w, "Error getting org: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := shared.OrgMaxContextsPerPlanResponse{
+		MaxContextsPerPlan: db.MaxContextsPerPlan,
+		IsDefault:          true,
+	}
+	if org.MaxContextsPerPlan != nil {
+		res.MaxContextsPerPlan = *org.MaxContextsPerPlan
+		res.IsDefault = false
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		log.Printf("Error marshalling response: %v\n", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully got org max contexts per plan")
+
+	w.Write(bytes)
+}
+
+func UpdateOrgMaxContextsPerPlanHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request for UpdateOrgMaxContextsPerPlanHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	if auth.User.IsTrial {
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeTrialActionNotAllowed,
+			Status: http.StatusForbidden,
+			Msg:    "Anonymous trial user can't update org settings",
+		})
+		return
+	}
+
+	if !auth.HasPermission(types.PermissionManageOrgSettings) {
+		log.Println("User cannot manage org settings")
+		http.Error(w, "User cannot manage org settings", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v\n", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var req shared.UpdateOrgMaxContextsPerPlanRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		log.Printf("Error unmarshalling request: %v\n", err)
+		http.Error(w, "Error unmarshalling request", http.StatusBadRequest)
+		return
+	}
+
+	if req.MaxContextsPerPlan != nil && *req.MaxContextsPerPlan < 1 {
+		log.Printf("Invalid max contexts per plan: %d\n", *req.MaxContextsPerPlan)
+		http.Error(w, "Max contexts per plan must be at least 1", http.StatusBadRequest)
+		return
+	}
+
+	err = db.UpdateOrgMaxContextsPerPlan(auth.OrgId, req.MaxContextsPerPlan)
+
+	if err != nil {
+		log.Printf("Error updating org max contexts per plan: %v\n", err)
+		http.Error(w, "Error updating org max contexts per plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully updated org max contexts per plan")
+}
+
+const defaultLargestContextsLimit = 20
+const maxLargestContextsLimit = 100
+
+func ListOrgLargestContextsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request for ListOrgLargestContextsHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	if auth.User.IsTrial {
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeTrialActionNotAllowed,
+			Status: http.StatusForbidden,
+			Msg:    "Anonymous trial user can't view org usage",
+		})
+		return
+	}
+
+	if !auth.HasPermission(types.PermissionViewOrgUsage) {
+		log.Println("User cannot view org usage")
+		http.Error(w, "User cannot view org usage", http.StatusForbidden)
+		return
+	}
+
+	limit := defaultLargestContextsLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			log.Printf("Invalid limit: %s\n", s)
+			http.Error(w, "Invalid limit: "+s, http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxLargestContextsLimit)
+	}
+
+	offset := 0
+	if s := r.URL.Query().Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			log.Printf("Invalid offset: %s\n", s)
+			http.Error(w, "Invalid offset: "+s, http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	contexts, err := db.ListOrgContextSizes(auth.OrgId)
+
+	if err != nil {
+		log.Printf("Error listing org contexts: %v\n", err)
+		http.Error(w, "Error listing org contexts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := shared.OrgLargestContextsResponse{
+		Contexts: []*shared.OrgContextSize{},
+		Total:    len(contexts),
+	}
+	if offset < len(contexts) {
+		res.Contexts = contexts[offset:min(offset+limit, len(contexts))]
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		log.Printf("Error marshalling response: %v\n", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully listed org largest contexts")
+
+	w.Write(bytes)
+}
@@ -3,17 +3,23 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/plandex/plandex/shared"
 )
 
 func ListContextHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListContextHandler")
+	logger := requestLogger(r)
+	logger.Info("Received request for ListContextHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -22,15 +28,185 @@ func ListContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
-	log.Println("planId: ", planId)
+	logger = logger.With("planId", planId)
 
-	if authorizePlan(w, planId, auth) == nil {
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	var modifiedSince *time.Time
+	if s := r.URL.Query().Get("modifiedSince"); s != "" {
+		ts, ok := parseModifiedSince(s)
+		if !ok {
+			logger.Warn("Invalid modifiedSince", "modifiedSince", s)
+			http.Error(w, "Invalid modifiedSince, expected an RFC 3339 timestamp or unix seconds", http.StatusBadRequest)
+			return
+		}
+		modifiedSince = &ts
+	}
+
+	sortKey, sortDesc, err := parseContextSort(r)
+	if err != nil {
+		logger.Warn("Invalid sort", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseContextListFilter(r)
+	if err != nil {
+		logger.Warn("Invalid filter", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, err := parseContextPaging(r)
+	if err != nil {
+		logger.Warn("Invalid paging", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	withBodies := r.URL.Query().Get("withBodies") == "true"
+
+	snapshot, err := listContextsCoalesced(logger, auth, plan, branchName, modifiedSince)
+
+	if err != nil {
+		logger.Error("Error listing contexts", "err", err)
+
+		var branchNotFoundErr *db.BranchNotFoundError
+		if errors.As(err, &branchNotFoundErr) {
+			http.Error(w, "Branch not found: "+branchNotFoundErr.Branch, http.StatusNotFound)
+			return
+		}
+
+		if errors.Is(err, db.ErrRepoLocked) {
+			w.Header().Set("Retry-After", strconv.Itoa(lockRetryAfterSeconds))
+			http.Error(w, "Error listing contexts: "+err.Error(), http.StatusConflict)
+			return
+		}
+
+		http.Error(w, "Error listing contexts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	dbContexts := sortContexts(db.FilterContexts(snapshot.contexts, filter), sortKey, sortDesc)
+	deletedIds := snapshot.deletedIds
+	syncedAt := snapshot.syncedAt
+
+	// number of contexts matching the filter, before paging
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(dbContexts)))
+	dbContexts = pageContexts(dbContexts, limit, offset)
+
+	// csv rows never include bodies
+	if withBodies && len(dbContexts) > 0 && r.URL.Query().Get("format") != "csv" {
+		// snapshot contexts may be shared with coalesced requests, so fill bodies on copies
+		withBodyContexts := make([]*db.Context, len(dbContexts))
+		for i, dbContext := range dbContexts {
+			c := *dbContext
+			withBodyContexts[i] = &c
+		}
+
+		err = db.GetContextBodiesAtCommit(auth.OrgId, planId, snapshot.commit, withBodyContexts)
+		if err != nil {
+			var tooLargeErr *db.ContextBodiesTooLargeError
+			if errors.As(err, &tooLargeErr) {
+				logger.Warn("Context bodies too large to list", "err", err)
+				http.Error(w, fmt.Sprintf("Context bodies total %d bytes, over the %d byte limit for listing with bodies. Use limit and offset to list fewer contexts at a time.", tooLargeErr.Bytes, tooLargeErr.MaxBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			logger.Error("Error getting context bodies", "err", err)
+			http.Error(w, "Error getting context bodies: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		dbContexts = withBodyContexts
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		err = writeContextsCsv(w, dbContexts)
+		if err != nil {
+			logger.Error("Error writing contexts csv", "err", err)
+		}
+		return
+	}
+
+	if r.URL.Query().Get("stream") == "true" {
+		var changes *shared.ListContextChangesResponse
+		if modifiedSince != nil {
+			changes = &shared.ListContextChangesResponse{
+				DeletedIds: deletedIds,
+				SyncedAt:   syncedAt,
+			}
+		}
+
+		err = writeContextsJsonStream(w, dbContexts, changes)
+		if err != nil {
+			logger.Error("Error streaming contexts", "err", err)
+		}
+		return
+	}
+
+	var apiContexts []*shared.Context
+
+	for _, dbContext := range dbContexts {
+		apiContexts = append(apiContexts, dbContext.ToApi())
+	}
+
+	var bytes []byte
+	if modifiedSince == nil {
+		bytes, err = json.Marshal(apiContexts)
+	} else {
+		bytes, err = json.Marshal(shared.ListContextChangesResponse{
+			Contexts:   apiContexts,
+			DeletedIds: deletedIds,
+			SyncedAt:   syncedAt,
+		})
+	}
+
+	if err != nil {
+		logger.Error("Error marshalling contexts", "err", err)
+		http.Error(w, "Error marshalling contexts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func GetContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for GetContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	contextRef := vars["contextRef"]
+	logger = logger.With("planId", planId, "contextRef", contextRef)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
 		return
 	}
 
 	var err error
 	ctx, cancel := context.WithCancel(context.Background())
-	unlockFn := lockRepo(w, r, auth, db.LockScopeRead, ctx, cancel, true)
+	// reads the branch's committed state, so it doesn't wait on writes to other branches
+	unlockFn := lockRepo(w, r, auth, db.LockScopeBranchRead, ctx, cancel, true)
 	if unlockFn == nil {
 		return
 	} else {
@@ -39,33 +215,46 @@ func ListContextHandler(w http.ResponseWriter, r *http.Request) {
 		}()
 	}
 
-	dbContexts, err := db.GetPlanContexts(auth.OrgId, planId, false)
+	includeBody := r.URL.Query().Get("includeBody") != "false"
 
+	commit, err := db.GitBranchCommit(auth.OrgId, planId, branchName)
 	if err != nil {
-		log.Printf("Error getting contexts: %v\n", err)
-		http.Error(w, "Error getting contexts: "+err.Error(), http.StatusInternalServerError)
+		logger.Error("Error getting branch commit", "err", err)
+		http.Error(w, "Error getting branch commit: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	var apiContexts []*shared.Context
+	dbContext, err := db.ResolveContextRefAtCommit(auth.OrgId, planId, commit, contextRef, includeBody)
 
-	for _, dbContext := range dbContexts {
-		apiContexts = append(apiContexts, dbContext.ToApi())
+	if err != nil {
+		logger.Error("Error getting context", "err", err)
+		http.Error(w, "Error getting context: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if dbContext == nil {
+		err = fmt.Errorf("context not found: %s", contextRef)
+		logger.Warn("Context not found")
+		http.Error(w, "Context not found: "+contextRef, http.StatusNotFound)
+		return
 	}
 
-	bytes, err := json.Marshal(apiContexts)
+	bytes, err := json.Marshal(dbContext.ToApi())
 
 	if err != nil {
-		log.Printf("Error marshalling contexts: %v\n", err)
-		http.Error(w, "Error marshalling contexts: "+err.Error(), http.StatusInternalServerError)
+		logger.Error("Error marshalling context", "err", err)
+		http.Error(w, "Error marshalling context: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Write(bytes)
+	logger.Info("Successfully processed GetContextHandler request")
+
+	writeJsonBytes(w, r, bytes)
 }
 
 func LoadContextHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for LoadContextHandler")
+	logger := requestLogger(r)
+	logger.Info("Received request for LoadContextHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -74,18 +263,28 @@ func LoadContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
-	branchName := vars["branch"]
-	log.Println("planId: ", planId)
+	logger = logger.With("planId", planId)
 
 	plan := authorizePlan(w, planId, auth)
 	if plan == nil {
 		return
 	}
 
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	w, finishIdempotent, ok := beginIdempotentRequest(w, r, auth, planId, branchName)
+	if !ok {
+		return
+	}
+	defer finishIdempotent()
+
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Error("Error reading request body", "err", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -93,32 +292,157 @@ func LoadContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.LoadContextRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Error("Error parsing request body", "err", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
-	res, _ := loadContexts(w, r, auth, &requestBody, plan, branchName)
+	// fetched before anything else, and without a repo lock, since it can take up to the fetch timeout
+	err = fetchIssueContexts(requestBody)
+	if err != nil {
+		logger.Warn("Error fetching issue contexts", "err", err)
+		writeContextUpdateError(w, err, "Error fetching issue contexts")
+		return
+	}
+
+	if isStageRequest(r) {
+		stageContextChanges(w, r, auth, plan, branchName, db.StageContextParams{Load: requestBody})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	res, _, err := loadContexts(w, r, auth, &requestBody, plan, branchName)
+
+	if res == nil {
+		return
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully processed LoadContextHandler request")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+// LoadContextTarballHandler loads the text files in a tar or tar.gz archive as file contexts, in a single commit.
+// The archive is the request body, or the 'file' part of a multipart form. Repeated 'path' query params limit which paths are loaded.
+// The org's default .plandexignore rules apply, then the archive's root .plandexignore.
+func LoadContextTarballHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for LoadContextTarballHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	archive, err := tarballArchive(r)
+	if err != nil {
+		logger.Warn("Error reading archive", "err", err)
+		http.Error(w, "Error reading archive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	org, err := db.GetOrg(auth.OrgId)
+	if err != nil {
+		logger.Error("Error getting org", "err", err)
+		http.Error(w, "Error getting org: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var ignoreLines []string
+	if org.DefaultPlandexIgnore != nil {
+		ignoreLines = strings.Split(*org.DefaultPlandexIgnore, "\n")
+	}
+
+	// extracted before the repo is locked, since a large archive takes a while to read
+	tarball, err := db.ContextsFromTarball(db.TarballContextsParams{
+		Archive:     archive,
+		AllowPaths:  r.URL.Query()["path"],
+		IgnoreLines: ignoreLines,
+	})
+
+	if err != nil {
+		logger.Warn("Error extracting archive", "err", err)
+
+		var reqErr *db.ContextRequestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.Msg, http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, "Error extracting archive: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Extracted archive", "numFiles", len(tarball.Req), "numIgnored", tarball.NumIgnored, "numBinary", len(tarball.SkippedBinary))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	res, _, err := loadContexts(w, r, auth, &tarball.Req, plan, branchName)
 
 	if res == nil {
 		return
 	}
 
+	if len(tarball.SkippedBinary) > 0 {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("Skipped %d binary files: %s", len(tarball.SkippedBinary), strings.Join(tarball.SkippedBinary, ", ")))
+	}
+
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Error("Error marshalling response", "err", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed LoadContextHandler request")
+	logger.Info("Successfully processed LoadContextTarballHandler request")
 
-	w.Write(bytes)
+	writeJsonBytes(w, r, bytes)
 }
 
 func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for UpdateContextHandler")
+	logger := requestLogger(r)
+	logger.Info("Received request for UpdateContextHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -127,18 +451,28 @@ func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
-	branchName := vars["branch"]
-	log.Println("planId: ", planId)
+	logger = logger.With("planId", planId)
 
 	plan := authorizePlan(w, planId, auth)
 	if plan == nil {
 		return
 	}
 
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	w, finishIdempotent, ok := beginIdempotentRequest(w, r, auth, planId, branchName)
+	if !ok {
+		return
+	}
+	defer finishIdempotent()
+
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Error("Error reading request body", "err", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -146,11 +480,16 @@ func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.UpdateContextRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Error("Error parsing request body", "err", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
+	if isStageRequest(r) {
+		stageContextChanges(w, r, auth, plan, branchName, db.StageContextParams{Update: requestBody})
+		return
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
 	if unlockFn == nil {
@@ -161,56 +500,130 @@ func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
 		}()
 	}
 
+	// with 'Accept: text/event-stream', an event is sent as each context is stored. If the client disconnects, the update
+	// still runs to completion and is committed so the repo isn't left half-written, and remaining events are dropped.
+	var events *sseWriter
+	if wantsEventStream(r) {
+		events = newSseWriter(w, r)
+	}
+
+	var onStored func(event shared.ContextStoredEvent)
+	if events != nil {
+		onStored = func(event shared.ContextStoredEvent) {
+			events.send("context", event)
+		}
+	}
+
 	updateRes, err := db.UpdateContexts(db.UpdateContextsParams{
+		Logger:     logger,
 		Req:        &requestBody,
 		OrgId:      auth.OrgId,
 		Plan:       plan,
 		BranchName: branchName,
+		UserId:     auth.User.Id,
+		// contexts that fail are reported in FailedById and the rest are committed, unless ?atomic=true.
+		// Unknown ids are a 404 either way.
+		Partial:  r.URL.Query().Get("atomic") != "true",
+		OnStored: onStored,
 	})
 
 	if err != nil {
-		log.Printf("Error error updating contexts: %v\n", err)
-		http.Error(w, "Error error updating contexts: "+err.Error(), http.StatusInternalServerError)
+		logger.Error("Error error updating contexts", "err", err)
+
+		// once events have been sent the status can't change, so report the error as an event
+		if events.isStarted() {
+			events.sendError(shared.ApiError{
+				Type:   shared.ApiErrorTypeOther,
+				Status: http.StatusInternalServerError,
+				Msg:    "Error error updating contexts: " + err.Error(),
+			})
+			return
+		}
+
+		writeContextUpdateError(w, err, "Error error updating contexts")
 		return
 	}
 
+	if len(updateRes.FailedById) > 0 {
+		logger.Warn("Contexts failed to update and were skipped", "numFailed", len(updateRes.FailedById))
+	}
+
 	if updateRes.MaxTokensExceeded {
-		log.Printf("The total number of tokens (%d) exceeds the maximum allowed (%d)", updateRes.TotalTokens, updateRes.MaxTokens)
-		bytes, err := json.Marshal(updateRes)
+		logLimitExceeded(logger, updateRes)
+
+		if events != nil {
+			events.send("done", updateRes)
+			return
+		}
+
+		// assign rather than redeclare err so the deferred unlock sees a failure here
+		var bytes []byte
+		bytes, err = json.Marshal(updateRes)
 
 		if err != nil {
-			log.Printf("Error marshalling response: %v\n", err)
+			logger.Error("Error marshalling response", "err", err)
 			http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		w.Write(bytes)
+		writeJsonBytes(w, r, bytes)
 		return
 	}
 
-	err = db.GitAddAndCommit(auth.OrgId, planId, branchName, updateRes.Msg)
+	if len(updateRes.UnchangedIds) > 0 {
+		logger.Info("Contexts were unchanged and were skipped", "numUnchanged", len(updateRes.UnchangedIds))
+	}
 
-	if err != nil {
-		log.Printf("Error committing changes: %v\n", err)
-		http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+	// no message means every context was unchanged, so nothing was written
+	if updateRes.Msg != "" {
+		err = db.GitAddAndCommit(auth.OrgId, planId, branchName, updateRes.Msg)
+
+		if err != nil {
+			logger.Error("Error committing changes", "err", err)
+
+			if events.isStarted() {
+				events.sendError(shared.ApiError{
+					Type:   shared.ApiErrorTypeOther,
+					Status: http.StatusInternalServerError,
+					Msg:    "Error committing changes: " + err.Error(),
+				})
+				return
+			}
+
+			http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// a count below zero can only come from a branch count that had already drifted below its contexts' sum
+		if updateRes.TotalTokens < 0 {
+			updateRes.TotalTokens = correctDriftedTokens(logger, auth.OrgId, planId, branchName, updateRes.TotalTokens)
+		}
+	}
+
+	if events != nil {
+		events.send("done", updateRes)
+		logger.Info("Successfully processed UpdateContextHandler request")
 		return
 	}
 
 	bytes, err := json.Marshal(updateRes)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Error("Error marshalling response", "err", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed UpdateContextHandler request")
+	logger.Info("Successfully processed UpdateContextHandler request")
 
-	w.Write(bytes)
+	writeJsonBytes(w, r, bytes)
 }
 
-func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for DeleteContextHandler")
+// PreviewUpdateContextHandler runs an update without storing or committing anything, returning the response an update
+// would, with the commit message it would create in Msg
+func PreviewUpdateContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for PreviewUpdateContextHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -219,41 +632,36 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
-	branchName := vars["branch"]
-	log.Println("planId: ", planId)
+	logger = logger.With("planId", planId)
 
 	plan := authorizePlan(w, planId, auth)
-
 	if plan == nil {
 		return
 	}
 
-	branch, err := db.GetDbBranch(planId, branchName)
-
-	if err != nil {
-		log.Printf("Error getting branch: %v\n", err)
-		http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
 		return
 	}
 
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Error("Error reading request body", "err", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
 	defer r.Body.Close()
 
-	var requestBody shared.DeleteContextRequest
+	var requestBody shared.UpdateContextRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Error("Error parsing request body", "err", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	unlockFn := lockRepo(w, r, auth, db.LockScopeRead, ctx, cancel, true)
 	if unlockFn == nil {
 		return
 	} else {
@@ -262,67 +670,1643 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 		}()
 	}
 
-	dbContexts, err := db.GetPlanContexts(auth.OrgId, planId, false)
+	updateRes, err := db.UpdateContexts(db.UpdateContextsParams{
+		Logger:     logger,
+		Req:        &requestBody,
+		OrgId:      auth.OrgId,
+		Plan:       plan,
+		BranchName: branchName,
+		UserId:     auth.User.Id,
+		// the same as an update, so the preview fails or skips the same contexts
+		Partial: r.URL.Query().Get("atomic") != "true",
+		DryRun:  true,
+	})
 
 	if err != nil {
-		log.Printf("Error getting contexts: %v\n", err)
-		http.Error(w, "Error getting contexts: "+err.Error(), http.StatusInternalServerError)
+		logger.Error("Error previewing context update", "err", err)
+		writeContextUpdateError(w, err, "Error previewing context update")
 		return
 	}
 
-	var toRemove []*db.Context
-	for _, dbContext := range dbContexts {
-		if _, ok := requestBody.Ids[dbContext.Id]; ok {
-			toRemove = append(toRemove, dbContext)
-		}
-	}
-
-	err = db.ContextRemove(toRemove)
+	bytes, err := json.Marshal(updateRes)
 
 	if err != nil {
-		log.Printf("Error deleting contexts: %v\n", err)
-		http.Error(w, "Error deleting contexts: "+err.Error(), http.StatusInternalServerError)
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	removeTokens := 0
-	var toRemoveApiContexts []*shared.Context
-	for _, dbContext := range toRemove {
-		toRemoveApiContexts = append(toRemoveApiContexts, dbContext.ToApi())
-		removeTokens += dbContext.NumTokens
-	}
+	logger.Info("Successfully processed PreviewUpdateContextHandler request")
 
-	commitMsg := shared.SummaryForRemoveContext(toRemoveApiContexts, branch.ContextTokens) + "\n\n" + shared.TableForRemoveContext(toRemoveApiContexts)
-	err = db.GitAddAndCommit(auth.OrgId, planId, branchName, commitMsg)
+	writeJsonBytes(w, r, bytes)
+}
 
-	if err != nil {
-		log.Printf("Error committing changes: %v\n", err)
-		http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
-		return
+func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for DeleteContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.DeleteContextRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	selector, err := db.NewContextSelector(requestBody.Types, requestBody.PathGlobs, requestBody.Sources)
+	if err != nil {
+		logger.Error("Error parsing context selector", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// a dry run previews the removal itself, so it isn't staged either
+	if isStageRequest(r) && !requestBody.DryRun {
+		// staged deletes are stored as refs, so a selection would have to be resolved before the contexts it applies to are known
+		if !selector.IsEmpty() {
+			http.Error(w, "Deletes by type, path glob, or source can't be staged. Pass the ids instead.", http.StatusBadRequest)
+			return
+		}
+		stageContextChanges(w, r, auth, plan, branchName, db.StageContextParams{Delete: requestBody.Ids})
+		return
+	}
+
+	lockScope := db.LockScopeWrite
+	if requestBody.DryRun {
+		lockScope = db.LockScopeRead
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, lockScope, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	// read the branch under the lock so the token totals in the response aren't stale
+	branch, err := db.GetDbBranch(planId, branchName)
+
+	if err != nil {
+		logger.Error("Error getting branch", "err", err)
+		http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if branch == nil {
+		err = fmt.Errorf("branch not found: %s", branchName)
+		logger.Warn("Branch not found", "branch", branchName)
+		http.Error(w, "Branch not found: "+branchName, http.StatusNotFound)
+		return
 	}
 
-	err = db.AddPlanContextTokens(planId, branchName, -removeTokens)
+	dbContexts, err := db.GetPlanContexts(auth.OrgId, planId, false)
+
 	if err != nil {
-		log.Printf("Error updating plan tokens: %v\n", err)
-		http.Error(w, "Error updating plan tokens: "+err.Error(), http.StatusInternalServerError)
+		logger.Error("Error getting contexts", "err", err)
+		http.Error(w, "Error getting contexts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var toRemove []*db.Context
+	var deletedIds []string
+	for _, dbContext := range dbContexts {
+		if dbContext.InRefs(requestBody.Ids) || selector.Matches(dbContext) {
+			toRemove = append(toRemove, dbContext)
+			deletedIds = append(deletedIds, dbContext.Id)
+		}
+	}
+
+	notFoundIds := db.UnmatchedRefs(dbContexts, requestBody.Ids)
+
+	if len(toRemove) == 0 && len(notFoundIds) > 0 {
+		err = &db.ContextNotFoundError{Ids: notFoundIds}
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeContextNotFound,
+			Status: http.StatusNotFound,
+			Msg:    "Contexts not found: " + strings.Join(notFoundIds, ", "),
+			ContextNotFoundError: &shared.ContextNotFoundError{
+				ContextIds: notFoundIds,
+			},
+		})
 		return
 	}
 
+	// the sum of every stored context, to check the branch's count hasn't drifted from it
+	var storedTokens int64
+	for _, dbContext := range dbContexts {
+		storedTokens += dbContext.NumTokens
+	}
+
+	var removeTokens int64
+	var toRemoveApiContexts []*shared.Context
+	for _, dbContext := range toRemove {
+		toRemoveApiContexts = append(toRemoveApiContexts, dbContext.ToApi())
+		removeTokens += dbContext.NumTokens
+	}
+
+	var commitMsg string
+	if len(toRemove) == 0 {
+		commitMsg = "No contexts matched"
+	} else {
+		commitMsg = shared.SummaryForRemoveContext(toRemoveApiContexts, branch.ContextTokens) + "\n\n" + shared.TableForRemoveContext(toRemoveApiContexts)
+	}
+
+	// a selection that matches nothing isn't an error, but there's nothing to commit
+	if !requestBody.DryRun && len(toRemove) > 0 {
+		err = db.ContextRemove(toRemove)
+
+		if err != nil {
+			logger.Error("Error deleting contexts", "err", err)
+			http.Error(w, "Error deleting contexts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = db.GitAddAndCommit(auth.OrgId, planId, branchName, commitMsg)
+
+		if err != nil {
+			logger.Error("Error committing changes", "err", err)
+			http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = db.AddPlanContextTokens(planId, branchName, -removeTokens)
+		if err != nil {
+			logger.Error("Error updating plan tokens", "err", err)
+			http.Error(w, "Error updating plan tokens: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	res := shared.DeleteContextResponse{
 		TokensRemoved: removeTokens,
 		TotalTokens:   branch.ContextTokens - removeTokens,
 		Msg:           commitMsg,
+		NotFoundIds:   notFoundIds,
+		DeletedIds:    deletedIds,
+	}
+
+	if !requestBody.DryRun && len(toRemove) > 0 && storedTokens != branch.ContextTokens {
+		res.TotalTokens = correctDriftedTokens(logger, auth.OrgId, planId, branchName, res.TotalTokens)
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully deleted contexts")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func ExportContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for ExportContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	var err error
+	ctx, cancel := context.WithCancel(context.Background())
+	// reads the branch's committed state, so it doesn't wait on writes to other branches
+	unlockFn := lockRepo(w, r, auth, db.LockScopeBranchRead, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	branch, err := db.GetDbBranch(planId, branchName)
+
+	if err != nil {
+		logger.Error("Error getting branch", "err", err)
+		http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if branch == nil {
+		err = fmt.Errorf("branch not found: %s", branchName)
+		logger.Warn("Branch not found", "branch", branchName)
+		http.Error(w, "Branch not found: "+branchName, http.StatusNotFound)
+		return
+	}
+
+	commit, err := db.GitBranchCommit(auth.OrgId, planId, branchName)
+	if err != nil {
+		logger.Error("Error getting branch commit", "err", err)
+		http.Error(w, "Error getting branch commit: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// bodies are read one at a time as the archive is written
+	dbContexts, err := db.GetPlanContextsAtCommit(auth.OrgId, planId, commit)
+
+	if err != nil {
+		logger.Error("Error getting contexts", "err", err)
+		http.Error(w, "Error getting contexts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// once the archive has started, the status is sent, so a failure can only cut it short
+	err = writeContextExport(w, db.ContextExportParams{
+		OrgId:       auth.OrgId,
+		PlanId:      planId,
+		BranchName:  branchName,
+		Contexts:    dbContexts,
+		TotalTokens: branch.ContextTokens,
+		Commit:      commit,
+	})
+	if err != nil {
+		logger.Error("Error writing context export", "err", err)
+		return
+	}
+
+	logger.Info("Successfully exported contexts", "numContexts", len(dbContexts))
+}
+
+func PruneContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for PruneContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.PruneContextRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	lockScope := db.LockScopeWrite
+	if requestBody.DryRun {
+		lockScope = db.LockScopeRead
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, lockScope, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	branch, err := db.GetDbBranch(planId, branchName)
+
+	if err != nil {
+		logger.Error("Error getting branch", "err", err)
+		http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if branch == nil {
+		err = fmt.Errorf("branch not found: %s", branchName)
+		logger.Warn("Branch not found", "branch", branchName)
+		http.Error(w, "Branch not found: "+branchName, http.StatusNotFound)
+		return
+	}
+
+	dbContexts, err := db.GetPlanContexts(auth.OrgId, planId, false)
+
+	if err != nil {
+		logger.Error("Error getting contexts", "err", err)
+		http.Error(w, "Error getting contexts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	selection, err := db.SelectContextsToPrune(dbContexts, branch.ContextTokens, requestBody.TargetTokens)
+
+	if err != nil {
+		var reqErr *db.ContextRequestError
+		if errors.As(err, &reqErr) {
+			logger.Warn("Can't prune contexts", "err", err)
+			http.Error(w, reqErr.Msg, http.StatusBadRequest)
+			return
+		}
+
+		logger.Error("Error selecting contexts to prune", "err", err)
+		http.Error(w, "Error selecting contexts to prune: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var storedTokens int64
+	for _, dbContext := range dbContexts {
+		storedTokens += dbContext.NumTokens
+	}
+
+	res := shared.PruneContextResponse{
+		PrunedIds:    []string{},
+		KeptIds:      []string{},
+		TotalTokens:  selection.TotalTokens,
+		TargetTokens: requestBody.TargetTokens,
+	}
+
+	var pruneApiContexts []*shared.Context
+	for _, dbContext := range selection.Prune {
+		pruneApiContexts = append(pruneApiContexts, dbContext.ToApi())
+		res.PrunedIds = append(res.PrunedIds, dbContext.Id)
+		res.TokensRemoved += dbContext.NumTokens
+	}
+
+	for _, dbContext := range selection.Keep {
+		res.KeptIds = append(res.KeptIds, dbContext.Id)
+	}
+
+	if len(selection.Prune) == 0 {
+		res.Msg = "Context is already within the target, nothing to prune"
+	} else {
+		res.Msg = shared.SummaryForRemoveContext(pruneApiContexts, branch.ContextTokens) + "\n\n" + shared.TableForRemoveContext(pruneApiContexts)
+	}
+
+	if !requestBody.DryRun && len(selection.Prune) > 0 {
+		err = db.ContextRemove(selection.Prune)
+
+		if err != nil {
+			logger.Error("Error pruning contexts", "err", err)
+			http.Error(w, "Error pruning contexts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = db.GitAddAndCommit(auth.OrgId, planId, branchName, res.Msg)
+
+		if err != nil {
+			logger.Error("Error committing changes", "err", err)
+			http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = db.AddPlanContextTokens(planId, branchName, -res.TokensRemoved)
+		if err != nil {
+			logger.Error("Error updating plan tokens", "err", err)
+			http.Error(w, "Error updating plan tokens: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if storedTokens != branch.ContextTokens {
+			res.TotalTokens = correctDriftedTokens(logger, auth.OrgId, planId, branchName, res.TotalTokens)
+		}
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully pruned contexts", "numPruned", len(res.PrunedIds), "dryRun", requestBody.DryRun)
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func TagContextsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for TagContextsHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.TagContextsRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	if requestBody.Tag == "" {
+		logger.Info("Tag not specified")
+		http.Error(w, "Tag not specified", http.StatusBadRequest)
+		return
+	}
+
+	if requestBody.PathPattern == "" && len(requestBody.Types) == 0 {
+		logger.Info("No path pattern or type filter specified")
+		http.Error(w, "A path pattern or type filter is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	tagged, err := db.TagContexts(db.TagContextsParams{
+		Req:    &requestBody,
+		OrgId:  auth.OrgId,
+		PlanId: planId,
+	})
+
+	if err != nil {
+		logger.Error("Error tagging contexts", "err", err)
+		writeContextUpdateError(w, err, "Error tagging contexts")
+		return
+	}
+
+	var apiContexts []*shared.Context
+	ids := []string{}
+	for _, dbContext := range tagged {
+		apiContexts = append(apiContexts, dbContext.ToApi())
+		ids = append(ids, dbContext.Id)
+	}
+
+	commitMsg := shared.SummaryForTagContexts(apiContexts, requestBody.Tag, requestBody.Remove)
+
+	if len(tagged) > 0 {
+		err = db.GitAddAndCommit(auth.OrgId, planId, branchName, commitMsg)
+
+		if err != nil {
+			logger.Error("Error committing changes", "err", err)
+			http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	res := shared.TagContextsResponse{
+		Ids: ids,
+		Msg: commitMsg,
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully processed TagContextsHandler request")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func MoveContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for MoveContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.MoveContextRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	moved, fromPathsById, err := db.MoveContexts(db.MoveContextsParams{
+		Req:    &requestBody,
+		OrgId:  auth.OrgId,
+		PlanId: planId,
+	})
+
+	if err != nil {
+		logger.Error("Error moving contexts", "err", err)
+		writeContextUpdateError(w, err, "Error moving contexts")
+		return
+	}
+
+	apiContexts := []*shared.Context{}
+	for _, dbContext := range moved {
+		apiContexts = append(apiContexts, dbContext.ToApi())
+	}
+
+	commitMsg := shared.SummaryForMoveContexts(apiContexts, fromPathsById)
+
+	if len(moved) > 0 {
+		err = db.GitAddAndCommit(auth.OrgId, planId, branchName, commitMsg)
+
+		if err != nil {
+			logger.Error("Error committing changes", "err", err)
+			http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	res := shared.MoveContextResponse{
+		Contexts: apiContexts,
+		Msg:      commitMsg,
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully processed MoveContextHandler request")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func ReplaceContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for ReplaceContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.ReplaceContextRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	res, err := db.ReplaceContexts(db.ReplaceContextsParams{
+		Logger:     logger,
+		Req:        &requestBody,
+		OrgId:      auth.OrgId,
+		Plan:       plan,
+		BranchName: branchName,
+		UserId:     auth.User.Id,
+	})
+
+	if err != nil {
+		logger.Error("Error replacing contexts", "err", err)
+		writeContextUpdateError(w, err, "Error replacing contexts")
+		return
+	}
+
+	if res.LimitExceeded() {
+		logLimitExceeded(logger, &res.LoadContextResponse)
+
+		var bytes []byte
+		bytes, err = json.Marshal(res)
+
+		if err != nil {
+			logger.Error("Error marshalling response", "err", err)
+			http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// removals may already have been applied, so roll back the repo rather than leaving a partial change
+		err = fmt.Errorf("context limit exceeded")
+
+		writeJsonBytes(w, r, bytes)
+		return
+	}
+
+	// no message means nothing was written, since every context already matched
+	if res.Msg != "" {
+		err = db.GitAddAndCommit(auth.OrgId, planId, branchName, res.Msg)
+
+		if err != nil {
+			logger.Error("Error committing changes", "err", err)
+			http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully processed ReplaceContextHandler request")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func RefreshTreeContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for RefreshTreeContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.RefreshTreeContextRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	res, err := db.RefreshTreeContexts(db.RefreshTreeContextsParams{
+		Req:        &requestBody,
+		OrgId:      auth.OrgId,
+		Plan:       plan,
+		BranchName: branchName,
+		UserId:     auth.User.Id,
+	})
+
+	if err != nil {
+		logger.Error("Error refreshing directory trees", "err", err)
+		writeContextUpdateError(w, err, "Error refreshing directory trees")
+		return
+	}
+
+	if res.MaxTokensExceeded {
+		logLimitExceeded(logger, &res.UpdateContextResponse)
+	} else if res.Msg != "" {
+		err = db.GitAddAndCommit(auth.OrgId, planId, branchName, res.Msg)
+
+		if err != nil {
+			logger.Error("Error committing changes", "err", err)
+			http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully refreshed directory trees", "numChanged", len(res.ChangedIds), "numTrees", len(requestBody.Trees)+len(requestBody.Paths))
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func RefreshUrlContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for RefreshUrlContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.RefreshUrlContextRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	var urlContexts []*db.Context
+	readCtx, readCancel := context.WithCancel(context.Background())
+	readUnlockFn := lockRepo(w, r, auth, db.LockScopeRead, readCtx, readCancel, true)
+	if readUnlockFn == nil {
+		return
+	}
+	urlContexts, err = db.GetUrlContexts(auth.OrgId, planId, requestBody.Ids)
+	(*readUnlockFn)(nil)
+
+	if err != nil {
+		logger.Error("Error getting url contexts", "err", err)
+		writeContextUpdateError(w, err, "Error getting url contexts")
+		return
+	}
+
+	// fetched without a repo lock, since each url can take up to the fetch timeout
+	bodiesById, fetchErrorsById := fetchUrlContexts(urlContexts, requestBody.IssueTokens)
+
+	res := shared.RefreshUrlContextResponse{
+		ChangedIds:      []string{},
+		FetchErrorsById: fetchErrorsById,
+	}
+
+	if len(bodiesById) > 0 {
+		updateReq := shared.UpdateContextRequest{}
+		for _, urlContext := range urlContexts {
+			if body, ok := bodiesById[urlContext.Id]; ok {
+				updateReq[urlContext.Id] = &shared.UpdateContextParams{
+					Body: body,
+					// a change made while the urls were fetched wins over the refresh
+					ExpectedSha: urlContext.Sha,
+				}
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+		if unlockFn == nil {
+			return
+		} else {
+			defer func() {
+				(*unlockFn)(err)
+			}()
+		}
+
+		var updateRes *shared.UpdateContextResponse
+		updateRes, err = db.UpdateContexts(db.UpdateContextsParams{
+			Logger:     logger,
+			Req:        &updateReq,
+			OrgId:      auth.OrgId,
+			Plan:       plan,
+			BranchName: branchName,
+			UserId:     auth.User.Id,
+			Partial:    true,
+		})
+
+		if err != nil {
+			logger.Error("Error updating url contexts", "err", err)
+			writeContextUpdateError(w, err, "Error updating url contexts")
+			return
+		}
+
+		res.UpdateContextResponse = *updateRes
+
+		if updateRes.MaxTokensExceeded {
+			logLimitExceeded(logger, updateRes)
+		} else {
+			unchanged := make(map[string]bool)
+			for _, id := range updateRes.UnchangedIds {
+				unchanged[id] = true
+			}
+			for id := range updateRes.TokenDiffsById {
+				if !unchanged[id] {
+					res.ChangedIds = append(res.ChangedIds, id)
+				}
+			}
+			sort.Strings(res.ChangedIds)
+
+			if updateRes.Msg != "" {
+				err = db.GitAddAndCommit(auth.OrgId, planId, branchName, updateRes.Msg)
+
+				if err != nil {
+					logger.Error("Error committing changes", "err", err)
+					http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully refreshed urls", "numChanged", len(res.ChangedIds), "numUrls", len(urlContexts))
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func GetStagedContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for GetStagedContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	staged, err := db.GetStagedContext(auth.OrgId, planId, branchName, auth.User.Id)
+
+	if err != nil {
+		logger.Error("Error getting staged context", "err", err)
+		http.Error(w, "Error getting staged context: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeStagedContext(w, r, staged)
+
+	logger.Info("Successfully processed GetStagedContextHandler request")
+}
+
+func DiscardStagedContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for DiscardStagedContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	err := db.ClearStagedContext(auth.OrgId, planId, branchName, auth.User.Id)
+
+	if err != nil {
+		logger.Error("Error discarding staged context", "err", err)
+		http.Error(w, "Error discarding staged context: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully discarded staged context")
+}
+
+func CommitStagedContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for CommitStagedContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	var err error
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	res, err := db.CommitStagedContext(db.CommitStagedContextParams{
+		Logger:     logger,
+		OrgId:      auth.OrgId,
+		Plan:       plan,
+		BranchName: branchName,
+		UserId:     auth.User.Id,
+	})
+
+	if err != nil {
+		logger.Error("Error committing staged context", "err", err)
+
+		var reqErr *db.ContextRequestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.Msg, http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, "Error committing staged context: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if res.LimitExceeded() {
+		logLimitExceeded(logger, res)
+
+		var bytes []byte
+		bytes, err = json.Marshal(res)
+
+		if err != nil {
+			logger.Error("Error marshalling response", "err", err)
+			http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// staged deletes may already have been applied, so roll back the repo rather than leaving a partial change
+		err = fmt.Errorf("context limit exceeded")
+
+		writeJsonBytes(w, r, bytes)
+		return
+	}
+
+	// no message means nothing was written, e.g. every staged update matched the stored body
+	if res.Msg != "" {
+		err = db.GitAddAndCommit(auth.OrgId, planId, branchName, res.Msg)
+
+		if err != nil {
+			logger.Error("Error committing changes", "err", err)
+			http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = db.ClearStagedContext(auth.OrgId, planId, branchName, auth.User.Id)
+
+	if err != nil {
+		// changes are already committed, so don't roll back
+		logger.Error("Error clearing staged context", "err", err)
+		err = nil
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully committed staged context")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func PreviewUrlContextHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for PreviewUrlContextHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.PreviewUrlContextRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	if !shared.IsValidBaseUrl(requestBody.Url) {
+		logger.Warn("Invalid url", "url", requestBody.Url)
+		http.Error(w, "Invalid url: "+requestBody.Url, http.StatusBadRequest)
+		return
+	}
+
+	err = checkUrlHost(requestBody.Url)
+	if err != nil {
+		logger.Warn("Url not allowed", "err", err)
+		http.Error(w, "Url not allowed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// fetched before anything else, and without a repo lock, since it can take up to the fetch timeout
+	urlBody, contentType, err := publicUrlGuard.FetchURLContent(requestBody.Url)
+
+	if err != nil {
+		logger.Error("Error fetching url", "err", err)
+		http.Error(w, "Error fetching url: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestBody.ResolveRelativeUrls {
+		baseUrl := requestBody.BaseUrl
+		if baseUrl == "" {
+			baseUrl = requestBody.Url
+		}
+
+		if !shared.IsValidBaseUrl(baseUrl) {
+			logger.Warn("Invalid base url", "baseUrl", baseUrl)
+			http.Error(w, "Invalid base url: "+baseUrl, http.StatusBadRequest)
+			return
+		}
+
+		urlBody = shared.ResolveRelativeUrls(requestBody.Url, contentType, urlBody, baseUrl)
+	}
+
+	numTokens, err := shared.GetNumTokens(urlBody)
+
+	if err != nil {
+		logger.Error("Error getting num tokens", "err", err)
+		http.Error(w, "Error getting num tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	branch, err := db.GetDbBranch(planId, branchName)
+
+	if err != nil {
+		logger.Error("Error getting branch", "err", err)
+		http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if branch == nil {
+		logger.Warn("Branch not found", "branch", branchName)
+		http.Error(w, "Branch not found: "+branchName, http.StatusNotFound)
+		return
+	}
+
+	maxTokens, _, err := db.GetBranchMaxTokens(plan, branch)
+
+	if err != nil {
+		logger.Error("Error getting max tokens", "err", err)
+		http.Error(w, "Error getting max tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	totalTokens := branch.ContextTokens + numTokens
+
+	res := shared.PreviewUrlContextResponse{
+		Url:               requestBody.Url,
+		Body:              urlBody,
+		NumTokens:         numTokens,
+		TotalTokens:       totalTokens,
+		MaxTokens:         maxTokens,
+		MaxTokensExceeded: totalTokens > maxTokens,
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully processed PreviewUrlContextHandler request")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func ContextAllowanceHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for ContextAllowanceHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.ContextAllowanceRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	branch, err := db.GetDbBranch(planId, branchName)
+
+	if err != nil {
+		logger.Error("Error getting branch", "err", err)
+		http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if branch == nil {
+		logger.Warn("Branch not found", "branch", branchName)
+		http.Error(w, "Branch not found: "+branchName, http.StatusNotFound)
+		return
+	}
+
+	maxTokens, _, err := db.GetBranchMaxTokens(plan, branch)
+
+	if err != nil {
+		logger.Error("Error getting max tokens", "err", err)
+		http.Error(w, "Error getting max tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := shared.ContextAllowanceResponse{
+		TotalTokens: branch.ContextTokens,
+		MaxTokens:   maxTokens,
+		Entries:     []shared.ContextAllowanceResult{},
+		ExceededAt:  -1,
+	}
+
+	totalTokens := branch.ContextTokens
+	for i, entry := range requestBody.Entries {
+		tokens := entry.Tokens
+		if tokens <= 0 {
+			tokens = shared.EstimateTokens(entry.Body)
+		}
+		totalTokens += tokens
+
+		fits := res.ExceededAt == -1 && totalTokens <= res.MaxTokens
+		if fits {
+			res.NumFit++
+		} else if res.ExceededAt == -1 {
+			res.ExceededAt = i
+		}
+
+		res.Entries = append(res.Entries, shared.ContextAllowanceResult{
+			Path:        entry.Path,
+			Tokens:      tokens,
+			TotalTokens: totalTokens,
+			Fits:        fits,
+		})
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully processed ContextAllowanceHandler request")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+// ContextTokensHandler counts the tokens a load request would add, without storing or committing anything.
+// Nothing is written, so no repo lock is taken, like ContextAllowanceHandler.
+func ContextTokensHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for ContextTokensHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.ContextTokensRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.CountContextTokens(db.CountContextTokensParams{
+		Req:        &requestBody,
+		Plan:       plan,
+		BranchName: branchName,
+	})
+
+	if err != nil {
+		logger.Error("Error counting context tokens", "err", err)
+
+		var reqErr *db.ContextRequestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.Msg, http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, "Error counting context tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully processed ContextTokensHandler request")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+func ContextExistsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for ContextExistsHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	// read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body", "err", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody shared.ContextExistsRequest
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		logger.Error("Error parsing request body", "err", err)
+		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// reads the branch's committed state, so it doesn't wait on writes to other branches
+	unlockFn := lockRepo(w, r, auth, db.LockScopeBranchRead, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	commit, err := db.GitBranchCommit(auth.OrgId, planId, branchName)
+	if err != nil {
+		logger.Error("Error getting branch commit", "err", err)
+		http.Error(w, "Error getting branch commit: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var exists map[string]bool
+	exists, err = db.ContextsExistAtCommit(auth.OrgId, planId, commit, requestBody.Ids)
+
+	if err != nil {
+		logger.Error("Error checking contexts", "err", err)
+
+		var reqErr *db.ContextRequestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.Msg, http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, "Error checking contexts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(shared.ContextExistsResponse(exists))
+
+	if err != nil {
+		logger.Error("Error marshalling response", "err", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Successfully processed ContextExistsHandler request")
+
+	writeJsonBytes(w, r, bytes)
+}
+
+// RecomputeContextTokensHandler corrects the branch's context token count from the sum of its contexts, responding with the
+// count before and after so the drift is visible
+func RecomputeContextTokensHandler(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	logger.Info("Received request for RecomputeContextTokensHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	logger = logger.With("planId", planId)
+
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	branchName := resolveBranch(w, r, plan)
+	if branchName == "" {
+		return
+	}
+
+	var err error
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	var res shared.RecomputeContextTokensResponse
+	res.OldTokens, res.NewTokens, err = db.RecomputeBranchContextTokens(auth.OrgId, planId, branchName)
+
+	if err != nil {
+		logger.Error("Error recomputing context tokens", "err", err)
+		http.Error(w, "Error recomputing context tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if res.OldTokens != res.NewTokens {
+		logger.Warn("Corrected branch context tokens", "branch", branchName, "oldTokens", res.OldTokens, "newTokens", res.NewTokens)
 	}
 
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Error("Error marshalling response", "err", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully deleted contexts")
+	logger.Info("Successfully processed RecomputeContextTokensHandler request")
 
-	w.Write(bytes)
+	writeJsonBytes(w, r, bytes)
 }
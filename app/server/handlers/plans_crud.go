@@ -172,6 +172,123 @@ func GetPlanHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(bytes)
 }
 
+func UpdatePlanDefaultBranchHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request for UpdatePlanDefaultBranchHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+
+	log.Println("planId: ", planId)
+
+	plan := authorizePlanUpdate(w, planId, auth)
+	if plan == nil {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v\n", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var req shared.UpdatePlanDefaultBranchRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		log.Printf("Error unmarshalling request: %v\n", err)
+		http.Error(w, "Error unmarshalling request", http.StatusBadRequest)
+		return
+	}
+
+	var defaultBranch *string
+	if branchName := strings.TrimSpace(req.Branch); branchName != "" {
+		branch, err := db.GetDbBranch(planId, branchName)
+		if err != nil {
+			log.Printf("Error getting branch: %v\n", err)
+			http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if branch == nil {
+			log.Printf("Branch not found: %s\n", branchName)
+			http.Error(w, "Branch not found: "+branchName, http.StatusNotFound)
+			return
+		}
+
+		defaultBranch = &branchName
+	}
+
+	err = db.SetPlanDefaultBranch(planId, defaultBranch)
+	if err != nil {
+		log.Printf("Error updating plan default branch: %v\n", err)
+		http.Error(w, "Error updating plan default branch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully updated plan default branch")
+}
+
+func GetPlanLockStatusHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received request for GetPlanLockStatusHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+
+	log.Println("planId: ", planId)
+
+	plan := authorizePlan(w, planId, auth)
+
+	if plan == nil {
+		return
+	}
+
+	// purely informational, so no lock is taken to read the locks
+	locks, err := db.GetPlanLocks(planId)
+
+	if err != nil {
+		log.Printf("Error getting plan locks: %v\n", err)
+		http.Error(w, "Error getting plan locks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	namesByUserId := map[string]string{}
+	for _, lock := range locks {
+		name, ok := namesByUserId[lock.UserId]
+		if !ok {
+			user, err := db.GetUser(lock.UserId)
+			if err != nil {
+				// the holder's name is a nicety, so the status is still returned without it
+				log.Printf("Error getting user %s: %v\n", lock.UserId, err)
+			} else if user != nil {
+				name = user.Name
+			}
+			namesByUserId[lock.UserId] = name
+		}
+		lock.UserName = name
+	}
+
+	bytes, err := json.Marshal(shared.PlanLockStatusResponse{Locks: locks})
+
+	if err != nil {
+		log.Printf("Error marshalling plan lock status: %v\n", err)
+		http.Error(w, "Error marshalling plan lock status: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+}
+
 func DeletePlanHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received request for DeletePlanHandler")
 
@@ -322,12 +322,14 @@ func RespondMissingFileHandler(w http.ResponseWriter, r *http.Request) {
 
 	if requestBody.Choice == shared.RespondMissingFileChoiceLoad {
 		log.Println("loading missing file")
-		res, dbContexts := loadContexts(w, r, auth, &shared.LoadContextRequest{
+		res, dbContexts := lockAndLoadContexts(w, r, auth, &shared.LoadContextRequest{
 			&shared.LoadContextParams{
 				ContextType: shared.ContextFileType,
 				Name:        requestBody.FilePath,
 				FilePath:    requestBody.FilePath,
 				Body:        requestBody.Body,
+				// the plan needs this file in context, so load it even if it's empty
+				EmptyBody: shared.EmptyBodyAllow,
 			},
 		}, plan, branch)
 		if res == nil {
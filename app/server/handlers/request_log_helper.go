@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+const requestIdHeader = "X-Request-Id"
+
+// ids from clients are only reused if they're short and can't break up a log line
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestLoggerKey struct{}
+
+// RequestIdMiddleware gives each request an id, taken from its X-Request-Id header or generated, and echoes it back in the
+// response header. Handlers log through requestLogger so every line for a request carries its id.
+func RequestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIdHeader)
+		if !validRequestId.MatchString(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(requestIdHeader, id)
+
+		logger := slog.Default().With("requestId", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestLoggerKey{}, logger)))
+	})
+}
+
+// requestLogger returns the logger for a request, which includes its id if it went through RequestIdMiddleware
+func requestLogger(r *http.Request) *slog.Logger {
+	if logger, ok := r.Context().Value(requestLoggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIdMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	handler := RequestIdMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestLogger(r).Info("handled")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIdHeader, "client-id-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(requestIdHeader); got != "client-id-1" {
+		t.Errorf("expected the client's id to be echoed back, got %q", got)
+	}
+	if !strings.Contains(buf.String(), "requestId=client-id-1") {
+		t.Errorf("expected the log line to include the request id, got %q", buf.String())
+	}
+
+	for _, id := range []string{"", "has spaces", "line\nbreak", strings.Repeat("a", 129)} {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(requestIdHeader, id)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		got := rec.Header().Get(requestIdHeader)
+		if got == "" || got == id {
+			t.Errorf("expected a generated id for %q, got %q", id, got)
+		}
+		if !strings.Contains(buf.String(), "requestId="+got) {
+			t.Errorf("expected the log line to include the generated id %q, got %q", got, buf.String())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// sseWriter writes server-sent events. Headers are only written with the first event, so the handler can still respond with a
+// plain http error until then. Sends are safe from multiple goroutines, and become no-ops once the client has disconnected.
+type sseWriter struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	flusher http.Flusher
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+}
+
+func wantsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// newSseWriter returns nil if the response can't be flushed, in which case the handler should respond normally
+func newSseWriter(w http.ResponseWriter, r *http.Request) *sseWriter {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		requestLogger(r).Warn("Response writer doesn't support flushing, not streaming events")
+		return nil
+	}
+
+	return &sseWriter{w: w, r: r, flusher: flusher}
+}
+
+func (s *sseWriter) isStarted() bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
+
+func (s *sseWriter) send(event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		requestLogger(s.r).Error("Error marshalling event", "event", event, "err", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	if s.r.Context().Err() != nil {
+		requestLogger(s.r).Info("Client disconnected, dropping event and any after it", "event", event)
+		s.closed = true
+		return
+	}
+
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+
+	_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	if err != nil {
+		requestLogger(s.r).Error("Error writing event", "event", event, "err", err)
+		s.closed = true
+		return
+	}
+
+	s.flusher.Flush()
+}
+
+func (s *sseWriter) sendError(apiErr shared.ApiError) {
+	requestLogger(s.r).Error("API Error", "status", apiErr.Status, "msg", apiErr.Msg)
+	markResponseFailed(s.w)
+	s.send("error", apiErr)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"plandex-server/db"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// publicUrlGuard is used whenever the server fetches urls on a user's behalf, so requests can't reach loopback, private, or
+// link-local addresses other than those in db.UrlAllowPrivateNets
+var publicUrlGuard = &shared.UrlGuard{AllowedNets: urlAllowedPrivateNets()}
+
+func urlAllowedPrivateNets() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range db.UrlAllowPrivateNets {
+		parsed, err := shared.ParseAllowedNets([]string{entry})
+		if err != nil {
+			log.Printf("Invalid entry in PLANDEX_URL_ALLOW_PRIVATE_NETS: %v | ignoring it\n", err)
+			continue
+		}
+		nets = append(nets, parsed...)
+	}
+	return nets
+}
+
+// checkUrlHost applies db.UrlDenyHosts and db.UrlAllowHosts to a url before the server fetches it
+func checkUrlHost(rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("invalid url %s: %v", rawUrl, err)
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	if hostListed(host, db.UrlDenyHosts) {
+		return fmt.Errorf("fetching from %s isn't allowed", host)
+	}
+
+	if len(db.UrlAllowHosts) > 0 && !hostListed(host, db.UrlAllowHosts) {
+		return fmt.Errorf("%s isn't in the allowed hosts", host)
+	}
+
+	return nil
+}
+
+func hostListed(host string, hosts []string) bool {
+	for _, listed := range hosts {
+		if host == listed || strings.HasSuffix(host, "."+listed) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchUrlContexts fetches the current body of each url context, at most db.UrlFetchConcurrency at a time. Contexts fetched
+// through an issue tracker's api are fetched with the token for their platform in issueTokens.
+// Returns the bodies by id, and the error for each url that couldn't be fetched.
+func fetchUrlContexts(contexts []*db.Context, issueTokens map[shared.IssuePlatform]string) (map[string]string, map[string]string) {
+	bodiesById := make(map[string]string)
+	errorsById := make(map[string]string)
+
+	var limiter chan struct{}
+	if db.UrlFetchConcurrency > 0 {
+		limiter = make(chan struct{}, db.UrlFetchConcurrency)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, context := range contexts {
+		wg.Add(1)
+		go func(context *db.Context) {
+			defer wg.Done()
+
+			if limiter != nil {
+				limiter <- struct{}{}
+				defer func() { <-limiter }()
+			}
+
+			body, err := fetchUrlContext(context, issueTokens)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errorsById[context.Id] = err.Error()
+				return
+			}
+			bodiesById[context.Id] = body
+		}(context)
+	}
+
+	wg.Wait()
+
+	return bodiesById, errorsById
+}
+
+func fetchUrlContext(context *db.Context, issueTokens map[shared.IssuePlatform]string) (string, error) {
+	if !shared.IsValidBaseUrl(context.Url) {
+		return "", fmt.Errorf("invalid url: %s", context.Url)
+	}
+
+	err := checkUrlHost(context.Url)
+	if err != nil {
+		return "", err
+	}
+
+	if context.UrlSource != "" {
+		ref, ok := shared.ParseIssueUrl(context.Url)
+		if !ok {
+			return "", fmt.Errorf("not an issue or pull request url: %s", context.Url)
+		}
+
+		// the token isn't stored with the context, so it has to come with each refresh
+		token := issueTokens[ref.Platform]
+		if token == "" {
+			return "", fmt.Errorf("fetched through the %s, so it needs a %s token to refresh", context.UrlSource, ref.Platform)
+		}
+
+		body, err := publicUrlGuard.FetchIssueContent(ref, token)
+		if err != nil {
+			return "", fmt.Errorf("error fetching issue: %v", err)
+		}
+
+		return body, nil
+	}
+
+	// the context keeps the Content-Type it was loaded with
+	body, _, err := publicUrlGuard.FetchURLContent(context.Url)
+	if err != nil {
+		return "", fmt.Errorf("error fetching url: %v", err)
+	}
+
+	return body, nil
+}
+
+// fetchIssueContexts fills in the body of each context loaded with an IssueToken by fetching it through the issue tracker's api,
+// at most db.UrlFetchConcurrency at a time. Tokens are cleared once used, so they're never staged or stored.
+// Returns a *db.ContextRequestError if a context with a token isn't an issue or pull request url, or can't be fetched.
+func fetchIssueContexts(req shared.LoadContextRequest) error {
+	type issueFetch struct {
+		params *shared.LoadContextParams
+		ref    *shared.IssueRef
+		token  string
+	}
+
+	var fetches []issueFetch
+	for _, params := range req {
+		if params.IssueToken == "" {
+			continue
+		}
+
+		fetch := issueFetch{params: params, token: params.IssueToken}
+		params.IssueToken = ""
+
+		ref, ok := shared.ParseIssueUrl(params.Url)
+		if !ok {
+			return &db.ContextRequestError{Msg: fmt.Sprintf("%s was sent with an issue token, but isn't a github or gitlab issue or pull request url", params.Url)}
+		}
+		fetch.ref = ref
+
+		err := checkUrlHost(params.Url)
+		if err != nil {
+			return &db.ContextRequestError{Msg: err.Error()}
+		}
+
+		fetches = append(fetches, fetch)
+	}
+
+	var limiter chan struct{}
+	if db.UrlFetchConcurrency > 0 {
+		limiter = make(chan struct{}, db.UrlFetchConcurrency)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []string
+
+	for _, fetch := range fetches {
+		wg.Add(1)
+		go func(params *shared.LoadContextParams, ref *shared.IssueRef, token string) {
+			defer wg.Done()
+
+			if limiter != nil {
+				limiter <- struct{}{}
+				defer func() { <-limiter }()
+			}
+
+			body, err := publicUrlGuard.FetchIssueContent(ref, token)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", params.Url, err))
+				return
+			}
+
+			params.ContextType = shared.ContextURLType
+			params.Body = body
+			params.UrlSource = ref.UrlSource()
+			params.ContentType = "text/markdown"
+		}(fetch.params, fetch.ref, fetch.token)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return &db.ContextRequestError{Msg: "error fetching issues: " + strings.Join(errs, "; ")}
+	}
+
+	return nil
+}
@@ -3,16 +3,21 @@ package main
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"plandex-server/db"
+	"plandex-server/handlers"
 	"plandex-server/host"
 	"plandex-server/model/plan"
+	"plandex-server/proto/contextpb"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/plandex/plandex/shared"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -41,9 +46,25 @@ func main() {
 		externalPort = "8088"
 	}
 
+	go func() {
+		err := shared.WarmTokenizer()
+		if err != nil {
+			log.Printf("Error warming tokenizer: %v\n", err)
+		}
+	}()
+
 	go startServer(externalPort, routes())
 	log.Println("Started server on port " + externalPort)
 
+	// the context service over grpc, on GRPC_PORT or 8089
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "8089"
+	}
+
+	go startGrpcServer(grpcPort)
+	log.Println("Started grpc server on port " + grpcPort)
+
 	sigTermChan := make(chan os.Signal, 1)
 	signal.Notify(sigTermChan, syscall.SIGTERM)
 
@@ -71,3 +92,18 @@ func startServer(port string, routes *mux.Router) {
 		log.Fatalf("Failed to start server on port %s: %v", port, err)
 	}
 }
+
+func startGrpcServer(port string) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		log.Fatalf("Failed to listen on grpc port %s: %v", port, err)
+	}
+
+	server := grpc.NewServer()
+	contextpb.RegisterContextServiceServer(server, handlers.NewContextService())
+
+	err = server.Serve(lis)
+	if err != nil {
+		log.Fatalf("Failed to start grpc server on port %s: %v", port, err)
+	}
+}
@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE name = 'manage_org_settings';
+
+ALTER TABLE orgs DROP COLUMN default_plandex_ignore;
@@ -0,0 +1,11 @@
+ALTER TABLE orgs ADD COLUMN default_plandex_ignore TEXT;
+
+INSERT INTO permissions (name, description, resource_id) VALUES
+  ('manage_org_settings', 'Manage org-wide defaults like the default .plandexignore', NULL);
+
+INSERT INTO org_roles_permissions (org_role_id, permission_id)
+SELECT r.id, p.id
+FROM org_roles r, permissions p
+WHERE r.org_id IS NULL
+  AND r.name IN ('owner', 'admin')
+  AND p.name = 'manage_org_settings';
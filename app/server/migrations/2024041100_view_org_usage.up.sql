@@ -0,0 +1,9 @@
+INSERT INTO permissions (name, description, resource_id) VALUES
+  ('view_org_usage', 'View org-wide usage, like the largest contexts across all plans', NULL);
+
+INSERT INTO org_roles_permissions (org_role_id, permission_id)
+SELECT r.id, p.id
+FROM org_roles r, permissions p
+WHERE r.org_id IS NULL
+  AND r.name IN ('owner', 'admin')
+  AND p.name = 'view_org_usage';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS context_idempotency_keys (
+  plan_id UUID NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+  branch VARCHAR(255) NOT NULL,
+  idempotency_key VARCHAR(255) NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  request_sha VARCHAR(64) NOT NULL,
+  -- null while the request is still being processed
+  status_code INTEGER,
+  content_type VARCHAR(255),
+  response BYTEA,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (plan_id, branch, idempotency_key)
+);
+
+CREATE INDEX context_idempotency_keys_created_idx ON context_idempotency_keys(plan_id, created_at);
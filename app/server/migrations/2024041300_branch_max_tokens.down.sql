@@ -0,0 +1 @@
+ALTER TABLE branches DROP COLUMN max_tokens;
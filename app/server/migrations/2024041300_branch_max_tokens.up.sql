@@ -0,0 +1,2 @@
+-- a tighter context token limit for the branch. Null means the branch uses the plan's limit.
+ALTER TABLE branches ADD COLUMN max_tokens INTEGER;
@@ -0,0 +1,4 @@
+ALTER TABLE branches ALTER COLUMN context_tokens TYPE INTEGER;
+ALTER TABLE branches ALTER COLUMN convo_tokens TYPE INTEGER;
+ALTER TABLE branches ALTER COLUMN max_tokens TYPE INTEGER;
+ALTER TABLE convo_summaries ALTER COLUMN tokens TYPE INTEGER;
@@ -0,0 +1 @@
+ALTER TABLE orgs DROP COLUMN max_contexts_per_plan;
@@ -0,0 +1 @@
+ALTER TABLE orgs ADD COLUMN max_contexts_per_plan INTEGER;
@@ -0,0 +1 @@
+ALTER TABLE plans ADD COLUMN default_branch VARCHAR(255);
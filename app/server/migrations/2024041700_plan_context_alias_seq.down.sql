@@ -0,0 +1 @@
+ALTER TABLE plans DROP COLUMN context_alias_seq;
@@ -0,0 +1 @@
+ALTER TABLE plans ADD COLUMN context_alias_seq INTEGER NOT NULL DEFAULT 0;
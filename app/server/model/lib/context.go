@@ -8,9 +8,9 @@ import (
 	"github.com/plandex/plandex/shared"
 )
 
-func FormatModelContext(context []*db.Context) (string, int, error) {
+func FormatModelContext(context []*db.Context) (string, int64, error) {
 	var contextMessages []string
-	var numTokens int
+	var numTokens int64
 	for _, part := range context {
 		var message string
 		var fmtStr string
@@ -19,8 +19,15 @@ func FormatModelContext(context []*db.Context) (string, int, error) {
 		if part.ContextType == shared.ContextDirectoryTreeType {
 			fmtStr = "\n\n- %s | directory tree:\n\n```\n%s\n```"
 			args = append(args, part.FilePath, part.Body)
+		} else if part.ContextType == shared.ContextMapType {
+			fmtStr = "\n\n- %s | map:\n\n```\n%s\n```"
+			args = append(args, part.FilePath, part.Body)
 		} else if part.ContextType == shared.ContextFileType {
-			fmtStr = "\n\n- %s:\n\n```\n%s\n```"
+			if shared.IsExecutableMode(part.FileMode) {
+				fmtStr = "\n\n- %s | executable:\n\n```\n%s\n```"
+			} else {
+				fmtStr = "\n\n- %s:\n\n```\n%s\n```"
+			}
 			args = append(args, part.FilePath, part.Body)
 		} else if part.Url != "" {
 			fmtStr = "\n\n- %s:\n\n```\n%s\n```"
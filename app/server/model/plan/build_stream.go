@@ -131,7 +131,7 @@ func (fileState *activeBuildStreamFileState) listenStream(stream *openai.ChatCom
 				fileState.activeBuild.BufferTokens++
 
 				// After a reasonable threshhold, if buffer has significantly more tokens than original file + proposed changes, something is wrong
-				cutoff := int(math.Max(float64(fileState.activeBuild.CurrentFileTokens+fileState.activeBuild.FileContentTokens), 500) * 1.5)
+				cutoff := int64(math.Max(float64(fileState.activeBuild.CurrentFileTokens+fileState.activeBuild.FileContentTokens), 500) * 1.5)
 				if fileState.activeBuild.BufferTokens > 500 && fileState.activeBuild.BufferTokens > cutoff {
 					log.Printf("File %s: Stream buffer tokens too high\n", filePath)
 					log.Printf("Current file tokens: %d\n", fileState.activeBuild.CurrentFileTokens)
@@ -178,8 +178,8 @@ func execTellPlan(
 	}
 
 	var (
-		numPromptTokens int
-		promptTokens    int
+		numPromptTokens int64
+		promptTokens    int64
 	)
 	if iteration == 0 && missingFileResponse == "" {
 		numPromptTokens, err = shared.GetNumTokens(req.Prompt)
@@ -33,9 +33,9 @@ type activeTellStreamState struct {
 	summarizedToMessageId string
 	promptMessage         *openai.ChatCompletionMessage
 	replyParser           *types.ReplyParser
-	replyNumTokens        int
+	replyNumTokens        int64
 	messages              []openai.ChatCompletionMessage
-	tokensBeforeConvo     int
+	tokensBeforeConvo     int64
 	settings              *shared.PlanSettings
 }
 
@@ -27,8 +27,8 @@ func (state *activeTellStreamState) summarizeMessagesIfNeeded() bool {
 		return false
 	}
 
-	conversationTokens := 0
-	tokensUpToTimestamp := make(map[int64]int)
+	var conversationTokens int64
+	tokensUpToTimestamp := make(map[int64]int64)
 	for _, convoMessage := range convo {
 		conversationTokens += convoMessage.Tokens
 		timestamp := convoMessage.CreatedAt.UnixNano() / int64(time.Millisecond)
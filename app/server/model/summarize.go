@@ -67,7 +67,7 @@ func PlanSummary(client *openai.Client, config shared.ModelRoleConfig, params Pl
 		OrgId:                       params.OrgId,
 		PlanId:                      params.PlanId,
 		Summary:                     content,
-		Tokens:                      resp.Usage.CompletionTokens,
+		Tokens:                      int64(resp.Usage.CompletionTokens),
 		LatestConvoMessageId:        params.LatestConvoMessageId,
 		LatestConvoMessageCreatedAt: params.LatestConvoMessageCreatedAt,
 		NumMessages:                 params.NumMessages,
@@ -0,0 +1,1414 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: context.proto
+
+package contextpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ContextType values match shared.ContextType
+type ContextType int32
+
+const (
+	ContextType_CONTEXT_TYPE_UNSPECIFIED    ContextType = 0
+	ContextType_CONTEXT_TYPE_FILE           ContextType = 1
+	ContextType_CONTEXT_TYPE_URL            ContextType = 2
+	ContextType_CONTEXT_TYPE_NOTE           ContextType = 3
+	ContextType_CONTEXT_TYPE_DIRECTORY_TREE ContextType = 4
+	ContextType_CONTEXT_TYPE_PIPED_DATA     ContextType = 5
+	ContextType_CONTEXT_TYPE_MAP            ContextType = 6
+)
+
+// Enum value maps for ContextType.
+var (
+	ContextType_name = map[int32]string{
+		0: "CONTEXT_TYPE_UNSPECIFIED",
+		1: "CONTEXT_TYPE_FILE",
+		2: "CONTEXT_TYPE_URL",
+		3: "CONTEXT_TYPE_NOTE",
+		4: "CONTEXT_TYPE_DIRECTORY_TREE",
+		5: "CONTEXT_TYPE_PIPED_DATA",
+		6: "CONTEXT_TYPE_MAP",
+	}
+	ContextType_value = map[string]int32{
+		"CONTEXT_TYPE_UNSPECIFIED":    0,
+		"CONTEXT_TYPE_FILE":           1,
+		"CONTEXT_TYPE_URL":            2,
+		"CONTEXT_TYPE_NOTE":           3,
+		"CONTEXT_TYPE_DIRECTORY_TREE": 4,
+		"CONTEXT_TYPE_PIPED_DATA":     5,
+		"CONTEXT_TYPE_MAP":            6,
+	}
+)
+
+func (x ContextType) Enum() *ContextType {
+	p := new(ContextType)
+	*p = x
+	return p
+}
+
+func (x ContextType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ContextType) Descriptor() protoreflect.EnumDescriptor {
+	return file_context_proto_enumTypes[0].Descriptor()
+}
+
+func (ContextType) Type() protoreflect.EnumType {
+	return &file_context_proto_enumTypes[0]
+}
+
+func (x ContextType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ContextType.Descriptor instead.
+func (ContextType) EnumDescriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{0}
+}
+
+type Context struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id          string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	OwnerId     string                 `protobuf:"bytes,2,opt,name=owner_id,json=ownerId,proto3" json:"owner_id,omitempty"`
+	ContextType ContextType            `protobuf:"varint,3,opt,name=context_type,json=contextType,proto3,enum=plandex.context.v1.ContextType" json:"context_type,omitempty"`
+	Name        string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Url         string                 `protobuf:"bytes,5,opt,name=url,proto3" json:"url,omitempty"`
+	FilePath    string                 `protobuf:"bytes,6,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Sha         string                 `protobuf:"bytes,7,opt,name=sha,proto3" json:"sha,omitempty"`
+	NumTokens   int64                  `protobuf:"varint,8,opt,name=num_tokens,json=numTokens,proto3" json:"num_tokens,omitempty"`
+	// only set when listed with include_bodies
+	Body                  string                 `protobuf:"bytes,9,opt,name=body,proto3" json:"body,omitempty"`
+	ForceSkipIgnore       bool                   `protobuf:"varint,10,opt,name=force_skip_ignore,json=forceSkipIgnore,proto3" json:"force_skip_ignore,omitempty"`
+	FileMode              uint32                 `protobuf:"varint,11,opt,name=file_mode,json=fileMode,proto3" json:"file_mode,omitempty"`
+	Tags                  []string               `protobuf:"bytes,12,rep,name=tags,proto3" json:"tags,omitempty"`
+	StripComments         bool                   `protobuf:"varint,13,opt,name=strip_comments,json=stripComments,proto3" json:"strip_comments,omitempty"`
+	PreserveDocstrings    bool                   `protobuf:"varint,14,opt,name=preserve_docstrings,json=preserveDocstrings,proto3" json:"preserve_docstrings,omitempty"`
+	ResolveRelativeUrls   bool                   `protobuf:"varint,15,opt,name=resolve_relative_urls,json=resolveRelativeUrls,proto3" json:"resolve_relative_urls,omitempty"`
+	BaseUrl               string                 `protobuf:"bytes,16,opt,name=base_url,json=baseUrl,proto3" json:"base_url,omitempty"`
+	Encoding              string                 `protobuf:"bytes,17,opt,name=encoding,proto3" json:"encoding,omitempty"`
+	CreatedAt             *timestamppb.Timestamp `protobuf:"bytes,18,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt             *timestamppb.Timestamp `protobuf:"bytes,19,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	LineEndingsNormalized bool                   `protobuf:"varint,20,opt,name=line_endings_normalized,json=lineEndingsNormalized,proto3" json:"line_endings_normalized,omitempty"`
+	Deminified            bool                   `protobuf:"varint,21,opt,name=deminified,proto3" json:"deminified,omitempty"`
+	BomStripped           bool                   `protobuf:"varint,22,opt,name=bom_stripped,json=bomStripped,proto3" json:"bom_stripped,omitempty"`
+	// "single", "none", or empty if left as-is
+	TrailingNewline string `protobuf:"bytes,23,opt,name=trailing_newline,json=trailingNewline,proto3" json:"trailing_newline,omitempty"`
+	// "github api", "gitlab api", or empty if the page itself was fetched
+	UrlSource string `protobuf:"bytes,24,opt,name=url_source,json=urlSource,proto3" json:"url_source,omitempty"`
+	// plan-scoped alias, referred to as "#<alias>"
+	Alias  int32 `protobuf:"varint,25,opt,name=alias,proto3" json:"alias,omitempty"`
+	Pinned bool  `protobuf:"varint,26,opt,name=pinned,proto3" json:"pinned,omitempty"`
+	// the tool that loaded the context
+	Source string `protobuf:"bytes,27,opt,name=source,proto3" json:"source,omitempty"`
+	// the Content-Type a url context was fetched with
+	ContentType   string `protobuf:"bytes,28,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Context) Reset() {
+	*x = Context{}
+	mi := &file_context_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Context) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Context) ProtoMessage() {}
+
+func (x *Context) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Context.ProtoReflect.Descriptor instead.
+func (*Context) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Context) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Context) GetOwnerId() string {
+	if x != nil {
+		return x.OwnerId
+	}
+	return ""
+}
+
+func (x *Context) GetContextType() ContextType {
+	if x != nil {
+		return x.ContextType
+	}
+	return ContextType_CONTEXT_TYPE_UNSPECIFIED
+}
+
+func (x *Context) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Context) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Context) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *Context) GetSha() string {
+	if x != nil {
+		return x.Sha
+	}
+	return ""
+}
+
+func (x *Context) GetNumTokens() int64 {
+	if x != nil {
+		return x.NumTokens
+	}
+	return 0
+}
+
+func (x *Context) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Context) GetForceSkipIgnore() bool {
+	if x != nil {
+		return x.ForceSkipIgnore
+	}
+	return false
+}
+
+func (x *Context) GetFileMode() uint32 {
+	if x != nil {
+		return x.FileMode
+	}
+	return 0
+}
+
+func (x *Context) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Context) GetStripComments() bool {
+	if x != nil {
+		return x.StripComments
+	}
+	return false
+}
+
+func (x *Context) GetPreserveDocstrings() bool {
+	if x != nil {
+		return x.PreserveDocstrings
+	}
+	return false
+}
+
+func (x *Context) GetResolveRelativeUrls() bool {
+	if x != nil {
+		return x.ResolveRelativeUrls
+	}
+	return false
+}
+
+func (x *Context) GetBaseUrl() string {
+	if x != nil {
+		return x.BaseUrl
+	}
+	return ""
+}
+
+func (x *Context) GetEncoding() string {
+	if x != nil {
+		return x.Encoding
+	}
+	return ""
+}
+
+func (x *Context) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Context) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Context) GetLineEndingsNormalized() bool {
+	if x != nil {
+		return x.LineEndingsNormalized
+	}
+	return false
+}
+
+func (x *Context) GetDeminified() bool {
+	if x != nil {
+		return x.Deminified
+	}
+	return false
+}
+
+func (x *Context) GetBomStripped() bool {
+	if x != nil {
+		return x.BomStripped
+	}
+	return false
+}
+
+func (x *Context) GetTrailingNewline() string {
+	if x != nil {
+		return x.TrailingNewline
+	}
+	return ""
+}
+
+func (x *Context) GetUrlSource() string {
+	if x != nil {
+		return x.UrlSource
+	}
+	return ""
+}
+
+func (x *Context) GetAlias() int32 {
+	if x != nil {
+		return x.Alias
+	}
+	return 0
+}
+
+func (x *Context) GetPinned() bool {
+	if x != nil {
+		return x.Pinned
+	}
+	return false
+}
+
+func (x *Context) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Context) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+type ListContextRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	PlanId string                 `protobuf:"bytes,1,opt,name=plan_id,json=planId,proto3" json:"plan_id,omitempty"`
+	// empty uses the plan's default branch
+	Branch        string `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	IncludeBodies bool   `protobuf:"varint,3,opt,name=include_bodies,json=includeBodies,proto3" json:"include_bodies,omitempty"`
+	// any of these types, by short name like file, url, tree, or all types if empty
+	Types      []string `protobuf:"bytes,4,rep,name=types,proto3" json:"types,omitempty"`
+	PathPrefix string   `protobuf:"bytes,5,opt,name=path_prefix,json=pathPrefix,proto3" json:"path_prefix,omitempty"`
+	Source     string   `protobuf:"bytes,6,opt,name=source,proto3" json:"source,omitempty"`
+	// one of the ListContext sort keys, like name, path, tokens, createdAt. Contexts are in load order if empty.
+	Sort          string `protobuf:"bytes,7,opt,name=sort,proto3" json:"sort,omitempty"`
+	SortDesc      bool   `protobuf:"varint,8,opt,name=sort_desc,json=sortDesc,proto3" json:"sort_desc,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListContextRequest) Reset() {
+	*x = ListContextRequest{}
+	mi := &file_context_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListContextRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListContextRequest) ProtoMessage() {}
+
+func (x *ListContextRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListContextRequest.ProtoReflect.Descriptor instead.
+func (*ListContextRequest) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListContextRequest) GetPlanId() string {
+	if x != nil {
+		return x.PlanId
+	}
+	return ""
+}
+
+func (x *ListContextRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *ListContextRequest) GetIncludeBodies() bool {
+	if x != nil {
+		return x.IncludeBodies
+	}
+	return false
+}
+
+func (x *ListContextRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *ListContextRequest) GetPathPrefix() string {
+	if x != nil {
+		return x.PathPrefix
+	}
+	return ""
+}
+
+func (x *ListContextRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *ListContextRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *ListContextRequest) GetSortDesc() bool {
+	if x != nil {
+		return x.SortDesc
+	}
+	return false
+}
+
+type ListContextResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Contexts      []*Context             `protobuf:"bytes,1,rep,name=contexts,proto3" json:"contexts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListContextResponse) Reset() {
+	*x = ListContextResponse{}
+	mi := &file_context_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListContextResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListContextResponse) ProtoMessage() {}
+
+func (x *ListContextResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListContextResponse.ProtoReflect.Descriptor instead.
+func (*ListContextResponse) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListContextResponse) GetContexts() []*Context {
+	if x != nil {
+		return x.Contexts
+	}
+	return nil
+}
+
+type LoadContextParams struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// inferred from the path or url if unspecified
+	ContextType         ContextType `protobuf:"varint,1,opt,name=context_type,json=contextType,proto3,enum=plandex.context.v1.ContextType" json:"context_type,omitempty"`
+	Name                string      `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Url                 string      `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	FilePath            string      `protobuf:"bytes,4,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Body                string      `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	ForceSkipIgnore     bool        `protobuf:"varint,6,opt,name=force_skip_ignore,json=forceSkipIgnore,proto3" json:"force_skip_ignore,omitempty"`
+	FileMode            uint32      `protobuf:"varint,7,opt,name=file_mode,json=fileMode,proto3" json:"file_mode,omitempty"`
+	StripComments       bool        `protobuf:"varint,8,opt,name=strip_comments,json=stripComments,proto3" json:"strip_comments,omitempty"`
+	PreserveDocstrings  bool        `protobuf:"varint,9,opt,name=preserve_docstrings,json=preserveDocstrings,proto3" json:"preserve_docstrings,omitempty"`
+	ResolveRelativeUrls bool        `protobuf:"varint,10,opt,name=resolve_relative_urls,json=resolveRelativeUrls,proto3" json:"resolve_relative_urls,omitempty"`
+	BaseUrl             string      `protobuf:"bytes,11,opt,name=base_url,json=baseUrl,proto3" json:"base_url,omitempty"`
+	Encoding            string      `protobuf:"bytes,12,opt,name=encoding,proto3" json:"encoding,omitempty"`
+	RawBody             []byte      `protobuf:"bytes,13,opt,name=raw_body,json=rawBody,proto3" json:"raw_body,omitempty"`
+	TreePaths           []string    `protobuf:"bytes,14,rep,name=tree_paths,json=treePaths,proto3" json:"tree_paths,omitempty"`
+	Dedupe              bool        `protobuf:"varint,15,opt,name=dedupe,proto3" json:"dedupe,omitempty"`
+	Source              string      `protobuf:"bytes,16,opt,name=source,proto3" json:"source,omitempty"`
+	// decides whether resolve_relative_urls applies, over the path or url's extension
+	ContentType   string `protobuf:"bytes,17,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoadContextParams) Reset() {
+	*x = LoadContextParams{}
+	mi := &file_context_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoadContextParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadContextParams) ProtoMessage() {}
+
+func (x *LoadContextParams) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadContextParams.ProtoReflect.Descriptor instead.
+func (*LoadContextParams) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LoadContextParams) GetContextType() ContextType {
+	if x != nil {
+		return x.ContextType
+	}
+	return ContextType_CONTEXT_TYPE_UNSPECIFIED
+}
+
+func (x *LoadContextParams) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *LoadContextParams) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *LoadContextParams) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *LoadContextParams) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *LoadContextParams) GetForceSkipIgnore() bool {
+	if x != nil {
+		return x.ForceSkipIgnore
+	}
+	return false
+}
+
+func (x *LoadContextParams) GetFileMode() uint32 {
+	if x != nil {
+		return x.FileMode
+	}
+	return 0
+}
+
+func (x *LoadContextParams) GetStripComments() bool {
+	if x != nil {
+		return x.StripComments
+	}
+	return false
+}
+
+func (x *LoadContextParams) GetPreserveDocstrings() bool {
+	if x != nil {
+		return x.PreserveDocstrings
+	}
+	return false
+}
+
+func (x *LoadContextParams) GetResolveRelativeUrls() bool {
+	if x != nil {
+		return x.ResolveRelativeUrls
+	}
+	return false
+}
+
+func (x *LoadContextParams) GetBaseUrl() string {
+	if x != nil {
+		return x.BaseUrl
+	}
+	return ""
+}
+
+func (x *LoadContextParams) GetEncoding() string {
+	if x != nil {
+		return x.Encoding
+	}
+	return ""
+}
+
+func (x *LoadContextParams) GetRawBody() []byte {
+	if x != nil {
+		return x.RawBody
+	}
+	return nil
+}
+
+func (x *LoadContextParams) GetTreePaths() []string {
+	if x != nil {
+		return x.TreePaths
+	}
+	return nil
+}
+
+func (x *LoadContextParams) GetDedupe() bool {
+	if x != nil {
+		return x.Dedupe
+	}
+	return false
+}
+
+func (x *LoadContextParams) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *LoadContextParams) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+type LoadContextRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	PlanId string                 `protobuf:"bytes,1,opt,name=plan_id,json=planId,proto3" json:"plan_id,omitempty"`
+	// empty uses the plan's default branch
+	Branch        string               `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	Contexts      []*LoadContextParams `protobuf:"bytes,3,rep,name=contexts,proto3" json:"contexts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoadContextRequest) Reset() {
+	*x = LoadContextRequest{}
+	mi := &file_context_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoadContextRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadContextRequest) ProtoMessage() {}
+
+func (x *LoadContextRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadContextRequest.ProtoReflect.Descriptor instead.
+func (*LoadContextRequest) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LoadContextRequest) GetPlanId() string {
+	if x != nil {
+		return x.PlanId
+	}
+	return ""
+}
+
+func (x *LoadContextRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *LoadContextRequest) GetContexts() []*LoadContextParams {
+	if x != nil {
+		return x.Contexts
+	}
+	return nil
+}
+
+type LoadContextResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	TokensAdded       int64                  `protobuf:"varint,1,opt,name=tokens_added,json=tokensAdded,proto3" json:"tokens_added,omitempty"`
+	TotalTokens       int64                  `protobuf:"varint,2,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
+	MaxTokensExceeded bool                   `protobuf:"varint,3,opt,name=max_tokens_exceeded,json=maxTokensExceeded,proto3" json:"max_tokens_exceeded,omitempty"`
+	MaxTokens         int64                  `protobuf:"varint,4,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
+	Msg               string                 `protobuf:"bytes,5,opt,name=msg,proto3" json:"msg,omitempty"`
+	Warnings          []string               `protobuf:"bytes,6,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	TokensSaved       int64                  `protobuf:"varint,7,opt,name=tokens_saved,json=tokensSaved,proto3" json:"tokens_saved,omitempty"`
+	// set when the load would put the plan over its cap on contexts
+	ContextCountExceeded bool `protobuf:"varint,8,opt,name=context_count_exceeded,json=contextCountExceeded,proto3" json:"context_count_exceeded,omitempty"`
+	// for updates, contexts that failed and were skipped, mapped to why
+	FailedById   map[string]string `protobuf:"bytes,9,rep,name=failed_by_id,json=failedById,proto3" json:"failed_by_id,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	UnchangedIds []string          `protobuf:"bytes,10,rep,name=unchanged_ids,json=unchangedIds,proto3" json:"unchanged_ids,omitempty"`
+	// test or source counterparts of loaded files that aren't in context. They may not exist.
+	PairedFiles   []*PairedFile `protobuf:"bytes,11,rep,name=paired_files,json=pairedFiles,proto3" json:"paired_files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoadContextResponse) Reset() {
+	*x = LoadContextResponse{}
+	mi := &file_context_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoadContextResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadContextResponse) ProtoMessage() {}
+
+func (x *LoadContextResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadContextResponse.ProtoReflect.Descriptor instead.
+func (*LoadContextResponse) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *LoadContextResponse) GetTokensAdded() int64 {
+	if x != nil {
+		return x.TokensAdded
+	}
+	return 0
+}
+
+func (x *LoadContextResponse) GetTotalTokens() int64 {
+	if x != nil {
+		return x.TotalTokens
+	}
+	return 0
+}
+
+func (x *LoadContextResponse) GetMaxTokensExceeded() bool {
+	if x != nil {
+		return x.MaxTokensExceeded
+	}
+	return false
+}
+
+func (x *LoadContextResponse) GetMaxTokens() int64 {
+	if x != nil {
+		return x.MaxTokens
+	}
+	return 0
+}
+
+func (x *LoadContextResponse) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *LoadContextResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *LoadContextResponse) GetTokensSaved() int64 {
+	if x != nil {
+		return x.TokensSaved
+	}
+	return 0
+}
+
+func (x *LoadContextResponse) GetContextCountExceeded() bool {
+	if x != nil {
+		return x.ContextCountExceeded
+	}
+	return false
+}
+
+func (x *LoadContextResponse) GetFailedById() map[string]string {
+	if x != nil {
+		return x.FailedById
+	}
+	return nil
+}
+
+func (x *LoadContextResponse) GetUnchangedIds() []string {
+	if x != nil {
+		return x.UnchangedIds
+	}
+	return nil
+}
+
+func (x *LoadContextResponse) GetPairedFiles() []*PairedFile {
+	if x != nil {
+		return x.PairedFiles
+	}
+	return nil
+}
+
+type PairedFile struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// the loaded file path pairs with
+	ForPath       string `protobuf:"bytes,2,opt,name=for_path,json=forPath,proto3" json:"for_path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PairedFile) Reset() {
+	*x = PairedFile{}
+	mi := &file_context_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PairedFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PairedFile) ProtoMessage() {}
+
+func (x *PairedFile) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PairedFile.ProtoReflect.Descriptor instead.
+func (*PairedFile) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PairedFile) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *PairedFile) GetForPath() string {
+	if x != nil {
+		return x.ForPath
+	}
+	return ""
+}
+
+type UpdateContextParams struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Body    string                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	RawBody []byte                 `protobuf:"bytes,2,opt,name=raw_body,json=rawBody,proto3" json:"raw_body,omitempty"`
+	// only applied if the stored context's sha still matches
+	ExpectedSha string `protobuf:"bytes,3,opt,name=expected_sha,json=expectedSha,proto3" json:"expected_sha,omitempty"`
+	// "replace", "append", or "prepend". Defaults to replace.
+	Mode string `protobuf:"bytes,4,opt,name=mode,proto3" json:"mode,omitempty"`
+	// pins or unpins the context. With no body, only the pin changes.
+	Pinned        *bool `protobuf:"varint,5,opt,name=pinned,proto3,oneof" json:"pinned,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateContextParams) Reset() {
+	*x = UpdateContextParams{}
+	mi := &file_context_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateContextParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateContextParams) ProtoMessage() {}
+
+func (x *UpdateContextParams) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateContextParams.ProtoReflect.Descriptor instead.
+func (*UpdateContextParams) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UpdateContextParams) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *UpdateContextParams) GetRawBody() []byte {
+	if x != nil {
+		return x.RawBody
+	}
+	return nil
+}
+
+func (x *UpdateContextParams) GetExpectedSha() string {
+	if x != nil {
+		return x.ExpectedSha
+	}
+	return ""
+}
+
+func (x *UpdateContextParams) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *UpdateContextParams) GetPinned() bool {
+	if x != nil && x.Pinned != nil {
+		return *x.Pinned
+	}
+	return false
+}
+
+type UpdateContextRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	PlanId string                 `protobuf:"bytes,1,opt,name=plan_id,json=planId,proto3" json:"plan_id,omitempty"`
+	// empty uses the plan's default branch
+	Branch string `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	// context ids or aliases like "#3"
+	Contexts map[string]*UpdateContextParams `protobuf:"bytes,3,rep,name=contexts,proto3" json:"contexts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// fail the whole update if any context fails, rather than skipping it
+	Atomic        bool `protobuf:"varint,4,opt,name=atomic,proto3" json:"atomic,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateContextRequest) Reset() {
+	*x = UpdateContextRequest{}
+	mi := &file_context_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateContextRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateContextRequest) ProtoMessage() {}
+
+func (x *UpdateContextRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateContextRequest.ProtoReflect.Descriptor instead.
+func (*UpdateContextRequest) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UpdateContextRequest) GetPlanId() string {
+	if x != nil {
+		return x.PlanId
+	}
+	return ""
+}
+
+func (x *UpdateContextRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *UpdateContextRequest) GetContexts() map[string]*UpdateContextParams {
+	if x != nil {
+		return x.Contexts
+	}
+	return nil
+}
+
+func (x *UpdateContextRequest) GetAtomic() bool {
+	if x != nil {
+		return x.Atomic
+	}
+	return false
+}
+
+type DeleteContextRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	PlanId string                 `protobuf:"bytes,1,opt,name=plan_id,json=planId,proto3" json:"plan_id,omitempty"`
+	// empty uses the plan's default branch
+	Branch string `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	// context ids or aliases like "#3"
+	Ids           []string `protobuf:"bytes,3,rep,name=ids,proto3" json:"ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteContextRequest) Reset() {
+	*x = DeleteContextRequest{}
+	mi := &file_context_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteContextRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteContextRequest) ProtoMessage() {}
+
+func (x *DeleteContextRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteContextRequest.ProtoReflect.Descriptor instead.
+func (*DeleteContextRequest) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeleteContextRequest) GetPlanId() string {
+	if x != nil {
+		return x.PlanId
+	}
+	return ""
+}
+
+func (x *DeleteContextRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *DeleteContextRequest) GetIds() []string {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+type DeleteContextResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TokensRemoved int64                  `protobuf:"varint,1,opt,name=tokens_removed,json=tokensRemoved,proto3" json:"tokens_removed,omitempty"`
+	TotalTokens   int64                  `protobuf:"varint,2,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
+	Msg           string                 `protobuf:"bytes,3,opt,name=msg,proto3" json:"msg,omitempty"`
+	DeletedIds    []string               `protobuf:"bytes,4,rep,name=deleted_ids,json=deletedIds,proto3" json:"deleted_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteContextResponse) Reset() {
+	*x = DeleteContextResponse{}
+	mi := &file_context_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteContextResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteContextResponse) ProtoMessage() {}
+
+func (x *DeleteContextResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_context_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteContextResponse.ProtoReflect.Descriptor instead.
+func (*DeleteContextResponse) Descriptor() ([]byte, []int) {
+	return file_context_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteContextResponse) GetTokensRemoved() int64 {
+	if x != nil {
+		return x.TokensRemoved
+	}
+	return 0
+}
+
+func (x *DeleteContextResponse) GetTotalTokens() int64 {
+	if x != nil {
+		return x.TotalTokens
+	}
+	return 0
+}
+
+func (x *DeleteContextResponse) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *DeleteContextResponse) GetDeletedIds() []string {
+	if x != nil {
+		return x.DeletedIds
+	}
+	return nil
+}
+
+var File_context_proto protoreflect.FileDescriptor
+
+const file_context_proto_rawDesc = "" +
+	"\n" +
+	"\rcontext.proto\x12\x12plandex.context.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc4\a\n" +
+	"\aContext\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x19\n" +
+	"\bowner_id\x18\x02 \x01(\tR\aownerId\x12B\n" +
+	"\fcontext_type\x18\x03 \x01(\x0e2\x1f.plandex.context.v1.ContextTypeR\vcontextType\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x10\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\x12\x1b\n" +
+	"\tfile_path\x18\x06 \x01(\tR\bfilePath\x12\x10\n" +
+	"\x03sha\x18\a \x01(\tR\x03sha\x12\x1d\n" +
+	"\n" +
+	"num_tokens\x18\b \x01(\x03R\tnumTokens\x12\x12\n" +
+	"\x04body\x18\t \x01(\tR\x04body\x12*\n" +
+	"\x11force_skip_ignore\x18\n" +
+	" \x01(\bR\x0fforceSkipIgnore\x12\x1b\n" +
+	"\tfile_mode\x18\v \x01(\rR\bfileMode\x12\x12\n" +
+	"\x04tags\x18\f \x03(\tR\x04tags\x12%\n" +
+	"\x0estrip_comments\x18\r \x01(\bR\rstripComments\x12/\n" +
+	"\x13preserve_docstrings\x18\x0e \x01(\bR\x12preserveDocstrings\x122\n" +
+	"\x15resolve_relative_urls\x18\x0f \x01(\bR\x13resolveRelativeUrls\x12\x19\n" +
+	"\bbase_url\x18\x10 \x01(\tR\abaseUrl\x12\x1a\n" +
+	"\bencoding\x18\x11 \x01(\tR\bencoding\x129\n" +
+	"\n" +
+	"created_at\x18\x12 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x13 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x126\n" +
+	"\x17line_endings_normalized\x18\x14 \x01(\bR\x15lineEndingsNormalized\x12\x1e\n" +
+	"\n" +
+	"deminified\x18\x15 \x01(\bR\n" +
+	"deminified\x12!\n" +
+	"\fbom_stripped\x18\x16 \x01(\bR\vbomStripped\x12)\n" +
+	"\x10trailing_newline\x18\x17 \x01(\tR\x0ftrailingNewline\x12\x1d\n" +
+	"\n" +
+	"url_source\x18\x18 \x01(\tR\turlSource\x12\x14\n" +
+	"\x05alias\x18\x19 \x01(\x05R\x05alias\x12\x16\n" +
+	"\x06pinned\x18\x1a \x01(\bR\x06pinned\x12\x16\n" +
+	"\x06source\x18\x1b \x01(\tR\x06source\x12!\n" +
+	"\fcontent_type\x18\x1c \x01(\tR\vcontentType\"\xec\x01\n" +
+	"\x12ListContextRequest\x12\x17\n" +
+	"\aplan_id\x18\x01 \x01(\tR\x06planId\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x12%\n" +
+	"\x0einclude_bodies\x18\x03 \x01(\bR\rincludeBodies\x12\x14\n" +
+	"\x05types\x18\x04 \x03(\tR\x05types\x12\x1f\n" +
+	"\vpath_prefix\x18\x05 \x01(\tR\n" +
+	"pathPrefix\x12\x16\n" +
+	"\x06source\x18\x06 \x01(\tR\x06source\x12\x12\n" +
+	"\x04sort\x18\a \x01(\tR\x04sort\x12\x1b\n" +
+	"\tsort_desc\x18\b \x01(\bR\bsortDesc\"N\n" +
+	"\x13ListContextResponse\x127\n" +
+	"\bcontexts\x18\x01 \x03(\v2\x1b.plandex.context.v1.ContextR\bcontexts\"\xc7\x04\n" +
+	"\x11LoadContextParams\x12B\n" +
+	"\fcontext_type\x18\x01 \x01(\x0e2\x1f.plandex.context.v1.ContextTypeR\vcontextType\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\x12\x1b\n" +
+	"\tfile_path\x18\x04 \x01(\tR\bfilePath\x12\x12\n" +
+	"\x04body\x18\x05 \x01(\tR\x04body\x12*\n" +
+	"\x11force_skip_ignore\x18\x06 \x01(\bR\x0fforceSkipIgnore\x12\x1b\n" +
+	"\tfile_mode\x18\a \x01(\rR\bfileMode\x12%\n" +
+	"\x0estrip_comments\x18\b \x01(\bR\rstripComments\x12/\n" +
+	"\x13preserve_docstrings\x18\t \x01(\bR\x12preserveDocstrings\x122\n" +
+	"\x15resolve_relative_urls\x18\n" +
+	" \x01(\bR\x13resolveRelativeUrls\x12\x19\n" +
+	"\bbase_url\x18\v \x01(\tR\abaseUrl\x12\x1a\n" +
+	"\bencoding\x18\f \x01(\tR\bencoding\x12\x19\n" +
+	"\braw_body\x18\r \x01(\fR\arawBody\x12\x1d\n" +
+	"\n" +
+	"tree_paths\x18\x0e \x03(\tR\ttreePaths\x12\x16\n" +
+	"\x06dedupe\x18\x0f \x01(\bR\x06dedupe\x12\x16\n" +
+	"\x06source\x18\x10 \x01(\tR\x06source\x12!\n" +
+	"\fcontent_type\x18\x11 \x01(\tR\vcontentType\"\x88\x01\n" +
+	"\x12LoadContextRequest\x12\x17\n" +
+	"\aplan_id\x18\x01 \x01(\tR\x06planId\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x12A\n" +
+	"\bcontexts\x18\x03 \x03(\v2%.plandex.context.v1.LoadContextParamsR\bcontexts\"\xb3\x04\n" +
+	"\x13LoadContextResponse\x12!\n" +
+	"\ftokens_added\x18\x01 \x01(\x03R\vtokensAdded\x12!\n" +
+	"\ftotal_tokens\x18\x02 \x01(\x03R\vtotalTokens\x12.\n" +
+	"\x13max_tokens_exceeded\x18\x03 \x01(\bR\x11maxTokensExceeded\x12\x1d\n" +
+	"\n" +
+	"max_tokens\x18\x04 \x01(\x03R\tmaxTokens\x12\x10\n" +
+	"\x03msg\x18\x05 \x01(\tR\x03msg\x12\x1a\n" +
+	"\bwarnings\x18\x06 \x03(\tR\bwarnings\x12!\n" +
+	"\ftokens_saved\x18\a \x01(\x03R\vtokensSaved\x124\n" +
+	"\x16context_count_exceeded\x18\b \x01(\bR\x14contextCountExceeded\x12Y\n" +
+	"\ffailed_by_id\x18\t \x03(\v27.plandex.context.v1.LoadContextResponse.FailedByIdEntryR\n" +
+	"failedById\x12#\n" +
+	"\runchanged_ids\x18\n" +
+	" \x03(\tR\funchangedIds\x12A\n" +
+	"\fpaired_files\x18\v \x03(\v2\x1e.plandex.context.v1.PairedFileR\vpairedFiles\x1a=\n" +
+	"\x0fFailedByIdEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\";\n" +
+	"\n" +
+	"PairedFile\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x19\n" +
+	"\bfor_path\x18\x02 \x01(\tR\aforPath\"\xa3\x01\n" +
+	"\x13UpdateContextParams\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\tR\x04body\x12\x19\n" +
+	"\braw_body\x18\x02 \x01(\fR\arawBody\x12!\n" +
+	"\fexpected_sha\x18\x03 \x01(\tR\vexpectedSha\x12\x12\n" +
+	"\x04mode\x18\x04 \x01(\tR\x04mode\x12\x1b\n" +
+	"\x06pinned\x18\x05 \x01(\bH\x00R\x06pinned\x88\x01\x01B\t\n" +
+	"\a_pinned\"\x99\x02\n" +
+	"\x14UpdateContextRequest\x12\x17\n" +
+	"\aplan_id\x18\x01 \x01(\tR\x06planId\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x12R\n" +
+	"\bcontexts\x18\x03 \x03(\v26.plandex.context.v1.UpdateContextRequest.ContextsEntryR\bcontexts\x12\x16\n" +
+	"\x06atomic\x18\x04 \x01(\bR\x06atomic\x1ad\n" +
+	"\rContextsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12=\n" +
+	"\x05value\x18\x02 \x01(\v2'.plandex.context.v1.UpdateContextParamsR\x05value:\x028\x01\"Y\n" +
+	"\x14DeleteContextRequest\x12\x17\n" +
+	"\aplan_id\x18\x01 \x01(\tR\x06planId\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x12\x10\n" +
+	"\x03ids\x18\x03 \x03(\tR\x03ids\"\x94\x01\n" +
+	"\x15DeleteContextResponse\x12%\n" +
+	"\x0etokens_removed\x18\x01 \x01(\x03R\rtokensRemoved\x12!\n" +
+	"\ftotal_tokens\x18\x02 \x01(\x03R\vtotalTokens\x12\x10\n" +
+	"\x03msg\x18\x03 \x01(\tR\x03msg\x12\x1f\n" +
+	"\vdeleted_ids\x18\x04 \x03(\tR\n" +
+	"deletedIds*\xc3\x01\n" +
+	"\vContextType\x12\x1c\n" +
+	"\x18CONTEXT_TYPE_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11CONTEXT_TYPE_FILE\x10\x01\x12\x14\n" +
+	"\x10CONTEXT_TYPE_URL\x10\x02\x12\x15\n" +
+	"\x11CONTEXT_TYPE_NOTE\x10\x03\x12\x1f\n" +
+	"\x1bCONTEXT_TYPE_DIRECTORY_TREE\x10\x04\x12\x1b\n" +
+	"\x17CONTEXT_TYPE_PIPED_DATA\x10\x05\x12\x14\n" +
+	"\x10CONTEXT_TYPE_MAP\x10\x062\x9a\x03\n" +
+	"\x0eContextService\x12^\n" +
+	"\vListContext\x12&.plandex.context.v1.ListContextRequest\x1a'.plandex.context.v1.ListContextResponse\x12^\n" +
+	"\vLoadContext\x12&.plandex.context.v1.LoadContextRequest\x1a'.plandex.context.v1.LoadContextResponse\x12b\n" +
+	"\rUpdateContext\x12(.plandex.context.v1.UpdateContextRequest\x1a'.plandex.context.v1.LoadContextResponse\x12d\n" +
+	"\rDeleteContext\x12(.plandex.context.v1.DeleteContextRequest\x1a).plandex.context.v1.DeleteContextResponseB Z\x1eplandex-server/proto/contextpbb\x06proto3"
+
+var (
+	file_context_proto_rawDescOnce sync.Once
+	file_context_proto_rawDescData []byte
+)
+
+func file_context_proto_rawDescGZIP() []byte {
+	file_context_proto_rawDescOnce.Do(func() {
+		file_context_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_context_proto_rawDesc), len(file_context_proto_rawDesc)))
+	})
+	return file_context_proto_rawDescData
+}
+
+var file_context_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_context_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_context_proto_goTypes = []any{
+	(ContextType)(0),              // 0: plandex.context.v1.ContextType
+	(*Context)(nil),               // 1: plandex.context.v1.Context
+	(*ListContextRequest)(nil),    // 2: plandex.context.v1.ListContextRequest
+	(*ListContextResponse)(nil),   // 3: plandex.context.v1.ListContextResponse
+	(*LoadContextParams)(nil),     // 4: plandex.context.v1.LoadContextParams
+	(*LoadContextRequest)(nil),    // 5: plandex.context.v1.LoadContextRequest
+	(*LoadContextResponse)(nil),   // 6: plandex.context.v1.LoadContextResponse
+	(*PairedFile)(nil),            // 7: plandex.context.v1.PairedFile
+	(*UpdateContextParams)(nil),   // 8: plandex.context.v1.UpdateContextParams
+	(*UpdateContextRequest)(nil),  // 9: plandex.context.v1.UpdateContextRequest
+	(*DeleteContextRequest)(nil),  // 10: plandex.context.v1.DeleteContextRequest
+	(*DeleteContextResponse)(nil), // 11: plandex.context.v1.DeleteContextResponse
+	nil,                           // 12: plandex.context.v1.LoadContextResponse.FailedByIdEntry
+	nil,                           // 13: plandex.context.v1.UpdateContextRequest.ContextsEntry
+	(*timestamppb.Timestamp)(nil), // 14: google.protobuf.Timestamp
+}
+var file_context_proto_depIdxs = []int32{
+	0,  // 0: plandex.context.v1.Context.context_type:type_name -> plandex.context.v1.ContextType
+	14, // 1: plandex.context.v1.Context.created_at:type_name -> google.protobuf.Timestamp
+	14, // 2: plandex.context.v1.Context.updated_at:type_name -> google.protobuf.Timestamp
+	1,  // 3: plandex.context.v1.ListContextResponse.contexts:type_name -> plandex.context.v1.Context
+	0,  // 4: plandex.context.v1.LoadContextParams.context_type:type_name -> plandex.context.v1.ContextType
+	4,  // 5: plandex.context.v1.LoadContextRequest.contexts:type_name -> plandex.context.v1.LoadContextParams
+	12, // 6: plandex.context.v1.LoadContextResponse.failed_by_id:type_name -> plandex.context.v1.LoadContextResponse.FailedByIdEntry
+	7,  // 7: plandex.context.v1.LoadContextResponse.paired_files:type_name -> plandex.context.v1.PairedFile
+	13, // 8: plandex.context.v1.UpdateContextRequest.contexts:type_name -> plandex.context.v1.UpdateContextRequest.ContextsEntry
+	8,  // 9: plandex.context.v1.UpdateContextRequest.ContextsEntry.value:type_name -> plandex.context.v1.UpdateContextParams
+	2,  // 10: plandex.context.v1.ContextService.ListContext:input_type -> plandex.context.v1.ListContextRequest
+	5,  // 11: plandex.context.v1.ContextService.LoadContext:input_type -> plandex.context.v1.LoadContextRequest
+	9,  // 12: plandex.context.v1.ContextService.UpdateContext:input_type -> plandex.context.v1.UpdateContextRequest
+	10, // 13: plandex.context.v1.ContextService.DeleteContext:input_type -> plandex.context.v1.DeleteContextRequest
+	3,  // 14: plandex.context.v1.ContextService.ListContext:output_type -> plandex.context.v1.ListContextResponse
+	6,  // 15: plandex.context.v1.ContextService.LoadContext:output_type -> plandex.context.v1.LoadContextResponse
+	6,  // 16: plandex.context.v1.ContextService.UpdateContext:output_type -> plandex.context.v1.LoadContextResponse
+	11, // 17: plandex.context.v1.ContextService.DeleteContext:output_type -> plandex.context.v1.DeleteContextResponse
+	14, // [14:18] is the sub-list for method output_type
+	10, // [10:14] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_context_proto_init() }
+func file_context_proto_init() {
+	if File_context_proto != nil {
+		return
+	}
+	file_context_proto_msgTypes[7].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_context_proto_rawDesc), len(file_context_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_context_proto_goTypes,
+		DependencyIndexes: file_context_proto_depIdxs,
+		EnumInfos:         file_context_proto_enumTypes,
+		MessageInfos:      file_context_proto_msgTypes,
+	}.Build()
+	File_context_proto = out.File
+	file_context_proto_goTypes = nil
+	file_context_proto_depIdxs = nil
+}
@@ -0,0 +1,234 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: context.proto
+
+package contextpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	ContextService_ListContext_FullMethodName   = "/plandex.context.v1.ContextService/ListContext"
+	ContextService_LoadContext_FullMethodName   = "/plandex.context.v1.ContextService/LoadContext"
+	ContextService_UpdateContext_FullMethodName = "/plandex.context.v1.ContextService/UpdateContext"
+	ContextService_DeleteContext_FullMethodName = "/plandex.context.v1.ContextService/DeleteContext"
+)
+
+// ContextServiceClient is the client API for ContextService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ContextService mirrors the REST context endpoints under /plans/{planId}/{branch}/context. It's implemented in
+// handlers/grpc_context.go on the same db layer functions as the HTTP handlers (GetPlanContexts, LoadContexts,
+// UpdateContexts, ContextRemove) under the same repo locks, so both surfaces behave the same.
+// Auth uses the same bearer token as the REST api, passed in the "authorization" metadata key.
+type ContextServiceClient interface {
+	ListContext(ctx context.Context, in *ListContextRequest, opts ...grpc.CallOption) (*ListContextResponse, error)
+	LoadContext(ctx context.Context, in *LoadContextRequest, opts ...grpc.CallOption) (*LoadContextResponse, error)
+	UpdateContext(ctx context.Context, in *UpdateContextRequest, opts ...grpc.CallOption) (*LoadContextResponse, error)
+	DeleteContext(ctx context.Context, in *DeleteContextRequest, opts ...grpc.CallOption) (*DeleteContextResponse, error)
+}
+
+type contextServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewContextServiceClient(cc grpc.ClientConnInterface) ContextServiceClient {
+	return &contextServiceClient{cc}
+}
+
+func (c *contextServiceClient) ListContext(ctx context.Context, in *ListContextRequest, opts ...grpc.CallOption) (*ListContextResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListContextResponse)
+	err := c.cc.Invoke(ctx, ContextService_ListContext_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contextServiceClient) LoadContext(ctx context.Context, in *LoadContextRequest, opts ...grpc.CallOption) (*LoadContextResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoadContextResponse)
+	err := c.cc.Invoke(ctx, ContextService_LoadContext_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contextServiceClient) UpdateContext(ctx context.Context, in *UpdateContextRequest, opts ...grpc.CallOption) (*LoadContextResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoadContextResponse)
+	err := c.cc.Invoke(ctx, ContextService_UpdateContext_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contextServiceClient) DeleteContext(ctx context.Context, in *DeleteContextRequest, opts ...grpc.CallOption) (*DeleteContextResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteContextResponse)
+	err := c.cc.Invoke(ctx, ContextService_DeleteContext_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ContextServiceServer is the server API for ContextService service.
+// All implementations must embed UnimplementedContextServiceServer
+// for forward compatibility
+//
+// ContextService mirrors the REST context endpoints under /plans/{planId}/{branch}/context. It's implemented in
+// handlers/grpc_context.go on the same db layer functions as the HTTP handlers (GetPlanContexts, LoadContexts,
+// UpdateContexts, ContextRemove) under the same repo locks, so both surfaces behave the same.
+// Auth uses the same bearer token as the REST api, passed in the "authorization" metadata key.
+type ContextServiceServer interface {
+	ListContext(context.Context, *ListContextRequest) (*ListContextResponse, error)
+	LoadContext(context.Context, *LoadContextRequest) (*LoadContextResponse, error)
+	UpdateContext(context.Context, *UpdateContextRequest) (*LoadContextResponse, error)
+	DeleteContext(context.Context, *DeleteContextRequest) (*DeleteContextResponse, error)
+	mustEmbedUnimplementedContextServiceServer()
+}
+
+// UnimplementedContextServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedContextServiceServer struct {
+}
+
+func (UnimplementedContextServiceServer) ListContext(context.Context, *ListContextRequest) (*ListContextResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListContext not implemented")
+}
+func (UnimplementedContextServiceServer) LoadContext(context.Context, *LoadContextRequest) (*LoadContextResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LoadContext not implemented")
+}
+func (UnimplementedContextServiceServer) UpdateContext(context.Context, *UpdateContextRequest) (*LoadContextResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateContext not implemented")
+}
+func (UnimplementedContextServiceServer) DeleteContext(context.Context, *DeleteContextRequest) (*DeleteContextResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteContext not implemented")
+}
+func (UnimplementedContextServiceServer) mustEmbedUnimplementedContextServiceServer() {}
+
+// UnsafeContextServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ContextServiceServer will
+// result in compilation errors.
+type UnsafeContextServiceServer interface {
+	mustEmbedUnimplementedContextServiceServer()
+}
+
+func RegisterContextServiceServer(s grpc.ServiceRegistrar, srv ContextServiceServer) {
+	s.RegisterService(&ContextService_ServiceDesc, srv)
+}
+
+func _ContextService_ListContext_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListContextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContextServiceServer).ListContext(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContextService_ListContext_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContextServiceServer).ListContext(ctx, req.(*ListContextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContextService_LoadContext_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoadContextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContextServiceServer).LoadContext(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContextService_LoadContext_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContextServiceServer).LoadContext(ctx, req.(*LoadContextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContextService_UpdateContext_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateContextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContextServiceServer).UpdateContext(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContextService_UpdateContext_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContextServiceServer).UpdateContext(ctx, req.(*UpdateContextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContextService_DeleteContext_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteContextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContextServiceServer).DeleteContext(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContextService_DeleteContext_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContextServiceServer).DeleteContext(ctx, req.(*DeleteContextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ContextService_ServiceDesc is the grpc.ServiceDesc for ContextService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ContextService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "plandex.context.v1.ContextService",
+	HandlerType: (*ContextServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListContext",
+			Handler:    _ContextService_ListContext_Handler,
+		},
+		{
+			MethodName: "LoadContext",
+			Handler:    _ContextService_LoadContext_Handler,
+		},
+		{
+			MethodName: "UpdateContext",
+			Handler:    _ContextService_UpdateContext_Handler,
+		},
+		{
+			MethodName: "DeleteContext",
+			Handler:    _ContextService_DeleteContext_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "context.proto",
+}
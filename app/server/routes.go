@@ -11,6 +11,8 @@ import (
 
 func routes() *mux.Router {
 	r := mux.NewRouter()
+	r.Use(handlers.RequestIdMiddleware)
+	r.Use(handlers.GzipContextMiddleware)
 
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "OK")
@@ -42,6 +44,11 @@ func routes() *mux.Router {
 	r.HandleFunc("/users", handlers.ListUsersHandler).Methods("GET")
 	r.HandleFunc("/orgs/users/{userId}", handlers.DeleteOrgUserHandler).Methods("DELETE")
 	r.HandleFunc("/orgs/roles", handlers.ListOrgRolesHandler).Methods("GET")
+	r.HandleFunc("/orgs/default_ignore", handlers.GetOrgDefaultIgnoreHandler).Methods("GET")
+	r.HandleFunc("/orgs/default_ignore", handlers.UpdateOrgDefaultIgnoreHandler).Methods("PUT")
+	r.HandleFunc("/orgs/max_contexts_per_plan", handlers.GetOrgMaxContextsPerPlanHandler).Methods("GET")
+	r.HandleFunc("/orgs/max_contexts_per_plan", handlers.UpdateOrgMaxContextsPerPlanHandler).Methods("PUT")
+	r.HandleFunc("/orgs/contexts/largest", handlers.ListOrgLargestContextsHandler).Methods("GET")
 
 	r.HandleFunc("/invites", handlers.InviteUserHandler).Methods("POST")
 	r.HandleFunc("/invites/pending", handlers.ListPendingInvitesHandler).Methods("GET")
@@ -66,6 +73,8 @@ func routes() *mux.Router {
 
 	r.HandleFunc("/plans/{planId}", handlers.GetPlanHandler).Methods("GET")
 	r.HandleFunc("/plans/{planId}", handlers.DeletePlanHandler).Methods("DELETE")
+	r.HandleFunc("/plans/{planId}/lock", handlers.GetPlanLockStatusHandler).Methods("GET")
+	r.HandleFunc("/plans/{planId}/default_branch", handlers.UpdatePlanDefaultBranchHandler).Methods("PUT")
 
 	r.HandleFunc("/plans/{planId}/{branch}/tell", handlers.TellPlanHandler).Methods("POST")
 
@@ -81,10 +90,7 @@ func routes() *mux.Router {
 	r.HandleFunc("/plans/{planId}/{branch}/reject_all", handlers.RejectAllChangesHandler).Methods("PATCH")
 	r.HandleFunc("/plans/{planId}/{branch}/reject_file", handlers.RejectFileHandler).Methods("PATCH")
 
-	r.HandleFunc("/plans/{planId}/{branch}/context", handlers.ListContextHandler).Methods("GET")
-	r.HandleFunc("/plans/{planId}/{branch}/context", handlers.LoadContextHandler).Methods("POST")
-	r.HandleFunc("/plans/{planId}/{branch}/context", handlers.UpdateContextHandler).Methods("PUT")
-	r.HandleFunc("/plans/{planId}/{branch}/context", handlers.DeleteContextHandler).Methods("DELETE")
+	contextRoutes(r, handlers.ContextRoutePrefix)
 
 	r.HandleFunc("/plans/{planId}/{branch}/convo", handlers.ListConvoHandler).Methods("GET")
 	r.HandleFunc("/plans/{planId}/{branch}/rewind", handlers.RewindPlanHandler).Methods("PATCH")
@@ -97,6 +103,36 @@ func routes() *mux.Router {
 	r.HandleFunc("/plans/{planId}/{branch}/settings", handlers.GetSettingsHandler).Methods("GET")
 	r.HandleFunc("/plans/{planId}/{branch}/settings", handlers.UpdateSettingsHandler).Methods("PUT")
 
+	// the same context routes on the plan's default branch. Registered last so they can't shadow a route on a branch
+	// named "context".
+	contextRoutes(r, handlers.DefaultBranchContextRoutePrefix)
+
 	return r
 
 }
+
+func contextRoutes(r *mux.Router, prefix string) {
+	r.HandleFunc(prefix, handlers.ListContextHandler).Methods("GET")
+	r.HandleFunc(prefix, handlers.LoadContextHandler).Methods("POST")
+	r.HandleFunc(prefix, handlers.UpdateContextHandler).Methods("PUT")
+	r.HandleFunc(prefix, handlers.DeleteContextHandler).Methods("DELETE")
+	r.HandleFunc(prefix+"/tags", handlers.TagContextsHandler).Methods("PATCH")
+	r.HandleFunc(prefix+"/paths", handlers.MoveContextHandler).Methods("PATCH")
+	r.HandleFunc(prefix+"/replace", handlers.ReplaceContextHandler).Methods("PUT")
+	r.HandleFunc(prefix+"/trees", handlers.RefreshTreeContextHandler).Methods("PUT")
+	r.HandleFunc(prefix+"/urls", handlers.RefreshUrlContextHandler).Methods("PUT")
+	r.HandleFunc(prefix+"/preview_update", handlers.PreviewUpdateContextHandler).Methods("POST")
+	r.HandleFunc(prefix+"/preview_url", handlers.PreviewUrlContextHandler).Methods("POST")
+	r.HandleFunc(prefix+"/allowance", handlers.ContextAllowanceHandler).Methods("POST")
+	r.HandleFunc(prefix+"/tokens", handlers.ContextTokensHandler).Methods("POST")
+	r.HandleFunc(prefix+"/exists", handlers.ContextExistsHandler).Methods("POST")
+	r.HandleFunc(prefix+"/recompute", handlers.RecomputeContextTokensHandler).Methods("POST")
+	r.HandleFunc(prefix+"/tarball", handlers.LoadContextTarballHandler).Methods("POST")
+	r.HandleFunc(prefix+"/prune", handlers.PruneContextHandler).Methods("POST")
+	r.HandleFunc(prefix+"/export", handlers.ExportContextHandler).Methods("GET")
+	r.HandleFunc(prefix+"/staged", handlers.GetStagedContextHandler).Methods("GET")
+	r.HandleFunc(prefix+"/staged", handlers.DiscardStagedContextHandler).Methods("DELETE")
+	r.HandleFunc(prefix+"/staged/commit", handlers.CommitStagedContextHandler).Methods("POST")
+	// after the fixed context routes so it doesn't shadow them. contextRef is an id or an alias like '#3', url-encoded as '%233'.
+	r.HandleFunc(prefix+"/{contextRef}", handlers.GetContextHandler).Methods("GET")
+}
@@ -17,12 +17,12 @@ type ActiveBuild struct {
 	ReplyId           string
 	FileDescription   string
 	FileContent       string
-	FileContentTokens int
-	CurrentFileTokens int
+	FileContentTokens int64
+	CurrentFileTokens int64
 	Path              string
 	Idx               int
 	Buffer            string
-	BufferTokens      int
+	BufferTokens      int64
 	Success           bool
 	Error             error
 }
@@ -55,7 +55,7 @@ type ActivePlan struct {
 	BuiltFiles              map[string]bool
 	IsBuildingByPath        map[string]bool
 	CurrentReplyContent     string
-	NumTokens               int
+	NumTokens               int64
 	MessageNum              int
 	BuildQueuesByPath       map[string][]*ActiveBuild
 	RepliesFinished         bool
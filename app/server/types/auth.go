@@ -46,4 +46,6 @@ const (
 	PermissionDeleteAnyPlan         Permission = "delete_any_plan"
 	PermissionUpdateAnyPlan         Permission = "update_any_plan"
 	PermissionArchiveAnyPlan        Permission = "archive_any_plan"
+	PermissionManageOrgSettings     Permission = "manage_org_settings"
+	PermissionViewOrgUsage          Permission = "view_org_usage"
 )
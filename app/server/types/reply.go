@@ -10,8 +10,8 @@ type parserRes struct {
 	FileContents       []string
 	FileDescriptions   []string
 	RepliesBeforeFiles []string
-	NumTokensByFile    map[string]int
-	TotalTokens        int
+	NumTokensByFile    map[string]int64
+	TotalTokens        int64
 }
 
 type ReplyParser struct {
@@ -26,8 +26,8 @@ type ReplyParser struct {
 	fileDescriptions          []string
 	currentDescriptionLines   []string
 	currentDescriptionLineIdx int
-	numTokens                 int
-	numTokensByFile           map[string]int
+	numTokens                 int64
+	numTokensByFile           map[string]int64
 }
 
 func NewReplyParser() *ReplyParser {
@@ -38,7 +38,7 @@ func NewReplyParser() *ReplyParser {
 		fileContents:            []string{},
 		currentDescriptionLines: []string{""},
 		fileDescriptions:        []string{},
-		numTokensByFile:         make(map[string]int),
+		numTokensByFile:         make(map[string]int64),
 	}
 
 	return info
@@ -8,7 +8,7 @@ import (
 
 type TestExample struct {
 	N                int
-	TokensByFilePath map[string]int
+	TokensByFilePath map[string]int64
 }
 
 // These aren't the real number of tokens
@@ -16,41 +16,41 @@ type TestExample struct {
 var examples = []TestExample{
 	{
 		N: 1,
-		TokensByFilePath: map[string]int{
+		TokensByFilePath: map[string]int64{
 			"cmd/checkout.go": 54,
 			"cmd/apply.go":    180,
 		},
 	},
 	{
 		N: 2,
-		TokensByFilePath: map[string]int{
+		TokensByFilePath: map[string]int64{
 			"cmd/context_rm.go":     210,
 			"cmd/context_update.go": 188,
 		},
 	},
 	{
 		N: 3,
-		TokensByFilePath: map[string]int{
+		TokensByFilePath: map[string]int64{
 			"cmd/context_rm.go":     210,
 			"cmd/context_update.go": 188,
 		},
 	},
 	{
 		N: 4,
-		TokensByFilePath: map[string]int{
+		TokensByFilePath: map[string]int64{
 			"server/types/section.go": 32,
 		},
 	},
 	{
 		N: 5,
-		TokensByFilePath: map[string]int{
+		TokensByFilePath: map[string]int64{
 			"shared/types.go":         20,
 			"cli/lib/conversation.go": 58,
 		},
 	},
 	{
 		N: 6,
-		TokensByFilePath: map[string]int{
+		TokensByFilePath: map[string]int64{
 			"server/model/proposal/create.go": 239,
 		},
 	},
@@ -73,7 +73,7 @@ func TestReplyTokenCounter(t *testing.T) {
 
 		counter := NewReplyParser()
 
-		totalTokens := 0
+		var totalTokens int64
 		for i := 0; i < len(content); {
 			end := i + tokenSize
 			if end > len(content) {
@@ -15,6 +15,9 @@ const (
 
 	ApiErrorTypeContinueNoMessages ApiErrorType = "continue_no_messages"
 
+	ApiErrorTypeContextNotFound ApiErrorType = "context_not_found"
+	ApiErrorTypeContextConflict ApiErrorType = "context_conflict"
+
 	ApiErrorTypeOther ApiErrorType = "other"
 )
 
@@ -26,6 +29,15 @@ type TrialMessagesExceededError struct {
 	MaxReplies int `json:"maxMessages"`
 }
 
+type ContextNotFoundError struct {
+	ContextIds []string `json:"contextIds"`
+}
+
+type ContextConflictError struct {
+	// the stored sha of each context whose ExpectedSha didn't match
+	ShasById map[string]string `json:"shasById"`
+}
+
 type ApiError struct {
 	Type   ApiErrorType `json:"type"`
 	Status int          `json:"status"`
@@ -36,4 +48,10 @@ type ApiError struct {
 
 	// only used for trial messages exceeded error
 	TrialMessagesExceededError *TrialMessagesExceededError `json:"trialMessagesExceededError,omitempty"`
+
+	// only used for context not found error
+	ContextNotFoundError *ContextNotFoundError `json:"contextNotFoundError,omitempty"`
+
+	// only used for context conflict error
+	ContextConflictError *ContextConflictError `json:"contextConflictError,omitempty"`
 }
@@ -2,22 +2,229 @@ package shared
 
 import (
 	"fmt"
-	"math"
 	"strconv"
 	"strings"
 
 	"github.com/olekukonko/tablewriter"
 )
 
+// ContextChangeKind is how a context changed in an update
+type ContextChangeKind string
+
+const (
+	ContextChangeAdded   ContextChangeKind = "added"
+	ContextChangeUpdated ContextChangeKind = "updated"
+	ContextChangeRemoved ContextChangeKind = "removed"
+)
+
+// ContextChangeCounts counts the contexts of each type with one kind of change
+type ContextChangeCounts struct {
+	NumFiles int
+	NumUrls  int
+	NumTrees int
+	NumNotes int
+	NumPiped int
+	NumMaps  int
+}
+
+func (c *ContextChangeCounts) Add(contextType ContextType) {
+	switch contextType {
+	case ContextFileType:
+		c.NumFiles++
+	case ContextURLType:
+		c.NumUrls++
+	case ContextDirectoryTreeType:
+		c.NumTrees++
+	case ContextNoteType:
+		c.NumNotes++
+	case ContextPipedDataType:
+		c.NumPiped++
+	case ContextMapType:
+		c.NumMaps++
+	}
+}
+
+// labels describes the counts, like "2 files" and "a note", leaving out types with none
+func (c ContextChangeCounts) labels() []string {
+	var labels []string
+	add := func(n int, singular, plural string) {
+		if n == 1 {
+			labels = append(labels, "1 "+singular)
+		} else if n > 1 {
+			labels = append(labels, fmt.Sprintf("%d %s", n, plural))
+		}
+	}
+	add(c.NumFiles, "file", "files")
+	add(c.NumTrees, "tree", "trees")
+	add(c.NumUrls, "url", "urls")
+	add(c.NumNotes, "note", "notes")
+	add(c.NumPiped, "piped data context", "piped data contexts")
+	add(c.NumMaps, "map", "maps")
+	return labels
+}
+
 type ContextUpdateResult struct {
 	UpdatedContexts []*Context
-	TokenDiffsById  map[string]int
-	TokensDiff      int
-	TotalTokens     int
-	NumFiles        int
-	NumUrls         int
-	NumTrees        int
-	MaxTokens       int
+	TokenDiffsById  map[string]int64
+	TokensDiff      int64
+	TotalTokens     int64
+	// how each context in UpdatedContexts changed. Contexts with no entry were updated in place.
+	KindsById map[string]ContextChangeKind
+	Added     ContextChangeCounts
+	Updated   ContextChangeCounts
+	Removed   ContextChangeCounts
+	MaxTokens int64
+	// errors for contexts that weren't updated, when a batch is applied partially
+	FailedById map[string]string
+}
+
+// AddChange records a change to a context, counting it under its kind
+func (r *ContextUpdateResult) AddChange(context *Context, kind ContextChangeKind, tokenDiff int64) {
+	r.UpdatedContexts = append(r.UpdatedContexts, context)
+
+	if r.TokenDiffsById == nil {
+		r.TokenDiffsById = map[string]int64{}
+	}
+	r.TokenDiffsById[context.Id] = tokenDiff
+
+	if r.KindsById == nil {
+		r.KindsById = map[string]ContextChangeKind{}
+	}
+	r.KindsById[context.Id] = kind
+
+	switch kind {
+	case ContextChangeAdded:
+		r.Added.Add(context.ContextType)
+	case ContextChangeRemoved:
+		r.Removed.Add(context.ContextType)
+	default:
+		r.Updated.Add(context.ContextType)
+	}
+}
+
+func (r *ContextUpdateResult) kindFor(id string) ContextChangeKind {
+	if kind, ok := r.KindsById[id]; ok {
+		return kind
+	}
+	return ContextChangeUpdated
+}
+
+// ContextAliasRef formats a context's alias the way it's referred to, e.g. '#3'
+func ContextAliasRef(alias int) string {
+	return "#" + strconv.Itoa(alias)
+}
+
+// ParseContextAlias parses an alias ref like '#3'
+func ParseContextAlias(ref string) (int, bool) {
+	if !strings.HasPrefix(ref, "#") {
+		return 0, false
+	}
+	alias, err := strconv.Atoi(ref[1:])
+	if err != nil || alias < 1 {
+		return 0, false
+	}
+	return alias, true
+}
+
+// IsEmptyContextBody reports whether a body is empty or only whitespace, so a context with it adds nothing
+func IsEmptyContextBody(body string) bool {
+	return strings.TrimSpace(body) == ""
+}
+
+// IsExecutableMode reports whether any of the executable bits are set in a file mode captured at load time
+func IsExecutableMode(mode uint32) bool {
+	return mode&0111 != 0
+}
+
+func (c *Context) IsExecutable() bool {
+	return IsExecutableMode(c.FileMode)
+}
+
+// NormalizeLineEndings converts CRLF and lone CR line endings to LF
+func NormalizeLineEndings(body string) string {
+	if !strings.Contains(body, "\r") {
+		return body
+	}
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	return strings.ReplaceAll(body, "\r", "\n")
+}
+
+// StripBom removes a leading utf-8 byte order mark
+func StripBom(body string) string {
+	return strings.TrimPrefix(body, "\uFEFF")
+}
+
+// ApplyTrailingNewline ends a body with exactly one newline (TrailingNewlineSingle) or none (TrailingNewlineNone). Any other policy leaves it as-is.
+func ApplyTrailingNewline(body string, policy TrailingNewlinePolicy) string {
+	switch policy {
+	case TrailingNewlineSingle:
+		return strings.TrimRight(body, "\r\n") + "\n"
+	case TrailingNewlineNone:
+		return strings.TrimRight(body, "\r\n")
+	}
+	return body
+}
+
+// LinkPath is the path HasLinkMarkup checks the extension of: the file path, or the url for contexts without one
+func (c *Context) LinkPath() string {
+	if c.FilePath != "" {
+		return c.FilePath
+	}
+	return c.Url
+}
+
+// StoredBody reapplies the transformations recorded on a context at load time to a freshly read body, so it matches what the server stores
+func (c *Context) StoredBody(body string) string {
+	if c.ContextType == ContextFileType && c.BomStripped {
+		body = StripBom(body)
+	}
+
+	if c.LineEndingsNormalized {
+		body = NormalizeLineEndings(body)
+	}
+
+	if c.ContextType == ContextFileType && c.Deminified && IsMinified(body) {
+		body, _ = Deminify(c.FilePath, body)
+	}
+
+	if c.ContextType == ContextFileType && c.StripComments {
+		body = StripComments(c.FilePath, body, c.PreserveDocstrings)
+	}
+
+	if c.ResolveRelativeUrls && c.BaseUrl != "" {
+		body = ResolveRelativeUrls(c.LinkPath(), c.ContentType, body, c.BaseUrl)
+	}
+
+	if c.ContextType == ContextFileType {
+		body = ApplyTrailingNewline(body, c.TrailingNewline)
+	}
+
+	return body
+}
+
+var contextTypesByShortName = map[string]ContextType{
+	"file":  ContextFileType,
+	"url":   ContextURLType,
+	"tree":  ContextDirectoryTreeType,
+	"note":  ContextNoteType,
+	"piped": ContextPipedDataType,
+	"map":   ContextMapType,
+}
+
+// ContextTypeNames lists the short type names ParseContextTypeName accepts, for error messages
+const ContextTypeNames = "file, url, tree, note, piped, map"
+
+// ParseContextTypeName accepts a short type name as shown by TypeAndIcon (file, url, tree, note, piped, map) or a full ContextType value
+func ParseContextTypeName(name string) (ContextType, bool) {
+	if contextType, ok := contextTypesByShortName[name]; ok {
+		return contextType, true
+	}
+	for _, contextType := range contextTypesByShortName {
+		if string(contextType) == name {
+			return contextType, true
+		}
+	}
+	return "", false
 }
 
 func (c *Context) TypeAndIcon() (string, string) {
@@ -39,6 +246,9 @@ func (c *Context) TypeAndIcon() (string, string) {
 	case ContextPipedDataType:
 		icon = "↔️ "
 		t = "piped"
+	case ContextMapType:
+		icon = "🗺️ "
+		t = "map"
 	}
 
 	return t, icon
@@ -55,7 +265,7 @@ func TableForLoadContext(contexts []*Context) string {
 		row := []string{
 			" " + icon + " " + context.Name,
 			t,
-			"+" + strconv.Itoa(context.NumTokens),
+			"+" + strconv.FormatInt(context.NumTokens, 10),
 		}
 
 		table.Rich(row, []tablewriter.Colors{
@@ -70,14 +280,15 @@ func TableForLoadContext(contexts []*Context) string {
 	return tableString.String()
 }
 
-func SummaryForLoadContext(contexts []*Context, tokensAdded, totalTokens int) string {
+func SummaryForLoadContext(contexts []*Context, tokensAdded, totalTokens int64) string {
 
-	var hasNote bool
 	var hasPiped bool
 
 	var numFiles int
 	var numTrees int
 	var numUrls int
+	var numNotes int
+	var numMaps int
 
 	for _, context := range contexts {
 		switch context.ContextType {
@@ -88,16 +299,20 @@ func SummaryForLoadContext(contexts []*Context, tokensAdded, totalTokens int) st
 		case ContextDirectoryTreeType:
 			numTrees++
 		case ContextNoteType:
-			hasNote = true
+			numNotes++
 		case ContextPipedDataType:
 			hasPiped = true
+		case ContextMapType:
+			numMaps++
 		}
 	}
 
 	var added []string
 
-	if hasNote {
+	if numNotes == 1 {
 		added = append(added, "a note")
+	} else if numNotes > 1 {
+		added = append(added, fmt.Sprintf("%d notes", numNotes))
 	}
 	if hasPiped {
 		added = append(added, "piped data")
@@ -123,6 +338,13 @@ func SummaryForLoadContext(contexts []*Context, tokensAdded, totalTokens int) st
 		}
 		added = append(added, fmt.Sprintf("%d %s", numUrls, label))
 	}
+	if numMaps > 0 {
+		label := "map"
+		if numMaps > 1 {
+			label = "maps"
+		}
+		added = append(added, fmt.Sprintf("%d %s", numMaps, label))
+	}
 
 	msg := "Loaded "
 
@@ -154,7 +376,7 @@ func TableForRemoveContext(contexts []*Context) string {
 		row := []string{
 			" " + icon + " " + context.Name,
 			t,
-			"-" + strconv.Itoa(context.NumTokens),
+			"-" + strconv.FormatInt(context.NumTokens, 10),
 		}
 
 		table.Rich(row, []tablewriter.Colors{
@@ -169,8 +391,8 @@ func TableForRemoveContext(contexts []*Context) string {
 	return tableString.String()
 }
 
-func SummaryForRemoveContext(contexts []*Context, previousTotalTokens int) string {
-	removedTokens := 0
+func SummaryForRemoveContext(contexts []*Context, previousTotalTokens int64) string {
+	var removedTokens int64
 
 	for _, context := range contexts {
 		removedTokens += context.NumTokens
@@ -186,48 +408,89 @@ func SummaryForRemoveContext(contexts []*Context, previousTotalTokens int) strin
 	return fmt.Sprintf("Removed %d piece%s of context | removed → %d 🪙 | total → %d 🪙", len(contexts), suffix, removedTokens, totalTokens)
 }
 
+func SummaryForTagContexts(contexts []*Context, tag string, removed bool) string {
+	suffix := ""
+	if len(contexts) != 1 {
+		suffix = "s"
+	}
+
+	if removed {
+		return fmt.Sprintf("Removed tag '%s' from %d piece%s of context", tag, len(contexts), suffix)
+	}
+
+	return fmt.Sprintf("Tagged %d piece%s of context with '%s'", len(contexts), suffix, tag)
+}
+
+func SummaryForPinContexts(numPinned, numUnpinned int) string {
+	var parts []string
+	if numPinned > 0 {
+		parts = append(parts, fmt.Sprintf("pinned → %d", numPinned))
+	}
+	if numUnpinned > 0 {
+		parts = append(parts, fmt.Sprintf("unpinned → %d", numUnpinned))
+	}
+	return "📌 Updated pins | " + strings.Join(parts, " | ")
+}
+
+func SummaryForReplaceContext(res *ReplaceContextResponse) string {
+	action := "added"
+	if res.TokensAdded < 0 {
+		action = "removed"
+	}
+	absTokenDiff := absTokens(res.TokensAdded)
+
+	return fmt.Sprintf("Replaced context | added → %d | updated → %d | removed → %d | %s → %d 🪙 | total → %d 🪙", res.NumAdded, res.NumUpdated, res.NumRemoved, action, absTokenDiff, res.TotalTokens)
+}
+
+// SummaryForMoveContexts describes moved contexts, given the path each one was moved from by id
+func SummaryForMoveContexts(contexts []*Context, fromPathsById map[string]string) string {
+	suffix := ""
+	if len(contexts) != 1 {
+		suffix = "s"
+	}
+
+	msg := fmt.Sprintf("Moved %d piece%s of context", len(contexts), suffix)
+	for _, context := range contexts {
+		msg += fmt.Sprintf("\n%s → %s", fromPathsById[context.Id], context.FilePath)
+	}
+
+	return msg
+}
+
+func SummaryForStagedContext(staged *StagedContextChanges) string {
+	if len(staged.Load) == 0 && len(staged.Update) == 0 && len(staged.Delete) == 0 {
+		return "No staged context changes"
+	}
+
+	return fmt.Sprintf("Staged context changes | load → %d | update → %d | remove → %d", len(staged.Load), len(staged.Update), len(staged.Delete))
+}
+
+// SummaryForUpdateContext describes an update by kind of change, like "Updated 3 files and added 2 urls in context"
 func SummaryForUpdateContext(updateRes *ContextUpdateResult) string {
-	numFiles := updateRes.NumFiles
-	numTrees := updateRes.NumTrees
-	numUrls := updateRes.NumUrls
 	tokensDiff := updateRes.TokensDiff
 	totalTokens := updateRes.TotalTokens
 
-	msg := "Updated"
-	var toAdd []string
-	if numFiles > 0 {
-		postfix := "s"
-		if numFiles == 1 {
-			postfix = ""
+	var parts []string
+	for _, kind := range []struct {
+		verb   string
+		counts ContextChangeCounts
+	}{
+		{"updated", updateRes.Updated},
+		{"added", updateRes.Added},
+		{"removed", updateRes.Removed},
+	} {
+		labels := kind.counts.labels()
+		if len(labels) == 0 {
+			continue
 		}
-		toAdd = append(toAdd, fmt.Sprintf("%d file%s", numFiles, postfix))
-	}
-	if numTrees > 0 {
-		postfix := "s"
-		if numTrees == 1 {
-			postfix = ""
-		}
-		toAdd = append(toAdd, fmt.Sprintf("%d tree%s", numTrees, postfix))
-	}
-	if numUrls > 0 {
-		postfix := "s"
-		if numUrls == 1 {
-			postfix = ""
-		}
-		toAdd = append(toAdd, fmt.Sprintf("%d url%s", numUrls, postfix))
+		parts = append(parts, kind.verb+" "+joinLabels(labels, " and "))
 	}
 
-	if len(toAdd) <= 2 {
-		msg += " " + strings.Join(toAdd, " and ")
-	} else {
-		for i, add := range toAdd {
-			if i == len(toAdd)-1 {
-				msg += ", and " + add
-			} else {
-				msg += ", " + add
-			}
-		}
+	msg := joinLabels(parts, ", and ")
+	if msg == "" {
+		msg = "updated"
 	}
+	msg = strings.ToUpper(msg[:1]) + msg[1:]
 
 	msg += " in context"
 
@@ -235,12 +498,28 @@ func SummaryForUpdateContext(updateRes *ContextUpdateResult) string {
 	if tokensDiff < 0 {
 		action = "removed"
 	}
-	absTokenDiff := int(math.Abs(float64(tokensDiff)))
+	absTokenDiff := absTokens(tokensDiff)
 	msg += fmt.Sprintf(" | %s → %d 🪙 | total → %d 🪙", action, absTokenDiff, totalTokens)
 
 	return msg
 }
 
+// absTokens is the size of a token diff, which math.Abs can't take exactly for large values since it works on float64
+func absTokens(diff int64) int64 {
+	if diff < 0 {
+		return -diff
+	}
+	return diff
+}
+
+// joinLabels joins two labels with sep, or more as a comma-separated list ending in ", and"
+func joinLabels(labels []string, sep string) string {
+	if len(labels) <= 2 {
+		return strings.Join(labels, sep)
+	}
+	return strings.Join(labels[:len(labels)-1], ", ") + ", and " + labels[len(labels)-1]
+}
+
 func TableForContextUpdate(updateRes *ContextUpdateResult) string {
 	contexts := updateRes.UpdatedContexts
 	tokenDiffsById := updateRes.TokenDiffsById
@@ -249,20 +528,34 @@ func TableForContextUpdate(updateRes *ContextUpdateResult) string {
 		return ""
 	}
 
+	// the change column is only needed when the update isn't all in-place updates
+	showKind := false
+	for _, context := range contexts {
+		if updateRes.kindFor(context.Id) != ContextChangeUpdated {
+			showKind = true
+			break
+		}
+	}
+
 	tableString := &strings.Builder{}
 	table := tablewriter.NewWriter(tableString)
-	table.SetHeader([]string{"Name", "Type", "🪙"})
+	header := []string{"Name", "Type", "🪙"}
+	if showKind {
+		header = append(header, "Change")
+	}
+	table.SetHeader(header)
 	table.SetAutoWrapText(false)
 
 	for _, context := range contexts {
 		t, icon := context.TypeAndIcon()
 		diff := tokenDiffsById[context.Id]
+		kind := updateRes.kindFor(context.Id)
 
-		diffStr := "+" + strconv.Itoa(diff)
+		diffStr := "+" + strconv.FormatInt(diff, 10)
 		tableColor := tablewriter.FgHiGreenColor
 
-		if diff < 0 {
-			diffStr = strconv.Itoa(diff)
+		if diff < 0 || kind == ContextChangeRemoved {
+			diffStr = strconv.FormatInt(diff, 10)
 			tableColor = tablewriter.FgHiRedColor
 		}
 
@@ -271,12 +564,17 @@ func TableForContextUpdate(updateRes *ContextUpdateResult) string {
 			t,
 			diffStr,
 		}
-
-		table.Rich(row, []tablewriter.Colors{
+		colors := []tablewriter.Colors{
 			{tableColor, tablewriter.Bold},
 			{tableColor},
 			{tableColor},
-		})
+		}
+		if showKind {
+			row = append(row, string(kind))
+			colors = append(colors, tablewriter.Colors{tableColor})
+		}
+
+		table.Rich(row, colors)
 	}
 
 	table.Render()
@@ -0,0 +1,62 @@
+package shared
+
+import "strings"
+
+// InferContextType returns a load entry's context type. An explicit ContextType is always used as-is.
+// Otherwise it's inferred from the entry's source, which is Url, then FilePath, then Name:
+//   - an http(s):// source is a url
+//   - a source ending in a path separator, or '.' or '..', is a directory tree
+//   - any other source is a file
+//   - an entry with no source but a body is a note
+func InferContextType(params *LoadContextParams) ContextType {
+	if params.ContextType != "" {
+		return params.ContextType
+	}
+
+	if params.TreePaths != nil {
+		return ContextDirectoryTreeType
+	}
+
+	source := contextSource(params)
+
+	switch {
+	case params.Url != "" || isHttpUrl(source):
+		return ContextURLType
+	case source == "":
+		if params.Body != "" {
+			return ContextNoteType
+		}
+		return ""
+	case isDirectoryPath(source):
+		return ContextDirectoryTreeType
+	}
+
+	return ContextFileType
+}
+
+func contextSource(params *LoadContextParams) string {
+	if params.Url != "" {
+		return params.Url
+	}
+	if params.FilePath != "" {
+		return params.FilePath
+	}
+	return strings.TrimSpace(params.Name)
+}
+
+func isHttpUrl(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func isDirectoryPath(s string) bool {
+	if strings.HasSuffix(s, "/") || strings.HasSuffix(s, "\\") {
+		return true
+	}
+
+	last := s
+	if i := strings.LastIndexAny(s, "/\\"); i >= 0 {
+		last = s[i+1:]
+	}
+	return last == "." || last == ".."
+}
@@ -0,0 +1,36 @@
+package shared
+
+import "testing"
+
+func TestInferContextType(t *testing.T) {
+	tests := []struct {
+		name   string
+		params LoadContextParams
+		want   ContextType
+	}{
+		{"explicit type wins over a url path", LoadContextParams{ContextType: ContextFileType, FilePath: "https://example.com"}, ContextFileType},
+		{"explicit type wins over a directory path", LoadContextParams{ContextType: ContextFileType, FilePath: "src/"}, ContextFileType},
+		{"url field", LoadContextParams{Url: "https://example.com/docs"}, ContextURLType},
+		{"https path", LoadContextParams{FilePath: "https://example.com/docs"}, ContextURLType},
+		{"uppercase scheme", LoadContextParams{Name: "HTTP://example.com"}, ContextURLType},
+		{"file named like a scheme", LoadContextParams{FilePath: "http.go"}, ContextFileType},
+		{"malformed scheme", LoadContextParams{FilePath: "https:/example.com"}, ContextFileType},
+		{"non-http scheme", LoadContextParams{FilePath: "ftp://example.com/file"}, ContextFileType},
+		{"trailing slash", LoadContextParams{FilePath: "src/api/"}, ContextDirectoryTreeType},
+		{"trailing backslash", LoadContextParams{FilePath: `src\api\`}, ContextDirectoryTreeType},
+		{"cwd", LoadContextParams{FilePath: "."}, ContextDirectoryTreeType},
+		{"parent", LoadContextParams{FilePath: "../.."}, ContextDirectoryTreeType},
+		{"dotfile", LoadContextParams{FilePath: ".env"}, ContextFileType},
+		{"directory without trailing slash", LoadContextParams{FilePath: "src/api"}, ContextFileType},
+		{"file path wins over name", LoadContextParams{Name: "https://example.com", FilePath: "main.go"}, ContextFileType},
+		{"name only", LoadContextParams{Name: "main.go"}, ContextFileType},
+		{"body only", LoadContextParams{Body: "remember to update the docs"}, ContextNoteType},
+		{"nothing", LoadContextParams{}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := InferContextType(&tt.params); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -52,6 +52,7 @@ type Plan struct {
 	TotalReplies    int        `json:"totalReplies"`
 	ActiveBranches  int        `json:"activeBranches"`
 	ArchivedAt      *time.Time `json:"archivedAt,omitempty"`
+	DefaultBranch   string     `json:"defaultBranch,omitempty"`
 	CreatedAt       time.Time  `json:"createdAt"`
 	UpdatedAt       time.Time  `json:"updatedAt"`
 }
@@ -63,8 +64,9 @@ type Branch struct {
 	ParentBranchId  *string    `json:"parentBranchId"`
 	Name            string     `json:"name"`
 	Status          PlanStatus `json:"status"`
-	ContextTokens   int        `json:"contextTokens"`
-	ConvoTokens     int        `json:"convoTokens"`
+	ContextTokens   int64      `json:"contextTokens"`
+	ConvoTokens     int64      `json:"convoTokens"`
+	MaxTokens       *int64     `json:"maxTokens,omitempty"` // the branch's own context token limit, if it has one
 	SharedWithOrgAt *time.Time `json:"sharedWithOrgAt,omitempty"`
 	ArchivedAt      *time.Time `json:"archivedAt,omitempty"`
 	CreatedAt       time.Time  `json:"createdAt"`
@@ -79,28 +81,59 @@ const (
 	ContextNoteType          ContextType = "note"
 	ContextDirectoryTreeType ContextType = "directory tree"
 	ContextPipedDataType     ContextType = "piped data"
+	// a map of the files under FilePath and what they define, in place of their full contents
+	ContextMapType ContextType = "map"
+)
+
+// UrlSource records how a url context's body was fetched. It's empty when the page itself was fetched.
+type UrlSource string
+
+const (
+	UrlSourceGithubApi UrlSource = "github api"
+	UrlSourceGitlabApi UrlSource = "gitlab api"
 )
 
 type Context struct {
-	Id              string      `json:"id"`
-	OwnerId         string      `json:"ownerId"`
-	ContextType     ContextType `json:"contextType"`
-	Name            string      `json:"name"`
-	Url             string      `json:"url"`
-	FilePath        string      `json:"file_path"`
-	Sha             string      `json:"sha"`
-	NumTokens       int         `json:"numTokens"`
-	Body            string      `json:"body,omitempty"`
-	ForceSkipIgnore bool        `json:"forceSkipIgnore"`
-	CreatedAt       time.Time   `json:"createdAt"`
-	UpdatedAt       time.Time   `json:"updatedAt"`
+	Id string `json:"id"`
+	// short, plan-scoped sequential alias, referred to as '#<alias>'. 0 for contexts that haven't been given one yet.
+	Alias                 int                   `json:"alias,omitempty"`
+	OwnerId               string                `json:"ownerId"`
+	ContextType           ContextType           `json:"contextType"`
+	Name                  string                `json:"name"`
+	Url                   string                `json:"url"`
+	FilePath              string                `json:"file_path"`
+	Sha                   string                `json:"sha"`
+	NumTokens             int64                 `json:"numTokens"`
+	TokenizerVersion      int                   `json:"tokenizerVersion,omitempty"` // the TokenizerVersion NumTokens was counted with
+	Body                  string                `json:"body,omitempty"`
+	ForceSkipIgnore       bool                  `json:"forceSkipIgnore"`
+	FileMode              uint32                `json:"fileMode,omitempty"`
+	Tags                  []string              `json:"tags,omitempty"`
+	Pinned                bool                  `json:"pinned,omitempty"` // kept when contexts are pruned
+	Source                string                `json:"source,omitempty"` // the tool that loaded the context
+	StripComments         bool                  `json:"stripComments,omitempty"`
+	PreserveDocstrings    bool                  `json:"preserveDocstrings,omitempty"`
+	ResolveRelativeUrls   bool                  `json:"resolveRelativeUrls,omitempty"`
+	BaseUrl               string                `json:"baseUrl,omitempty"`
+	ContentType           string                `json:"contentType,omitempty"` // the Content-Type a url context was fetched with
+	UrlSource             UrlSource             `json:"urlSource,omitempty"`
+	Encoding              string                `json:"encoding,omitempty"`
+	LineEndingsNormalized bool                  `json:"lineEndingsNormalized,omitempty"`
+	Deminified            bool                  `json:"deminified,omitempty"`
+	BomStripped           bool                  `json:"bomStripped,omitempty"`
+	TrailingNewline       TrailingNewlinePolicy `json:"trailingNewline,omitempty"`
+	Oversized             bool                  `json:"oversized,omitempty"` // derived from NumTokens by the server, not stored
+	CreatedBy             string                `json:"createdBy,omitempty"` // id of the user who loaded the context
+	UpdatedBy             string                `json:"updatedBy,omitempty"` // id of the user who last updated its body
+	CreatedAt             time.Time             `json:"createdAt"`
+	UpdatedAt             time.Time             `json:"updatedAt"`
 }
 
 type ConvoMessage struct {
 	Id        string    `json:"id"`
 	UserId    string    `json:"userId"`
 	Role      string    `json:"role"`
-	Tokens    int       `json:"tokens"`
+	Tokens    int64     `json:"tokens"`
 	Num       int       `json:"num"`
 	Message   string    `json:"message"`
 	Stopped   bool      `json:"stopped"`
@@ -112,7 +145,7 @@ type ConvoSummary struct {
 	LatestConvoMessageCreatedAt time.Time `json:"latestConvoMessageCreatedAt"`
 	LatestConvoMessageId        string    `json:"lastestConvoMessageId"`
 	Summary                     string    `json:"summary"`
-	Tokens                      int       `json:"tokens"`
+	Tokens                      int64     `json:"tokens"`
 	NumMessages                 int       `json:"numMessages"`
 	CreatedAt                   time.Time `json:"createdAt"`
 }
@@ -196,12 +229,12 @@ type BaseModelConfig struct {
 	Provider  ModelProvider `json:"provider"`
 	BaseUrl   string        `json:"baseUrl"`
 	ModelName string        `json:"modelName"`
-	MaxTokens int           `json:"maxTokens"`
+	MaxTokens int64         `json:"maxTokens"`
 }
 
 type PlannerModelConfig struct {
-	MaxConvoTokens       int `json:"maxConvoTokens"`
-	ReservedOutputTokens int `json:"maxOutputTokens"`
+	MaxConvoTokens       int64 `json:"maxConvoTokens"`
+	ReservedOutputTokens int64 `json:"maxOutputTokens"`
 }
 
 type TaskModelConfig struct {
@@ -235,13 +268,18 @@ type ModelSet struct {
 }
 
 type ModelOverrides struct {
-	MaxConvoTokens       *int `json:"maxConvoTokens"`
-	MaxTokens            *int `json:"maxContextTokens"`
-	ReservedOutputTokens *int `json:"maxOutputTokens"`
+	MaxConvoTokens       *int64 `json:"maxConvoTokens"`
+	MaxTokens            *int64 `json:"maxContextTokens"`
+	ReservedOutputTokens *int64 `json:"maxOutputTokens"`
 }
 
 type PlanSettings struct {
 	ModelOverrides ModelOverrides `json:"modelOverrides"`
 	ModelSet       *ModelSet      `json:"modelSet"`
-	UpdatedAt      time.Time      `json:"updatedAt"`
+	// convert CRLF line endings to LF in context bodies when they're loaded
+	NormalizeLineEndings bool `json:"normalizeLineEndings,omitempty"`
+	// for file contexts loaded into the plan: strip a leading byte order mark, and ensure a single trailing newline or none
+	StripBom        bool                  `json:"stripBom,omitempty"`
+	TrailingNewline TrailingNewlinePolicy `json:"trailingNewline,omitempty"`
+	UpdatedAt       time.Time             `json:"updatedAt"`
 }
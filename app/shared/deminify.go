@@ -0,0 +1,232 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+var deminifyExts = map[string]bool{".js": true, ".mjs": true, ".cjs": true, ".css": true, ".json": true}
+
+// IsMinified guesses whether a body is minified: most of its content is on very long lines and it has little whitespace
+func IsMinified(body string) bool {
+	if len(body) < 500 {
+		return false
+	}
+
+	longLineChars := 0
+	for _, line := range strings.Split(body, "\n") {
+		if len(line) > 300 {
+			longLineChars += len(line)
+		}
+	}
+	if longLineChars*2 < len(body) {
+		return false
+	}
+
+	whitespace := 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case ' ', '\t', '\n', '\r':
+			whitespace++
+		}
+	}
+
+	return float64(whitespace)/float64(len(body)) < 0.15
+}
+
+// CanDeminify reports whether Deminify supports a file's type
+func CanDeminify(path string) bool {
+	return deminifyExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// Deminify reformats minified js, css, or json so it's readable. It only changes whitespace, breaking lines after braces and statements and indenting blocks.
+// It returns false if the file type isn't supported or the body couldn't be reformatted.
+func Deminify(path, body string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if !deminifyExts[ext] {
+		return body, false
+	}
+
+	if ext == ".json" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(body), "", "  "); err != nil {
+			return body, false
+		}
+		return buf.String(), true
+	}
+
+	return deminifyBraces(body, ext != ".css"), true
+}
+
+func deminifyBraces(body string, isJs bool) string {
+	out := make([]byte, 0, len(body)+len(body)/4)
+	indent := 0
+	parenDepth := 0
+	lineEmpty := true
+	lastSignificant := byte(0)
+
+	newline := func() {
+		if lineEmpty {
+			return
+		}
+		out = append(out, '\n')
+		out = append(out, strings.Repeat("  ", indent)...)
+		lineEmpty = true
+	}
+
+	write := func(s string) {
+		out = append(out, s...)
+		lineEmpty = false
+	}
+
+	nextSignificant := func(i int) byte {
+		for ; i < len(body); i++ {
+			switch body[i] {
+			case ' ', '\t', '\n', '\r':
+				continue
+			}
+			return body[i]
+		}
+		return 0
+	}
+
+	// copies a quoted string, template literal, or regex literal starting at i, returning the index after it
+	copyLiteral := func(i int, end byte) int {
+		j := i + 1
+		inClass := false
+		for j < len(body) {
+			c := body[j]
+			if c == '\\' {
+				j += 2
+				continue
+			}
+			if end == '/' {
+				if c == '[' {
+					inClass = true
+				} else if c == ']' {
+					inClass = false
+				} else if c == '\n' {
+					break
+				}
+			}
+			if c == end && !inClass {
+				j++
+				break
+			}
+			j++
+		}
+		if j > len(body) {
+			j = len(body)
+		}
+		write(body[i:j])
+		return j
+	}
+
+	for i := 0; i < len(body); {
+		c := body[i]
+
+		switch {
+		case c == '"' || c == '\'' || (isJs && c == '`'):
+			i = copyLiteral(i, c)
+			lastSignificant = c
+			continue
+
+		case c == '/' && i+1 < len(body) && body[i+1] == '*':
+			j := len(body)
+			if end := strings.Index(body[i+2:], "*/"); end >= 0 {
+				j = i + 2 + end + 2
+			}
+			write(body[i:j])
+			i = j
+			continue
+
+		case isJs && c == '/' && i+1 < len(body) && body[i+1] == '/':
+			j := len(body)
+			if end := strings.IndexByte(body[i:], '\n'); end >= 0 {
+				j = i + end
+			}
+			write(body[i:j])
+			i = j
+			continue
+
+		case isJs && c == '/' && (lastSignificant == 0 || strings.IndexByte("(,=:[!&|?{};+-*%<>~^", lastSignificant) >= 0):
+			i = copyLiteral(i, '/')
+			lastSignificant = '/'
+			continue
+
+		case c == ' ' || c == '\t' || c == '\r':
+			if !lineEmpty {
+				write(" ")
+			}
+
+		case c == '\n':
+			newline()
+
+		case c == '{':
+			write("{")
+			indent++
+			newline()
+
+		case c == '}':
+			if indent > 0 {
+				indent--
+			}
+			if lineEmpty {
+				// the empty line was indented for the block being closed
+				out = out[:bytes.LastIndexByte(out, '\n')+1]
+				out = append(out, strings.Repeat("  ", indent)...)
+			} else {
+				out = bytes.TrimRight(out, " ")
+				newline()
+			}
+			write("}")
+			if next := nextSignificant(i + 1); next != ';' && next != ',' && next != ')' && next != '(' && !continuesBlock(body[i+1:]) {
+				newline()
+			}
+
+		case c == '(':
+			parenDepth++
+			write("(")
+
+		case c == ')':
+			if parenDepth > 0 {
+				parenDepth--
+			}
+			write(")")
+
+		case c == ';':
+			write(";")
+			if parenDepth == 0 {
+				newline()
+			}
+
+		default:
+			out = append(out, c)
+			lineEmpty = false
+		}
+
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			lastSignificant = c
+		}
+		i++
+	}
+
+	return strings.TrimRight(string(out), " \n") + "\n"
+}
+
+// continuesBlock reports whether the code after a closing brace continues the same statement, e.g. `} else {`
+func continuesBlock(rest string) bool {
+	rest = strings.TrimLeft(rest, " \t\r\n")
+	for _, keyword := range []string{"else", "catch", "finally", "while"} {
+		if strings.HasPrefix(rest, keyword) && (len(rest) == len(keyword) || !isIdentByte(rest[len(keyword)])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,33 @@
+package shared
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// BuildDirectoryTree renders the body of a directory tree context from the paths in it: one path per line, with forward
+// slashes, sorted and without duplicates. Every client and the server build trees with it, so the same paths always
+// produce the same body and sha.
+func BuildDirectoryTree(paths []string) string {
+	seen := make(map[string]bool, len(paths))
+	var lines []string
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		p = path.Clean(strings.ReplaceAll(p, `\`, "/"))
+		if p == "." || seen[p] {
+			continue
+		}
+
+		seen[p] = true
+		lines = append(lines, p)
+	}
+
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,20 @@
+package shared
+
+import "testing"
+
+func TestBuildDirectoryTree(t *testing.T) {
+	got := BuildDirectoryTree([]string{"src/main.go", `src\util\util.go`, "README.md", "./src/main.go", "", " docs/ ", "."})
+	want := "README.md\ndocs\nsrc/main.go\nsrc/util/util.go"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// order of the input doesn't change the body
+	if BuildDirectoryTree([]string{"b", "a"}) != BuildDirectoryTree([]string{"a", "b"}) {
+		t.Errorf("expected the same body for the same paths in a different order")
+	}
+
+	if got := BuildDirectoryTree(nil); got != "" {
+		t.Errorf("got %q for no paths, want an empty body", got)
+	}
+}
@@ -0,0 +1,228 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// SupportedEncodings lists common encodings for error messages. Any encoding with a WHATWG or IANA name is supported.
+var SupportedEncodings = []string{"utf-8", "utf-16", "utf-16le", "utf-16be", "iso-8859-1", "windows-1252", "us-ascii", "shift_jis", "euc-jp", "gbk"}
+
+var encodingAliases = map[string]string{
+	"utf8":        "utf-8",
+	"utf16":       "utf-16",
+	"utf16le":     "utf-16le",
+	"utf16be":     "utf-16be",
+	"iso88591":    "iso-8859-1",
+	"latin1":      "iso-8859-1",
+	"l1":          "iso-8859-1",
+	"windows1252": "windows-1252",
+	"cp1252":      "windows-1252",
+	"ascii":       "us-ascii",
+	"usascii":     "us-ascii",
+}
+
+// windows-1252 differs from latin-1 only in 0x80-0x9f. 0 marks bytes with no mapping.
+var windows1252High = [32]rune{
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021, 0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014, 0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+}
+
+// NormalizeEncoding returns the canonical name for a supported encoding, or false if it isn't supported.
+// Encodings decoded here are checked first, then WHATWG labels, then IANA names.
+func NormalizeEncoding(name string) (string, bool) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if canonical, ok := encodingAliases[strings.NewReplacer("-", "", "_", "", " ", "").Replace(key)]; ok {
+		return canonical, true
+	}
+
+	if enc, err := htmlindex.Get(key); err == nil {
+		if canonical, err := htmlindex.Name(enc); err == nil {
+			return canonical, true
+		}
+	}
+
+	// ianaindex knows some names it has no decoder for, which come back as a nil encoding
+	if enc, err := ianaindex.IANA.Encoding(key); err == nil && enc != nil {
+		if canonical, err := ianaindex.IANA.Name(enc); err == nil {
+			return strings.ToLower(canonical), true
+		}
+	}
+
+	return "", false
+}
+
+// lookupEncoding returns the x/text encoding for a canonical name from NormalizeEncoding
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	if enc, err := htmlindex.Get(name); err == nil {
+		return enc, nil
+	}
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err == nil && enc == nil {
+		err = fmt.Errorf("no decoder for encoding '%s'", name)
+	}
+	return enc, err
+}
+
+// DecodeToUtf8 decodes raw bytes in the given encoding to a utf-8 string.
+// It returns an error if the encoding isn't supported or the bytes aren't valid in that encoding, rather than substituting replacement characters.
+func DecodeToUtf8(raw []byte, encoding string) (string, error) {
+	name, ok := NormalizeEncoding(encoding)
+	if !ok {
+		return "", fmt.Errorf("unsupported encoding '%s'. Supported encodings include %s", encoding, strings.Join(SupportedEncodings, ", "))
+	}
+
+	switch name {
+	case "utf-8":
+		raw = trimBom(raw, []byte{0xEF, 0xBB, 0xBF})
+		if !utf8.Valid(raw) {
+			return "", fmt.Errorf("content is not valid utf-8")
+		}
+		return string(raw), nil
+
+	case "utf-16", "utf-16le", "utf-16be":
+		bigEndian := name == "utf-16be"
+		if name == "utf-16" {
+			// a byte order mark decides, and big endian is the default without one
+			bigEndian = !(len(raw) >= 2 && raw[0] == 0xFF && raw[1] == 0xFE)
+		}
+		if bigEndian {
+			raw = trimBom(raw, []byte{0xFE, 0xFF})
+		} else {
+			raw = trimBom(raw, []byte{0xFF, 0xFE})
+		}
+		return decodeUtf16(raw, bigEndian)
+
+	case "iso-8859-1":
+		var sb strings.Builder
+		for _, b := range raw {
+			sb.WriteRune(rune(b))
+		}
+		return sb.String(), nil
+
+	case "windows-1252":
+		var sb strings.Builder
+		for i, b := range raw {
+			if b >= 0x80 && b <= 0x9F {
+				r := windows1252High[b-0x80]
+				if r == 0 {
+					return "", fmt.Errorf("byte 0x%X at offset %d is not valid windows-1252", b, i)
+				}
+				sb.WriteRune(r)
+			} else {
+				sb.WriteRune(rune(b))
+			}
+		}
+		return sb.String(), nil
+
+	case "us-ascii":
+		for i, b := range raw {
+			if b > 0x7F {
+				return "", fmt.Errorf("byte 0x%X at offset %d is not valid ascii", b, i)
+			}
+		}
+		return string(raw), nil
+	}
+
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return "", fmt.Errorf("unsupported encoding '%s': %v", encoding, err)
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		return "", fmt.Errorf("content is not valid %s: %v", name, err)
+	}
+
+	// x/text decoders substitute invalid bytes with utf8.RuneError. That's only ambiguous for encodings that can represent it.
+	if strings.ContainsRune(string(decoded), utf8.RuneError) {
+		if _, err := enc.NewEncoder().String(string(utf8.RuneError)); err != nil {
+			return "", fmt.Errorf("content is not valid %s", name)
+		}
+	}
+
+	return string(decoded), nil
+}
+
+// binarySampleBytes is how much of the content LooksBinary checks for non-text bytes
+const binarySampleBytes = 8000
+
+// LooksBinary reports whether content is likely binary rather than text: it has a null byte, or more than maxNonTextPercent
+// of its first 8000 bytes are invalid utf-8 or control characters other than whitespace
+func LooksBinary(content string, maxNonTextPercent int) bool {
+	if strings.IndexByte(content, 0) != -1 {
+		return true
+	}
+
+	sample := content
+	if len(sample) > binarySampleBytes {
+		sample = sample[:binarySampleBytes]
+		// don't count a rune cut off at the end of the sample
+		start := len(sample) - 1
+		for start > 0 && len(sample)-start < utf8.UTFMax && !utf8.RuneStart(sample[start]) {
+			start--
+		}
+		if !utf8.FullRuneInString(sample[start:]) {
+			sample = sample[:start]
+		}
+	}
+	if sample == "" {
+		return false
+	}
+
+	nonText := 0
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRuneInString(sample[i:])
+		if r == utf8.RuneError && size == 1 {
+			nonText++
+		} else if r < 0x20 && r != '\n' && r != '\r' && r != '\t' && r != '\f' && r != '\v' {
+			nonText += size
+		}
+		i += size
+	}
+
+	return nonText*100 > len(sample)*maxNonTextPercent
+}
+
+func trimBom(raw, bom []byte) []byte {
+	if len(raw) >= len(bom) && string(raw[:len(bom)]) == string(bom) {
+		return raw[len(bom):]
+	}
+	return raw
+}
+
+func decodeUtf16(raw []byte, bigEndian bool) (string, error) {
+	if len(raw)%2 != 0 {
+		return "", fmt.Errorf("content is not valid utf-16: odd number of bytes")
+	}
+
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(raw[2*i])<<8 | uint16(raw[2*i+1])
+		} else {
+			units[i] = uint16(raw[2*i+1])<<8 | uint16(raw[2*i])
+		}
+	}
+
+	// utf16.Decode silently substitutes unpaired surrogates, so check for them first
+	for i := 0; i < len(units); i++ {
+		u := units[i]
+		if u >= 0xD800 && u <= 0xDBFF {
+			if i+1 >= len(units) || units[i+1] < 0xDC00 || units[i+1] > 0xDFFF {
+				return "", fmt.Errorf("content is not valid utf-16: unpaired surrogate at offset %d", 2*i)
+			}
+			i++
+		} else if u >= 0xDC00 && u <= 0xDFFF {
+			return "", fmt.Errorf("content is not valid utf-16: unpaired surrogate at offset %d", 2*i)
+		}
+	}
+
+	return string(utf16.Decode(units)), nil
+}
@@ -0,0 +1,71 @@
+package shared
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestDecodeToUtf8RoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		enc      encoding.Encoding
+		text     string
+	}{
+		{"shift_jis", "Shift_JIS", japanese.ShiftJIS, "こんにちは、世界"},
+		{"shift_jis alias", "sjis", japanese.ShiftJIS, "ｶﾀｶﾅ and ascii"},
+		{"euc-jp", "EUC-JP", japanese.EUCJP, "日本語のテキスト"},
+		{"gbk", "gbk", simplifiedchinese.GBK, "你好，世界"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := tt.enc.NewEncoder().Bytes([]byte(tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := DecodeToUtf8(raw, tt.encoding)
+			if err != nil {
+				t.Fatalf("DecodeToUtf8(%q) error: %v", tt.encoding, err)
+			}
+			if got != tt.text {
+				t.Errorf("DecodeToUtf8(%q) = %q, want %q", tt.encoding, got, tt.text)
+			}
+		})
+	}
+}
+
+func TestDecodeToUtf8InvalidShiftJis(t *testing.T) {
+	// 0x81 starts a two-byte sequence that 0x20 can't complete
+	if _, err := DecodeToUtf8([]byte{0x41, 0x81, 0x20}, "shift_jis"); err == nil {
+		t.Error("got no error for invalid shift_jis")
+	}
+}
+
+func TestNormalizeEncoding(t *testing.T) {
+	tests := []struct {
+		encoding string
+		want     string
+		ok       bool
+	}{
+		{"UTF8", "utf-8", true},
+		{"latin1", "iso-8859-1", true},
+		{"cp1252", "windows-1252", true},
+		{"Shift_JIS", "shift_jis", true},
+		{"sjis", "shift_jis", true},
+		{"euc-jp", "euc-jp", true},
+		{"GBK", "gbk", true},
+		{"not-an-encoding", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeEncoding(tt.encoding)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeEncoding(%q) = %q, %v, want %q, %v", tt.encoding, got, ok, tt.want, tt.ok)
+		}
+	}
+}
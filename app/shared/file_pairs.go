@@ -0,0 +1,89 @@
+package shared
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+var jsExts = map[string]bool{".js": true, ".jsx": true, ".mjs": true, ".cjs": true, ".ts": true, ".tsx": true}
+
+// PairedFileCandidates returns the paths of a file's conventional test or source counterparts.
+// Only well-known same-directory patterns are recognized:
+//   - go: foo.go <-> foo_test.go
+//   - js/ts: foo.ts <-> foo.test.ts / foo.spec.ts
+//   - python: foo.py <-> test_foo.py / foo_test.py
+//   - ruby: foo.rb <-> foo_spec.rb / foo_test.rb
+//   - java/kotlin: src/main/.../Foo.java <-> src/test/.../FooTest.java
+//
+// Anything else returns nil. Candidates aren't checked for existence.
+func PairedFileCandidates(path string) []string {
+	var res []string
+	for _, candidate := range pairedFileCandidates(path) {
+		// e.g. '_test.go' has no source counterpart
+		if name := filepath.Base(candidate); name != filepath.Ext(name) {
+			res = append(res, candidate)
+		}
+	}
+	return res
+}
+
+func pairedFileCandidates(path string) []string {
+	dir, file := filepath.Split(path)
+	ext := filepath.Ext(file)
+	stem := strings.TrimSuffix(file, ext)
+
+	join := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	switch {
+	case ext == ".go":
+		if strings.HasSuffix(stem, "_test") {
+			return []string{join(strings.TrimSuffix(stem, "_test") + ext)}
+		}
+		return []string{join(stem + "_test" + ext)}
+
+	case jsExts[ext]:
+		for _, marker := range []string{".test", ".spec"} {
+			if strings.HasSuffix(stem, marker) {
+				return []string{join(strings.TrimSuffix(stem, marker) + ext)}
+			}
+		}
+		return []string{join(stem + ".test" + ext), join(stem + ".spec" + ext)}
+
+	case ext == ".py":
+		if strings.HasPrefix(stem, "test_") {
+			return []string{join(strings.TrimPrefix(stem, "test_") + ext)}
+		}
+		if strings.HasSuffix(stem, "_test") {
+			return []string{join(strings.TrimSuffix(stem, "_test") + ext)}
+		}
+		return []string{join("test_" + stem + ext), join(stem + "_test" + ext)}
+
+	case ext == ".rb":
+		for _, marker := range []string{"_spec", "_test"} {
+			if strings.HasSuffix(stem, marker) {
+				return []string{join(strings.TrimSuffix(stem, marker) + ext)}
+			}
+		}
+		return []string{join(stem + "_spec" + ext), join(stem + "_test" + ext)}
+
+	case ext == ".java" || ext == ".kt":
+		slashed := filepath.ToSlash(path)
+		if strings.Contains(slashed, "/src/test/") || strings.HasPrefix(slashed, "src/test/") {
+			if !strings.HasSuffix(stem, "Test") {
+				return nil
+			}
+			source := strings.Replace(slashed, "src/test/", "src/main/", 1)
+			source = strings.TrimSuffix(source, "Test"+ext) + ext
+			return []string{filepath.FromSlash(source)}
+		}
+		if strings.Contains(slashed, "/src/main/") || strings.HasPrefix(slashed, "src/main/") {
+			test := strings.Replace(slashed, "src/main/", "src/test/", 1)
+			test = strings.TrimSuffix(test, ext) + "Test" + ext
+			return []string{filepath.FromSlash(test)}
+		}
+	}
+
+	return nil
+}
@@ -3,11 +3,17 @@ module shared
 go 1.21.3
 
 require (
+	github.com/PuerkitoBio/goquery v1.8.1
 	github.com/davecgh/go-spew v1.1.1
 	github.com/pkoukk/tiktoken-go v0.1.6
+	golang.org/x/text v0.14.0
 )
 
-require github.com/mattn/go-runewidth v0.0.9 // indirect
+require (
+	github.com/andybalholm/cascadia v1.3.2 // indirect
+	github.com/mattn/go-runewidth v0.0.9 // indirect
+	golang.org/x/net v0.18.0 // indirect
+)
 
 require (
 	github.com/dlclark/regexp2 v1.10.0 // indirect
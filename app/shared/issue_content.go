@@ -0,0 +1,318 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	issueCommentsPerPage = 100
+	// stop paging comments after this many pages so a huge thread can't stall a load
+	maxIssueCommentPages = 10
+)
+
+type IssuePlatform string
+
+const (
+	IssuePlatformGithub IssuePlatform = "github"
+	IssuePlatformGitlab IssuePlatform = "gitlab"
+)
+
+// IssueRef identifies an issue, pull request, or merge request on a known platform
+type IssueRef struct {
+	Platform IssuePlatform
+	// 'owner/repo' on github, the full group/project path on gitlab
+	Project string
+	// true for github pull requests and gitlab merge requests
+	IsPullRequest bool
+	Number        int
+}
+
+// UrlSource is how a url context fetched for the issue is recorded
+func (ref *IssueRef) UrlSource() UrlSource {
+	if ref.Platform == IssuePlatformGitlab {
+		return UrlSourceGitlabApi
+	}
+	return UrlSourceGithubApi
+}
+
+// ParseIssueUrl recognizes github.com issue/pull request urls and gitlab.com issue/merge request urls.
+// Trailing segments like '/files' or fragments are ignored. Other hosts, including self-hosted gitlab, aren't recognized.
+func ParseIssueUrl(rawUrl string) (*IssueRef, bool) {
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch host {
+	case "github.com":
+		// owner/repo/issues/1 or owner/repo/pull/1
+		if len(segments) < 4 || (segments[2] != "issues" && segments[2] != "pull") {
+			return nil, false
+		}
+		number, err := strconv.Atoi(segments[3])
+		if err != nil || number < 1 {
+			return nil, false
+		}
+		return &IssueRef{
+			Platform:      IssuePlatformGithub,
+			Project:       segments[0] + "/" + segments[1],
+			IsPullRequest: segments[2] == "pull",
+			Number:        number,
+		}, true
+
+	case "gitlab.com":
+		// group/subgroup/project/-/issues/1 or .../-/merge_requests/1
+		for i, segment := range segments {
+			if segment != "-" {
+				continue
+			}
+			if i == 0 || len(segments) < i+3 || (segments[i+1] != "issues" && segments[i+1] != "merge_requests") {
+				return nil, false
+			}
+			number, err := strconv.Atoi(segments[i+2])
+			if err != nil || number < 1 {
+				return nil, false
+			}
+			return &IssueRef{
+				Platform:      IssuePlatformGitlab,
+				Project:       strings.Join(segments[:i], "/"),
+				IsPullRequest: segments[i+1] == "merge_requests",
+				Number:        number,
+			}, true
+		}
+	}
+
+	return nil, false
+}
+
+type issueComment struct {
+	author    string
+	createdAt string
+	body      string
+}
+
+type issueContent struct {
+	title    string
+	url      string
+	state    string
+	author   string
+	body     string
+	comments []issueComment
+}
+
+// FetchIssueContent fetches an issue or pull/merge request's description and comments through the platform's api and formats them as markdown.
+// The token is sent as a bearer token, which both platforms accept for personal access tokens, so it's dropped if a request is redirected to another host.
+// If dialer is non-nil it's used for connections, as with FetchURLContent.
+func FetchIssueContent(ref *IssueRef, token string, dialer *net.Dialer) (string, error) {
+	client := newFetchClient(dialer)
+
+	var content *issueContent
+	var err error
+	switch ref.Platform {
+	case IssuePlatformGithub:
+		content, err = fetchGithubIssue(client, ref, token)
+	case IssuePlatformGitlab:
+		content, err = fetchGitlabIssue(client, ref, token)
+	default:
+		return "", fmt.Errorf("unsupported issue platform '%s'", ref.Platform)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return content.format(ref), nil
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+func fetchGithubIssue(client *http.Client, ref *IssueRef, token string) (*issueContent, error) {
+	// the issues endpoints also cover pull requests, including their conversation comments
+	base := "https://api.github.com/repos/" + ref.Project + "/issues/" + strconv.Itoa(ref.Number)
+
+	var issue struct {
+		Title   string     `json:"title"`
+		Body    string     `json:"body"`
+		State   string     `json:"state"`
+		HtmlUrl string     `json:"html_url"`
+		User    githubUser `json:"user"`
+	}
+	err := getIssueJson(client, base, token, &issue)
+	if err != nil {
+		return nil, err
+	}
+
+	content := &issueContent{
+		title:  issue.Title,
+		url:    issue.HtmlUrl,
+		state:  issue.State,
+		author: issue.User.Login,
+		body:   issue.Body,
+	}
+
+	for page := 1; page <= maxIssueCommentPages; page++ {
+		var comments []struct {
+			Body      string     `json:"body"`
+			CreatedAt string     `json:"created_at"`
+			User      githubUser `json:"user"`
+		}
+		err := getIssueJson(client, fmt.Sprintf("%s/comments?per_page=%d&page=%d", base, issueCommentsPerPage, page), token, &comments)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, comment := range comments {
+			content.comments = append(content.comments, issueComment{
+				author:    comment.User.Login,
+				createdAt: comment.CreatedAt,
+				body:      comment.Body,
+			})
+		}
+
+		if len(comments) < issueCommentsPerPage {
+			break
+		}
+	}
+
+	return content, nil
+}
+
+type gitlabUser struct {
+	Username string `json:"username"`
+}
+
+func fetchGitlabIssue(client *http.Client, ref *IssueRef, token string) (*issueContent, error) {
+	kind := "issues"
+	if ref.IsPullRequest {
+		kind = "merge_requests"
+	}
+	base := "https://gitlab.com/api/v4/projects/" + url.PathEscape(ref.Project) + "/" + kind + "/" + strconv.Itoa(ref.Number)
+
+	var issue struct {
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		State       string     `json:"state"`
+		WebUrl      string     `json:"web_url"`
+		Author      gitlabUser `json:"author"`
+	}
+	err := getIssueJson(client, base, token, &issue)
+	if err != nil {
+		return nil, err
+	}
+
+	content := &issueContent{
+		title:  issue.Title,
+		url:    issue.WebUrl,
+		state:  issue.State,
+		author: issue.Author.Username,
+		body:   issue.Description,
+	}
+
+	for page := 1; page <= maxIssueCommentPages; page++ {
+		var notes []struct {
+			Body      string     `json:"body"`
+			CreatedAt string     `json:"created_at"`
+			System    bool       `json:"system"`
+			Author    gitlabUser `json:"author"`
+		}
+		err := getIssueJson(client, fmt.Sprintf("%s/notes?per_page=%d&page=%d&sort=asc&order_by=created_at", base, issueCommentsPerPage, page), token, &notes)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, note := range notes {
+			// system notes record events like label changes rather than discussion
+			if note.System {
+				continue
+			}
+			content.comments = append(content.comments, issueComment{
+				author:    note.Author.Username,
+				createdAt: note.CreatedAt,
+				body:      note.Body,
+			})
+		}
+
+		if len(notes) < issueCommentsPerPage {
+			break
+		}
+	}
+
+	return content, nil
+}
+
+func getIssueJson(client *http.Client, apiUrl, token string, v interface{}) error {
+	req, err := http.NewRequest("GET", apiUrl, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("non-2xx HTTP response status: " + resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxContentSizeInMB*1024*1024))
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling response from %s: %v", apiUrl, err)
+	}
+
+	return nil
+}
+
+func (content *issueContent) format(ref *IssueRef) string {
+	kind := "Issue"
+	if ref.IsPullRequest {
+		if ref.Platform == IssuePlatformGitlab {
+			kind = "Merge request"
+		} else {
+			kind = "Pull request"
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", content.title)
+	fmt.Fprintf(&sb, "%s %s#%d | %s\n", kind, ref.Project, ref.Number, content.url)
+	fmt.Fprintf(&sb, "State: %s | Author: %s\n\n", content.state, content.author)
+
+	if strings.TrimSpace(content.body) != "" {
+		sb.WriteString(strings.TrimSpace(content.body) + "\n\n")
+	}
+
+	if len(content.comments) > 0 {
+		sb.WriteString("## Comments\n\n")
+		for _, comment := range content.comments {
+			fmt.Fprintf(&sb, "### %s | %s\n\n", comment.author, comment.createdAt)
+			sb.WriteString(strings.TrimSpace(comment.body) + "\n\n")
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
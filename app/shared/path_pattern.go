@@ -0,0 +1,102 @@
+package shared
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MatchPathPattern matches a slash-separated path against a glob pattern.
+// In addition to the usual '*', '?' and '[...]' wildcards, '**' matches across directory separators, so 'test/**' matches everything under 'test/'.
+// A class starting with '!' or '^' is negated, and never matches '/'. To match many paths against one pattern, compile it once with CompilePathPattern.
+func MatchPathPattern(pattern, path string) (bool, error) {
+	p, err := CompilePathPattern(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	return p.Match(path), nil
+}
+
+// PathPattern is a glob pattern compiled for MatchPathPattern
+type PathPattern struct {
+	re *regexp.Regexp
+}
+
+func CompilePathPattern(pattern string) (*PathPattern, error) {
+	re, err := compilePathPattern(filepath.ToSlash(pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	return &PathPattern{re: re}, nil
+}
+
+func (p *PathPattern) Match(path string) bool {
+	return p.re.MatchString(strings.TrimPrefix(filepath.ToSlash(path), "./"))
+}
+
+func compilePathPattern(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimPrefix(pattern, "./")
+
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					// '**/' matches zero or more directories
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end == -1 {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			} else {
+				class := pattern[i+1 : i+end]
+				negated := strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^")
+				if negated {
+					class = class[1:]
+				}
+
+				if class == "" {
+					// '[]' or '[!]' can't match anything, so the bracket is taken literally
+					sb.WriteString(regexp.QuoteMeta(string(c)))
+					continue
+				}
+
+				// everything in the class but ranges is literal, so a '\' or '[' isn't parsed as regexp syntax
+				parts := strings.Split(class, "-")
+				for j, part := range parts {
+					parts[j] = regexp.QuoteMeta(part)
+				}
+				escaped := strings.Join(parts, "-")
+
+				if negated {
+					sb.WriteString("[^/" + escaped + "]")
+				} else {
+					sb.WriteString("[" + escaped + "]")
+				}
+				i += end
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	return regexp.Compile(sb.String())
+}
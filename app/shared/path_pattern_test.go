@@ -0,0 +1,65 @@
+package shared
+
+import "testing"
+
+func TestMatchPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"./*.go", "./main.go", true},
+
+		{"**", "src/a/b.go", true},
+		{"src/**", "src/a/b.go", true},
+		{"src/**", "lib/a.go", false},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/a/b/main.go", true},
+		{"src/**/*.go", "src/a/main.ts", false},
+		{"**/test", "test", true},
+		{"**/test", "a/b/test", true},
+		{"**/test", "a/b/test2", false},
+
+		{"?.go", "a.go", true},
+		{"?.go", "ab.go", false},
+		{"a?b", "a/b", false},
+
+		{"[abc].go", "b.go", true},
+		{"[abc].go", "d.go", false},
+		{"[a-c].go", "b.go", true},
+		{"[a-c].go", "-.go", false},
+		{"[-a].go", "-.go", true},
+		{"[.].go", "..go", true},
+		{"[.].go", "x.go", false},
+		{`[\w].go`, "x.go", false},
+		{`[\w].go`, `w.go`, true},
+
+		{"[!a].go", "b.go", true},
+		{"[!a].go", "a.go", false},
+		{"[^a].go", "b.go", true},
+		{"a[!b]c", "a/c", false},
+		{"a[^b]c", "a/c", false},
+
+		{"[].go", "[].go", true},
+		{"[abc", "[abc", true},
+	}
+
+	for _, tt := range tests {
+		got, err := MatchPathPattern(tt.pattern, tt.path)
+		if err != nil {
+			t.Errorf("MatchPathPattern(%q, %q) error: %v", tt.pattern, tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("MatchPathPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPathPatternInvalid(t *testing.T) {
+	if _, err := MatchPathPattern("[z-a].go", "b.go"); err == nil {
+		t.Error("got no error for a reversed range")
+	}
+}
@@ -15,6 +15,13 @@ const (
 	ModelRoleExecStatus  ModelRole = "auto-continue"
 )
 
+type TrailingNewlinePolicy string
+
+const (
+	TrailingNewlineSingle TrailingNewlinePolicy = "single"
+	TrailingNewlineNone   TrailingNewlinePolicy = "none"
+)
+
 var AllModelRoles = []ModelRole{ModelRolePlanner, ModelRolePlanSummary, ModelRoleBuilder, ModelRoleName, ModelRoleCommitMsg, ModelRoleExecStatus}
 var ModelRoleDescriptions = map[ModelRole]string{
 	ModelRolePlanner:     "replies to prompts and makes plans",
@@ -32,7 +39,7 @@ var SettingDescriptions = map[string]string{
 
 var ModelOverridePropsDasherized = []string{"max-convo-tokens", "max-tokens", "reserved-output-tokens"}
 
-func (ps PlanSettings) GetPlannerMaxTokens() int {
+func (ps PlanSettings) GetPlannerMaxTokens() int64 {
 	if ps.ModelOverrides.MaxTokens == nil {
 		if ps.ModelSet == nil {
 			return DefaultModelSet.Planner.BaseModelConfig.MaxTokens
@@ -44,7 +51,7 @@ func (ps PlanSettings) GetPlannerMaxTokens() int {
 	}
 }
 
-func (ps PlanSettings) GetPlannerMaxConvoTokens() int {
+func (ps PlanSettings) GetPlannerMaxConvoTokens() int64 {
 	if ps.ModelOverrides.MaxConvoTokens == nil {
 		if ps.ModelSet == nil {
 			return DefaultModelSet.Planner.PlannerModelConfig.MaxConvoTokens
@@ -56,7 +63,7 @@ func (ps PlanSettings) GetPlannerMaxConvoTokens() int {
 	}
 }
 
-func (ps PlanSettings) GetPlannerReservedOutputTokens() int {
+func (ps PlanSettings) GetPlannerReservedOutputTokens() int64 {
 	if ps.ModelOverrides.ReservedOutputTokens == nil {
 		if ps.ModelSet == nil {
 			return DefaultModelSet.Planner.PlannerModelConfig.ReservedOutputTokens
@@ -68,6 +75,6 @@ func (ps PlanSettings) GetPlannerReservedOutputTokens() int {
 	}
 }
 
-func (ps PlanSettings) GetPlannerEffectiveMaxTokens() int {
+func (ps PlanSettings) GetPlannerEffectiveMaxTokens() int64 {
 	return ps.GetPlannerMaxTokens() - ps.GetPlannerReservedOutputTokens()
 }
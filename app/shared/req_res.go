@@ -56,6 +56,31 @@ type CreateOrgResponse struct {
 	Id string `json:"id"`
 }
 
+type OrgDefaultIgnoreResponse struct {
+	Body string `json:"body"`
+}
+
+type UpdateOrgDefaultIgnoreRequest struct {
+	Body string `json:"body"`
+}
+
+type OrgMaxContextsPerPlanResponse struct {
+	// the cap in effect for the org's plans, 0 if there's none
+	MaxContextsPerPlan int `json:"maxContextsPerPlan"`
+	// whether the cap is the server's, since the org hasn't set its own
+	IsDefault bool `json:"isDefault"`
+}
+
+type UpdateOrgMaxContextsPerPlanRequest struct {
+	// nil goes back to the server's cap
+	MaxContextsPerPlan *int `json:"maxContextsPerPlan"`
+}
+
+type UpdatePlanDefaultBranchRequest struct {
+	// an existing branch of the plan, or empty to clear the default
+	Branch string `json:"branch"`
+}
+
 type InviteRequest struct {
 	Email     string `json:"email"`
 	Name      string `json:"name"`
@@ -139,40 +164,450 @@ type RespondMissingFileRequest struct {
 }
 
 type LoadContextParams struct {
+	// inferred from the path or url with InferContextType if empty
 	ContextType     ContextType `json:"contextType"`
 	Name            string      `json:"name"`
 	Url             string      `json:"url"`
 	FilePath        string      `json:"file_path"`
 	Body            string      `json:"body"`
 	ForceSkipIgnore bool        `json:"forceSkipIgnore"`
+	FileMode        uint32      `json:"fileMode,omitempty"`
+
+	// strip code comments from file contexts before storing
+	StripComments      bool `json:"stripComments,omitempty"`
+	PreserveDocstrings bool `json:"preserveDocstrings,omitempty"`
+
+	// rewrite relative links in markdown/html to absolute urls. BaseUrl defaults to Url for url contexts.
+	ResolveRelativeUrls bool   `json:"resolveRelativeUrls,omitempty"`
+	BaseUrl             string `json:"baseUrl,omitempty"`
+
+	// the Content-Type a url context was fetched with, which decides whether its links are resolved over the url's extension
+	ContentType string `json:"contentType,omitempty"`
+
+	// set when a url context was fetched through an issue tracker's api rather than as a page
+	UrlSource UrlSource `json:"urlSource,omitempty"`
+
+	// for a github or gitlab issue or pull/merge request url, a token the server fetches its description and comments with
+	// through the platform's api, in place of Body. It's only used for the fetch and is never stored or staged.
+	IssueToken string `json:"issueToken,omitempty"`
+
+	// decode RawBody from this encoding to utf-8 instead of using Body as-is
+	Encoding string `json:"encoding,omitempty"`
+	RawBody  []byte `json:"rawBody,omitempty"`
+
+	// how to handle a directory tree that's fully contained in another tree context. Defaults to NestedTreesWarn.
+	NestedTrees NestedTreesMode `json:"nestedTrees,omitempty"`
+
+	// for a directory tree, the paths in it, which the server builds Body from with BuildDirectoryTree
+	TreePaths []string `json:"treePaths,omitempty"`
+
+	// how to handle a file context that looks minified. Minified files are loaded as-is when empty.
+	Minified MinifiedMode `json:"minified,omitempty"`
+
+	// how to handle a context whose body is empty or only whitespace. Defaults to EmptyBodySkip.
+	EmptyBody EmptyBodyMode `json:"emptyBody,omitempty"`
+
+	// skip the context if one with the same type, path or url, and body is already loaded in the plan or earlier in the request
+	Dedupe bool `json:"dedupe,omitempty"`
+
+	// the tool that loaded the context, like "cli", a script's name, or an editor plugin. Defaults to the request's
+	// X-Plandex-Source header.
+	Source string `json:"source,omitempty"`
+}
+
+type EmptyBodyMode string
+
+const (
+	EmptyBodySkip  EmptyBodyMode = "skip"
+	EmptyBodyAllow EmptyBodyMode = "allow"
+)
+
+type MinifiedMode string
+
+const (
+	MinifiedReject   MinifiedMode = "reject"
+	MinifiedDeminify MinifiedMode = "deminify"
+)
+
+type InferredContextType struct {
+	Name        string      `json:"name"`
+	ContextType ContextType `json:"contextType"`
+}
+
+type DeminifiedContext struct {
+	Name         string `json:"name"`
+	TokensBefore int64  `json:"tokensBefore"`
+	TokensAfter  int64  `json:"tokensAfter"`
 }
 
+type NestedTreesMode string
+
+const (
+	NestedTreesWarn  NestedTreesMode = "warn"
+	NestedTreesSkip  NestedTreesMode = "skip"
+	NestedTreesAllow NestedTreesMode = "allow"
+)
+
 type LoadContextRequest []*LoadContextParams
 
 type LoadContextResponse struct {
-	TokensAdded       int    `json:"tokensAdded"`
-	TotalTokens       int    `json:"totalTokens"`
+	TokensAdded       int64    `json:"tokensAdded"`
+	TotalTokens       int64    `json:"totalTokens"`
+	MaxTokensExceeded bool     `json:"maxTokensExceeded"`
+	MaxTokens         int64    `json:"maxTokens"`
+	Msg               string   `json:"msg"`
+	Warnings          []string `json:"warnings,omitempty"`
+	TokensSaved       int64    `json:"tokensSaved,omitempty"`
+
+	// the plan's limit, which MaxTokens is tighter than when the branch has its own limit
+	PlanMaxTokens int64 `json:"planMaxTokens,omitempty"`
+
+	// directory trees that weren't loaded because another tree context already contains them
+	SkippedNestedTrees []string `json:"skippedNestedTrees,omitempty"`
+
+	// names of contexts that weren't loaded because their bodies were empty
+	SkippedEmpty []string `json:"skippedEmpty,omitempty"`
+
+	// names of contexts loaded with Dedupe that weren't loaded because an identical context already exists
+	SkippedDuplicates []string `json:"skippedDuplicates,omitempty"`
+
+	Deminified []DeminifiedContext `json:"deminified,omitempty"`
+
+	// types inferred for entries that didn't specify one
+	InferredTypes []InferredContextType `json:"inferredTypes,omitempty"`
+
+	// set when a load would leave the plan with more than MaxContexts contexts. NumContexts is the count with the load applied.
+	ContextCountExceeded bool `json:"contextCountExceeded,omitempty"`
+	NumContexts          int  `json:"numContexts,omitempty"`
+	MaxContexts          int  `json:"maxContexts,omitempty"`
+
+	// per-context errors for updates that weren't applied, unless the update was atomic
+	FailedById map[string]string `json:"failedById,omitempty"`
+
+	// updates that weren't applied because the context's sha didn't match their ExpectedSha, mapped to the stored sha, unless the update was atomic
+	ConflictsById map[string]string `json:"conflictsById,omitempty"`
+
+	// token change for each updated context, including 0 for unchanged ones
+	TokenDiffsById map[string]int64 `json:"tokenDiffsById,omitempty"`
+	// contexts whose body matched what was already stored, so they weren't re-tokenized or committed
+	UnchangedIds []string `json:"unchangedIds,omitempty"`
+
+	// test or source counterparts of loaded files that aren't in context, by shared.PairedFileCandidates. They may not exist,
+	// since only the client has the project's files.
+	PairedFiles []PairedFileSuggestion `json:"pairedFiles,omitempty"`
+}
+
+type PairedFileSuggestion struct {
+	Path string `json:"path"`
+	// the loaded file Path pairs with
+	ForPath string `json:"forPath"`
+}
+
+// LimitExceeded reports whether the change was rejected for exceeding the token limit or the cap on contexts per plan, so nothing was applied
+func (res *LoadContextResponse) LimitExceeded() bool {
+	return res.MaxTokensExceeded || res.ContextCountExceeded
+}
+
+type PreviewUrlContextRequest struct {
+	Url                 string `json:"url"`
+	ResolveRelativeUrls bool   `json:"resolveRelativeUrls,omitempty"`
+	BaseUrl             string `json:"baseUrl,omitempty"`
+}
+
+type PreviewUrlContextResponse struct {
+	Url               string `json:"url"`
+	Body              string `json:"body"`
+	NumTokens         int64  `json:"numTokens"`
+	TotalTokens       int64  `json:"totalTokens"`
+	MaxTokens         int64  `json:"maxTokens"`
 	MaxTokensExceeded bool   `json:"maxTokensExceeded"`
-	MaxTokens         int    `json:"maxTokens"`
-	Msg               string `json:"msg"`
+}
+
+type OrgContextSize struct {
+	PlanId      string      `json:"planId"`
+	PlanName    string      `json:"planName"`
+	ContextId   string      `json:"contextId"`
+	Name        string      `json:"name"`
+	ContextType ContextType `json:"contextType"`
+	FilePath    string      `json:"filePath,omitempty"`
+	Url         string      `json:"url,omitempty"`
+	NumTokens   int64       `json:"numTokens"`
+}
+
+type OrgLargestContextsResponse struct {
+	Contexts []*OrgContextSize `json:"contexts"`
+	// number of contexts across the org, for pagination
+	Total int `json:"total"`
+}
+
+type ContextAllowanceEntry struct {
+	Path string `json:"path"`
+	// an estimate from the client. If 0, it's estimated from Body.
+	Tokens int64  `json:"tokens,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+type ContextAllowanceRequest struct {
+	Entries []ContextAllowanceEntry `json:"entries"`
+}
+
+type ContextAllowanceResult struct {
+	Path        string `json:"path"`
+	Tokens      int64  `json:"tokens"`
+	TotalTokens int64  `json:"totalTokens"`
+	Fits        bool   `json:"fits"`
+}
+
+type ContextAllowanceResponse struct {
+	// the branch's context tokens before any entries are added
+	TotalTokens int64                    `json:"totalTokens"`
+	MaxTokens   int64                    `json:"maxTokens"`
+	Entries     []ContextAllowanceResult `json:"entries"`
+	NumFit      int                      `json:"numFit"`
+	// index of the first entry that would exceed the budget, or -1 if they all fit. Entries after it don't fit either, since loading is in order.
+	ExceededAt int `json:"exceededAt"`
+}
+
+// ContextTokensRequest is a set of contexts to count tokens for, given as they'd be loaded
+type ContextTokensRequest = LoadContextRequest
+
+type ContextTokensEntry struct {
+	Name        string      `json:"name"`
+	ContextType ContextType `json:"contextType"`
+	NumTokens   int64       `json:"numTokens"`
+}
+
+type ContextTokensResponse struct {
+	// in request order
+	Entries     []ContextTokensEntry `json:"entries"`
+	TokensAdded int64                `json:"tokensAdded"`
+	// the branch's context tokens with the entries added
+	TotalTokens       int64 `json:"totalTokens"`
+	MaxTokens         int64 `json:"maxTokens"`
+	PlanMaxTokens     int64 `json:"planMaxTokens,omitempty"`
+	MaxTokensExceeded bool  `json:"maxTokensExceeded"`
+}
+
+type ContextExistsRequest struct {
+	Ids []string `json:"ids"`
+}
+
+// ContextExistsResponse maps each requested id to whether the branch has a context with that id
+type ContextExistsResponse map[string]bool
+
+type RecomputeContextTokensResponse struct {
+	// the branch's context token count before and after it was recomputed from its contexts, which are equal if there was no drift
+	OldTokens int64 `json:"oldTokens"`
+	NewTokens int64 `json:"newTokens"`
 }
 
 type UpdateContextParams struct {
 	Body string `json:"body"`
+
+	// for contexts loaded with an encoding, decoded with that encoding in place of Body
+	RawBody []byte `json:"rawBody,omitempty"`
+
+	// an update that would leave the body empty fails for the context unless this is EmptyBodyAllow
+	EmptyBody EmptyBodyMode `json:"emptyBody,omitempty"`
+
+	// if set, the update is only applied if the stored context's sha still matches, so a change made since it was read isn't clobbered
+	ExpectedSha string `json:"expectedSha,omitempty"`
+
+	// how Body is applied to the stored body. Defaults to UpdateContextReplace.
+	Mode UpdateContextMode `json:"mode,omitempty"`
+
+	// pins or unpins the context, so pruning keeps it or can remove it. An update with Pinned and no Body or RawBody
+	// only changes the pin, leaving the body as it is.
+	Pinned *bool `json:"pinned,omitempty"`
 }
 
+type UpdateContextMode string
+
+const (
+	UpdateContextReplace UpdateContextMode = "replace"
+	UpdateContextAppend  UpdateContextMode = "append"
+	UpdateContextPrepend UpdateContextMode = "prepend"
+)
+
 type UpdateContextRequest map[string]*UpdateContextParams
 
 type UpdateContextResponse = LoadContextResponse
 
+// RefreshTreeContextRequest maps directory tree context ids to their regenerated bodies
+type RefreshTreeContextRequest struct {
+	Trees map[string]string `json:"trees"`
+	// tree context ids mapped to the paths in them, built into bodies with BuildDirectoryTree. An id can't be in both.
+	Paths map[string][]string `json:"paths,omitempty"`
+}
+
+type RefreshTreeContextResponse struct {
+	UpdateContextResponse
+
+	// trees whose body changed and were stored. Empty if nothing changed or the token limit was exceeded.
+	ChangedIds []string `json:"changedIds"`
+}
+
+type PlanLock struct {
+	// "read" or "write"
+	Scope    string `json:"scope"`
+	Branch   string `json:"branch,omitempty"`
+	UserId   string `json:"userId"`
+	UserName string `json:"userName,omitempty"`
+	// set when the lock is held by a running build
+	PlanBuildId    string  `json:"planBuildId,omitempty"`
+	HeldForSeconds float64 `json:"heldForSeconds"`
+}
+
+// PlanLockStatusResponse lists the locks currently held on a plan, oldest first. Locks whose holder stopped sending heartbeats aren't included.
+type PlanLockStatusResponse struct {
+	Locks []*PlanLock `json:"locks"`
+}
+
+// RefreshUrlContextRequest lists url contexts for the server to fetch again
+type RefreshUrlContextRequest struct {
+	Ids []string `json:"ids"`
+
+	// tokens for contexts fetched through an issue tracker's api, by platform. They're only used for the fetch and never stored.
+	// Those contexts fail to refresh without a token for their platform.
+	IssueTokens map[IssuePlatform]string `json:"issueTokens,omitempty"`
+}
+
+type RefreshUrlContextResponse struct {
+	UpdateContextResponse
+
+	// urls whose body changed and were stored. Empty if nothing changed or the token limit was exceeded.
+	ChangedIds []string `json:"changedIds"`
+
+	// urls that couldn't be fetched, by id. They're left as they were without failing the rest.
+	FetchErrorsById map[string]string `json:"fetchErrorsById,omitempty"`
+}
+
+// ContextStoredEvent is sent as a 'context' event for each stored context when an update is requested with 'Accept: text/event-stream'.
+// The stream ends with a 'done' event carrying the UpdateContextResponse, or an 'error' event carrying an ApiError.
+type ContextStoredEvent struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	NumTokens int64  `json:"numTokens"`
+	Sha       string `json:"sha"`
+}
+
 type DeleteContextRequest struct {
 	Ids map[string]bool `json:"ids"`
+
+	// also remove contexts selected by type and path, as short type names like file, url, tree, and globs like "src/legacy" or "*.md".
+	// A context is selected when it has one of the types, if any are given, and its path matches one of the globs, if any are given.
+	Types     []string `json:"types,omitempty"`
+	PathGlobs []string `json:"pathGlobs,omitempty"`
+	// also narrows the selection to contexts loaded by one of these sources, if any are given
+	Sources []string `json:"sources,omitempty"`
+
+	// return what would be removed, including the commit message, without removing anything or committing
+	DryRun bool `json:"dryRun,omitempty"`
 }
 
 type DeleteContextResponse struct {
-	TokensRemoved int    `json:"tokensRemoved"`
-	TotalTokens   int    `json:"totalTokens"`
+	TokensRemoved int64  `json:"tokensRemoved"`
+	TotalTokens   int64  `json:"totalTokens"`
 	Msg           string `json:"msg"`
+
+	// requested ids and alias refs that didn't match any context
+	NotFoundIds []string `json:"notFoundIds,omitempty"`
+
+	// ids of every context removed, whether requested by id or selected, or that would be removed on a dry run
+	DeletedIds []string `json:"deletedIds"`
+}
+
+type PruneContextRequest struct {
+	// unpinned contexts are removed, least recently updated first, until the branch's total is at or under this
+	TargetTokens int64 `json:"targetTokens"`
+
+	// return what would be pruned, including the commit message, without removing anything or committing
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+type PruneContextResponse struct {
+	// ids of the contexts removed, or that would be removed on a dry run, in the order they were pruned
+	PrunedIds []string `json:"prunedIds"`
+	// ids of every context left, pinned or not
+	KeptIds       []string `json:"keptIds"`
+	TokensRemoved int64    `json:"tokensRemoved"`
+	TotalTokens   int64    `json:"totalTokens"`
+	TargetTokens  int64    `json:"targetTokens"`
+	Msg           string   `json:"msg"`
+}
+
+// ContextExportManifest is the manifest.json at the root of an archive from the context export endpoint
+type ContextExportManifest struct {
+	PlanId      string                `json:"planId"`
+	Branch      string                `json:"branch"`
+	ExportedAt  time.Time             `json:"exportedAt"`
+	TotalTokens int64                 `json:"totalTokens"`
+	Contexts    []*ContextExportEntry `json:"contexts"`
+}
+
+type ContextExportEntry struct {
+	// where the context's body is in the archive
+	Path string `json:"path"`
+	// the context's metadata, without its body
+	Context *Context `json:"context"`
+}
+
+// ListContextChangesResponse is returned by ListContextHandler when modifiedSince is set.
+// SyncedAt can be passed as the next modifiedSince.
+type ListContextChangesResponse struct {
+	Contexts   []*Context `json:"contexts"`
+	DeletedIds []string   `json:"deletedIds"`
+	SyncedAt   time.Time  `json:"syncedAt"`
+}
+
+// StagedContextChanges accumulates context loads, updates, and deletes for a plan branch and user until they're committed together
+type StagedContextChanges struct {
+	Load      LoadContextRequest   `json:"load"`
+	Update    UpdateContextRequest `json:"update"`
+	Delete    map[string]bool      `json:"delete"`
+	UpdatedAt time.Time            `json:"updatedAt"`
+}
+
+type StageContextResponse struct {
+	Staged *StagedContextChanges `json:"staged"`
+	Msg    string                `json:"msg"`
+}
+
+type CommitStagedContextResponse = LoadContextResponse
+
+type TagContextsRequest struct {
+	Tag         string        `json:"tag"`
+	Remove      bool          `json:"remove"`
+	PathPattern string        `json:"pathPattern"`
+	Types       []ContextType `json:"types"`
+}
+
+type TagContextsResponse struct {
+	Ids []string `json:"ids"`
+	Msg string   `json:"msg"`
+}
+
+// MoveContextRequest maps file context ids to their new paths
+type MoveContextRequest struct {
+	Paths map[string]string `json:"paths"`
+}
+
+type MoveContextResponse struct {
+	// the moved contexts, with their new paths. Contexts already at their new path aren't included.
+	Contexts []*Context `json:"contexts"`
+	Msg      string     `json:"msg"`
+}
+
+// ReplaceContextRequest is the full set of contexts a plan should have, given as they'd be loaded
+type ReplaceContextRequest = LoadContextRequest
+
+type ReplaceContextResponse struct {
+	// TokensAdded is the net change, which is negative if more tokens were removed than added
+	LoadContextResponse
+
+	NumAdded   int `json:"numAdded"`
+	NumUpdated int `json:"numUpdated"`
+	NumRemoved int `json:"numRemoved"`
 }
 
 type RejectFileRequest struct {
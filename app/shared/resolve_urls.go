@@ -0,0 +1,115 @@
+package shared
+
+import (
+	"mime"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownLinkRegex   = regexp.MustCompile(`(!?\[[^\]]*\]\(\s*<?)([^)\s>]+)(>?(?:\s+(?:"[^"]*"|'[^']*'))?\s*\))`)
+	markdownRefDefRegex = regexp.MustCompile(`^(\s{0,3}\[[^\]]+\]:\s*<?)([^\s>]+)(.*)$`)
+	htmlUrlAttrRegex    = regexp.MustCompile(`(?i)(\b(?:href|src)\s*=\s*)("[^"]*"|'[^']*')`)
+	markdownFenceRegex  = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+)
+
+var linkMarkupExts = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".mdx":      true,
+	".html":     true,
+	".htm":      true,
+	".xhtml":    true,
+}
+
+var linkMarkupContentTypes = map[string]bool{
+	"text/markdown":         true,
+	"text/x-markdown":       true,
+	"text/html":             true,
+	"application/xhtml+xml": true,
+}
+
+// HasLinkMarkup reports whether content is markdown or html, the only content whose links ResolveRelativeUrls rewrites.
+// A known Content-Type decides. Otherwise it's decided by the extension of path, which can be a file path or a url.
+func HasLinkMarkup(path, contentType string) bool {
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err == nil {
+			return linkMarkupContentTypes[mediaType]
+		}
+	}
+
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" && u.Host != "" {
+		path = u.Path
+	}
+
+	return linkMarkupExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// IsValidBaseUrl reports whether a url is absolute, so relative urls can be resolved against it
+func IsValidBaseUrl(baseUrl string) bool {
+	u, err := url.Parse(baseUrl)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// ResolveRelativeUrls rewrites relative markdown links, images, and reference definitions, as well as html href/src attributes, to absolute urls based on baseUrl.
+// Absolute urls, same-document anchors, and anything inside fenced code blocks are left alone. If baseUrl isn't valid, or the body isn't markdown or html
+// going by HasLinkMarkup, the body is returned unchanged, so source code with src= or href= in it isn't rewritten.
+func ResolveRelativeUrls(path, contentType, body, baseUrl string) string {
+	if !IsValidBaseUrl(baseUrl) || !HasLinkMarkup(path, contentType) {
+		return body
+	}
+	base, _ := url.Parse(baseUrl)
+
+	resolve := func(ref string) string {
+		if ref == "" || strings.HasPrefix(ref, "#") {
+			return ref
+		}
+		u, err := url.Parse(ref)
+		if err != nil || u.IsAbs() {
+			return ref
+		}
+		return base.ResolveReference(u).String()
+	}
+
+	lines := strings.Split(body, "\n")
+	inFence := false
+	fence := ""
+
+	for i, line := range lines {
+		if m := markdownFenceRegex.FindStringSubmatch(line); m != nil {
+			if !inFence {
+				inFence = true
+				fence = m[1]
+			} else if m[1] == fence {
+				inFence = false
+			}
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		line = markdownLinkRegex.ReplaceAllStringFunc(line, func(match string) string {
+			parts := markdownLinkRegex.FindStringSubmatch(match)
+			return parts[1] + resolve(parts[2]) + parts[3]
+		})
+
+		if parts := markdownRefDefRegex.FindStringSubmatch(line); parts != nil {
+			line = parts[1] + resolve(parts[2]) + parts[3]
+		}
+
+		line = htmlUrlAttrRegex.ReplaceAllStringFunc(line, func(match string) string {
+			parts := htmlUrlAttrRegex.FindStringSubmatch(match)
+			quoted := parts[2]
+			quote := quoted[:1]
+			return parts[1] + quote + resolve(quoted[1:len(quoted)-1]) + quote
+		})
+
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
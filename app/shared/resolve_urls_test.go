@@ -0,0 +1,52 @@
+package shared
+
+import "testing"
+
+func TestResolveRelativeUrls(t *testing.T) {
+	base := "https://example.com/docs/guide/"
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"markdown link", "README.md", "", "see [intro](intro.md)", "see [intro](https://example.com/docs/guide/intro.md)"},
+		{"markdown image", "README.md", "", "![logo](../img/logo.png)", "![logo](https://example.com/docs/img/logo.png)"},
+		{"markdown ref def", "README.md", "", "[intro]: intro.md", "[intro]: https://example.com/docs/guide/intro.md"},
+		{"html attr", "index.html", "", `<img src="a.png">`, `<img src="https://example.com/docs/guide/a.png">`},
+		{"absolute and anchor kept", "README.md", "", "[a](https://x.org/a) [b](#top)", "[a](https://x.org/a) [b](#top)"},
+		{"fenced code kept", "README.md", "", "```\n[a](a.md)\n```", "```\n[a](a.md)\n```"},
+		{"url with markdown extension", "https://example.com/docs/guide/README.md", "", "[a](a.md)", "[a](https://example.com/docs/guide/a.md)"},
+		{"content type decides", "https://example.com/docs/guide/", "text/html; charset=utf-8", `<a href="a">`, `<a href="https://example.com/docs/guide/a">`},
+
+		{"tsx unchanged", "App.tsx", "", `const img = <img src="logo.png" />`, `const img = <img src="logo.png" />`},
+		{"go unchanged", "main.go", "", "s := `<a href=\"x\">` // [a](b.md)", "s := `<a href=\"x\">` // [a](b.md)"},
+		{"plain text content type over extension", "notes.md", "text/plain", "[a](a.md)", "[a](a.md)"},
+		{"extensionless url without content type", "https://example.com/docs/guide/", "", "[a](a.md)", "[a](a.md)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveRelativeUrls(tt.path, tt.contentType, tt.body, base); got != tt.want {
+				t.Errorf("ResolveRelativeUrls(%q, %q) = %q, want %q", tt.path, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoredBodyResolvesOnlyMarkup(t *testing.T) {
+	body := `<a href="a.html">`
+
+	for path, want := range map[string]string{
+		"page.html": `<a href="https://example.com/a.html">`,
+		"page.tsx":  body,
+		"page.go":   body,
+	} {
+		c := &Context{ContextType: ContextFileType, FilePath: path, ResolveRelativeUrls: true, BaseUrl: "https://example.com/"}
+		if got := c.StoredBody(body); got != want {
+			t.Errorf("StoredBody for %s = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -4,7 +4,7 @@ const STREAM_MESSAGE_SEPARATOR = "@@PX@@"
 
 type BuildInfo struct {
 	Path      string `json:"path"`
-	NumTokens int    `json:"numTokens"`
+	NumTokens int64  `json:"numTokens"`
 	Finished  bool   `json:"finished"`
 }
 
@@ -0,0 +1,400 @@
+package shared
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+type commentSyntax struct {
+	line          []string
+	blockStart    string
+	blockEnd      string
+	quotes        string
+	rawBackticks  bool
+	tripleQuoted  bool
+	docLinePrefix []string
+	// a line comment marker only starts a comment at the start of a line or after whitespace, as with '#' in shell, where
+	// '$#' or '${#var}' aren't comments. '#{' never starts one, since it's interpolation or templating.
+	lineNeedsSpace bool
+	// '/' starts a regex literal where an operand is expected, as in javascript
+	regexLiterals bool
+	// quote-delimited char literals like '"', as in rust, where a lone quote is a lifetime
+	charLiterals bool
+}
+
+var cStyleSyntax = commentSyntax{
+	line:          []string{"//"},
+	blockStart:    "/*",
+	blockEnd:      "*/",
+	quotes:        "\"'`",
+	docLinePrefix: []string{"///", "//!"},
+}
+
+var jsSyntax = commentSyntax{
+	line:          []string{"//"},
+	blockStart:    "/*",
+	blockEnd:      "*/",
+	quotes:        "\"'`",
+	docLinePrefix: []string{"///", "//!"},
+	regexLiterals: true,
+}
+
+var hashSyntax = commentSyntax{
+	line:           []string{"#"},
+	quotes:         "\"'",
+	lineNeedsSpace: true,
+}
+
+var commentSyntaxByExt = map[string]commentSyntax{
+	".go":    {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: "\"'`", rawBackticks: true},
+	".rs":    {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: "\"", docLinePrefix: []string{"///", "//!"}, charLiterals: true},
+	".js":    jsSyntax,
+	".jsx":   jsSyntax,
+	".mjs":   jsSyntax,
+	".cjs":   jsSyntax,
+	".ts":    jsSyntax,
+	".tsx":   jsSyntax,
+	".java":  cStyleSyntax,
+	".kt":    cStyleSyntax,
+	".scala": cStyleSyntax,
+	".swift": cStyleSyntax,
+	".c":     cStyleSyntax,
+	".h":     cStyleSyntax,
+	".cc":    cStyleSyntax,
+	".cpp":   cStyleSyntax,
+	".hpp":   cStyleSyntax,
+	".cs":    cStyleSyntax,
+	".php":   cStyleSyntax,
+	".css":   {blockStart: "/*", blockEnd: "*/", quotes: "\"'"},
+	".scss":  cStyleSyntax,
+	".py":    {line: []string{"#"}, quotes: "\"'", tripleQuoted: true},
+	".rb":    hashSyntax,
+	".sh":    hashSyntax,
+	".bash":  hashSyntax,
+	".zsh":   hashSyntax,
+	".yml":   hashSyntax,
+	".yaml":  hashSyntax,
+	".toml":  hashSyntax,
+	".pl":    hashSyntax,
+	".r":     hashSyntax,
+}
+
+// CanStripComments reports whether StripComments knows the comment syntax for a path's extension
+func CanStripComments(path string) bool {
+	_, ok := commentSyntaxByExt[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// StripComments removes line and block comments from source code, choosing the comment syntax from the path's extension.
+// String literals, javascript regex literals, and rust char literals are respected so comment markers inside them are left alone.
+// In shell, ruby, yaml, and toml, '#' only starts a comment at the start of a line or after whitespace.
+// Lines that only held a comment are dropped.
+// If preserveDocstrings is set, doc comments ('/** */', '///', '//!') and python docstrings are kept.
+// Files with an unknown extension are returned unchanged.
+func StripComments(path, body string, preserveDocstrings bool) string {
+	syntax, ok := commentSyntaxByExt[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return body
+	}
+
+	// a shebang line looks like a '#' comment but is meaningful
+	var shebang string
+	if strings.HasPrefix(body, "#!") {
+		idx := strings.IndexByte(body, '\n')
+		if idx == -1 {
+			return body
+		}
+		shebang = body[:idx+1]
+		body = body[idx+1:]
+	}
+
+	var out strings.Builder
+	// track which output lines had a comment removed, so lines left empty by stripping can be dropped
+	strippedLine := false
+
+	lines := []string{}
+	flushLine := func(line string) {
+		if strippedLine && strings.TrimSpace(line) == "" {
+			// comment-only line
+		} else if strippedLine {
+			lines = append(lines, strings.TrimRight(line, " \t"))
+		} else {
+			lines = append(lines, line)
+		}
+		strippedLine = false
+	}
+
+	n := len(body)
+	i := 0
+	for i < n {
+		c := body[i]
+
+		if c == '\n' {
+			flushLine(out.String())
+			out.Reset()
+			i++
+			continue
+		}
+
+		// python triple-quoted strings
+		if syntax.tripleQuoted && i+2 < n && (body[i:i+3] == `"""` || body[i:i+3] == `'''`) {
+			delim := body[i : i+3]
+			end := strings.Index(body[i+3:], delim)
+			var literal string
+			if end == -1 {
+				literal = body[i:]
+			} else {
+				literal = body[i : i+3+end+3]
+			}
+
+			if !preserveDocstrings && strings.TrimSpace(out.String()) == "" && isStandaloneLiteral(body, i+len(literal)) {
+				// docstring statement -- drop it, keeping line count consistent
+				strippedLine = true
+				for j := 0; j < strings.Count(literal, "\n"); j++ {
+					flushLine(out.String())
+					out.Reset()
+					strippedLine = true
+				}
+			} else {
+				for _, part := range strings.SplitAfter(literal, "\n") {
+					if strings.HasSuffix(part, "\n") {
+						out.WriteString(strings.TrimSuffix(part, "\n"))
+						flushLine(out.String())
+						out.Reset()
+					} else {
+						out.WriteString(part)
+					}
+				}
+			}
+			i += len(literal)
+			continue
+		}
+
+		// rust char literals, left as-is so a quote inside one doesn't start a string
+		if syntax.charLiterals && c == '\'' {
+			if end := charLiteralEnd(body, i); end != -1 {
+				out.WriteString(body[i:end])
+				i = end
+				continue
+			}
+		}
+
+		// regex literals, which can hold comment markers like '/*'. A '/' followed by '/' or '*' is always a comment.
+		if syntax.regexLiterals && c == '/' && i+1 < n && body[i+1] != '/' && body[i+1] != '*' && regexAllowedAt(body, i) {
+			if end := regexLiteralEnd(body, i); end != -1 {
+				out.WriteString(body[i:end])
+				i = end
+				continue
+			}
+		}
+
+		// string literals
+		if strings.IndexByte(syntax.quotes, c) != -1 {
+			j := i + 1
+			escapes := !(c == '`' && syntax.rawBackticks)
+			for j < n && body[j] != c {
+				if escapes && body[j] == '\\' {
+					j++
+				} else if body[j] == '\n' && c != '`' {
+					// unterminated literal on this line
+					break
+				}
+				j++
+			}
+			if j < n && body[j] == c {
+				j++
+			}
+			if j > n {
+				j = n
+			}
+			literal := body[i:j]
+			for _, part := range strings.SplitAfter(literal, "\n") {
+				if strings.HasSuffix(part, "\n") {
+					out.WriteString(strings.TrimSuffix(part, "\n"))
+					flushLine(out.String())
+					out.Reset()
+				} else {
+					out.WriteString(part)
+				}
+			}
+			i = j
+			continue
+		}
+
+		// block comments
+		if syntax.blockStart != "" && strings.HasPrefix(body[i:], syntax.blockStart) {
+			end := strings.Index(body[i+len(syntax.blockStart):], syntax.blockEnd)
+			var comment string
+			if end == -1 {
+				comment = body[i:]
+			} else {
+				comment = body[i : i+len(syntax.blockStart)+end+len(syntax.blockEnd)]
+			}
+
+			if preserveDocstrings && strings.HasPrefix(comment, "/**") && comment != "/**/" {
+				for _, part := range strings.SplitAfter(comment, "\n") {
+					if strings.HasSuffix(part, "\n") {
+						out.WriteString(strings.TrimSuffix(part, "\n"))
+						flushLine(out.String())
+						out.Reset()
+					} else {
+						out.WriteString(part)
+					}
+				}
+			} else {
+				strippedLine = true
+				for j := 0; j < strings.Count(comment, "\n"); j++ {
+					flushLine(out.String())
+					out.Reset()
+					strippedLine = true
+				}
+			}
+			i += len(comment)
+			continue
+		}
+
+		// line comments
+		if prefix := matchPrefix(body[i:], syntax.line); prefix != "" && (!syntax.lineNeedsSpace || lineCommentAllowedAt(body, i, prefix)) {
+			end := strings.IndexByte(body[i:], '\n')
+			var comment string
+			if end == -1 {
+				comment = body[i:]
+			} else {
+				comment = body[i : i+end]
+			}
+
+			if preserveDocstrings && matchPrefix(comment, syntax.docLinePrefix) != "" {
+				out.WriteString(comment)
+			} else {
+				strippedLine = true
+			}
+			i += len(comment)
+			continue
+		}
+
+		out.WriteByte(c)
+		i++
+	}
+
+	if out.Len() > 0 || strippedLine {
+		flushLine(out.String())
+		out.Reset()
+	}
+
+	res := strings.Join(lines, "\n")
+	if strings.HasSuffix(body, "\n") && !strings.HasSuffix(res, "\n") && len(lines) > 0 {
+		res += "\n"
+	}
+
+	return shebang + res
+}
+
+func matchPrefix(s string, prefixes []string) string {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// isStandaloneLiteral reports whether only whitespace follows a literal on its last line
+func isStandaloneLiteral(body string, end int) bool {
+	rest := body[end:]
+	if idx := strings.IndexByte(rest, '\n'); idx != -1 {
+		rest = rest[:idx]
+	}
+	return strings.TrimSpace(rest) == ""
+}
+
+// lineCommentAllowedAt reports whether a line comment marker at i is at the start of a line or after whitespace, and isn't '#{'
+func lineCommentAllowedAt(body string, i int, prefix string) bool {
+	if prefix == "#" && strings.HasPrefix(body[i:], "#{") {
+		return false
+	}
+	return i == 0 || body[i-1] == ' ' || body[i-1] == '\t' || body[i-1] == '\n'
+}
+
+// regexStartKeywords are keywords after which a '/' starts a regex literal rather than a division
+var regexStartKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true, "delete": true,
+	"void": true, "throw": true, "case": true, "do": true, "else": true, "yield": true, "await": true,
+}
+
+// regexAllowedAt reports whether a '/' at i is where an operand is expected, so it starts a regex literal rather than a division.
+// That's anywhere but after an identifier, number, or closing bracket, other than a keyword like return.
+func regexAllowedAt(body string, i int) bool {
+	j := i - 1
+	for j >= 0 && (body[j] == ' ' || body[j] == '\t' || body[j] == '\n' || body[j] == '\r') {
+		j--
+	}
+	if j < 0 {
+		return true
+	}
+
+	prev := body[j]
+	if prev == ')' || prev == ']' || prev == '}' || prev == '"' || prev == '\'' || prev == '`' {
+		return false
+	}
+
+	if isIdentByte(prev) {
+		start := j
+		for start > 0 && isIdentByte(body[start-1]) {
+			start--
+		}
+		return regexStartKeywords[body[start:j+1]]
+	}
+
+	return true
+}
+
+// regexLiteralEnd returns the index just past a regex literal starting at i, or -1 if it isn't closed on its line.
+// A '/' inside a character class like [/*] doesn't close it.
+func regexLiteralEnd(body string, i int) int {
+	inClass := false
+	for j := i + 1; j < len(body); j++ {
+		switch body[j] {
+		case '\\':
+			j++
+		case '\n':
+			return -1
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				return j + 1
+			}
+		}
+	}
+	return -1
+}
+
+// charLiteralEnd returns the index just past a char literal like 'a', '"', or '\u{1F600}' starting at i, or -1 if the quote
+// at i doesn't start one, as with a lifetime like 'a
+func charLiteralEnd(body string, i int) int {
+	if i+1 >= len(body) {
+		return -1
+	}
+
+	if body[i+1] == '\\' {
+		// escapes are at most '\u{10FFFF}'
+		for j := i + 3; j < len(body) && j <= i+11; j++ {
+			if body[j] == '\n' {
+				return -1
+			}
+			if body[j] == '\'' {
+				return j + 1
+			}
+		}
+		return -1
+	}
+
+	_, size := utf8.DecodeRuneInString(body[i+1:])
+	if body[i+1] == '\n' || i+1+size >= len(body) || body[i+1+size] != '\'' {
+		return -1
+	}
+	return i + 1 + size + 1
+}
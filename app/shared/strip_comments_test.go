@@ -0,0 +1,78 @@
+package shared
+
+import "testing"
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+		want string
+	}{
+		{"go line comment", "main.go", "x := 1 // one\n// gone\ny := 2\n", "x := 1\ny := 2\n"},
+		{"go block comment", "main.go", "a /* b */ c\n", "a  c\n"},
+		{"go marker in string", "main.go", "s := \"// not a comment\"\n", "s := \"// not a comment\"\n"},
+		{"go raw string", "main.go", "s := `/* kept */`\n", "s := `/* kept */`\n"},
+
+		{"shell comment", "run.sh", "echo hi # greet\n# gone\n", "echo hi\n"},
+		{"shell arg count", "run.sh", "echo $#\n", "echo $#\n"},
+		{"shell length expansion", "run.sh", "echo ${#name} # len\n", "echo ${#name}\n"},
+		{"shell mid-word hash", "run.sh", "echo a#b\n", "echo a#b\n"},
+		{"shell shebang", "run.sh", "#!/bin/sh\n# gone\necho hi\n", "#!/bin/sh\necho hi\n"},
+
+		{"ruby interpolation", "app.rb", "puts \"#{x}\" # print\n", "puts \"#{x}\"\n"},
+		{"ruby interpolation outside string", "app.rb", "x = #{y}\n", "x = #{y}\n"},
+
+		{"yaml comment", "config.yaml", "key: value # note\n", "key: value\n"},
+		{"yaml template", "config.yaml", "key: #{value}\n", "key: #{value}\n"},
+		{"yaml hash in value", "config.yaml", "color: a#fff\n", "color: a#fff\n"},
+
+		{"toml comment", "Cargo.toml", "# header\nname = \"x\" # inline\n", "name = \"x\"\n"},
+		{"toml hash in quoted key", "Cargo.toml", "\"a#b\" = 1\n", "\"a#b\" = 1\n"},
+
+		{"python mid-expression comment", "main.py", "x = 1#note\n", "x = 1\n"},
+
+		{"js regex with block marker", "main.js", "const re = /a\\/*b/; // re\n", "const re = /a\\/*b/;\n"},
+		{"js regex class with block marker", "main.js", "s.replace(/[/*]/g, '') /* x */\n", "s.replace(/[/*]/g, '')\n"},
+		{"js regex after return", "main.ts", "return /\\/*/.test(s)\n", "return /\\/*/.test(s)\n"},
+		{"js division then comment", "main.js", "const x = a / b /* half */\n", "const x = a / b\n"},
+		{"js division after paren", "main.js", "const x = (a) / 2 // half\n", "const x = (a) / 2\n"},
+
+		{"rust char literal quote", "main.rs", "let q = '\"'; // quote\n", "let q = '\"';\n"},
+		{"rust escaped char literal", "main.rs", "let q = '\\''; // q\n", "let q = '\\'';\n"},
+		{"rust unicode char literal", "main.rs", "let c = '\\u{1F600}'; // smile\n", "let c = '\\u{1F600}';\n"},
+		{"rust lifetime", "main.rs", "fn f<'a>(s: &'a str) {} // f\n", "fn f<'a>(s: &'a str) {}\n"},
+		{"rust char then string", "main.rs", "let v = ('\"', \"// x\");\n", "let v = ('\"', \"// x\");\n"},
+
+		{"unknown extension", "notes.txt", "# kept\n", "# kept\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripComments(tt.path, tt.body, false); got != tt.want {
+				t.Errorf("StripComments(%q, %q) = %q, want %q", tt.path, tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripCommentsPreserveDocstrings(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+		want string
+	}{
+		{"rust doc comment", "lib.rs", "/// docs\nfn f() {} // f\n", "/// docs\nfn f() {}\n"},
+		{"js jsdoc", "main.js", "/** docs */\nf() // call\n", "/** docs */\nf()\n"},
+		{"python docstring", "main.py", "def f():\n    \"\"\"docs\"\"\"\n    pass # p\n", "def f():\n    \"\"\"docs\"\"\"\n    pass\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripComments(tt.path, tt.body, true); got != tt.want {
+				t.Errorf("StripComments(%q, %q) = %q, want %q", tt.path, tt.body, got, tt.want)
+			}
+		})
+	}
+}
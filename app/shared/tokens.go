@@ -2,15 +2,55 @@ package shared
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/pkoukk/tiktoken-go"
 )
 
-func GetNumTokens(text string) (int, error) {
+// EstimateTokens approximates the token count of text at ~4 bytes per token, without running the tokenizer.
+// Use it for cheap pre-flight checks, not accounting.
+func EstimateTokens(text string) int64 {
+	return (int64(len(text)) + 3) / 4
+}
+
+// TokenizerVersion identifies the encoding GetNumTokens counts with. It's recorded with each context's token count,
+// so bump it whenever the encoding changes and stored counts will be recomputed rather than compared across encodings.
+const TokenizerVersion = 1
+
+var (
+	tokenizerMu sync.Mutex
+	tokenizer   *tiktoken.Tiktoken
+)
+
+// getTokenizer loads the encoder on first use and reuses it after. Concurrent callers wait on a single load
+// rather than each loading it. Unlike a sync.Once, a failed load, like a failed download of the encoding, is retried by the next caller.
+func getTokenizer() (*tiktoken.Tiktoken, error) {
+	tokenizerMu.Lock()
+	defer tokenizerMu.Unlock()
+
+	if tokenizer != nil {
+		return tokenizer, nil
+	}
+
 	tkm, err := tiktoken.EncodingForModel("gpt-4")
 	if err != nil {
-		err = fmt.Errorf("error getting encoding for model: %v", err)
+		return nil, fmt.Errorf("error getting encoding for model: %v", err)
+	}
+	tokenizer = tkm
+
+	return tokenizer, nil
+}
+
+// WarmTokenizer loads the encoder ahead of the first GetNumTokens call, so that call doesn't pay for it
+func WarmTokenizer() error {
+	_, err := getTokenizer()
+	return err
+}
+
+func GetNumTokens(text string) (int64, error) {
+	tkm, err := getTokenizer()
+	if err != nil {
 		return 0, err
 	}
-	return len(tkm.Encode(text, nil, nil)), nil
+	return int64(len(tkm.Encode(text, nil, nil))), nil
 }
@@ -0,0 +1,44 @@
+package shared
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTokenTotalsPastInt32(t *testing.T) {
+	contexts := []*Context{
+		{NumTokens: math.MaxInt32},
+		{NumTokens: 10},
+	}
+
+	summary := SummaryForRemoveContext(contexts, math.MaxInt32+100)
+	if !strings.Contains(summary, "removed → 2147483657 🪙") {
+		t.Errorf("expected removed tokens past the int32 max, got %q", summary)
+	}
+	if !strings.Contains(summary, "total → 90 🪙") {
+		t.Errorf("expected total of 90, got %q", summary)
+	}
+
+	res := &ReplaceContextResponse{}
+	res.TokensAdded = math.MinInt32 - 1
+	res.TotalTokens = math.MaxInt32 + 1
+	summary = SummaryForReplaceContext(res)
+	if !strings.Contains(summary, "removed → 2147483649 🪙") || !strings.Contains(summary, "total → 2147483648 🪙") {
+		t.Errorf("unexpected replace summary %q", summary)
+	}
+
+	bytes, err := json.Marshal(LoadContextResponse{TotalTokens: math.MaxInt32 + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded LoadContextResponse
+	err = json.Unmarshal(bytes, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TotalTokens != math.MaxInt32+1 {
+		t.Errorf("expected %d after a round trip, got %d", int64(math.MaxInt32+1), decoded.TotalTokens)
+	}
+}
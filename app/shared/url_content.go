@@ -0,0 +1,81 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// Constants for FetchURLContent function
+	maxRedirections    = 10
+	httpTimeout        = 30 * time.Second
+	maxContentSizeInMB = 10
+)
+
+// FetchURLContent fetches a url for a url context, extracting the text from html responses, and returns it with the response's Content-Type.
+// If dialer is non-nil it's used for connections, so callers can restrict which addresses may be reached.
+func FetchURLContent(url string, dialer *net.Dialer) (string, string, error) {
+	resp, err := newFetchClient(dialer).Get(url)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", "", errors.New("non-2xx HTTP response status: " + resp.Status)
+	}
+
+	// Limit the response reader to a maximum amount
+	limitedReader := io.LimitReader(resp.Body, maxContentSizeInMB*1024*1024)
+
+	content, err := io.ReadAll(limitedReader)
+	if err != nil {
+		return "", "", err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if strings.Contains(contentType, "text/html") {
+		body, err := ExtractTextualContent(string(content))
+		return body, contentType, err
+	} else {
+		return string(content), contentType, nil
+	}
+}
+
+func newFetchClient(dialer *net.Dialer) *http.Client {
+	client := &http.Client{
+		Timeout: httpTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirections {
+				return errors.New("stopped after too many redirects")
+			}
+			return nil
+		},
+	}
+
+	if dialer != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+		client.Transport = transport
+	}
+
+	return client
+}
+
+func ExtractTextualContent(htmlContent string) (string, error) {
+	r := strings.NewReader(htmlContent)
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %v", err)
+	}
+
+	return doc.Text(), nil
+}
@@ -0,0 +1,185 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// IPResolver looks up a host's addresses. *net.Resolver implements it.
+type IPResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// UrlGuard keeps fetches made on a user's behalf from reaching loopback, private, or link-local addresses, like a cloud metadata endpoint.
+// Urls are checked before they're fetched, and the dialer checks the address again at connection time, so a dns answer that
+// changes between the two, or a redirect to an internal host, is caught too.
+type UrlGuard struct {
+	// addresses that are reachable even though they aren't public
+	AllowedNets []*net.IPNet
+	// net.DefaultResolver if nil
+	Resolver IPResolver
+}
+
+// ParseAllowedNets parses ips and cidr ranges, like "10.1.2.3" or "10.0.0.0/8", for UrlGuard.AllowedNets
+func ParseAllowedNets(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %s", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr range %s: %v", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ranges that aren't publicly routable but that net.IP's classification methods don't cover
+var deniedNets = mustParseCidrs(
+	"0.0.0.0/8",     // "this network", which some stacks route to the local host
+	"100.64.0.0/10", // carrier-grade nat, often used for internal cloud services
+	"192.0.0.0/24",  // ietf protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"64:ff9b::/96",  // nat64, which embeds an ipv4 address that may be internal
+)
+
+func mustParseCidrs(cidrs ...string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// IsBlocked reports whether ip isn't publicly routable and isn't in AllowedNets.
+// An ipv4-mapped ipv6 address, like ::ffff:127.0.0.1, is checked as the ipv4 address it maps to.
+func (g *UrlGuard) IsBlocked(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	for _, ipNet := range g.AllowedNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+
+	for _, ipNet := range deniedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast()
+}
+
+// CheckUrl resolves a url's host and returns an error if it has no addresses or any of them are blocked
+func (g *UrlGuard) CheckUrl(ctx context.Context, rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("invalid url %s: %v", rawUrl, err)
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("url %s has no host", rawUrl)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if g.IsBlocked(ip) {
+			return fmt.Errorf("address %s is not publicly routable", host)
+		}
+		return nil
+	}
+
+	var resolver IPResolver = net.DefaultResolver
+	if g.Resolver != nil {
+		resolver = g.Resolver
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", host, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no addresses found for %s", host)
+	}
+
+	for _, addr := range addrs {
+		if g.IsBlocked(addr.IP) {
+			return fmt.Errorf("%s resolves to %s, which is not publicly routable", host, addr.IP)
+		}
+	}
+
+	return nil
+}
+
+// Dialer returns a dialer that refuses to connect to blocked addresses. The check runs on the address being dialed,
+// after resolution, so it holds for every connection the client makes.
+func (g *UrlGuard) Dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("invalid address %s", host)
+			}
+
+			if g.IsBlocked(ip) {
+				return fmt.Errorf("address %s is not publicly routable", host)
+			}
+
+			return nil
+		},
+	}
+}
+
+// FetchURLContent checks a url, then fetches it as FetchURLContent does, through the guard's dialer
+func (g *UrlGuard) FetchURLContent(rawUrl string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+
+	err := g.CheckUrl(ctx, rawUrl)
+	if err != nil {
+		return "", "", err
+	}
+
+	return FetchURLContent(rawUrl, g.Dialer())
+}
+
+// FetchIssueContent fetches an issue as FetchIssueContent does, through the guard's dialer
+func (g *UrlGuard) FetchIssueContent(ref *IssueRef, token string) (string, error) {
+	return FetchIssueContent(ref, token, g.Dialer())
+}
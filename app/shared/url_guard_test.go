@@ -0,0 +1,141 @@
+package shared
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+type stubResolver map[string][]string
+
+func (r stubResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	var addrs []net.IPAddr
+	for _, ip := range r[host] {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func TestUrlGuardCheckUrl(t *testing.T) {
+	resolver := stubResolver{
+		"public.example.com":   {"93.184.216.34"},
+		"metadata.example.com": {"169.254.169.254"},
+		"internal.example.com": {"10.0.0.5"},
+		"mixed.example.com":    {"93.184.216.34", "192.168.1.10"},
+		"local.example.com":    {"127.0.0.1"},
+		"local6.example.com":   {"::1"},
+		"mapped.example.com":   {"::ffff:10.0.0.5"},
+		"docs.example.com":     {"10.1.2.3"},
+	}
+
+	allowed, err := ParseAllowedNets([]string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard := &UrlGuard{Resolver: resolver, AllowedNets: allowed}
+
+	tests := []struct {
+		url     string
+		blocked bool
+	}{
+		{"https://public.example.com/docs", false},
+		{"http://metadata.example.com/latest/meta-data", true},
+		{"https://internal.example.com", true},
+		{"https://mixed.example.com", true},
+		{"https://local.example.com", true},
+		{"https://local6.example.com", true},
+		{"https://mapped.example.com", true},
+		{"https://unknown.example.com", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://[::1]:8080", true},
+		{"http://8.8.8.8", false},
+		// allowed by AllowedNets
+		{"https://docs.example.com", false},
+		{"http://10.1.200.1", false},
+	}
+
+	for _, test := range tests {
+		err := guard.CheckUrl(context.Background(), test.url)
+		if test.blocked && err == nil {
+			t.Errorf("%s should be blocked", test.url)
+		}
+		if !test.blocked && err != nil {
+			t.Errorf("%s shouldn't be blocked: %v", test.url, err)
+		}
+	}
+}
+
+func TestUrlGuardDialerRejectsBlockedAddresses(t *testing.T) {
+	guard := &UrlGuard{}
+	control := guard.Dialer().Control
+
+	// the dialer sees the resolved address, so a host that resolved publicly when checked but privately when dialed is still caught
+	for _, address := range []string{"10.0.0.5:443", "169.254.169.254:80", "[::1]:443", "127.0.0.1:80"} {
+		if err := control("tcp", address, nil); err == nil {
+			t.Errorf("dialing %s should be rejected", address)
+		}
+	}
+
+	if err := control("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("dialing a public address shouldn't be rejected: %v", err)
+	}
+}
+
+func TestParseAllowedNets(t *testing.T) {
+	nets, err := ParseAllowedNets([]string{"10.1.2.3", " 192.168.0.0/24 ", "", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 3 {
+		t.Fatalf("got %d nets, want 3", len(nets))
+	}
+	if !nets[0].Contains(net.ParseIP("10.1.2.3")) || nets[0].Contains(net.ParseIP("10.1.2.4")) {
+		t.Errorf("a single ip should only allow itself, got %v", nets[0])
+	}
+
+	if _, err := ParseAllowedNets([]string{"not an ip"}); err == nil {
+		t.Errorf("an invalid entry should be an error")
+	}
+}
+
+func TestUrlGuardIsBlocked(t *testing.T) {
+	guard := &UrlGuard{}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"0.1.2.3", true},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"192.0.0.8", true},
+		{"198.18.0.1", true},
+		{"198.19.255.254", true},
+		{"64:ff9b::7f00:1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:100.64.0.1", true},
+		{"127.0.0.1", true},
+		{"169.254.169.254", true},
+		{"fd00::1", true},
+
+		{"100.128.0.1", false},
+		{"198.20.0.1", false},
+		{"192.0.1.1", false},
+		{"::ffff:93.184.216.34", false},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+	}
+
+	for _, tt := range tests {
+		if got := guard.IsBlocked(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("IsBlocked(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	// an allowed range wins over the deny list, for mapped addresses too
+	guard = &UrlGuard{AllowedNets: mustParseCidrs("100.64.0.0/16")}
+	if guard.IsBlocked(net.ParseIP("::ffff:100.64.0.1")) {
+		t.Errorf("an allowed mapped address should not be blocked")
+	}
+}